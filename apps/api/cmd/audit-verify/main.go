@@ -0,0 +1,186 @@
+// Command audit-verify checks the activity log's hash chain and signed
+// checkpoints without going through the API, so an auditor can run it
+// against a database replica or against exported files.
+//
+//	audit-verify -public-key <base64> -dsn postgres://...
+//	audit-verify -public-key <base64> -entries activities.jsonl -checkpoints checkpoints.json
+//	audit-verify -public-key <base64> -bundle audit.zip
+//
+// Take the public key from GET /audit/public-key once and keep it; a key
+// fetched from the system under audit proves nothing. The entries file holds
+// one activity per line in sequence order, the checkpoints file is the
+// response of GET /audit/checkpoints. A bundle from GET /audit/export or
+// audit-export is checked against its signed manifest, and its chain is
+// verified too when it is a complete JSONL export.
+//
+// It exits 0 when the log is intact, 1 when problems were found and 2 when
+// it could not run.
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/database"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+)
+
+func main() {
+	var (
+		pubB64      = flag.String("public-key", "", "base64 Ed25519 public key the checkpoints are signed with")
+		dsn         = flag.String("dsn", os.Getenv("DATABASE_URL"), "database to read from (default $DATABASE_URL)")
+		entries     = flag.String("entries", "", "JSONL file of activities, instead of -dsn")
+		checkpoints = flag.String("checkpoints", "", "JSON file of checkpoints, with -entries")
+		bundle      = flag.String("bundle", "", "export bundle (zip) to check, instead of -dsn")
+		asJSON      = flag.Bool("json", false, "print the report as JSON")
+	)
+	flag.Parse()
+
+	pub, err := base64.StdEncoding.DecodeString(*pubB64)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		fail("-public-key must be a base64 %d-byte Ed25519 public key", ed25519.PublicKeySize)
+	}
+
+	var report *audit.Report
+	switch {
+	case *bundle != "":
+		report, err = verifyBundle(ed25519.PublicKey(pub), *bundle)
+	case *entries != "":
+		report, err = verifyFiles(ed25519.PublicKey(pub), *entries, *checkpoints)
+	case *dsn != "":
+		report, err = services.VerifyAuditLog(database.Connect(*dsn), repository.NewAuditRepository(), ed25519.PublicKey(pub))
+	default:
+		fail("set -dsn or -entries")
+	}
+	if err != nil {
+		fail("%v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(report)
+	}
+	if !report.OK {
+		os.Exit(1)
+	}
+}
+
+func verifyFiles(pub ed25519.PublicKey, entriesPath, checkpointsPath string) (*audit.Report, error) {
+	var cps []audit.Checkpoint
+	if checkpointsPath != "" {
+		b, err := os.ReadFile(checkpointsPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &cps); err != nil {
+			return nil, fmt.Errorf("%s: %w", checkpointsPath, err)
+		}
+	}
+
+	f, err := os.Open(entriesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return verifyEntries(pub, cps, f, entriesPath)
+}
+
+// verifyBundle checks a bundle against its signed manifest. A bundle that
+// holds the complete log as JSONL is chain-verified as well; for any other
+// the report covers the manifest only.
+func verifyBundle(pub ed25519.PublicKey, path string) (*audit.Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	m, err := audit.VerifyBundle(f, st.Size(), pub)
+	if err != nil {
+		return nil, err
+	}
+	filter, _ := json.Marshal(m.Filter)
+	fmt.Fprintf(os.Stderr, "bundle:      %s export of %d entries (#%d to #%d), created %s\n", m.Format, m.Entries, m.FirstSeq, m.LastSeq, audit.Timestamp(m.CreatedAt))
+	fmt.Fprintf(os.Stderr, "manifest:    signed by key %s, filter %s, %d files intact\n", m.KeyID, filter, len(m.Files))
+
+	if !m.Complete || m.Format != audit.FormatJSONL {
+		return &audit.Report{OK: true, Entries: m.Entries, FirstSeq: m.FirstSeq, LastSeq: m.LastSeq, KeyID: m.KeyID, Problems: []audit.Problem{}}, nil
+	}
+	zr, err := zip.NewReader(f, st.Size())
+	if err != nil {
+		return nil, err
+	}
+	var cps []audit.Checkpoint
+	if rc, err := zr.Open("checkpoints.json"); err == nil {
+		err = json.NewDecoder(rc).Decode(&cps)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("checkpoints.json: %w", err)
+		}
+	}
+	rc, err := zr.Open("activities.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return verifyEntries(pub, cps, rc, "activities.jsonl")
+}
+
+func verifyEntries(pub ed25519.PublicKey, cps []audit.Checkpoint, r io.Reader, name string) (*audit.Report, error) {
+	v := audit.NewVerifier(pub, cps)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e audit.Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		v.Add(e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	report := v.Finish()
+	return &report, nil
+}
+
+func printReport(r *audit.Report) {
+	fmt.Printf("entries:     %d (#%d to #%d)\n", r.Entries, r.FirstSeq, r.LastSeq)
+	fmt.Printf("head hash:   %s\n", r.HeadHash)
+	fmt.Printf("checkpoints: %d, signed by key %s\n", r.Checkpoints, r.KeyID)
+	fmt.Printf("signed through #%d, %d later entries checked by chain only\n", r.VerifiedThrough, r.UnsignedTail)
+	if r.OK {
+		fmt.Println("OK: no gaps or modifications found")
+		return
+	}
+	fmt.Printf("FAILED: %d problems\n", r.ProblemCount)
+	for _, p := range r.Problems {
+		fmt.Printf("  #%d %s: %s\n", p.Seq, p.Kind, p.Detail)
+	}
+	if r.ProblemCount > len(r.Problems) {
+		fmt.Printf("  ... and %d more\n", r.ProblemCount-len(r.Problems))
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "audit-verify: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,295 @@
+// Package anomaly scores secret access against a user's own history. The
+// rules are deliberately simple: each compares one event with counts the
+// caller has already read from the activity log, and returns a Finding with
+// a score from 0 to 100 when the event looks out of place.
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Rules, as stored on alerts.
+const (
+	RuleRevealVolume = "reveal_volume"    // many more reveals than the user's baseline
+	RuleNewIP        = "new_ip"           // a reveal from an address the user has not used
+	RuleNewCountry   = "new_country"      // ... in a country the user has not been seen in
+	RuleUnusualHour  = "unusual_hour"     // access at an hour the user is rarely active
+	RuleEnumeration  = "mass_enumeration" // too many key listings in a short window
+)
+
+// Severities, from the score (see SeverityFor).
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// RevealTypes are the activity types that expose a secret value.
+var RevealTypes = []string{"apikey_revealed", "item_field_revealed", "break_glass_access"}
+
+// IsReveal reports whether an activity of the type exposed a secret.
+func IsReveal(activityType string) bool {
+	for _, t := range RevealTypes {
+		if t == activityType {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessTypes are the activity types that count towards a user's active
+// hours: reveals and sign-ins.
+var AccessTypes = append([]string{"user_signed_in"}, RevealTypes...)
+
+// IsAccess reports whether an activity of the type is in AccessTypes.
+func IsAccess(activityType string) bool {
+	for _, t := range AccessTypes {
+		if t == activityType {
+			return true
+		}
+	}
+	return false
+}
+
+// Finding is one rule's verdict on an event.
+type Finding struct {
+	Rule    string
+	Score   float64
+	Summary string
+	Details map[string]any
+}
+
+// Severity maps the finding's score to a severity.
+func (f Finding) Severity() string { return SeverityFor(f.Score) }
+
+// SeverityFor maps a score from 0 to 100 to a severity.
+func SeverityFor(score float64) string {
+	switch {
+	case score >= 90:
+		return SeverityCritical
+	case score >= 70:
+		return SeverityHigh
+	case score >= 40:
+		return SeverityMedium
+	default:
+		return SeverityLow
+	}
+}
+
+// Combine merges the scores of findings on the same event as independent
+// evidence: 1 - (1-a)(1-b)... on the 0-1 scale. A new country at an unusual
+// hour is more suspicious than either alone, but never exceeds 100.
+func Combine(scores ...float64) float64 {
+	rest := 1.0
+	for _, s := range scores {
+		rest *= 1 - clamp(s, 0, 100)/100
+	}
+	return round((1 - rest) * 100)
+}
+
+// Thresholds tune the rules. Zero values are not meaningful; start from
+// DefaultThresholds.
+type Thresholds struct {
+	// reveal volume: reveals in the trailing Window against the same window
+	// on each of the previous BaselineDays days
+	Window       time.Duration
+	BaselineDays int
+	VolumeMin    int     // never flag fewer reveals than this
+	VolumeZ      float64 // standard deviations above the baseline mean
+	VolumeRatio  float64 // ... and at least this many times the mean
+
+	// new address: only once the user has this many earlier events with an
+	// address in the last HistoryDays days
+	HistoryDays      int
+	HistoryMinEvents int
+
+	// unusual hour: an hour (with its neighbours) that holds at most
+	// HourMaxShare of at least HourMinEvents accesses in the last HistoryDays
+	HourMinEvents int
+	HourMaxShare  float64
+
+	// enumeration: key listings per user within ListWindow
+	ListWindow time.Duration
+	ListMax    int
+
+	// sessions are suspended when an event's combined score reaches this;
+	// 0 turns auto-suspension off
+	SuspendScore float64
+}
+
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Window:           24 * time.Hour,
+		BaselineDays:     14,
+		VolumeMin:        20,
+		VolumeZ:          3,
+		VolumeRatio:      3,
+		HistoryDays:      90,
+		HistoryMinEvents: 10,
+		HourMinEvents:    50,
+		HourMaxShare:     0.02,
+		ListWindow:       5 * time.Minute,
+		ListMax:          60,
+		SuspendScore:     90,
+	}
+}
+
+// Baseline summarises a user's reveal counts over past windows.
+type Baseline struct {
+	Mean    float64
+	Std     float64
+	Samples int
+}
+
+func NewBaseline(counts []int) Baseline {
+	b := Baseline{Samples: len(counts)}
+	if b.Samples == 0 {
+		return b
+	}
+	for _, c := range counts {
+		b.Mean += float64(c)
+	}
+	b.Mean /= float64(b.Samples)
+	for _, c := range counts {
+		d := float64(c) - b.Mean
+		b.Std += d * d
+	}
+	b.Std = math.Sqrt(b.Std / float64(b.Samples))
+	return b
+}
+
+// Volume flags current reveals in the window when they stand out from the
+// baseline both by z-score and by ratio to the mean. The standard deviation
+// and mean are floored at 1, so a user who rarely reveals anything is not
+// flagged for a handful. The score starts at 50 at the z threshold and rises
+// by 10 per further standard deviation. Without any reveals in the baseline
+// (a new or dormant account) there is nothing to compare with, so the score
+// stops at 70.
+func (t Thresholds) Volume(current int, b Baseline) (Finding, bool) {
+	if current < t.VolumeMin {
+		return Finding{}, false
+	}
+	z := (float64(current) - b.Mean) / math.Max(b.Std, 1)
+	if z < t.VolumeZ || float64(current) < t.VolumeRatio*math.Max(b.Mean, 1) {
+		return Finding{}, false
+	}
+	score := clamp(50+10*(z-t.VolumeZ), 50, 100)
+	if b.Mean == 0 {
+		score = math.Min(score, 70)
+	}
+	return Finding{
+		Rule:    RuleRevealVolume,
+		Score:   round(score),
+		Summary: fmt.Sprintf("%d reveals in %s against a baseline of %.1f", current, t.Window, b.Mean),
+		Details: map[string]any{
+			"reveals":       current,
+			"window":        t.Window.String(),
+			"baseline_mean": round(b.Mean),
+			"baseline_std":  round(b.Std),
+			"baseline_days": b.Samples,
+			"z_score":       round(z),
+		},
+	}, true
+}
+
+// NewAddress flags a reveal from ip when the user has enough history and
+// none of it is from ip. seen maps the addresses of earlier events to their
+// counts. With a geo lookup, an address in a country that none of the
+// earlier addresses resolve to scores higher. Private and unknown addresses
+// have no country.
+func (t Thresholds) NewAddress(ip string, seen map[string]int, geo Geo) (Finding, bool) {
+	if ip == "" || seen[ip] > 0 {
+		return Finding{}, false
+	}
+	total := 0
+	for _, n := range seen {
+		total += n
+	}
+	if total < t.HistoryMinEvents {
+		return Finding{}, false
+	}
+
+	f := Finding{
+		Rule:    RuleNewIP,
+		Score:   40,
+		Summary: "Secret revealed from a new address " + ip,
+		Details: map[string]any{"ip": ip, "known_addresses": len(seen)},
+	}
+	if geo == nil {
+		return f, true
+	}
+	country := geo.Country(ip)
+	if country == "" {
+		return f, true
+	}
+	f.Details["country"] = country
+	countries := map[string]bool{}
+	for addr := range seen {
+		if c := geo.Country(addr); c != "" {
+			countries[c] = true
+		}
+	}
+	if len(countries) > 0 && !countries[country] {
+		known := make([]string, 0, len(countries))
+		for c := range countries {
+			known = append(known, c)
+		}
+		f.Rule = RuleNewCountry
+		f.Score = 75
+		f.Summary = fmt.Sprintf("Secret revealed from %s (%s), a country not seen before", ip, country)
+		slices.Sort(known)
+		f.Details["known_countries"] = known
+	}
+	return f, true
+}
+
+// UnusualHour flags access at a UTC hour that, with the hours either side,
+// holds at most HourMaxShare of the user's accesses. hist counts earlier
+// accesses per UTC hour.
+func (t Thresholds) UnusualHour(hist [24]int, hour int) (Finding, bool) {
+	total := 0
+	for _, n := range hist {
+		total += n
+	}
+	if total < t.HourMinEvents || hour < 0 || hour > 23 {
+		return Finding{}, false
+	}
+	near := hist[(hour+23)%24] + hist[hour] + hist[(hour+1)%24]
+	share := float64(near) / float64(total)
+	if share > t.HourMaxShare {
+		return Finding{}, false
+	}
+	return Finding{
+		Rule:    RuleUnusualHour,
+		Score:   30,
+		Summary: fmt.Sprintf("Access at %02d:00 UTC, an hour this user is rarely active", hour),
+		Details: map[string]any{"hour_utc": hour, "share": round(share), "history": total},
+	}, true
+}
+
+// Enumeration flags the listing that brings a user's count in the window to
+// ListMax. Later listings in the same window are not flagged again.
+func (t Thresholds) Enumeration(count int) (Finding, bool) {
+	if count != t.ListMax {
+		return Finding{}, false
+	}
+	return Finding{
+		Rule:    RuleEnumeration,
+		Score:   70,
+		Summary: fmt.Sprintf("%d key listings within %s", count, t.ListWindow),
+		Details: map[string]any{"listings": count, "window": t.ListWindow.String()},
+	}, true
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Min(math.Max(v, lo), hi)
+}
+
+// round keeps two decimals, which is all an alert needs to show.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,203 @@
+package anomaly
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSeverityFor(t *testing.T) {
+	cases := map[float64]string{0: SeverityLow, 39.9: SeverityLow, 40: SeverityMedium, 70: SeverityHigh, 89: SeverityHigh, 90: SeverityCritical, 100: SeverityCritical}
+	for score, want := range cases {
+		if got := SeverityFor(score); got != want {
+			t.Errorf("SeverityFor(%v) = %q, want %q", score, got, want)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	if got := Combine(); got != 0 {
+		t.Errorf("Combine() = %v, want 0", got)
+	}
+	if got := Combine(75); got != 75 {
+		t.Errorf("Combine(75) = %v, want 75", got)
+	}
+	if got := Combine(75, 30); got != 82.5 {
+		t.Errorf("Combine(75, 30) = %v, want 82.5", got)
+	}
+	if got := Combine(100, 40, 150); got != 100 {
+		t.Errorf("Combine(100, 40, 150) = %v, want 100", got)
+	}
+}
+
+func TestNewBaseline(t *testing.T) {
+	b := NewBaseline([]int{2, 4, 4, 4, 5, 5, 7, 9})
+	if b.Mean != 5 || b.Std != 2 || b.Samples != 8 {
+		t.Errorf("NewBaseline = %+v, want mean 5, std 2, 8 samples", b)
+	}
+	if b := NewBaseline(nil); b.Mean != 0 || b.Std != 0 {
+		t.Errorf("NewBaseline(nil) = %+v", b)
+	}
+}
+
+func TestVolume(t *testing.T) {
+	th := DefaultThresholds()
+	steady := NewBaseline([]int{10, 12, 8, 11, 9, 10, 10, 12, 8, 10, 11, 9, 10, 10})
+
+	if _, ok := th.Volume(14, steady); ok {
+		t.Error("flagged fewer reveals than VolumeMin")
+	}
+	if _, ok := th.Volume(25, steady); ok {
+		t.Error("flagged 25 reveals against a mean of 10 (under the ratio)")
+	}
+	f, ok := th.Volume(60, steady)
+	if !ok || f.Rule != RuleRevealVolume {
+		t.Fatalf("Volume(60) = %+v, %v; want a finding", f, ok)
+	}
+	if f.Score != 100 || f.Severity() != SeverityCritical {
+		t.Errorf("score %v (%s), want 100 (critical)", f.Score, f.Severity())
+	}
+
+	// a user who never reveals anything has std and mean floored at 1, and
+	// without history the score stays below critical
+	quiet := NewBaseline(make([]int, 14))
+	f, ok = th.Volume(20, quiet)
+	if !ok || f.Score != 70 {
+		t.Errorf("Volume(20) against zero baseline = %+v, %v; want score 70", f, ok)
+	}
+	f, ok = th.Volume(20, NewBaseline([]int{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}))
+	if !ok || f.Score != 100 {
+		t.Errorf("Volume(20) against a near-zero baseline = %+v, %v; want score 100", f, ok)
+	}
+
+	// a bursty user with a wide spread is given more room
+	bursty := NewBaseline([]int{0, 0, 40, 0, 0, 35, 0, 0, 50, 0, 0, 45, 0, 0})
+	if _, ok := th.Volume(50, bursty); ok {
+		t.Error("flagged a bursty user within their usual spread")
+	}
+}
+
+type fakeGeo map[string]string
+
+func (g fakeGeo) Country(ip string) string { return g[ip] }
+
+func TestNewAddress(t *testing.T) {
+	th := DefaultThresholds()
+	seen := map[string]int{"203.0.113.5": 8, "203.0.113.9": 4}
+
+	if _, ok := th.NewAddress("203.0.113.5", seen, nil); ok {
+		t.Error("flagged a known address")
+	}
+	if _, ok := th.NewAddress("198.51.100.1", map[string]int{"203.0.113.5": 3}, nil); ok {
+		t.Error("flagged without enough history")
+	}
+	if _, ok := th.NewAddress("", seen, nil); ok {
+		t.Error("flagged an event without an address")
+	}
+
+	f, ok := th.NewAddress("198.51.100.1", seen, nil)
+	if !ok || f.Rule != RuleNewIP || f.Score != 40 {
+		t.Errorf("new address = %+v, %v; want new_ip at 40", f, ok)
+	}
+
+	geo := fakeGeo{"203.0.113.5": "DE", "203.0.113.9": "DE", "198.51.100.1": "DE", "198.51.100.2": "BR"}
+	f, ok = th.NewAddress("198.51.100.1", seen, geo)
+	if !ok || f.Rule != RuleNewIP {
+		t.Errorf("new address in a known country = %+v, %v; want new_ip", f, ok)
+	}
+	f, ok = th.NewAddress("198.51.100.2", seen, geo)
+	if !ok || f.Rule != RuleNewCountry || f.Score != 75 {
+		t.Fatalf("new country = %+v, %v; want new_country at 75", f, ok)
+	}
+	if f.Details["country"] != "BR" || !strings.Contains(f.Summary, "BR") {
+		t.Errorf("details %v, summary %q", f.Details, f.Summary)
+	}
+}
+
+func TestUnusualHour(t *testing.T) {
+	th := DefaultThresholds()
+	var hist [24]int
+	for h := 8; h <= 18; h++ {
+		hist[h] = 10
+	}
+	if _, ok := th.UnusualHour(hist, 12); ok {
+		t.Error("flagged a working hour")
+	}
+	if _, ok := th.UnusualHour(hist, 19); ok {
+		t.Error("flagged the hour next to a working hour")
+	}
+	f, ok := th.UnusualHour(hist, 3)
+	if !ok || f.Rule != RuleUnusualHour || f.Details["hour_utc"] != 3 {
+		t.Errorf("UnusualHour(3) = %+v, %v", f, ok)
+	}
+
+	// wraps around midnight
+	var night [24]int
+	night[23], night[0] = 40, 40
+	if _, ok := th.UnusualHour(night, 1); ok {
+		t.Error("flagged the hour after midnight for a night owl")
+	}
+
+	var sparse [24]int
+	sparse[10] = 20
+	if _, ok := th.UnusualHour(sparse, 3); ok {
+		t.Error("flagged without enough history")
+	}
+}
+
+func TestEnumeration(t *testing.T) {
+	th := DefaultThresholds()
+	for _, n := range []int{1, th.ListMax - 1, th.ListMax + 1, th.ListMax * 3} {
+		if _, ok := th.Enumeration(n); ok {
+			t.Errorf("Enumeration(%d) flagged", n)
+		}
+	}
+	if f, ok := th.Enumeration(th.ListMax); !ok || f.Rule != RuleEnumeration {
+		t.Errorf("Enumeration(ListMax) = %+v, %v", f, ok)
+	}
+}
+
+func TestIsReveal(t *testing.T) {
+	if !IsReveal("apikey_revealed") || !IsReveal("break_glass_access") || IsReveal("apikey_created") {
+		t.Error("IsReveal")
+	}
+	if !IsAccess("user_signed_in") || IsAccess("user_signin_failed") {
+		t.Error("IsAccess")
+	}
+}
+
+func TestCountryTable(t *testing.T) {
+	csv := `network,country_iso_code
+# test data
+81.2.69.0/24,GB
+2001:db8::/32,NL
+10.0.0.0/8,
+203.0.113.0/25,"us",extra
+`
+	table, err := LoadCountryTable(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Len() != 3 {
+		t.Errorf("Len = %d, want 3", table.Len())
+	}
+	cases := map[string]string{
+		"81.2.69.142":      "GB",
+		"81.2.70.1":        "",
+		"::ffff:81.2.69.1": "GB",
+		"2001:db8::1":      "NL",
+		"203.0.113.127":    "US",
+		"203.0.113.128":    "",
+		"10.1.2.3":         "",
+		"1.1.1.1":          "",
+		"not-an-ip":        "",
+	}
+	for ip, want := range cases {
+		if got := table.Country(ip); got != want {
+			t.Errorf("Country(%q) = %q, want %q", ip, got, want)
+		}
+	}
+
+	if _, err := LoadCountryTable(strings.NewReader("81.2.69.0/24,GB\nbogus,XX\n")); err == nil {
+		t.Error("accepted a bad network")
+	}
+}
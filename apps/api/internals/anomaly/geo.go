@@ -0,0 +1,91 @@
+package anomaly
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Geo resolves an address to an ISO country code, or "" when unknown.
+type Geo interface {
+	Country(ip string) string
+}
+
+// CountryTable is a Geo backed by a list of networks and their countries.
+type CountryTable struct {
+	nets []countryNet // sorted by first address
+}
+
+type countryNet struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// LoadCountryTable reads a CSV of "network,country" lines, e.g.
+// "81.2.69.0/24,GB". A header line, blank lines and lines starting with #
+// are skipped, as are further columns. The networks must not overlap, as in
+// the country blocks of the common free GeoIP databases.
+func LoadCountryTable(r io.Reader) (*CountryTable, error) {
+	var t CountryTable
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: want network,country", line)
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(fields[0]))
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		country := strings.ToUpper(strings.Trim(strings.TrimSpace(fields[1]), `"`))
+		if country == "" {
+			continue
+		}
+		t.nets = append(t.nets, countryNet{prefix: prefix.Masked(), country: country})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(t.nets, func(i, j int) bool {
+		return t.nets[i].prefix.Addr().Less(t.nets[j].prefix.Addr())
+	})
+	return &t, nil
+}
+
+// OpenCountryTable loads a table from a file.
+func OpenCountryTable(path string) (*CountryTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadCountryTable(f)
+}
+
+// Len is the number of networks in the table.
+func (t *CountryTable) Len() int { return len(t.nets) }
+
+func (t *CountryTable) Country(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	// the last network starting at or before addr is the only candidate
+	i := sort.Search(len(t.nets), func(i int) bool { return addr.Less(t.nets[i].prefix.Addr()) })
+	if i == 0 || !t.nets[i-1].prefix.Contains(addr) {
+		return ""
+	}
+	return t.nets[i-1].country
+}
@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// Names of the bundle's own files.
+const (
+	BundleManifest  = "manifest.json"
+	BundleSignature = "manifest.sig"
+)
+
+var ErrBadBundle = errors.New("bundle does not match its signed manifest")
+
+// Manifest describes an export bundle: what was exported, from which point
+// of the chain, and the SHA-256 of every other file in the bundle. It is
+// signed with the audit key, so the bundle can be checked long after the
+// export with only the public key.
+type Manifest struct {
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	Format    string       `json:"format"`
+	Filter    ExportFilter `json:"filter"`
+	Complete  bool         `json:"complete"` // every entry through HeadSeq, so the chain can be verified
+	Entries   int64        `json:"entries"`
+	FirstSeq  int64        `json:"first_seq"`
+	LastSeq   int64        `json:"last_seq"`
+	HeadSeq   int64        `json:"head_seq"` // chain head when the export started
+	HeadHash  string       `json:"head_hash"`
+	KeyID     string       `json:"key_id"`
+	Files     []BundleFile `json:"files"`
+}
+
+type BundleFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Bundle streams files into a zip archive and closes it with the signed
+// manifest. Files are hashed as they are written, so nothing is buffered.
+type Bundle struct {
+	Manifest Manifest
+
+	zw   *zip.Writer
+	priv ed25519.PrivateKey
+	cur  *bundleFile
+}
+
+func NewBundle(w io.Writer, priv ed25519.PrivateKey, m Manifest) *Bundle {
+	m.Version = 1
+	m.KeyID = KeyID(priv.Public().(ed25519.PublicKey))
+	return &Bundle{Manifest: m, zw: zip.NewWriter(w), priv: priv}
+}
+
+// Create starts the next file; the previous one is finished.
+func (b *Bundle) Create(name string) (io.Writer, error) {
+	if name == BundleManifest || name == BundleSignature {
+		return nil, fmt.Errorf("%s is reserved", name)
+	}
+	b.finish()
+	w, err := b.zw.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	b.cur = &bundleFile{name: name, w: w, h: sha256.New()}
+	return b.cur, nil
+}
+
+// Close writes the manifest and its signature and finishes the archive.
+func (b *Bundle) Close() error {
+	b.finish()
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := b.zw.Create(BundleManifest)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(manifest); err != nil {
+		return err
+	}
+	if w, err = b.zw.Create(BundleSignature); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, hex.EncodeToString(ed25519.Sign(b.priv, manifest))); err != nil {
+		return err
+	}
+	return b.zw.Close()
+}
+
+func (b *Bundle) finish() {
+	if b.cur == nil {
+		return
+	}
+	b.Manifest.Files = append(b.Manifest.Files, BundleFile{
+		Name:   b.cur.name,
+		Size:   b.cur.n,
+		SHA256: hex.EncodeToString(b.cur.h.Sum(nil)),
+	})
+	b.cur = nil
+}
+
+type bundleFile struct {
+	name string
+	w    io.Writer
+	h    hash.Hash
+	n    int64
+}
+
+func (f *bundleFile) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.h.Write(p[:n])
+	f.n += int64(n)
+	return n, err
+}
+
+// VerifyBundle checks a bundle's manifest signature against pub and every
+// file against the manifest, and returns the manifest.
+func VerifyBundle(r io.ReaderAt, size int64, pub ed25519.PublicKey) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		if files[f.Name] != nil {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrBadBundle, f.Name)
+		}
+		files[f.Name] = f
+	}
+
+	manifest, err := readBundleFile(files[BundleManifest], 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", BundleManifest, err)
+	}
+	sigHex, err := readBundleFile(files[BundleSignature], 1024)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", BundleSignature, err)
+	}
+	sig, err := hex.DecodeString(string(sigHex))
+	if err != nil || !ed25519.Verify(pub, manifest, sig) {
+		return nil, fmt.Errorf("%w: manifest is not signed by key %s", ErrBadBundle, KeyID(pub))
+	}
+	var m Manifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", BundleManifest, err)
+	}
+
+	if len(files) != len(m.Files)+2 {
+		return nil, fmt.Errorf("%w: it has %d files, the manifest lists %d", ErrBadBundle, len(files)-2, len(m.Files))
+	}
+	for _, want := range m.Files {
+		f := files[want.Name]
+		if f == nil {
+			return nil, fmt.Errorf("%w: %s is missing", ErrBadBundle, want.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", want.Name, err)
+		}
+		if n != want.Size || hex.EncodeToString(h.Sum(nil)) != want.SHA256 {
+			return nil, fmt.Errorf("%w: %s was modified", ErrBadBundle, want.Name)
+		}
+	}
+	return &m, nil
+}
+
+func readBundleFile(f *zip.File, limit int64) ([]byte, error) {
+	if f == nil {
+		return nil, errors.New("missing")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, errors.New("too large")
+	}
+	return b, nil
+}
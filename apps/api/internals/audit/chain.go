@@ -0,0 +1,136 @@
+// Package audit makes the activity log tamper-evident. Every entry carries
+// the hash of the one before it, so editing, inserting or deleting an entry
+// breaks the chain from that point on, and the chain head is periodically
+// signed so that rewriting the whole tail is caught as well.
+//
+// The package only depends on the standard library so the offline verifier
+// can check an export without the rest of the API.
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Entry is the hashed part of an activity.
+type Entry struct {
+	Seq       int64     `json:"seq"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+	UserID    uint      `json:"user_id"`
+	Type      string    `json:"type"`
+	Entity    string    `json:"entity"`
+	EntityID  uint      `json:"entity_id"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// who acted, from where, and how it went; empty on entries logged
+	// before this was recorded
+	OrganizationID uint   `json:"organization_id,omitempty"`
+	TeamID         uint   `json:"team_id,omitempty"`
+	Principal      string `json:"principal,omitempty"`
+	AuthMethod     string `json:"auth_method,omitempty"`
+	IP             string `json:"ip,omitempty"`
+	UserAgent      string `json:"user_agent,omitempty"`
+	RequestID      string `json:"request_id,omitempty"`
+	Outcome        string `json:"outcome,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	EventID        string `json:"event_id,omitempty"` // outbox event the entry was recorded from
+}
+
+// entryContext is the hashed form of an entry's context. It is left out of
+// the hash when empty, so entries from before it existed keep their hashes.
+type entryContext struct {
+	OrganizationID uint   `json:"org,omitempty"`
+	TeamID         uint   `json:"team,omitempty"`
+	Principal      string `json:"principal,omitempty"`
+	AuthMethod     string `json:"auth,omitempty"`
+	IP             string `json:"ip,omitempty"`
+	UserAgent      string `json:"ua,omitempty"`
+	RequestID      string `json:"req,omitempty"`
+	Outcome        string `json:"outcome,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	EventID        string `json:"event,omitempty"`
+}
+
+// Checkpoint is a signed statement that entry Seq had hash Hash.
+type Checkpoint struct {
+	Seq       int64     `json:"seq"`
+	Hash      string    `json:"hash"`
+	SignedAt  time.Time `json:"signed_at"`
+	KeyID     string    `json:"key_id"`
+	Signature string    `json:"signature"`
+}
+
+// Hash computes an entry's hash from the previous hash and its own fields.
+// The fields are encoded as a JSON array so no two entries share an
+// encoding; timestamps are hashed in UTC at microsecond precision, which is
+// what Postgres stores.
+func Hash(prevHash string, e Entry) string {
+	fields := []any{
+		e.Seq,
+		prevHash,
+		e.UserID,
+		e.Type,
+		e.Entity,
+		e.EntityID,
+		e.Message,
+		Timestamp(e.CreatedAt),
+	}
+	ctx := entryContext{
+		OrganizationID: e.OrganizationID,
+		TeamID:         e.TeamID,
+		Principal:      e.Principal,
+		AuthMethod:     e.AuthMethod,
+		IP:             e.IP,
+		UserAgent:      e.UserAgent,
+		RequestID:      e.RequestID,
+		Outcome:        e.Outcome,
+		Reason:         e.Reason,
+		EventID:        e.EventID,
+	}
+	if ctx != (entryContext{}) {
+		fields = append(fields, ctx)
+	}
+	b, _ := json.Marshal(fields)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Timestamp is the canonical form of an entry time.
+func Timestamp(t time.Time) string {
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
+
+// KeyID names a signing key by a short hash of its public half.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func checkpointPayload(c Checkpoint) []byte {
+	return []byte("one-password-audit-checkpoint\n" +
+		strconv.FormatInt(c.Seq, 10) + "\n" +
+		c.Hash + "\n" +
+		Timestamp(c.SignedAt))
+}
+
+// Sign fills in a checkpoint's key id and signature.
+func Sign(priv ed25519.PrivateKey, c *Checkpoint) {
+	pub := priv.Public().(ed25519.PublicKey)
+	c.KeyID = KeyID(pub)
+	c.Signature = hex.EncodeToString(ed25519.Sign(priv, checkpointPayload(*c)))
+}
+
+// ValidSignature reports whether a checkpoint was signed by pub.
+func ValidSignature(pub ed25519.PublicKey, c Checkpoint) bool {
+	sig, err := hex.DecodeString(c.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(pub, checkpointPayload(c), sig)
+}
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl" // one Entry per line, what audit-verify -entries reads
+	FormatCEF   = "cef"   // ArcSight Common Event Format, one event per line
+)
+
+var ErrUnknownFormat = errors.New("format must be csv, jsonl or cef")
+
+// ExportFilter selects the entries of an export. The zero filter selects the
+// whole log.
+type ExportFilter struct {
+	OrganizationID uint       `json:"organization_id,omitempty"`
+	UserID         uint       `json:"user_id,omitempty"`
+	Types          []string   `json:"types,omitempty"`
+	Entity         string     `json:"entity,omitempty"`
+	From           *time.Time `json:"from,omitempty"` // inclusive
+	To             *time.Time `json:"to,omitempty"`   // exclusive
+}
+
+func (f ExportFilter) IsZero() bool {
+	return f.OrganizationID == 0 && f.UserID == 0 && len(f.Types) == 0 && f.Entity == "" && f.From == nil && f.To == nil
+}
+
+// ExportWriter writes entries in one of the export formats. Close flushes
+// what is buffered; it does not close the underlying writer.
+type ExportWriter interface {
+	Write(e Entry) error
+	Close() error
+}
+
+// CheckFormat returns ErrUnknownFormat unless format is one of the export formats.
+func CheckFormat(format string) error {
+	switch format {
+	case FormatCSV, FormatJSONL, FormatCEF:
+		return nil
+	}
+	return ErrUnknownFormat
+}
+
+// NewExportWriter returns a writer for format.
+func NewExportWriter(w io.Writer, format string) (ExportWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{bw: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatCEF:
+		return &cefWriter{bw: bufio.NewWriter(w)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ContentType is the media type of an export in format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// CSVHeader names the columns of a CSV export.
+var CSVHeader = []string{
+	"seq", "created_at", "organization_id", "team_id", "user_id", "principal", "auth_method",
+	"type", "entity", "entity_id", "outcome", "reason", "message",
+	"ip", "user_agent", "request_id", "event_id", "prev_hash", "hash",
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	started bool
+}
+
+func (c *csvWriter) Write(e Entry) error {
+	if !c.started {
+		c.started = true
+		if err := c.w.Write(CSVHeader); err != nil {
+			return err
+		}
+	}
+	return c.w.Write([]string{
+		strconv.FormatInt(e.Seq, 10),
+		Timestamp(e.CreatedAt),
+		uintField(e.OrganizationID),
+		uintField(e.TeamID),
+		strconv.FormatUint(uint64(e.UserID), 10),
+		csvText(e.Principal),
+		csvText(e.AuthMethod),
+		csvText(e.Type),
+		csvText(e.Entity),
+		uintField(e.EntityID),
+		csvText(e.Outcome),
+		csvText(e.Reason),
+		csvText(e.Message),
+		csvText(e.IP),
+		csvText(e.UserAgent),
+		csvText(e.RequestID),
+		e.EventID,
+		e.PrevHash,
+		e.Hash,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	if !c.started {
+		c.started = true
+		c.w.Write(CSVHeader)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvText defuses text a spreadsheet would read as a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func uintField(v uint) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+type jsonlWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(e Entry) error { return j.enc.Encode(e) }
+
+func (j *jsonlWriter) Close() error { return j.bw.Flush() }
+
+const (
+	cefVendor  = "One-Password"
+	cefProduct = "One-Password API"
+	cefVersion = "1"
+)
+
+type cefWriter struct {
+	bw *bufio.Writer
+}
+
+// Write emits one CEF:0 line. Failed and denied actions get severity 7,
+// everything else 3.
+func (c *cefWriter) Write(e Entry) error {
+	severity := 3
+	if e.Outcome == OutcomeFailure || e.Outcome == OutcomeDenied {
+		severity = 7
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader(cefVendor), cefHeader(cefProduct), cefVersion,
+		cefHeader(e.Type), cefHeader(strings.ReplaceAll(e.Type, "_", " ")), severity)
+
+	ext := [][2]string{
+		{"rt", strconv.FormatInt(e.CreatedAt.UnixMilli(), 10)},
+		{"externalId", strconv.FormatInt(e.Seq, 10)},
+		{"act", e.Type},
+		{"suid", strconv.FormatUint(uint64(e.UserID), 10)},
+		{"outcome", e.Outcome},
+		{"reason", e.Reason},
+		{"src", e.IP},
+		{"requestClientApplication", e.UserAgent},
+		{"msg", e.Message},
+		{"cs1Label", "entity"}, {"cs1", e.Entity},
+		{"cn1Label", "entityId"}, {"cn1", uintField(e.EntityID)},
+		{"cn2Label", "organizationId"}, {"cn2", uintField(e.OrganizationID)},
+		{"cn3Label", "teamId"}, {"cn3", uintField(e.TeamID)},
+		{"cs2Label", "requestId"}, {"cs2", e.RequestID},
+		{"cs3Label", "principal"}, {"cs3", e.Principal},
+		{"cs4Label", "authMethod"}, {"cs4", e.AuthMethod},
+		{"cs5Label", "hash"}, {"cs5", e.Hash},
+	}
+	first := true
+	for i := 0; i < len(ext); i++ {
+		k, v := ext[i][0], ext[i][1]
+		if strings.HasSuffix(k, "Label") {
+			// a label is only written with its value
+			if ext[i+1][1] == "" {
+				i++
+				continue
+			}
+		} else if v == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(cefValue(v))
+	}
+	b.WriteByte('\n')
+	_, err := c.bw.WriteString(b.String())
+	return err
+}
+
+func (c *cefWriter) Close() error { return c.bw.Flush() }
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+
+func cefValue(s string) string { return cefValueEscaper.Replace(s) }
@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func export(t *testing.T, format string, entries []Entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewExportWriter(&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestExportCSV(t *testing.T) {
+	entries := chain(t, 2)
+	entries[0].Message = "=HYPERLINK(\"http://evil\")"
+	rows, err := csv.NewReader(strings.NewReader(export(t, FormatCSV, entries))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(CSVHeader, ",") {
+		t.Fatalf("rows = %q", rows)
+	}
+	if got := rows[1][12]; got != "'=HYPERLINK(\"http://evil\")" {
+		t.Fatalf("message = %q, want it defused", got)
+	}
+	if got := rows[2][len(CSVHeader)-1]; got != entries[1].Hash {
+		t.Fatalf("hash = %q, want %q", got, entries[1].Hash)
+	}
+
+	if out := export(t, FormatCSV, nil); strings.TrimSpace(out) != strings.Join(CSVHeader, ",") {
+		t.Fatalf("empty export = %q, want just the header", out)
+	}
+}
+
+func TestExportJSONLRoundTrips(t *testing.T) {
+	entries := chain(t, 3)
+	lines := strings.Split(strings.TrimSpace(export(t, FormatJSONL, entries)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	v := NewVerifier(nil, nil)
+	for _, line := range lines {
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		v.Add(e)
+	}
+	if r := v.Finish(); !r.OK {
+		t.Fatalf("exported chain does not verify: %+v", r.Problems)
+	}
+}
+
+func TestExportCEFEscapes(t *testing.T) {
+	e := chain(t, 1)[0]
+	e.Type = "access|denied"
+	e.Outcome = OutcomeDenied
+	e.Message = "a=b\\c\nd"
+	line := export(t, FormatCEF, []Entry{e})
+
+	if !strings.HasPrefix(line, `CEF:0|One-Password|One-Password API|1|access\|denied|access\|denied|7|`) {
+		t.Fatalf("header = %q", line)
+	}
+	if !strings.Contains(line, ` msg=a\=b\\c\nd `) {
+		t.Fatalf("msg not escaped: %q", line)
+	}
+	if strings.Count(line, "\n") != 1 || strings.Contains(line, "src=") {
+		t.Fatalf("line = %q", line)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	build := func(extra func(b *Bundle)) []byte {
+		var buf bytes.Buffer
+		b := NewBundle(&buf, priv, Manifest{Format: FormatJSONL})
+		w, err := b.Create("activities.jsonl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(export(t, FormatJSONL, chain(t, 3))))
+		if extra != nil {
+			extra(b)
+		}
+		b.Manifest.Entries = 3
+		if err := b.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	data := build(nil)
+	m, err := VerifyBundle(bytes.NewReader(data), int64(len(data)), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Entries != 3 || len(m.Files) != 1 || m.KeyID != KeyID(pub) {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := VerifyBundle(bytes.NewReader(data), int64(len(data)), other); !errors.Is(err, ErrBadBundle) {
+		t.Fatalf("wrong key: err = %v", err)
+	}
+
+	// rewrite the archive with one entry changed
+	tampered := rezip(t, data, func(name string, b []byte) []byte {
+		if name == "activities.jsonl" {
+			return bytes.Replace(b, []byte(`"entry 1"`), []byte(`"entry 9"`), 1)
+		}
+		return b
+	})
+	if _, err := VerifyBundle(bytes.NewReader(tampered), int64(len(tampered)), pub); !errors.Is(err, ErrBadBundle) {
+		t.Fatalf("tampered bundle: err = %v", err)
+	}
+}
+
+func rezip(t *testing.T, data []byte, edit func(name string, b []byte) []byte) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		w, _ := zw.Create(f.Name)
+		w.Write(edit(f.Name, b))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,48 @@
+package audit
+
+import "context"
+
+// Principal types: who or what acted.
+const (
+	PrincipalUser           = "user"
+	PrincipalPAT            = "pat"
+	PrincipalServiceAccount = "service_account"
+	PrincipalAnonymous      = "anonymous" // unauthenticated request, e.g. a share link or sign-in
+	PrincipalSystem         = "system"    // background job, no request
+)
+
+// Outcomes of an audited action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Meta describes the request an action came from. The HTTP middleware fills
+// it in as the request is authenticated and every activity logged while
+// serving the request records it.
+type Meta struct {
+	IP             string
+	UserAgent      string
+	RequestID      string
+	Principal      string
+	AuthMethod     string
+	OrganizationID uint
+}
+
+type metaKey struct{}
+
+// WithMeta returns a context carrying m. m is shared, not copied, so later
+// middleware can fill in what it learns.
+func WithMeta(ctx context.Context, m *Meta) context.Context {
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+// MetaFrom returns the request metadata in ctx, or nil outside a request.
+func MetaFrom(ctx context.Context) *Meta {
+	if ctx == nil {
+		return nil
+	}
+	m, _ := ctx.Value(metaKey{}).(*Meta)
+	return m
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+)
+
+// Problem kinds reported by the verifier.
+const (
+	ProblemUnsealed           = "unsealed"            // entry has no place in the chain
+	ProblemGap                = "gap"                 // entries are missing
+	ProblemOutOfOrder         = "out_of_order"        // duplicate or reordered sequence number
+	ProblemBrokenLink         = "broken_link"         // prev_hash does not match the entry before
+	ProblemModified           = "modified"            // entry no longer matches its own hash
+	ProblemBadSignature       = "bad_signature"       // checkpoint not signed by the audit key
+	ProblemCheckpointMismatch = "checkpoint_mismatch" // entry differs from what was signed
+	ProblemTruncated          = "truncated"           // signed entries are gone
+)
+
+// MaxProblems caps how many problems a report lists; ProblemCount has the total.
+const MaxProblems = 100
+
+type Problem struct {
+	Seq    int64  `json:"seq"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// Report is the outcome of a verification.
+type Report struct {
+	OK              bool      `json:"ok"`
+	Entries         int64     `json:"entries"`
+	FirstSeq        int64     `json:"first_seq"`
+	LastSeq         int64     `json:"last_seq"`
+	HeadHash        string    `json:"head_hash"`
+	Checkpoints     int       `json:"checkpoints"`
+	VerifiedThrough int64     `json:"verified_through"` // last entry covered by a good checkpoint
+	UnsignedTail    int64     `json:"unsigned_tail"`    // entries after it, only chain-checked
+	KeyID           string    `json:"key_id"`
+	ProblemCount    int       `json:"problem_count"`
+	Problems        []Problem `json:"problems"`
+}
+
+// Verifier checks entries fed to it in sequence order, so a log of any size
+// can be verified in batches.
+type Verifier struct {
+	checkpoints []Checkpoint
+	next        int
+	started     bool
+	prevSeq     int64
+	prevHash    string
+	report      Report
+}
+
+// NewVerifier checks the checkpoints' signatures against pub up front; only
+// validly signed checkpoints are then matched against entries.
+func NewVerifier(pub ed25519.PublicKey, checkpoints []Checkpoint) *Verifier {
+	v := &Verifier{report: Report{Checkpoints: len(checkpoints), KeyID: KeyID(pub), Problems: []Problem{}}}
+	for _, c := range checkpoints {
+		if !ValidSignature(pub, c) {
+			v.problem(c.Seq, ProblemBadSignature, fmt.Sprintf("checkpoint at entry %d signed %s is not signed by key %s", c.Seq, Timestamp(c.SignedAt), v.report.KeyID))
+			continue
+		}
+		v.checkpoints = append(v.checkpoints, c)
+	}
+	sort.SliceStable(v.checkpoints, func(i, j int) bool { return v.checkpoints[i].Seq < v.checkpoints[j].Seq })
+	return v
+}
+
+// Add checks the next entry.
+func (v *Verifier) Add(e Entry) {
+	if e.Seq <= 0 || e.Hash == "" {
+		v.problem(e.Seq, ProblemUnsealed, fmt.Sprintf("%q entry at %s is outside the chain", e.Type, Timestamp(e.CreatedAt)))
+		return
+	}
+
+	linked := true
+	switch {
+	case !v.started:
+		v.report.FirstSeq = e.Seq
+		if e.Seq != 1 {
+			v.problem(e.Seq, ProblemGap, fmt.Sprintf("entries 1 to %d are missing", e.Seq-1))
+			linked = false
+		} else if e.PrevHash != "" {
+			v.problem(e.Seq, ProblemBrokenLink, "first entry has a previous hash")
+		}
+	case e.Seq <= v.prevSeq:
+		v.problem(e.Seq, ProblemOutOfOrder, fmt.Sprintf("entry %d follows entry %d", e.Seq, v.prevSeq))
+		return
+	case e.Seq != v.prevSeq+1:
+		v.problem(e.Seq, ProblemGap, fmt.Sprintf("entries %d to %d are missing", v.prevSeq+1, e.Seq-1))
+		linked = false
+	}
+	if linked && v.started && e.PrevHash != v.prevHash {
+		v.problem(e.Seq, ProblemBrokenLink, fmt.Sprintf("previous hash does not match entry %d", v.prevSeq))
+	}
+	if Hash(e.PrevHash, e) != e.Hash {
+		v.problem(e.Seq, ProblemModified, "entry does not match its hash")
+	}
+
+	for v.next < len(v.checkpoints) && v.checkpoints[v.next].Seq <= e.Seq {
+		c := v.checkpoints[v.next]
+		v.next++
+		if c.Seq < e.Seq {
+			v.problem(c.Seq, ProblemTruncated, fmt.Sprintf("entry %d was signed %s but is missing", c.Seq, Timestamp(c.SignedAt)))
+			continue
+		}
+		if c.Hash != e.Hash {
+			v.problem(e.Seq, ProblemCheckpointMismatch, fmt.Sprintf("entry differs from the one signed %s", Timestamp(c.SignedAt)))
+			continue
+		}
+		v.report.VerifiedThrough = e.Seq
+	}
+
+	v.started = true
+	v.prevSeq = e.Seq
+	v.prevHash = e.Hash
+	v.report.Entries++
+	v.report.LastSeq = e.Seq
+	v.report.HeadHash = e.Hash
+}
+
+// Finish reports signed entries past the end of the log and returns the report.
+func (v *Verifier) Finish() Report {
+	for ; v.next < len(v.checkpoints); v.next++ {
+		c := v.checkpoints[v.next]
+		v.problem(c.Seq, ProblemTruncated, fmt.Sprintf("log ends at entry %d but entry %d was signed %s", v.report.LastSeq, c.Seq, Timestamp(c.SignedAt)))
+	}
+	v.report.UnsignedTail = v.report.LastSeq - v.report.VerifiedThrough
+	v.report.OK = v.report.ProblemCount == 0
+	return v.report
+}
+
+func (v *Verifier) problem(seq int64, kind, detail string) {
+	v.report.ProblemCount++
+	if len(v.report.Problems) < MaxProblems {
+		v.report.Problems = append(v.report.Problems, Problem{Seq: seq, Kind: kind, Detail: detail})
+	}
+}
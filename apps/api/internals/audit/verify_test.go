@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func chain(t *testing.T, n int) []Entry {
+	t.Helper()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	var out []Entry
+	prev := ""
+	for i := 1; i <= n; i++ {
+		e := Entry{
+			Seq:       int64(i),
+			PrevHash:  prev,
+			UserID:    uint(i % 3),
+			Type:      "apikey_revealed",
+			Entity:    "apikey",
+			EntityID:  uint(i),
+			Message:   fmt.Sprintf("entry %d", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		if i%2 == 0 {
+			e.IP = "203.0.113.7"
+			e.Outcome = OutcomeSuccess
+		}
+		e.Hash = Hash(prev, e)
+		prev = e.Hash
+		out = append(out, e)
+	}
+	return out
+}
+
+func checkpoint(priv ed25519.PrivateKey, e Entry) Checkpoint {
+	c := Checkpoint{Seq: e.Seq, Hash: e.Hash, SignedAt: e.CreatedAt.Add(time.Second)}
+	Sign(priv, &c)
+	return c
+}
+
+func verify(pub ed25519.PublicKey, entries []Entry, cps []Checkpoint) Report {
+	v := NewVerifier(pub, cps)
+	for _, e := range entries {
+		v.Add(e)
+	}
+	return v.Finish()
+}
+
+func kinds(r Report) []string {
+	var out []string
+	for _, p := range r.Problems {
+		out = append(out, p.Kind)
+	}
+	return out
+}
+
+func TestHashIgnoresTimeZoneAndNanoseconds(t *testing.T) {
+	e := chain(t, 1)[0]
+	moved := e
+	moved.CreatedAt = e.CreatedAt.In(time.FixedZone("IST", 19800)).Truncate(time.Microsecond)
+	if Hash("", e) != Hash("", moved) {
+		t.Fatal("hash depends on the time zone or sub-microsecond precision")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name   string
+		mutate func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint)
+		want   []string
+	}{
+		{"intact", nil, nil},
+		{"edited message", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			es[3].Message = "nothing to see"
+			return es, cps
+		}, []string{ProblemModified}},
+		{"edited source address", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			es[5].IP = "10.0.0.1"
+			return es, cps
+		}, []string{ProblemModified}},
+		{"edited and rehashed tail", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			es[3].Message = "nothing to see"
+			for i := 3; i < len(es); i++ {
+				es[i].PrevHash = es[i-1].Hash
+				es[i].Hash = Hash(es[i].PrevHash, es[i])
+			}
+			return es, cps
+		}, []string{ProblemCheckpointMismatch, ProblemCheckpointMismatch}},
+		{"deleted entry", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			return append(es[:2:2], es[3:]...), cps
+		}, []string{ProblemGap}},
+		{"deleted first entry", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			return es[1:], cps
+		}, []string{ProblemGap}},
+		{"truncated tail", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			return es[:6], cps
+		}, []string{ProblemTruncated}},
+		{"forged checkpoint", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			return es, append(cps, checkpoint(otherPriv, es[7]))
+		}, []string{ProblemBadSignature}},
+		{"unsealed insert", func(es []Entry, cps []Checkpoint) ([]Entry, []Checkpoint) {
+			return append([]Entry{{Type: "apikey_created"}}, es...), cps
+		}, []string{ProblemUnsealed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := chain(t, 10)
+			cps := []Checkpoint{checkpoint(priv, es[4]), checkpoint(priv, es[7])}
+			if tt.mutate != nil {
+				es, cps = tt.mutate(es, cps)
+			}
+			r := verify(pub, es, cps)
+			got := kinds(r)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("problems = %v, want %v (%+v)", got, tt.want, r.Problems)
+			}
+			if r.OK != (len(tt.want) == 0) {
+				t.Fatalf("OK = %v with problems %v", r.OK, got)
+			}
+		})
+	}
+}
+
+func TestVerifyReportsSignedCoverage(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	es := chain(t, 10)
+	r := verify(pub, es, []Checkpoint{checkpoint(priv, es[7])})
+	if !r.OK || r.VerifiedThrough != 8 || r.UnsignedTail != 2 || r.HeadHash != es[9].Hash {
+		t.Fatalf("unexpected report %+v", r)
+	}
+}
@@ -5,6 +5,12 @@ import (
 	"os"
 	"strconv"
 	"encoding/base64"
+	"crypto/ed25519"
+	"net/netip"
+	"time"
+	"github.com/intojhanurag/One-Password/apps/api/internals/anomaly"
+	"github.com/intojhanurag/One-Password/apps/api/internals/siem"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
 	"github.com/joho/godotenv"
 )
 
@@ -16,6 +22,28 @@ type Config struct {
 	JWTExpiresMin int
 	BCryptCost    int
 	MasterKey     []byte
+	NotifyWebhookURL string
+	ReminderIntervalMin int
+	RotationIntervalMin int
+	LeaseSweepIntervalSec int
+	RevealLeaseTTLMin int
+	RevealLeaseMaxTTLMin int
+	ShareBaseURL string
+	AuditSigningKey ed25519.PrivateKey
+	AuditCheckpointIntervalMin int
+	OutboxPollIntervalMs int
+	TrustedProxies []netip.Prefix
+	AuditSinks []siem.Config
+	AuditSpillDir string
+	WebhookPollIntervalSec int
+	WebhookAllowPrivate bool
+	Anomaly anomaly.Thresholds
+	GeoIPCountryCSV string
+	SMTPAddr string
+	SMTPFrom string
+	SMTPUsername string
+	SMTPPassword string
+	InviteBaseURL string
 }
 
 func Load() *Config {
@@ -27,6 +55,43 @@ func Load() *Config {
 	bcryptCost, err := strconv.Atoi(get("BCRYPT_COST", "12"))
 	if err != nil { bcryptCost = 12 }
 
+	reminderMin, err := strconv.Atoi(get("REMINDER_INTERVAL_MIN", "60"))
+	if err != nil || reminderMin <= 0 { reminderMin = 60 }
+
+	rotationMin, err := strconv.Atoi(get("ROTATION_INTERVAL_MIN", "5"))
+	if err != nil || rotationMin <= 0 { rotationMin = 5 }
+
+	sweepSec, err := strconv.Atoi(get("LEASE_SWEEP_INTERVAL_SEC", "30"))
+	if err != nil || sweepSec <= 0 { sweepSec = 30 }
+
+	leaseTTL, err := strconv.Atoi(get("REVEAL_LEASE_TTL_MIN", "60"))
+	if err != nil || leaseTTL <= 0 { leaseTTL = 60 }
+
+	leaseMaxTTL, err := strconv.Atoi(get("REVEAL_LEASE_MAX_TTL_MIN", "1440"))
+	if err != nil || leaseMaxTTL < leaseTTL { leaseMaxTTL = max(1440, leaseTTL) }
+
+	checkpointMin, err := strconv.Atoi(get("AUDIT_CHECKPOINT_INTERVAL_MIN", "15"))
+	if err != nil || checkpointMin <= 0 { checkpointMin = 15 }
+
+	outboxPollMs, err := strconv.Atoi(get("OUTBOX_POLL_INTERVAL_MS", "1000"))
+	if err != nil || outboxPollMs <= 0 { outboxPollMs = 1000 }
+
+	webhookPollSec, err := strconv.Atoi(get("WEBHOOK_POLL_INTERVAL_SEC", "5"))
+	if err != nil || webhookPollSec <= 0 { webhookPollSec = 5 }
+
+	// outbound webhooks may target http:// and internal addresses only when
+	// this is set, e.g. to test against a local receiver
+	webhookAllowPrivate, _ := strconv.ParseBool(get("WEBHOOK_ALLOW_PRIVATE", "false"))
+
+	// anomaly detection on secret access; ANOMALY_SUSPEND_SCORE=0 raises
+	// alerts without ever suspending sessions
+	thresholds := anomaly.DefaultThresholds()
+	if v, err := strconv.Atoi(get("ANOMALY_REVEAL_MIN", "")); err == nil && v > 0 { thresholds.VolumeMin = v }
+	if v, err := strconv.ParseFloat(get("ANOMALY_REVEAL_Z", ""), 64); err == nil && v > 0 { thresholds.VolumeZ = v }
+	if v, err := strconv.Atoi(get("ANOMALY_LIST_MAX", "")); err == nil && v > 0 { thresholds.ListMax = v }
+	if v, err := strconv.Atoi(get("ANOMALY_LIST_WINDOW_SEC", "")); err == nil && v > 0 { thresholds.ListWindow = time.Duration(v) * time.Second }
+	if v, err := strconv.ParseFloat(get("ANOMALY_SUSPEND_SCORE", ""), 64); err == nil && v >= 0 { thresholds.SuspendScore = v }
+
 	masterKeyB64:=must("MASTER_KEY_B64")
 
 	keyBytes, err:=base64.StdEncoding.DecodeString(masterKeyB64)
@@ -39,6 +104,30 @@ func Load() *Config {
 		log.Fatalf("MASTER_KEY_B64 must decode to 32 bytes,got %d",len(keyBytes))
 	}
 
+	// audit checkpoints are signed with AUDIT_SIGNING_KEY_B64 (a 32-byte
+	// ed25519 seed) or, without it, a key derived from the master key
+	auditKey := utils.DeriveSigningKey(keyBytes, "audit-checkpoint")
+	if seedB64 := get("AUDIT_SIGNING_KEY_B64", ""); seedB64 != "" {
+		seed, err := base64.StdEncoding.DecodeString(seedB64)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			log.Fatalf("AUDIT_SIGNING_KEY_B64 must decode to %d bytes", ed25519.SeedSize)
+		}
+		auditKey = ed25519.NewKeyFromSeed(seed)
+	}
+
+	// X-Forwarded-For is only believed from these (comma-separated IPs/CIDRs)
+	trustedProxies, err := utils.ParseTrustedProxies(get("TRUSTED_PROXIES", ""))
+	if err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// audit events are also streamed to the sinks in AUDIT_SINKS, a JSON
+	// array of siem.Config; undeliverable ones wait in AUDIT_SPILL_DIR
+	auditSinks, err := siem.ParseConfigs(get("AUDIT_SINKS", ""))
+	if err != nil {
+		log.Fatalf("invalid AUDIT_SINKS: %v", err)
+	}
+
 	return &Config{
 		AppEnv:        get("APP_ENV", "dev"),
 		Port:          get("PORT", "8080"),
@@ -47,6 +136,32 @@ func Load() *Config {
 		JWTExpiresMin: jwtExp,
 		BCryptCost:    bcryptCost,
 		MasterKey: 	   keyBytes,
+		NotifyWebhookURL: get("NOTIFY_WEBHOOK_URL", ""),
+		ReminderIntervalMin: reminderMin,
+		RotationIntervalMin: rotationMin,
+		LeaseSweepIntervalSec: sweepSec,
+		RevealLeaseTTLMin: leaseTTL,
+		RevealLeaseMaxTTLMin: leaseMaxTTL,
+		ShareBaseURL: get("SHARE_BASE_URL", "https://one-password-web.vercel.app/share"),
+		AuditSigningKey: auditKey,
+		AuditCheckpointIntervalMin: checkpointMin,
+		OutboxPollIntervalMs: outboxPollMs,
+		TrustedProxies: trustedProxies,
+		AuditSinks: auditSinks,
+		AuditSpillDir: get("AUDIT_SPILL_DIR", "data/audit-spill"),
+		WebhookPollIntervalSec: webhookPollSec,
+		WebhookAllowPrivate: webhookAllowPrivate,
+		Anomaly: thresholds,
+		// "network,country" CSV for the new-country rule; without it only
+		// new addresses are flagged
+		GeoIPCountryCSV: get("GEOIP_COUNTRY_CSV", ""),
+		// invitations are emailed through this relay (host:port); without
+		// SMTP_ADDR they are only shown in-app and to the inviter
+		SMTPAddr: get("SMTP_ADDR", ""),
+		SMTPFrom: get("SMTP_FROM", "no-reply@one-password.local"),
+		SMTPUsername: get("SMTP_USERNAME", ""),
+		SMTPPassword: get("SMTP_PASSWORD", ""),
+		InviteBaseURL: get("INVITE_BASE_URL", "https://one-password-web.vercel.app/invite"),
 	}
 }
 
@@ -0,0 +1,48 @@
+package database
+
+import "gorm.io/gorm"
+
+// EnsureAuditLog makes activities and audit_checkpoints append-only. The only
+// update allowed is sealing a row that predates the hash chain: setting its
+// chain columns once, with nothing else changed. Anyone able to drop the
+// trigger can still rewrite rows, which the chain and its signed checkpoints
+// then reveal.
+//
+// It also lets each outbox event be recorded as an activity only once.
+func EnsureAuditLog(db *gorm.DB) error {
+	stmts := []string{
+		`CREATE OR REPLACE FUNCTION audit_append_only() RETURNS trigger AS $$
+		BEGIN
+			IF TG_TABLE_NAME = 'activities' AND TG_OP = 'UPDATE' THEN
+				IF OLD.hash = '' AND NEW.hash <> ''
+					AND to_jsonb(NEW) - 'seq' - 'prev_hash' - 'hash'
+						= to_jsonb(OLD) - 'seq' - 'prev_hash' - 'hash' THEN
+					RETURN NEW;
+				END IF;
+			END IF;
+			RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS activities_append_only ON activities`,
+		`CREATE TRIGGER activities_append_only BEFORE UPDATE OR DELETE ON activities
+			FOR EACH ROW EXECUTE FUNCTION audit_append_only()`,
+		`DROP TRIGGER IF EXISTS activities_no_truncate ON activities`,
+		`CREATE TRIGGER activities_no_truncate BEFORE TRUNCATE ON activities
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_append_only()`,
+		`DROP TRIGGER IF EXISTS audit_checkpoints_append_only ON audit_checkpoints`,
+		`CREATE TRIGGER audit_checkpoints_append_only BEFORE UPDATE OR DELETE ON audit_checkpoints
+			FOR EACH ROW EXECUTE FUNCTION audit_append_only()`,
+		`DROP TRIGGER IF EXISTS audit_checkpoints_no_truncate ON audit_checkpoints`,
+		`CREATE TRIGGER audit_checkpoints_no_truncate BEFORE TRUNCATE ON audit_checkpoints
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_append_only()`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_activities_event_id ON activities (event_id) WHERE event_id <> ''`,
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// ActivityFeedChannel is the LISTEN/NOTIFY channel on which every new
+// activity is announced, with its seq as the payload.
+const ActivityFeedChannel = "activity_feed"
+
+// EnsureActivityFeed makes each insert into activities notify
+// ActivityFeedChannel. Postgres sends the notification when the inserting
+// transaction commits, so listeners only hear of committed activities, in
+// any API instance.
+func EnsureActivityFeed(db *gorm.DB) error {
+	stmts := []string{
+		`CREATE OR REPLACE FUNCTION activity_feed_notify() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('` + ActivityFeedChannel + `', NEW.seq::text);
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS activities_feed_notify ON activities`,
+		`CREATE TRIGGER activities_feed_notify AFTER INSERT ON activities
+			FOR EACH ROW EXECUTE FUNCTION activity_feed_notify()`,
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Listen LISTENs on channel over a dedicated connection to dsn and calls fn
+// with each notification's payload until ctx is done, reconnecting after
+// errors. Notifications sent while it is disconnected are lost, so fn is
+// also called with an empty payload after every (re)connect.
+func Listen(ctx context.Context, dsn, channel string, fn func(payload string)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := listenOnce(ctx, dsn, channel, fn, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("listen %s: %v; reconnecting in %s\n", channel, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func listenOnce(ctx context.Context, dsn, channel string, fn func(string), connected func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+	fn("")
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
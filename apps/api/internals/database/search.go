@@ -0,0 +1,26 @@
+package database
+
+import "gorm.io/gorm"
+
+// EnsureSearchIndexes adds the full-text search column on api_keys and its GIN
+// index. AutoMigrate cannot express generated columns, so this runs after it.
+// Name and path weigh most, then description, then tags.
+func EnsureSearchIndexes(db *gorm.DB) error {
+	stmts := []string{
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+				setweight(to_tsvector('simple', replace(coalesce(path, ''), '/', ' ')), 'A') ||
+				setweight(to_tsvector('simple', coalesce(description, '')), 'B') ||
+				setweight(to_tsvector('simple', translate(coalesce(tags, ''), ',:', '  ')), 'C')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_api_keys_search_vector ON api_keys USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_keys_org_owner_created ON api_keys (organization_id, owner_id, created_at, id)`,
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
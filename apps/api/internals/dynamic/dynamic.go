@@ -0,0 +1,62 @@
+// Package dynamic mints short-lived credentials on demand. Unlike stored
+// secrets, each lease gets its own user at the target system, which is
+// removed again when the lease ends.
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Credentials are handed to the caller once and never stored.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Statements are the templates a role uses to manage its users. Each is a
+// ';'-separated script; see Render for the placeholders.
+type Statements struct {
+	Creation   string `json:"creation"`
+	Renewal    string `json:"renewal"`
+	Revocation string `json:"revocation"`
+}
+
+// Engine creates and removes users at one kind of target system.
+type Engine interface {
+	Create(ctx context.Context, st Statements, creds Credentials, expiresAt time.Time) error
+	Renew(ctx context.Context, st Statements, username string, expiresAt time.Time) error
+	Revoke(ctx context.Context, st Statements, username string) error
+	// Defaults fills in the statements a role did not set.
+	Defaults(st Statements) Statements
+	// Validate rejects statements outside the templates the engine allows.
+	Validate(st Statements) error
+}
+
+// Options apply to every engine, whatever its settings.
+type Options struct {
+	// AllowPrivate lets engines reach loopback, private and link-local
+	// addresses, for development against local targets. Without it, an admin
+	// who can configure a role could point it at internal services.
+	AllowPrivate bool
+}
+
+var (
+	ErrUnknownEngine       = errors.New("unknown secrets engine")
+	ErrStatementNotAllowed = errors.New("statement not allowed")
+)
+
+// New builds the engine of the given type from its connection settings.
+func New(kind string, config json.RawMessage, opts Options) (Engine, error) {
+	switch kind {
+	case "postgres":
+		var c PostgresConfig
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewPostgresEngine(c, opts)
+	}
+	return nil, ErrUnknownEngine
+}
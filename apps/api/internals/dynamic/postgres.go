@@ -0,0 +1,180 @@
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/pgsql"
+	"github.com/intojhanurag/One-Password/apps/api/internals/webhook"
+	"gorm.io/gorm"
+)
+
+// Default Postgres statements. VALID UNTIL makes the server itself refuse
+// logins after expiry, even if revocation is delayed.
+const (
+	DefaultPostgresCreation = `CREATE ROLE {{name}} WITH LOGIN PASSWORD {{password}} VALID UNTIL {{expiration}};
+GRANT CONNECT ON DATABASE {{database}} TO {{name}};
+GRANT USAGE ON SCHEMA public TO {{name}};
+GRANT SELECT ON ALL TABLES IN SCHEMA public TO {{name}}`
+	DefaultPostgresRenewal    = `ALTER ROLE {{name}} VALID UNTIL {{expiration}}`
+	DefaultPostgresRevocation = `SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE usename = {{username}};
+REASSIGN OWNED BY {{name}} TO CURRENT_USER;
+DROP OWNED BY {{name}};
+DROP ROLE IF EXISTS {{name}}`
+)
+
+// Each statement a role runs must match one of these templates, after
+// whitespace is collapsed, so a role manages its own user and nothing else.
+var (
+	postgresCreation = templates(
+		`CREATE (ROLE|USER) \{\{name\}\}( .*)?`,
+		`ALTER (ROLE|USER) \{\{name\}\}( .*)?`,
+		`GRANT [^;]+ TO \{\{name\}\}`,
+	)
+	postgresRenewal = templates(
+		`ALTER (ROLE|USER) \{\{name\}\}( .*)?`,
+	)
+	postgresRevocation = templates(
+		`SELECT pg_terminate_backend\(pid\) FROM pg_stat_activity WHERE usename = \{\{username\}\}`,
+		`REVOKE [^;]+ FROM \{\{name\}\}( CASCADE)?`,
+		`REASSIGN OWNED BY \{\{name\}\} TO [^ ]+`,
+		`DROP OWNED BY \{\{name\}\}( CASCADE)?`,
+		`DROP (ROLE|USER) (IF EXISTS )?\{\{name\}\}`,
+	)
+)
+
+// PostgresConfig is a postgres:// URL for a user with CREATEROLE. Unless
+// engines may reach private addresses, connections to loopback, private and
+// link-local hosts (and unix sockets) are refused.
+type PostgresConfig struct {
+	DSN string `json:"dsn"`
+}
+
+type PostgresEngine struct {
+	Config   PostgresConfig
+	Dialer   pgsql.Dialer // nil connects anywhere
+	database string
+}
+
+func NewPostgresEngine(c PostgresConfig, opts Options) (*PostgresEngine, error) {
+	u, err := url.Parse(c.DSN)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, errors.New("postgres engine needs a postgres:// dsn")
+	}
+	db := strings.TrimPrefix(u.Path, "/")
+	if db == "" {
+		db = "postgres"
+	}
+	e := &PostgresEngine{Config: c, database: db}
+	if !opts.AllowPrivate {
+		e.Dialer = webhook.NewDialer(false)
+	}
+	return e, nil
+}
+
+func (e *PostgresEngine) Defaults(st Statements) Statements {
+	if strings.TrimSpace(st.Creation) == "" {
+		st.Creation = DefaultPostgresCreation
+	}
+	if strings.TrimSpace(st.Renewal) == "" {
+		st.Renewal = DefaultPostgresRenewal
+	}
+	if strings.TrimSpace(st.Revocation) == "" {
+		st.Revocation = DefaultPostgresRevocation
+	}
+	return st
+}
+
+func (e *PostgresEngine) Validate(st Statements) error {
+	st = e.Defaults(st)
+	if err := checkScript(st.Creation, postgresCreation); err != nil {
+		return fmt.Errorf("creation: %w", err)
+	}
+	if err := checkScript(st.Renewal, postgresRenewal); err != nil {
+		return fmt.Errorf("renewal: %w", err)
+	}
+	if err := checkScript(st.Revocation, postgresRevocation); err != nil {
+		return fmt.Errorf("revocation: %w", err)
+	}
+	return nil
+}
+
+func (e *PostgresEngine) Create(ctx context.Context, st Statements, creds Credentials, expiresAt time.Time) error {
+	return e.exec(ctx, e.Defaults(st).Creation, postgresCreation, creds.Username, creds.Password, expiresAt)
+}
+
+func (e *PostgresEngine) Renew(ctx context.Context, st Statements, username string, expiresAt time.Time) error {
+	return e.exec(ctx, e.Defaults(st).Renewal, postgresRenewal, username, "", expiresAt)
+}
+
+// Revoke treats a user that does not exist as already revoked, so a lease
+// whose creation failed halfway can still be closed.
+func (e *PostgresEngine) Revoke(ctx context.Context, st Statements, username string) error {
+	var exists bool
+	err := pgsql.WithDialer(ctx, e.Config.DSN, e.Dialer, func(db *gorm.DB) error {
+		return db.Raw("SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ?)", username).Scan(&exists).Error
+	})
+	if err != nil || !exists {
+		return err
+	}
+	return e.exec(ctx, e.Defaults(st).Revocation, postgresRevocation, username, "", time.Time{})
+}
+
+// exec checks the script again before running it, so roles saved before a
+// template was disallowed cannot run it either.
+func (e *PostgresEngine) exec(ctx context.Context, script string, allowed []*regexp.Regexp, username, password string, expiresAt time.Time) error {
+	if err := checkScript(script, allowed); err != nil {
+		return err
+	}
+	stmts := pgsql.SplitStatements(Render(script, e.database, username, password, expiresAt))
+	return pgsql.ExecAll(ctx, e.Config.DSN, e.Dialer, stmts)
+}
+
+func templates(patterns ...string) []*regexp.Regexp {
+	out := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		out[i] = regexp.MustCompile(`(?i)^` + p + `$`)
+	}
+	return out
+}
+
+// checkScript returns ErrStatementNotAllowed for the first statement of the
+// unrendered script that matches none of the allowed templates.
+func checkScript(script string, allowed []*regexp.Regexp) error {
+	for _, stmt := range pgsql.SplitStatements(script) {
+		stmt = strings.Join(strings.Fields(stmt), " ")
+		ok := false
+		for _, re := range allowed {
+			if re.MatchString(stmt) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrStatementNotAllowed, stmt)
+		}
+	}
+	return nil
+}
+
+// Render substitutes the statement placeholders, already quoted:
+//
+//	{{name}}        the user as an identifier
+//	{{username}}    the user as a string literal
+//	{{password}}    the password as a string literal
+//	{{expiration}}  the expiry as a timestamp literal
+//	{{database}}    the database from the connection URL, as an identifier
+func Render(script, database, username, password string, expiresAt time.Time) string {
+	return strings.NewReplacer(
+		"{{name}}", pgsql.QuoteIdent(username),
+		"{{username}}", pgsql.QuoteLiteral(username),
+		"{{password}}", pgsql.QuoteLiteral(password),
+		"{{expiration}}", pgsql.QuoteLiteral(expiresAt.UTC().Format("2006-01-02 15:04:05-07")),
+		"{{database}}", pgsql.QuoteIdent(database),
+	).Replace(script)
+}
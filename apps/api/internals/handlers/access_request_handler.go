@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type AccessRequestHandler struct {
+	Service *services.AccessRequestService
+}
+
+func NewAccessRequestHandler(s *services.AccessRequestService) *AccessRequestHandler {
+	return &AccessRequestHandler{Service: s}
+}
+
+type decideAccessRequest struct {
+	RequestID     uint   `json:"request_id"`
+	Reason        string `json:"reason"`
+	GrantType     string `json:"grant_type"`
+	WindowMinutes int    `json:"window_minutes"`
+}
+
+// GET /access-requests/list?scope=mine|pending
+func (h *AccessRequestHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		reqs interface{}
+		err  error
+	)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	switch r.URL.Query().Get("scope") {
+	case "", "mine":
+		reqs, err = h.Service.WithContext(r.Context()).ListMine(orgID, userID)
+	case "pending":
+		reqs, err = h.Service.WithContext(r.Context()).ListPendingApprovals(orgID, userID)
+	default:
+		http.Error(w, "scope must be mine or pending", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to list access requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reqs)
+}
+
+// POST /access-requests/approve
+func (h *AccessRequestHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.Service.WithContext(r.Context()).Approve)
+}
+
+// POST /access-requests/deny
+func (h *AccessRequestHandler) Deny(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.Service.WithContext(r.Context()).Deny)
+}
+
+func (h *AccessRequestHandler) decide(w http.ResponseWriter, r *http.Request, fn func(services.DecideAccessRequestInput) (*models.AccessRequest, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req decideAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RequestID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	res, err := fn(services.DecideAccessRequestInput{
+		OrganizationID: orgID,
+		ApproverID:     userID,
+		RequestID:      req.RequestID,
+		Reason:         req.Reason,
+		GrantType:      req.GrantType,
+		WindowMinutes:  req.WindowMinutes,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "access request not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrNotApprover):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, services.ErrDecided):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -2,21 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
 	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+)
+
+const (
+	streamHeartbeat   = 15 * time.Second
+	streamTeamRefresh = time.Minute
+	streamMaxAge      = 30 * time.Minute // clients reconnect, re-checking their token
+	streamReplayPage  = 200
+	streamMaxReplay   = 1000 // beyond this a reconnecting client is told to reload
 )
 
 type ActivityHandler struct {
-	Repo *repository.ActivityRepository
+	Service *services.ActivityService
 }
 
-func NewActivityHandler(repo *repository.ActivityRepository) *ActivityHandler {
-	return &ActivityHandler{Repo: repo}
+func NewActivityHandler(svc *services.ActivityService) *ActivityHandler {
+	return &ActivityHandler{Service: svc}
 }
 
-// GET /activity/stats
+// GET /activity/stats?from=2024-05-01&to=2024-05-31&tz=Europe/Berlin
+// from and to are inclusive dates in tz; by default the last 30 days in UTC.
 func (h *ActivityHandler) GetActivityStats(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
 	if !ok {
@@ -24,58 +40,268 @@ func (h *ActivityHandler) GetActivityStats(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	total, err := h.Repo.CountByUser(userID)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	q := r.URL.Query()
+	stats, err := h.Service.Stats(services.ActivityStatsInput{
+		OrganizationID: orgID,
+		UserID:         userID,
+		From:           q.Get("from"),
+		To:             q.Get("to"),
+		Timezone:       q.Get("tz"),
+	})
 	if err != nil {
-		http.Error(w, "Failed to count total activities", http.StatusInternalServerError)
+		writeActivityError(w, err)
 		return
 	}
 
-	today, err := h.Repo.CountTodayByUser(userID)
-	if err != nil {
-		http.Error(w, "Failed to count today activities", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GET /activity?type=apikey_revealed&type=apikey_deleted&entity=apikey&entity_id=7&actor=3
+// GET /activity?team_id=2&from=2025-01-01&to=2025-01-31&q=stripe&limit=50&cursor=...
+// Without team_id it lists your own activities; with it, everything on that
+// team and its shared keys (team owners and admins only). to is exclusive,
+// except that a plain date includes that whole day. The body is a JSON
+// array, newest first; the next page is in the X-Next-Cursor header.
+func (h *ActivityHandler) ListActivities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
 		return
 	}
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
 
-	week, err := h.Repo.CountWeekByUser(userID)
-	if err != nil {
-		http.Error(w, "Failed to count week activities", http.StatusInternalServerError)
+	q := r.URL.Query()
+	in := services.ActivitySearchInput{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Types:          q["type"],
+		Entity:         q.Get("entity"),
+		Query:          q.Get("q"),
+		Cursor:         q.Get("cursor"),
+	}
+
+	for name, dst := range map[string]*uint{"team_id": &in.TeamID, "entity_id": &in.EntityID, "actor": &in.ActorID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 0)
+			if err != nil || id == 0 {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = uint(id)
+		}
+	}
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if in.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if in.From, err = parseTimeParam(q.Get("from")); err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+	if in.To, err = parseTimeParam(q.Get("to")); err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
 		return
 	}
+	if in.To != nil && len(q.Get("to")) == len("2006-01-02") {
+		end := in.To.AddDate(0, 0, 1)
+		in.To = &end
+	}
 
-	stats := map[string]interface{}{
-		"totalActivities":   total,
-		"activitiesToday":   today,
-		"activitiesThisWeek": week,
-		"activitiesThisMonth": total, // Simplified for now
-		"mostActiveDay": "Monday", // Simplified for now
-		"activityTypes": map[string]int{
-			"apikey_created": int(total / 2),
-			"team_created": int(total / 4),
-			"member_added": int(total / 4),
-		},
+	res, err := h.Service.Search(in)
+	if err != nil {
+		writeActivityError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	if res.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", res.NextCursor)
+	}
+	json.NewEncoder(w).Encode(res.Items)
 }
 
-// GET /activity?limit=10&offset=0
-func (h *ActivityHandler) ListActivities(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
-	if !ok {
-		http.Error(w, "user ID not found", http.StatusUnauthorized)
+// GET /dashboard/activity/stream  (text/event-stream)
+// Pushes your activities, and those of the teams you own or administer, as
+// they are committed on any API instance. Each "activity" event carries the
+// activity as JSON, with its seq as the event id; reconnecting with
+// Last-Event-ID (or ?last_event_id=) replays what was missed, and a
+// "reset" event means too much was missed to replay, so reload the list.
+// A comment line goes out every 15s to keep proxies from closing the
+// connection. Like every route it needs the Authorization header, so
+// browsers need a fetch-based EventSource.
+func (h *ActivityHandler) StreamActivities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
 
-	limit := 10
-	offset := 0
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("last_event_id")
+	}
+	var last int64
+	if resume != "" {
+		n, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		last = n
+	}
 
-	activities, err := h.Repo.ListByUser(userID, limit, offset)
+	sub, err := h.Service.Subscribe(orgID, userID)
+	if errors.Is(err, services.ErrFeedUnavailable) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to fetch activities", http.StatusInternalServerError)
+		writeActivityError(w, err)
 		return
 	}
+	defer h.Service.Unsubscribe(sub)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(activities)
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	s := &activityStream{w: w, h: h, sub: sub}
+	if resume == "" {
+		s.last = sub.Since
+	} else {
+		s.last = last
+		if err := s.catchUp(sub.Since); err != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	refresh := time.NewTicker(streamTeamRefresh)
+	defer refresh.Stop()
+	maxAge := time.NewTimer(streamMaxAge)
+	defer maxAge.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-maxAge.C:
+			return
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": keepalive\n\n")
+		case <-refresh.C:
+			if rerr := h.Service.RefreshFeedTeams(sub, orgID); rerr != nil {
+				fmt.Printf("activity stream: refreshing teams: %v\n", rerr)
+			}
+		case a := <-sub.C:
+			err = s.send(&a)
+		}
+		if err == nil && sub.Lagged() {
+			// activities were dropped while this client was slow
+			s.drain()
+			err = s.catchUp(h.Service.FeedHead())
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// activityStream writes one client's events, never the same seq twice.
+type activityStream struct {
+	w    io.Writer
+	h    *ActivityHandler
+	sub  *services.FeedSubscription
+	last int64
+}
+
+func (s *activityStream) send(a *models.Activity) error {
+	if a.Seq <= s.last {
+		return nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "id: %d\nevent: activity\ndata: %s\n\n", a.Seq, b); err != nil {
+		return err
+	}
+	s.last = a.Seq
+	return nil
+}
+
+// catchUp sends what the client missed up to seq through from the
+// database. If that is more than streamMaxReplay it sends a reset instead
+// and carries on from through.
+func (s *activityStream) catchUp(through int64) error {
+	for sent := 0; s.last < through; {
+		if sent >= streamMaxReplay {
+			if _, err := io.WriteString(s.w, "event: reset\ndata: {}\n\n"); err != nil {
+				return err
+			}
+			s.last = through
+			return nil
+		}
+		batch, err := s.h.Service.Replay(s.sub, s.last, through, streamReplayPage)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := s.send(&batch[i]); err != nil {
+				return err
+			}
+		}
+		sent += len(batch)
+		if len(batch) < streamReplayPage {
+			s.last = max(s.last, through)
+			return nil
+		}
+	}
+	return nil
+}
+
+// drain drops what is queued; catchUp reads it again from the database.
+func (s *activityStream) drain() {
+	for {
+		select {
+		case <-s.sub.C:
+		default:
+			return
+		}
+	}
+}
+
+func writeActivityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrTeamActivityForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, utils.ErrInvalidTimezone),
+		errors.Is(err, utils.ErrInvalidCursor),
+		errors.Is(err, services.ErrInvalidStatsRange),
+		errors.Is(err, services.ErrStatsRangeTooLong),
+		errors.Is(err, services.ErrInvalidActivityRange):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to fetch activity", http.StatusInternalServerError)
+	}
 }
@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
 	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
 )
 
 type APIKeyTeamHandler struct {
@@ -28,8 +31,10 @@ func (h *APIKeyTeamHandler) Attach(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.Attach(req.TeamID, req.APIKeyID); err != nil {
-		http.Error(w, fmt.Sprintf("failed to attach: %v", err), http.StatusInternalServerError)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	if err := h.Service.Attach(orgID, userID, req.TeamID, req.APIKeyID); err != nil {
+		writeAPIKeyTeamError(w, "failed to attach", err)
 		return
 	}
 
@@ -45,7 +50,8 @@ func (h *APIKeyTeamHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	teamID, _ := strconv.Atoi(teamIDStr)
-	ats, err := h.Service.ListByTeam(uint(teamID))
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	ats, err := h.Service.ListByTeam(orgID, uint(teamID))
 	if err != nil {
 		http.Error(w, fmt.Sprintf("failed to list: %v", err), http.StatusInternalServerError)
 		return
@@ -65,10 +71,23 @@ func (h *APIKeyTeamHandler) Detach(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.Detach(req.TeamID, req.APIKeyID); err != nil {
-		http.Error(w, fmt.Sprintf("failed to detach: %v", err), http.StatusInternalServerError)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	if err := h.Service.Detach(orgID, userID, req.TeamID, req.APIKeyID); err != nil {
+		writeAPIKeyTeamError(w, "failed to detach", err)
 		return
 	}
 
 	fmt.Fprintln(w, "detached successfully")
 }
+
+func writeAPIKeyTeamError(w http.ResponseWriter, prefix string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrCannotManageKey), errors.Is(err, services.ErrNotTeamManager):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, fmt.Sprintf("%s: %v", prefix, err), http.StatusInternalServerError)
+	}
+}
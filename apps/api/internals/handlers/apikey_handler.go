@@ -2,9 +2,15 @@ package handlers
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
+    "strconv"
+    "strings"
+    "time"
+
     "github.com/intojhanurag/One-Password/apps/api/internals/services"
     "github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+    "gorm.io/gorm"
 )
 
 type APIKeyHandler struct {
@@ -23,14 +29,23 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 
     var req struct {
         Name string `json:"name"`
+        Path string `json:"path"` // optional, e.g. "payments/stripe/live"
         Key string `json:"key"` 
         Description string `json:"description"`
         Tags string `json:"tags"`
+        RequiresApproval bool `json:"requires_approval"`
+        ExpiresAt *time.Time `json:"expires_at"`
+        RotateEvery string `json:"rotate_every"` // e.g. "90d"
     }
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, "invalid input", http.StatusBadRequest)
         return
     }
+    rotateDays, err := parseRotateEvery(req.RotateEvery)
+    if err != nil {
+        http.Error(w, "invalid rotate_every", http.StatusBadRequest)
+        return
+    }
 
     uid := r.Context().Value(middleware.UserIDKey)
 	if uid == nil {
@@ -38,16 +53,25 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ownerID := uid.(uint)
-    
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
 
 
-    res, err := h.Service.Create(services.CreateAPIKeyInput{
+    res, err := h.Service.WithContext(r.Context()).Create(services.CreateAPIKeyInput{
         Name: req.Name,
+        Path: req.Path,
         Key: req.Key,
         Description: req.Description,
         Tags: req.Tags,
+        RequiresApproval: req.RequiresApproval,
+        ExpiresAt: req.ExpiresAt,
+        RotateEveryDays: rotateDays,
+        OrganizationID: orgID,
         OwnerID: ownerID,
     })
+    if errors.Is(err, services.ErrPathTaken) {
+        http.Error(w, err.Error(), http.StatusConflict)
+        return
+    }
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
@@ -58,6 +82,11 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 
+// GET /apikeys/list?q=stripe&tag=env:prod&tags=vendor:stripe OR vendor:adyen&team_id=3
+//     &created_after=2025-01-01&created_before=...&expiring_within=30d
+//     &sort=-created_at|name|updated_at|expires_at|relevance&limit=50&cursor=...
+// The body stays a JSON array; paging is reported in the X-Total-Count and
+// X-Next-Cursor headers.
 func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
     uid := r.Context().Value(middleware.UserIDKey)
     if uid == nil {
@@ -66,19 +95,112 @@ func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
     }
     ownerID := uid.(uint)
 
-    keys, err := h.Service.List(ownerID)
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+    q := r.URL.Query()
+    in := services.KeySearchInput{
+        OrganizationID: orgID,
+        UserID: ownerID,
+        Query: q.Get("q"),
+        Tags: q["tag"],
+        TagExpr: q.Get("tags"),
+        Sort: q.Get("sort"),
+        Cursor: q.Get("cursor"),
+    }
+
+    var err error
+    if v := q.Get("team_id"); v != "" {
+        teamID, err := strconv.Atoi(v)
+        if err != nil || teamID <= 0 {
+            http.Error(w, "invalid team_id", http.StatusBadRequest)
+            return
+        }
+        in.TeamID = uint(teamID)
+    }
+    if v := q.Get("limit"); v != "" {
+        if in.Limit, err = strconv.Atoi(v); err != nil {
+            http.Error(w, "invalid limit", http.StatusBadRequest)
+            return
+        }
+    }
+    if in.CreatedAfter, err = parseTimeParam(q.Get("created_after")); err != nil {
+        http.Error(w, "invalid created_after", http.StatusBadRequest)
+        return
+    }
+    if in.CreatedBefore, err = parseTimeParam(q.Get("created_before")); err != nil {
+        http.Error(w, "invalid created_before", http.StatusBadRequest)
+        return
+    }
+    if v := q.Get("expiring_within"); v != "" {
+        if in.ExpiringWithin, err = parseDurationParam(v); err != nil {
+            http.Error(w, "invalid expiring_within", http.StatusBadRequest)
+            return
+        }
+    }
+
+    res, err := h.Service.WithContext(r.Context()).Search(in)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
 
-  
-    json.NewEncoder(w).Encode(keys)
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("X-Total-Count", strconv.FormatInt(res.Total, 10))
+    if res.NextCursor != "" {
+        w.Header().Set("X-Next-Cursor", res.NextCursor)
+    }
+    json.NewEncoder(w).Encode(res.Items)
+}
+
+// parseTimeParam accepts RFC 3339 timestamps or plain YYYY-MM-DD dates.
+func parseTimeParam(v string) (*time.Time, error) {
+    if v == "" {
+        return nil, nil
+    }
+    t, err := time.Parse(time.RFC3339, v)
+    if err != nil {
+        if t, err = time.Parse("2006-01-02", v); err != nil {
+            return nil, err
+        }
+    }
+    return &t, nil
+}
+
+// parseDurationParam accepts Go durations ("72h") and whole days ("30d").
+func parseDurationParam(v string) (time.Duration, error) {
+    if strings.HasSuffix(v, "d") {
+        days, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+        if err != nil || days < 0 {
+            return 0, errors.New("invalid day count")
+        }
+        return time.Duration(days) * 24 * time.Hour, nil
+    }
+    return time.ParseDuration(v)
+}
+
+// parseRotateEvery turns a rotation interval into whole days, rounding up;
+// an empty value means no rotation schedule.
+func parseRotateEvery(v string) (int, error) {
+    if v == "" {
+        return 0, nil
+    }
+    d, err := parseDurationParam(v)
+    if err != nil || d < 0 {
+        return 0, errors.New("invalid rotation interval")
+    }
+    day := 24 * time.Hour
+    return int((d + day - 1) / day), nil
 }
 
 
+// GET /apikeys/reveal?name=payments/stripe   (own keys, by full path)
+// GET /apikeys/reveal?id=7&reason=incident   (own or team-shared keys)
 func (h *APIKeyHandler) RevealByName(w http.ResponseWriter, r *http.Request) {
-    
+    if idStr := r.URL.Query().Get("id"); idStr != "" {
+        h.revealByID(w, r, idStr)
+        return
+    }
+
     name:=r.URL.Query().Get("name")
 
     if name==""{
@@ -91,18 +213,20 @@ func (h *APIKeyHandler) RevealByName(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "unauthorized", http.StatusUnauthorized)
         return
     }
-    plaintext, err:=h.Service.GetByName(uid.(uint),name)
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+    res, err:=h.Service.WithContext(r.Context()).GetByPath(orgID, uid.(uint),name)
     if err != nil {
         http.Error(w, "not found or unauthorized", http.StatusNotFound)
         return
     }
-    json.NewEncoder(w).Encode(map[string]string{
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(withLease(w, map[string]interface{}{
         "name": name,
-        "key": plaintext,
-    })
+        "key": res.Plaintext,
+    }, res.Lease))
 }
 
-
+// DELETE /apikeys/delete?name=payments/stripe   (own keys, by full path)
 func (h *APIKeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
     name:=r.URL.Query().Get("name")
     if name==""{
@@ -114,7 +238,8 @@ func (h *APIKeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "unauthorized", http.StatusUnauthorized)
         return
     }
-    err:=h.Service.DeleteByName(uid.(uint),name)
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+    err:=h.Service.WithContext(r.Context()).DeleteByPath(orgID, uid.(uint),name)
     if err != nil {
         http.Error(w, "not found or unauthorized", http.StatusNotFound)
         return
@@ -122,4 +247,151 @@ func (h *APIKeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(map[string]string{
         "message": "key deleted",
     })
-}
\ No newline at end of file
+}
+
+func (h *APIKeyHandler) revealByID(w http.ResponseWriter, r *http.Request, idStr string) {
+    id, err := strconv.Atoi(idStr)
+    if err != nil || id <= 0 {
+        http.Error(w, "invalid id", http.StatusBadRequest)
+        return
+    }
+
+    uid := r.Context().Value(middleware.UserIDKey)
+    if uid == nil {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+    res, err := h.Service.WithContext(r.Context()).RevealByID(orgID, uid.(uint), uint(id), r.URL.Query().Get("reason"))
+    if err != nil {
+        http.Error(w, "not found or unauthorized", http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    if res.PendingRequest != nil {
+        w.WriteHeader(http.StatusAccepted)
+        json.NewEncoder(w).Encode(map[string]interface{}{
+            "message": "approval required",
+            "request": res.PendingRequest,
+        })
+        return
+    }
+    json.NewEncoder(w).Encode(withLease(w, map[string]interface{}{
+        "id": id,
+        "key": res.Plaintext,
+    }, res.Lease))
+}
+
+// POST /apikeys/approval  {"name": "payments/stripe", "requires_approval": true}
+// name is the full path of one of the user's own keys.
+func (h *APIKeyHandler) SetApproval(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        Name string `json:"name"`
+        RequiresApproval bool `json:"requires_approval"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+        http.Error(w, "invalid input", http.StatusBadRequest)
+        return
+    }
+
+    uid := r.Context().Value(middleware.UserIDKey)
+    if uid == nil {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+    if err := h.Service.WithContext(r.Context()).SetRequiresApproval(orgID, uid.(uint), req.Name, req.RequiresApproval); err != nil {
+        http.Error(w, "not found or unauthorized", http.StatusNotFound)
+        return
+    }
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "name": req.Name,
+        "requires_approval": req.RequiresApproval,
+    })
+}
+
+// POST /apikeys/break-glass  {"name": "payments/stripe", "enabled": true}
+// name is the full path of one of the user's own keys.
+func (h *APIKeyHandler) SetBreakGlass(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        Name string `json:"name"`
+        Enabled bool `json:"enabled"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+        http.Error(w, "invalid input", http.StatusBadRequest)
+        return
+    }
+
+    uid := r.Context().Value(middleware.UserIDKey)
+    if uid == nil {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+    if err := h.Service.WithContext(r.Context()).SetBreakGlassEnabled(orgID, uid.(uint), req.Name, req.Enabled); err != nil {
+        http.Error(w, "not found or unauthorized", http.StatusNotFound)
+        return
+    }
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "name": req.Name,
+        "break_glass_enabled": req.Enabled,
+    })
+}
+
+// POST /apikeys/lifecycle  {"id": 7, "expires_at": "2026-01-31T00:00:00Z", "rotate_every": "90d"}
+// Omitting expires_at clears the expiry; "rotate_every": "" clears the schedule.
+func (h *APIKeyHandler) SetLifecycle(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost && r.Method != http.MethodPut {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        ID uint `json:"id"`
+        ExpiresAt *time.Time `json:"expires_at"`
+        RotateEvery string `json:"rotate_every"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+        http.Error(w, "invalid input", http.StatusBadRequest)
+        return
+    }
+    rotateDays, err := parseRotateEvery(req.RotateEvery)
+    if err != nil {
+        http.Error(w, "invalid rotate_every", http.StatusBadRequest)
+        return
+    }
+
+    userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+    if err := h.Service.WithContext(r.Context()).SetLifecycle(orgID, userID, req.ID, req.ExpiresAt, rotateDays); err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            http.Error(w, "not found or unauthorized", http.StatusNotFound)
+            return
+        }
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "id": req.ID,
+        "expires_at": req.ExpiresAt,
+        "rotate_every_days": rotateDays,
+    })
+}
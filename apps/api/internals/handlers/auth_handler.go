@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/intojhanurag/One-Password/apps/api/internals/config"
 	"github.com/intojhanurag/One-Password/apps/api/internals/services"
@@ -17,6 +19,7 @@ type signupRequest struct {
 	FullName string `json:"fullName"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	InviteToken string `json:"inviteToken"`
 }
 
 type signupResponse struct {
@@ -42,10 +45,11 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := h.Service.Signup(h.Cfg.JWTSecret, h.Cfg.JWTExpiresMin, services.SignupInput{
+	res, err := h.Service.WithContext(r.Context()).Signup(h.Cfg.JWTSecret, h.Cfg.JWTExpiresMin, services.SignupInput{
 		FullName: req.FullName,
 		Email:    req.Email,
 		Password: req.Password,
+		InviteToken: req.InviteToken,
 	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -75,7 +79,7 @@ func (h *AuthHandler) Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := h.Service.Signin(h.Cfg.JWTSecret, h.Cfg.JWTExpiresMin, input)
+	res, err := h.Service.WithContext(r.Context()).Signin(h.Cfg.JWTSecret, h.Cfg.JWTExpiresMin, input)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
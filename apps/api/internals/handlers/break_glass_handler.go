@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type BreakGlassHandler struct {
+	Service *services.BreakGlassService
+}
+
+func NewBreakGlassHandler(s *services.BreakGlassService) *BreakGlassHandler {
+	return &BreakGlassHandler{Service: s}
+}
+
+// POST /break-glass  {"api_key_id": 7, "justification": "...", "minutes": 30}
+func (h *BreakGlassHandler) Invoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		APIKeyID      uint   `json:"api_key_id"`
+		Justification string `json:"justification"`
+		Minutes       int    `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.APIKeyID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	grant, err := h.Service.WithContext(r.Context()).Invoke(services.InvokeBreakGlassInput{
+		OrganizationID: orgID,
+		UserID:         userID,
+		APIKeyID:       req.APIKeyID,
+		Justification:  req.Justification,
+		Minutes:        req.Minutes,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "api key not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrBreakGlassDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(grant)
+}
+
+// GET /break-glass/reveal?id=3
+func (h *BreakGlassHandler) Reveal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	res, err := h.Service.WithContext(r.Context()).Reveal(orgID, userID, uint(id))
+	if err != nil {
+		http.Error(w, "no active break-glass access", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withLease(w, map[string]interface{}{
+		"break_glass_id": id,
+		"key":            res.Plaintext,
+	}, res.Lease))
+}
+
+// POST /break-glass/acknowledge  {"id": 3, "note": "post-incident review done"}
+func (h *BreakGlassHandler) Acknowledge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ID   uint   `json:"id"`
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	grant, err := h.Service.WithContext(r.Context()).Acknowledge(orgID, userID, req.ID, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "break-glass event not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrNotApprover):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(grant)
+}
+
+// GET /break-glass/list?scope=mine|pending-ack
+func (h *BreakGlassHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		grants interface{}
+		err    error
+	)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	switch r.URL.Query().Get("scope") {
+	case "", "mine":
+		grants, err = h.Service.WithContext(r.Context()).ListMine(orgID, userID)
+	case "pending-ack":
+		grants, err = h.Service.WithContext(r.Context()).ListPendingAcknowledgment(orgID, userID)
+	default:
+		http.Error(w, "scope must be mine or pending-ack", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to list break-glass events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(grants)
+}
@@ -21,7 +21,8 @@ func (h *DashboardHandler) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "user ID not found", http.StatusUnauthorized)
 		return
 	}
-    data, err := h.service.GetDashboard(userID)
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+    data, err := h.service.GetDashboard(orgID, userID)
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
@@ -37,7 +38,8 @@ func (h* DashboardHandler) GetTeamsDashboard(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-    data,err :=h.service.GetTeamDashboard(userID)
+    orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+    data,err :=h.service.GetTeamDashboard(orgID, userID)
 
     if err!=nil {
         http.Error(w,"failed to fetch dashboard data",http.StatusInternalServerError)
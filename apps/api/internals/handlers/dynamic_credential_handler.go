@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/dynamic"
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type DynamicCredentialHandler struct {
+	Service *services.DynamicCredentialService
+}
+
+func NewDynamicCredentialHandler(s *services.DynamicCredentialService) *DynamicCredentialHandler {
+	return &DynamicCredentialHandler{Service: s}
+}
+
+// GET    /dynamic/roles
+// POST   /dynamic/roles  {"name": "readonly", "engine": "postgres", "config": {"dsn": "postgres://..."},
+//
+//	"creation_statements": "...", "ttl": "1h", "max_ttl": "24h", "team_id": 3}
+//
+// DELETE /dynamic/roles?name=readonly
+func (h *DynamicCredentialHandler) Roles(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		roles, err := h.Service.WithContext(r.Context()).ListRoles(orgID, userID)
+		if err != nil {
+			writeDynamicError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(roles)
+
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Name                 string          `json:"name"`
+			Engine               string          `json:"engine"`
+			Config               json.RawMessage `json:"config"`
+			CreationStatements   string          `json:"creation_statements"`
+			RenewalStatements    string          `json:"renewal_statements"`
+			RevocationStatements string          `json:"revocation_statements"`
+			TTL                  string          `json:"ttl"`
+			MaxTTL               string          `json:"max_ttl"`
+			TeamID               *uint           `json:"team_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		ttl, ok := optionalDuration(w, "ttl", req.TTL)
+		if !ok {
+			return
+		}
+		maxTTL, ok := optionalDuration(w, "max_ttl", req.MaxTTL)
+		if !ok {
+			return
+		}
+		role, err := h.Service.WithContext(r.Context()).SaveRole(orgID, userID, services.DynamicRoleInput{
+			Name:   req.Name,
+			Engine: req.Engine,
+			Config: req.Config,
+			Statements: dynamic.Statements{
+				Creation:   req.CreationStatements,
+				Renewal:    req.RenewalStatements,
+				Revocation: req.RevocationStatements,
+			},
+			DefaultTTL: ttl,
+			MaxTTL:     maxTTL,
+			TeamID:     req.TeamID,
+		})
+		if err != nil {
+			writeDynamicError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(role)
+
+	case http.MethodDelete:
+		if err := h.Service.WithContext(r.Context()).DeleteRole(orgID, userID, r.URL.Query().Get("name")); err != nil {
+			writeDynamicError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// POST /dynamic/creds  {"role": "readonly", "ttl": "30m"}
+func (h *DynamicCredentialHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		Role string `json:"role"`
+		TTL  string `json:"ttl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	ttl, ok := optionalDuration(w, "ttl", req.TTL)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := targetContext(r)
+	defer cancel()
+	creds, err := h.Service.WithContext(r.Context()).Issue(ctx, orgID, userID, req.Role, ttl)
+	if err != nil {
+		writeDynamicError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(creds)
+}
+
+// GET /dynamic/leases — the caller's active leases
+func (h *DynamicCredentialHandler) Leases(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	leases, err := h.Service.WithContext(r.Context()).ListLeases(orgID, userID)
+	if err != nil {
+		writeDynamicError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leases)
+}
+
+// POST /dynamic/leases/renew  {"lease_id": "...", "increment": "1h"}
+func (h *DynamicCredentialHandler) Renew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		LeaseID   string `json:"lease_id"`
+		Increment string `json:"increment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LeaseID == "" {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	inc, ok := optionalDuration(w, "increment", req.Increment)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := targetContext(r)
+	defer cancel()
+	lease, err := h.Service.WithContext(r.Context()).Renew(ctx, orgID, userID, req.LeaseID, inc)
+	if err != nil {
+		writeDynamicError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lease)
+}
+
+// POST /dynamic/leases/revoke  {"lease_id": "..."}
+func (h *DynamicCredentialHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		LeaseID string `json:"lease_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LeaseID == "" {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := targetContext(r)
+	defer cancel()
+	if err := h.Service.WithContext(r.Context()).Revoke(ctx, orgID, userID, req.LeaseID); err != nil {
+		writeDynamicError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// targetContext is detached from the client connection, for at most two
+// minutes, so a change made at a target system is always seen through and
+// recorded even if the client goes away.
+func targetContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), 2*time.Minute)
+}
+
+func optionalDuration(w http.ResponseWriter, name, v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, true
+	}
+	d, err := parseDurationParam(v)
+	if err != nil || d < 0 {
+		http.Error(w, "invalid "+name, http.StatusBadRequest)
+		return 0, false
+	}
+	return d, true
+}
+
+func writeDynamicError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNotOrgMember),
+		errors.Is(err, services.ErrNotOrgAdmin),
+		errors.Is(err, services.ErrDynamicRoleDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrRoleHasLeases),
+		errors.Is(err, services.ErrLeaseNotActive),
+		errors.Is(err, services.ErrLeaseInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type ItemHandler struct {
+	Service *services.ItemService
+}
+
+func NewItemHandler(s *services.ItemService) *ItemHandler {
+	return &ItemHandler{Service: s}
+}
+
+// GET /items/types — field schema of every item type
+func (h *ItemHandler) Types(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Service.WithContext(r.Context()).Types())
+}
+
+// Items dispatches /items: GET ?type=login lists my items, POST creates one:
+// {"type": "login", "name": "Admin console", "fields": {"username": {"value": "ops"}, "password": {"value": "..."}}}
+func (h *ItemHandler) Items(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.Service.WithContext(r.Context()).List(orgID, userID, r.URL.Query().Get("type"))
+		if err != nil {
+			writeItemError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	case http.MethodPost:
+		var req struct {
+			Type        string                             `json:"type"`
+			Name        string                             `json:"name"`
+			Description string                             `json:"description"`
+			Fields      map[string]services.ItemFieldInput `json:"fields"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		item, err := h.Service.WithContext(r.Context()).Create(services.CreateItemInput{
+			OrganizationID: orgID,
+			OwnerID:        userID,
+			Type:           req.Type,
+			Name:           req.Name,
+			Description:    req.Description,
+			Fields:         req.Fields,
+		})
+		if err != nil {
+			writeItemError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(item)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /items/get?id=1 — concealed fields are listed without their values
+func (h *ItemHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	item, err := h.Service.WithContext(r.Context()).Get(orgID, userID, id)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// PUT /items/update  {"id": 1, "name": "...", "fields": {"password": {"value": "..."}}}
+func (h *ItemHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		ID          uint                               `json:"id"`
+		Name        *string                            `json:"name"`
+		Description *string                            `json:"description"`
+		Fields      map[string]services.ItemFieldInput `json:"fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.Service.WithContext(r.Context()).Update(services.UpdateItemInput{
+		OrganizationID: orgID,
+		OwnerID:        userID,
+		ID:             req.ID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Fields:         req.Fields,
+	})
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// GET /items/reveal?id=1&field=password&reason=...
+func (h *ItemHandler) Reveal(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		http.Error(w, "missing field", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.WithContext(r.Context()).RevealField(orgID, userID, id, field, r.URL.Query().Get("reason"))
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.PendingRequest != nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "approval required",
+			"request": res.PendingRequest,
+		})
+		return
+	}
+	// api_key items are leased like /apikeys/reveal; other fields are not
+	json.NewEncoder(w).Encode(withLease(w, map[string]interface{}{
+		"id":    id,
+		"field": field,
+		"value": res.Plaintext,
+	}, res.Lease))
+}
+
+// DELETE /items/delete?id=1
+func (h *ItemHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.Service.WithContext(r.Context()).Delete(orgID, userID, id); err != nil {
+		writeItemError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "item deleted"})
+}
+
+func writeItemError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrAPIKeyItemManaged):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrPathTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type OrganizationHandler struct {
+	Service *services.OrganizationService
+}
+
+func NewOrganizationHandler(s *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{Service: s}
+}
+
+// Orgs dispatches /orgs: GET lists my organizations, POST creates one
+func (h *OrganizationHandler) Orgs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		orgs, err := h.Service.WithContext(r.Context()).ListMine(userID)
+		if err != nil {
+			http.Error(w, "failed to list organizations", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orgs)
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		org, err := h.Service.WithContext(r.Context()).Create(userID, req.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(org)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /orgs/members — member directory of the current organization
+func (h *OrganizationHandler) Members(w http.ResponseWriter, r *http.Request) {
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	members, err := h.Service.WithContext(r.Context()).Directory(orgID)
+	if err != nil {
+		http.Error(w, "failed to list members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// POST /orgs/members/add  {"email": "dev@example.com", "role": "member"}
+func (h *OrganizationHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.Service.WithContext(r.Context()).AddMember(userID, orgID, req.Email, req.Role)
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// POST /orgs/members/role  {"user_id": 2, "role": "admin"}
+func (h *OrganizationHandler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		UserID uint   `json:"user_id"`
+		Role   string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.WithContext(r.Context()).UpdateMemberRole(userID, orgID, req.UserID, req.Role); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "role updated"})
+}
+
+// DELETE /orgs/members/remove?user_id=2
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	memberID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || memberID <= 0 {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.WithContext(r.Context()).RemoveMember(userID, orgID, uint(memberID)); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "member removed"})
+}
+
+// Settings handles GET and PUT /orgs/settings for the current organization
+func (h *OrganizationHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		org, err := h.Service.WithContext(r.Context()).GetSettings(orgID)
+		if err != nil {
+			writeOrgError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(org)
+	case http.MethodPut:
+		var in services.OrgSettingsInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		org, err := h.Service.WithContext(r.Context()).UpdateSettings(userID, orgID, in)
+		if err != nil {
+			writeOrgError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(org)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeOrgError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNotOrgMember), errors.Is(err, services.ErrNotOrgAdmin), errors.Is(err, services.ErrNotOrgOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type ProjectHandler struct {
+	Service *services.ProjectService
+}
+
+func NewProjectHandler(s *services.ProjectService) *ProjectHandler {
+	return &ProjectHandler{Service: s}
+}
+
+// Projects dispatches /projects: GET lists the org's projects, POST creates one
+func (h *ProjectHandler) Projects(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		projects, err := h.Service.WithContext(r.Context()).ListProjects(orgID, userID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(projects)
+	case http.MethodPost:
+		var req struct {
+			Name         string   `json:"name"`
+			Description  string   `json:"description"`
+			Environments []string `json:"environments"` // defaults to dev, staging, prod
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		p, err := h.Service.WithContext(r.Context()).CreateProject(services.CreateProjectInput{
+			OrganizationID: orgID,
+			UserID:         userID,
+			Name:           req.Name,
+			Description:    req.Description,
+			Environments:   req.Environments,
+		})
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /projects/get?id=1 — project with my access level on each environment
+func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	projectID, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	p, err := h.Service.WithContext(r.Context()).GetProject(orgID, userID, projectID)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// POST /projects/environments  {"project_id": 1, "name": "qa"}
+func (h *ProjectHandler) AddEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		ProjectID uint   `json:"project_id"`
+		Name      string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ProjectID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	env, err := h.Service.WithContext(r.Context()).AddEnvironment(orgID, userID, req.ProjectID, req.Name)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(env)
+}
+
+// Permissions handles /environments/permissions: GET ?env_id= lists team grants,
+// POST {"env_id": 1, "team_id": 2, "access": "read|write|none"} sets one
+func (h *ProjectHandler) Permissions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		envID, ok := queryID(w, r, "env_id")
+		if !ok {
+			return
+		}
+		perms, err := h.Service.WithContext(r.Context()).ListPermissions(orgID, userID, envID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(perms)
+	case http.MethodPost:
+		var req struct {
+			EnvID  uint   `json:"env_id"`
+			TeamID uint   `json:"team_id"`
+			Access string `json:"access"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.EnvID == 0 || req.TeamID == 0 {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		if err := h.Service.WithContext(r.Context()).SetPermission(orgID, userID, req.EnvID, req.TeamID, req.Access); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "permission updated"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Secrets handles /environments/secrets: GET ?env_id= lists names and
+// fingerprints, POST {"env_id", "name", "value", "description"} sets a value,
+// DELETE ?env_id=&name= removes it
+func (h *ProjectHandler) Secrets(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		envID, ok := queryID(w, r, "env_id")
+		if !ok {
+			return
+		}
+		secrets, err := h.Service.WithContext(r.Context()).ListSecrets(orgID, userID, envID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(secrets)
+	case http.MethodPost:
+		var req struct {
+			EnvID       uint   `json:"env_id"`
+			Name        string `json:"name"`
+			Value       string `json:"value"`
+			Description string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.EnvID == 0 {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		secret, err := h.Service.WithContext(r.Context()).SetSecret(services.SetSecretInput{
+			OrganizationID: orgID,
+			UserID:         userID,
+			EnvironmentID:  req.EnvID,
+			Name:           req.Name,
+			Value:          req.Value,
+			Description:    req.Description,
+		})
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(secret)
+	case http.MethodDelete:
+		envID, ok := queryID(w, r, "env_id")
+		if !ok {
+			return
+		}
+		if err := h.Service.WithContext(r.Context()).DeleteSecret(orgID, userID, envID, r.URL.Query().Get("name")); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "secret deleted"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /environments/secrets/reveal?env_id=1&name=STRIPE_KEY
+func (h *ProjectHandler) Reveal(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	envID, ok := queryID(w, r, "env_id")
+	if !ok {
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name", http.StatusBadRequest)
+		return
+	}
+
+	value, err := h.Service.WithContext(r.Context()).RevealSecret(orgID, userID, envID, name)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"name": name, "value": value})
+}
+
+// GET /environments/compare?source=1&target=2 — differences by fingerprint, never by value
+func (h *ProjectHandler) Compare(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	source, ok := queryID(w, r, "source")
+	if !ok {
+		return
+	}
+	target, ok := queryID(w, r, "target")
+	if !ok {
+		return
+	}
+
+	cmp, err := h.Service.WithContext(r.Context()).Compare(orgID, userID, source, target)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cmp)
+}
+
+// queryID parses a positive id from the query string, writing a 400 if it is invalid.
+func queryID(w http.ResponseWriter, r *http.Request, param string) (uint, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(param))
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid "+param, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeProjectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrEnvironmentForbidden),
+		errors.Is(err, services.ErrNotProjectAdmin),
+		errors.Is(err, services.ErrNotOrgMember):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
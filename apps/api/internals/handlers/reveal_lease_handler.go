@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type RevealLeaseHandler struct {
+	Service *services.RevealLeaseService
+}
+
+func NewRevealLeaseHandler(s *services.RevealLeaseService) *RevealLeaseHandler {
+	return &RevealLeaseHandler{Service: s}
+}
+
+// withLease adds lease metadata to a reveal response and tells HTTP caches
+// the same thing: the value may be kept privately until the lease ends.
+func withLease(w http.ResponseWriter, body map[string]interface{}, lease *models.RevealLease) map[string]interface{} {
+	if lease == nil {
+		w.Header().Set("Cache-Control", "no-store")
+		return body
+	}
+	ttl := int(time.Until(lease.ExpiresAt) / time.Second)
+	if ttl < 0 {
+		ttl = 0
+	}
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(ttl))
+	body["lease_id"] = lease.LeaseID
+	body["lease_duration"] = ttl
+	body["renewable"] = lease.Renewable
+	body["version"] = lease.Version
+	body["expires_at"] = lease.ExpiresAt
+	return body
+}
+
+// GET /apikeys/leases?lease_id=... — is a cached value still good?
+func (h *RevealLeaseHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	st, err := h.Service.WithContext(r.Context()).Lookup(orgID, userID, r.URL.Query().Get("lease_id"))
+	if err != nil {
+		writeLeaseError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// POST /apikeys/leases/renew  {"lease_id": "...", "increment": "1h"}
+func (h *RevealLeaseHandler) Renew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		LeaseID   string `json:"lease_id"`
+		Increment string `json:"increment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LeaseID == "" {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	inc, ok := optionalDuration(w, "increment", req.Increment)
+	if !ok {
+		return
+	}
+
+	lease, err := h.Service.WithContext(r.Context()).Renew(orgID, userID, req.LeaseID, inc)
+	if err != nil {
+		writeLeaseError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withLease(w, map[string]interface{}{"api_key_id": lease.APIKeyID}, lease))
+}
+
+// POST /apikeys/leases/revoke  {"lease_id": "..."}   one lease
+// POST /apikeys/leases/revoke  {"api_key_id": 7}     every lease on a key you manage
+func (h *RevealLeaseHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		LeaseID  string `json:"lease_id"`
+		APIKeyID uint   `json:"api_key_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.LeaseID == "") == (req.APIKeyID == 0) {
+		http.Error(w, "give either lease_id or api_key_id", http.StatusBadRequest)
+		return
+	}
+
+	if req.LeaseID != "" {
+		if err := h.Service.WithContext(r.Context()).Revoke(orgID, userID, req.LeaseID); err != nil {
+			writeLeaseError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	n, err := h.Service.WithContext(r.Context()).RevokeKey(orgID, userID, req.APIKeyID)
+	if err != nil {
+		writeLeaseError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": n})
+}
+
+// An invalid lease answers 410 Gone: the client should drop its cached
+// value and reveal the secret again.
+func writeLeaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrRevealLeaseInvalid):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, services.ErrLeaseNotRenewable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
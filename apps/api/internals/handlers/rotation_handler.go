@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/rotation"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type RotationHandler struct {
+	Service *services.RotationService
+}
+
+func NewRotationHandler(s *services.RotationService) *RotationHandler {
+	return &RotationHandler{Service: s}
+}
+
+// GET /rotation/types
+func (h *RotationHandler) Types(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rotation.Types())
+}
+
+// GET /apikeys/rotation?id=7
+// PUT /apikeys/rotation  {"id": 7, "type": "webhook", "config": {"url": "https://..."},
+//
+//	"rotate_every": "30d", "max_attempts": 3, "enabled": true}
+func (h *RotationHandler) Policy(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		id, ok := queryID(w, r, "id")
+		if !ok {
+			return
+		}
+		p, err := h.Service.WithContext(r.Context()).GetPolicy(orgID, userID, id)
+		if err != nil {
+			writeRotationError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			ID          uint            `json:"id"`
+			Type        string          `json:"type"`
+			Config      json.RawMessage `json:"config"`
+			RotateEvery string          `json:"rotate_every"`
+			MaxAttempts int             `json:"max_attempts"`
+			Enabled     *bool           `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 || req.Type == "" {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		days, err := parseRotateEvery(req.RotateEvery)
+		if err != nil {
+			http.Error(w, "invalid rotate_every", http.StatusBadRequest)
+			return
+		}
+		enabled := req.Enabled == nil || *req.Enabled
+		p, err := h.Service.WithContext(r.Context()).Configure(orgID, userID, req.ID, services.RotationPolicyInput{
+			Type:        req.Type,
+			Config:      req.Config,
+			EveryDays:   days,
+			MaxAttempts: req.MaxAttempts,
+			Enabled:     enabled,
+		})
+		if err != nil {
+			writeRotationError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// POST /apikeys/rotation/run  {"id": 7}
+func (h *RotationHandler) RotateNow(w http.ResponseWriter, r *http.Request) {
+	h.runOperation(w, r, h.Service.WithContext(r.Context()).RotateNow)
+}
+
+// POST /apikeys/rotation/rollback  {"id": 7}
+func (h *RotationHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	h.runOperation(w, r, h.Service.WithContext(r.Context()).Rollback)
+}
+
+// GET /apikeys/rotation/history?id=7
+func (h *RotationHandler) History(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	hist, err := h.Service.WithContext(r.Context()).History(orgID, userID, id)
+	if err != nil {
+		writeRotationError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hist)
+}
+
+// runOperation decodes {"id": ...} and runs a rotation or rollback. A failed
+// operation still returns its run record, with 502 since the target system
+// rejected it.
+func (h *RotationHandler) runOperation(w http.ResponseWriter, r *http.Request, op func(ctx context.Context, orgID, userID, keyID uint) (*models.RotationRun, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	ctx, cancel := targetContext(r)
+	defer cancel()
+
+	run, err := op(ctx, orgID, userID, req.ID)
+	if err != nil && !errors.Is(err, services.ErrRotationFailed) {
+		writeRotationError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	json.NewEncoder(w).Encode(run)
+}
+
+func writeRotationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrNoRotationPolicy):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrNotOrgMember), errors.Is(err, services.ErrNotOrgAdmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrRotationInProgress), errors.Is(err, services.ErrNoPreviousVersion):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type SecretPathHandler struct {
+	Service *services.SecretPathService
+}
+
+func NewSecretPathHandler(s *services.SecretPathService) *SecretPathHandler {
+	return &SecretPathHandler{Service: s}
+}
+
+// Secrets handles /secrets: GET ?folder=payments&recursive=true lists a folder,
+// PUT {"path": "payments/stripe/live", "value": "...", "description": "..."} sets a secret
+func (h *SecretPathHandler) Secrets(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		listing, err := h.Service.WithContext(r.Context()).List(orgID, userID, q.Get("folder"), q.Get("recursive") == "true")
+		if err != nil {
+			writePathError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(listing)
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Path        string `json:"path"`
+			Value       string `json:"value"`
+			Description string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		entry, err := h.Service.WithContext(r.Context()).Put(services.PutPathSecretInput{
+			OrganizationID: orgID,
+			UserID:         userID,
+			Path:           req.Path,
+			Value:          req.Value,
+			Description:    req.Description,
+		})
+		if err != nil {
+			writePathError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /secrets/reveal?path=payments/stripe/live&reason=...
+func (h *SecretPathHandler) Reveal(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "missing path", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.WithContext(r.Context()).Reveal(orgID, userID, path, r.URL.Query().Get("reason"))
+	if err != nil {
+		writePathError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if res.PendingRequest != nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "approval required",
+			"request": res.PendingRequest,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(withLease(w, map[string]interface{}{
+		"path":  path,
+		"value": res.Plaintext,
+	}, res.Lease))
+}
+
+// POST /secrets/move  {"from": "payments/stripe", "to": "billing/stripe"}
+func (h *SecretPathHandler) Move(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	moved, err := h.Service.WithContext(r.Context()).Move(orgID, userID, req.From, req.To)
+	if err != nil {
+		writePathError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"moved": moved})
+}
+
+// Grants handles /secrets/grants: GET ?folder= lists effective team grants,
+// POST {"folder": "payments", "team_id": 2, "access": "read|write|none"} sets one
+func (h *SecretPathHandler) Grants(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		grants, err := h.Service.WithContext(r.Context()).ListGrants(orgID, userID, r.URL.Query().Get("folder"))
+		if err != nil {
+			writePathError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(grants)
+	case http.MethodPost:
+		var req struct {
+			Folder string `json:"folder"`
+			TeamID uint   `json:"team_id"`
+			Access string `json:"access"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TeamID == 0 {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		if err := h.Service.WithContext(r.Context()).SetGrant(orgID, userID, req.Folder, req.TeamID, req.Access); err != nil {
+			writePathError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "grant updated"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writePathError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrPathTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrPathForbidden),
+		errors.Is(err, services.ErrGrantUsed),
+		errors.Is(err, services.ErrNotOrgMember),
+		errors.Is(err, services.ErrNotOrgAdmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type SecurityAlertHandler struct {
+	Service *services.AnomalyService
+}
+
+func NewSecurityAlertHandler(s *services.AnomalyService) *SecurityAlertHandler {
+	return &SecurityAlertHandler{Service: s}
+}
+
+// GET /security/alerts?status=open&severity=high&rule=new_country&user_id=7&suspended=true&limit=50&before=1234
+// Newest first; pass the last id as before= for the next page.
+func (h *SecurityAlertHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	q := r.URL.Query()
+	in := services.AlertListInput{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Status:         q.Get("status"),
+		Severity:       q.Get("severity"),
+		Rule:           q.Get("rule"),
+		Suspended:      q.Get("suspended") == "true",
+	}
+	var ok bool
+	if q.Get("user_id") != "" {
+		if in.SubjectID, ok = queryID(w, r, "user_id"); !ok {
+			return
+		}
+	}
+	if q.Get("before") != "" {
+		if in.Before, ok = queryID(w, r, "before"); !ok {
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		in.Limit = n
+	}
+	alerts, err := h.Service.WithContext(r.Context()).ListAlerts(in)
+	if err != nil {
+		writeSecurityAlertError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// GET /security/alerts/get?id=12
+func (h *SecurityAlertHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	alert, err := h.Service.WithContext(r.Context()).GetAlert(orgID, userID, id)
+	if err != nil {
+		writeSecurityAlertError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alert)
+}
+
+// PUT /security/alerts/update  {"id": 12, "status": "acknowledged|resolved|dismissed|open", "note": "..."}
+// Omitted fields are left alone.
+func (h *SecurityAlertHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		ID     uint    `json:"id"`
+		Status string  `json:"status"`
+		Note   *string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	alert, err := h.Service.WithContext(r.Context()).UpdateAlert(services.UpdateAlertInput{
+		OrganizationID: orgID,
+		UserID:         userID,
+		ID:             req.ID,
+		Status:         req.Status,
+		Note:           req.Note,
+	})
+	if err != nil {
+		writeSecurityAlertError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alert)
+}
+
+// POST /security/suspensions/lift  {"user_id": 7}
+// The member can sign in again; tokens issued before the suspension stay
+// revoked.
+func (h *SecurityAlertHandler) LiftSuspension(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	if err := h.Service.WithContext(r.Context()).LiftSuspension(orgID, userID, req.UserID); err != nil {
+		writeSecurityAlertError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeSecurityAlertError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNotOrgMember), errors.Is(err, services.ErrNotOrgAdmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrNotSuspended), errors.Is(err, services.ErrNotSuspendedHere):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
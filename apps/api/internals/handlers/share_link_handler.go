@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type ShareLinkHandler struct {
+	Service *services.ShareLinkService
+}
+
+func NewShareLinkHandler(s *services.ShareLinkService) *ShareLinkHandler {
+	return &ShareLinkHandler{Service: s}
+}
+
+// POST   /apikeys/share  {"id": 7, "expires_in": "24h", "max_views": 1, "passphrase": "...", "note": "for Acme"}
+// GET    /apikeys/share?id=7
+// DELETE /apikeys/share?link_id=3
+func (h *ShareLinkHandler) Links(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			ID         uint   `json:"id"`
+			ExpiresIn  string `json:"expires_in"`
+			MaxViews   int    `json:"max_views"`
+			Passphrase string `json:"passphrase"`
+			Note       string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		ttl, ok := optionalDuration(w, "expires_in", req.ExpiresIn)
+		if !ok {
+			return
+		}
+		created, err := h.Service.WithContext(r.Context()).Create(services.CreateShareLinkInput{
+			OrganizationID: orgID,
+			UserID:         userID,
+			APIKeyID:       req.ID,
+			ExpiresIn:      ttl,
+			MaxViews:       req.MaxViews,
+			Passphrase:     req.Passphrase,
+			Note:           req.Note,
+		})
+		if err != nil {
+			writeShareError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	case http.MethodGet:
+		id, ok := queryID(w, r, "id")
+		if !ok {
+			return
+		}
+		links, err := h.Service.WithContext(r.Context()).ListForKey(orgID, userID, id)
+		if err != nil {
+			writeShareError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(links)
+
+	case http.MethodDelete:
+		id, ok := queryID(w, r, "link_id")
+		if !ok {
+			return
+		}
+		if err := h.Service.WithContext(r.Context()).Revoke(orgID, userID, id); err != nil {
+			writeShareError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /share/info?token=...  (public) — does not use up a view
+func (h *ShareLinkHandler) Info(w http.ResponseWriter, r *http.Request) {
+	info, err := h.Service.WithContext(r.Context()).Info(r.URL.Query().Get("token"))
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(info)
+}
+
+// POST /share/redeem  {"token": "...", "passphrase": "..."}  (public)
+// The token travels in the body rather than the query string so it stays
+// out of access logs; the decryption key never reaches the server at all.
+func (h *ShareLinkHandler) Redeem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Token      string `json:"token"`
+		Passphrase string `json:"passphrase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.WithContext(r.Context()).Redeem(req.Token, req.Passphrase, middleware.ClientIP(r))
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(res)
+}
+
+func writeShareError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrShareLinkGone):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, services.ErrSharePassphraseNeed):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, services.ErrShareNeedsApproval), errors.Is(err, services.ErrGrantUsed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrSharePassphrase):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
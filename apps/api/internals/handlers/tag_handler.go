@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type TagHandler struct {
+	Service *services.TagService
+}
+
+func NewTagHandler(s *services.TagService) *TagHandler {
+	return &TagHandler{Service: s}
+}
+
+// GET /tags — every tag in the organization with its usage count
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	tags, err := h.Service.WithContext(r.Context()).ListTags(orgID, userID)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// POST /tags/rename  {"id": 3, "tag": "env:production"}
+func (h *TagHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		ID  uint   `json:"id"`
+		Tag string `json:"tag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.Service.WithContext(r.Context()).Rename(orgID, userID, req.ID, req.Tag)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// POST /tags/merge  {"from_id": 4, "into_id": 3}
+func (h *TagHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		FromID uint `json:"from_id"`
+		IntoID uint `json:"into_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.FromID == 0 || req.IntoID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.Service.WithContext(r.Context()).Merge(orgID, userID, req.FromID, req.IntoID)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// POST /apikeys/tags  {"id": 1, "tags": ["env:prod", "vendor:stripe"]}
+func (h *TagHandler) SetKeyTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		ID   uint     `json:"id"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.Service.WithContext(r.Context()).SetKeyTags(orgID, userID, req.ID, req.Tags)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Fields handles /apikeys/fields: GET ?id= lists custom fields,
+// POST {"id": 1, "name": "cost_center", "type": "string", "value": "R&D"} sets one,
+// DELETE ?id=&name= removes one
+func (h *TagHandler) Fields(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodGet:
+		keyID, ok := queryID(w, r, "id")
+		if !ok {
+			return
+		}
+		fields, err := h.Service.WithContext(r.Context()).ListFields(orgID, userID, keyID)
+		if err != nil {
+			writeTagError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fields)
+	case http.MethodPost:
+		var req struct {
+			ID    uint   `json:"id"`
+			Name  string `json:"name"`
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		field, err := h.Service.WithContext(r.Context()).SetField(services.SetCustomFieldInput{
+			OrganizationID: orgID,
+			UserID:         userID,
+			KeyID:          req.ID,
+			Name:           req.Name,
+			Type:           req.Type,
+			Value:          req.Value,
+		})
+		if err != nil {
+			writeTagError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(field)
+	case http.MethodDelete:
+		keyID, ok := queryID(w, r, "id")
+		if !ok {
+			return
+		}
+		if err := h.Service.WithContext(r.Context()).DeleteField(orgID, userID, keyID, r.URL.Query().Get("name")); err != nil {
+			writeTagError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "field deleted"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrCannotManageKey),
+		errors.Is(err, services.ErrNotOrgMember),
+		errors.Is(err, services.ErrNotOrgAdmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
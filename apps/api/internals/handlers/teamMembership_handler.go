@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
 	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
 )
 
 type TeamMembershipHandler struct {
@@ -16,8 +19,15 @@ func NewTeamMembershipHandler(svc services.TeamMembershipService) *TeamMembershi
 	return &TeamMembershipHandler{svc: svc}
 }
 
-// POST /team-memberships
+// POST /team-memberships  {"team_id": 1, "user_id": 2, "role": "member"}
+// Team owners and admins only; everyone else is invited.
 func (h *TeamMembershipHandler) Create(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
 	var body struct {
 		TeamID uint   `json:"team_id"`
 		UserID uint   `json:"user_id"`
@@ -33,8 +43,9 @@ func (h *TeamMembershipHandler) Create(w http.ResponseWriter, r *http.Request) {
 		body.Role = "member"
 	}
 
-	if err := h.svc.AddUserToTeam(body.TeamID, body.UserID, body.Role); err != nil {
-		http.Error(w, "failed to add member: "+err.Error(), http.StatusInternalServerError)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	if err := h.svc.WithContext(r.Context()).AddUserToTeam(orgID, actorID, body.TeamID, body.UserID, body.Role); err != nil {
+		writeTeamMembershipError(w, err)
 		return
 	}
 
@@ -47,10 +58,11 @@ func (h *TeamMembershipHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *TeamMembershipHandler) List(w http.ResponseWriter, r *http.Request) {
 	teamIDStr := r.URL.Query().Get("team_id")
 	userIDStr := r.URL.Query().Get("user_id")
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
 
 	if teamIDStr != "" {
 		teamID, _ := strconv.Atoi(teamIDStr)
-		members, err := h.svc.GetTeamMemberships(uint(teamID))
+		members, err := h.svc.WithContext(r.Context()).GetTeamMemberships(orgID, uint(teamID))
 		if err != nil {
 			http.Error(w, "failed to list team memberships", http.StatusInternalServerError)
 			return
@@ -61,7 +73,7 @@ func (h *TeamMembershipHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	if userIDStr != "" {
 		userID, _ := strconv.Atoi(userIDStr)
-		members, err := h.svc.GetUserMemberships(uint(userID))
+		members, err := h.svc.WithContext(r.Context()).GetUserMemberships(orgID, uint(userID))
 		if err != nil {
 			http.Error(w, "failed to list user memberships", http.StatusInternalServerError)
 			return
@@ -74,7 +86,14 @@ func (h *TeamMembershipHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 // DELETE /team-memberships/delete?team_id=1&user_id=2
+// Team owners and admins remove members; anyone can remove themselves.
 func (h *TeamMembershipHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
 	teamIDStr := r.URL.Query().Get("team_id")
 	userIDStr := r.URL.Query().Get("user_id")
 
@@ -86,10 +105,24 @@ func (h *TeamMembershipHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	teamID, _ := strconv.Atoi(teamIDStr)
 	userID, _ := strconv.Atoi(userIDStr)
 
-	if err := h.svc.RemoveUserFromTeam(uint(teamID), uint(userID)); err != nil {
-		http.Error(w, "failed to remove member", http.StatusInternalServerError)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	if err := h.svc.WithContext(r.Context()).RemoveUserFromTeam(orgID, actorID, uint(teamID), uint(userID)); err != nil {
+		writeTeamMembershipError(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(map[string]string{"message": "user removed from team"})
 }
+
+func writeTeamMembershipError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNotTeamManager), errors.Is(err, services.ErrNotOrgMember):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrRemoveTeamOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
 	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
 )
 
 type TeamHandler struct {
@@ -14,6 +17,15 @@ type TeamHandler struct {
 
 func NewTeamHandler(s *services.TeamService) *TeamHandler { return &TeamHandler{Service: s} }
 
+// Teams dispatches /teams: GET lists my teams, POST creates one
+func (h *TeamHandler) Teams(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		h.List(w, r)
+		return
+	}
+	h.Create(w, r)
+}
+
 // Create handles POST /teams to create a new team for the authenticated user
 func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -36,8 +48,10 @@ func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ownerID := uid.(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
 
-	res, err := h.Service.Create(services.CreateTeamInput{
+	res, err := h.Service.WithContext(r.Context()).Create(services.CreateTeamInput{
+		OrganizationID: orgID,
 		Name:        req.Name,
 		Description: req.Description,
 		OwnerID:     ownerID,
@@ -49,4 +63,201 @@ func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(res)
-}
\ No newline at end of file
+}
+
+// List handles GET /teams?archived=true to list the teams the user belongs to
+func (h *TeamHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	teams, err := h.Service.WithContext(r.Context()).List(orgID, userID, r.URL.Query().Get("archived") == "true")
+	if err != nil {
+		http.Error(w, "failed to list teams", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(teams)
+}
+
+// Get handles GET /teams/get?id=1 and returns the team with members and attached keys
+func (h *TeamHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	teamID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || teamID <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	team, err := h.Service.WithContext(r.Context()).Get(orgID, userID, uint(teamID))
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
+
+// Update handles PUT /teams/update to rename a team or edit its description
+func (h *TeamHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ID          uint    `json:"id"`
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	team, err := h.Service.WithContext(r.Context()).Update(services.UpdateTeamInput{
+		OrganizationID: orgID,
+		UserID:      userID,
+		TeamID:      req.ID,
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
+
+// Archive handles POST /teams/archive {"id": 1, "archived": true}
+func (h *TeamHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ID       uint `json:"id"`
+		Archived bool `json:"archived"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	team, err := h.Service.WithContext(r.Context()).SetArchived(orgID, userID, req.ID, req.Archived)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
+
+// Delete handles DELETE /teams/delete?id=1; attached keys are detached, not deleted
+func (h *TeamHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	teamID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || teamID <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	if err := h.Service.WithContext(r.Context()).Delete(orgID, userID, uint(teamID)); err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "team deleted"})
+}
+
+// Transfer handles POST /teams/transfer {"id": 1, "new_owner_id": 2}
+func (h *TeamHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ID         uint `json:"id"`
+		NewOwnerID uint `json:"new_owner_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 || req.NewOwnerID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	team, err := h.Service.WithContext(r.Context()).TransferOwnership(orgID, userID, req.ID, req.NewOwnerID)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
+
+func writeTeamError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "team not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNotTeamMember), errors.Is(err, services.ErrNotTeamOwner), errors.Is(err, services.ErrNotTeamManager):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrTeamArchived):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type TeamInvitationHandler struct {
+	Service *services.TeamInvitationService
+}
+
+func NewTeamInvitationHandler(s *services.TeamInvitationService) *TeamInvitationHandler {
+	return &TeamInvitationHandler{Service: s}
+}
+
+type invitationActionRequest struct {
+	ID    uint   `json:"id"`
+	Token string `json:"token"`
+}
+
+// POST /team-invitations  {"team_id": 1, "email": "dev@example.com", "role": "member"}
+func (h *TeamInvitationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		TeamID uint   `json:"team_id"`
+		Email  string `json:"email"`
+		Role   string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TeamID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	res, err := h.Service.WithContext(r.Context()).Invite(services.InviteInput{
+		OrganizationID: orgID,
+		InviterID:      userID,
+		TeamID:         req.TeamID,
+		Email:          req.Email,
+		Role:           req.Role,
+	})
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
+// GET /team-invitations/list?scope=received|sent
+func (h *TeamInvitationHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		invs []models.TeamInvitation
+		err  error
+	)
+	switch r.URL.Query().Get("scope") {
+	case "", "received":
+		invs, err = h.Service.WithContext(r.Context()).ListReceived(userID)
+	case "sent":
+		orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+		invs, err = h.Service.WithContext(r.Context()).ListSent(orgID, userID)
+	default:
+		http.Error(w, "scope must be received or sent", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to list invitations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invs)
+}
+
+// POST /team-invitations/accept  {"token": "..."} or {"id": 3}
+func (h *TeamInvitationHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	h.act(w, r, func(userID uint, req invitationActionRequest) (*models.TeamInvitation, error) {
+		return h.Service.WithContext(r.Context()).Accept(userID, req.Token, req.ID)
+	})
+}
+
+// POST /team-invitations/decline  {"token": "..."} or {"id": 3}
+func (h *TeamInvitationHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	h.act(w, r, func(userID uint, req invitationActionRequest) (*models.TeamInvitation, error) {
+		return h.Service.WithContext(r.Context()).Decline(userID, req.Token, req.ID)
+	})
+}
+
+// POST /team-invitations/revoke  {"id": 3}
+func (h *TeamInvitationHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+	h.act(w, r, func(userID uint, req invitationActionRequest) (*models.TeamInvitation, error) {
+		return h.Service.WithContext(r.Context()).Revoke(orgID, userID, req.ID)
+	})
+}
+
+func (h *TeamInvitationHandler) act(w http.ResponseWriter, r *http.Request, fn func(uint, invitationActionRequest) (*models.TeamInvitation, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok {
+		http.Error(w, "user ID not found", http.StatusUnauthorized)
+		return
+	}
+
+	var req invitationActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Token == "" && req.ID == 0) {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+
+	inv, err := fn(userID, req)
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inv)
+}
+
+func writeInvitationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "invitation not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNotTeamManager), errors.Is(err, services.ErrInvitationMismatch):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
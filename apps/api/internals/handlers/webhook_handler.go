@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/middleware"
+	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	Service *services.WebhookService
+}
+
+func NewWebhookHandler(s *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{Service: s}
+}
+
+// POST   /webhooks  {"url": "https://...", "events": ["apikey_created", "member_*"], "team_id": 2, "description": "..."}
+// GET    /webhooks?team_id=2  (without team_id: your personal webhooks)
+// DELETE /webhooks?id=5
+// The signing secret is only in the POST response.
+func (h *WebhookHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			URL         string   `json:"url"`
+			Events      []string `json:"events"`
+			TeamID      *uint    `json:"team_id"`
+			Description string   `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		if req.TeamID != nil && *req.TeamID == 0 {
+			req.TeamID = nil
+		}
+		created, err := h.Service.WithContext(r.Context()).Create(services.CreateWebhookInput{
+			OrganizationID: orgID,
+			UserID:         userID,
+			TeamID:         req.TeamID,
+			URL:            req.URL,
+			Description:    req.Description,
+			Events:         req.Events,
+		})
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	case http.MethodGet:
+		var teamID *uint
+		if r.URL.Query().Get("team_id") != "" {
+			id, ok := queryID(w, r, "team_id")
+			if !ok {
+				return
+			}
+			teamID = &id
+		}
+		hooks, err := h.Service.WithContext(r.Context()).List(orgID, userID, teamID)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hooks)
+
+	case http.MethodDelete:
+		id, ok := queryID(w, r, "id")
+		if !ok {
+			return
+		}
+		if err := h.Service.WithContext(r.Context()).Delete(orgID, userID, id); err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PUT /webhooks/update  {"id": 5, "url": "...", "events": [...], "description": "...", "active": true}
+// Omitted fields are left alone; "active": true re-enables a webhook that
+// was disabled after repeated failures.
+func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		ID          uint     `json:"id"`
+		URL         *string  `json:"url"`
+		Events      []string `json:"events"`
+		Description *string  `json:"description"`
+		Active      *bool    `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	hook, err := h.Service.WithContext(r.Context()).Update(services.UpdateWebhookInput{
+		OrganizationID: orgID,
+		UserID:         userID,
+		ID:             req.ID,
+		URL:            req.URL,
+		Description:    req.Description,
+		Events:         req.Events,
+		Active:         req.Active,
+	})
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+// POST /webhooks/rotate-secret  {"id": 5}
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	res, err := h.Service.WithContext(r.Context()).RotateSecret(orgID, userID, req.ID)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(res)
+}
+
+// GET /webhooks/deliveries?id=5&status=failed&limit=50&before=1234
+// Newest first; pass the last id as before= for the next page.
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	in := services.WebhookDeliveriesInput{
+		OrganizationID: orgID,
+		UserID:         userID,
+		WebhookID:      id,
+		Status:         r.URL.Query().Get("status"),
+	}
+	if r.URL.Query().Get("before") != "" {
+		if in.Before, ok = queryID(w, r, "before"); !ok {
+			return
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		in.Limit = n
+	}
+	deliveries, err := h.Service.WithContext(r.Context()).Deliveries(in)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// POST /webhooks/redeliver  {"delivery_id": 1234}
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uint)
+	orgID, _ := r.Context().Value(middleware.OrgIDKey).(uint)
+
+	var req struct {
+		DeliveryID uint `json:"delivery_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DeliveryID == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	d, err := h.Service.WithContext(r.Context()).Redeliver(orgID, userID, req.DeliveryID)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(d)
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrWebhookForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrWebhookDisabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
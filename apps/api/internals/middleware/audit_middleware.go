@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+)
+
+// RequestIDHeader carries the request id, echoed back on every response.
+const RequestIDHeader = "X-Request-ID"
+
+// AuditMW wraps the whole API. It records where each request came from in an
+// audit.Meta on the request context; AuthMW and OrgMW add who made it.
+// Addresses in X-Forwarded-For are only believed from trusted proxies.
+func AuditMW(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			meta := &audit.Meta{
+				IP:        utils.ClientIP(r.RemoteAddr, r.Header.Get("X-Forwarded-For"), trusted),
+				UserAgent: clip(r.UserAgent(), 255),
+				RequestID: requestID(r.Header.Get(RequestIDHeader)),
+				Principal: audit.PrincipalAnonymous,
+			}
+			w.Header().Set(RequestIDHeader, meta.RequestID)
+			next.ServeHTTP(w, r.WithContext(audit.WithMeta(r.Context(), meta)))
+		})
+	}
+}
+
+// ClientIP is the caller's address as resolved by AuditMW.
+func ClientIP(r *http.Request) string {
+	if m := audit.MetaFrom(r.Context()); m != nil {
+		return m.IP
+	}
+	return r.RemoteAddr
+}
+
+// requestID keeps a caller's id if it is safe to log, else makes one up.
+func requestID(given string) string {
+	if given != "" && len(given) <= 64 && strings.Trim(given, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.") == "" {
+		return given
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -5,14 +5,23 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
 )
 
 type contextKey string
 
 const UserIDKey contextKey = "userID"
 
+// AuthMethodKey holds how the request's token was obtained, e.g. "password".
+const AuthMethodKey contextKey = "authMethod"
+
+// IssuedAtKey holds when the request's token was issued, from its "iat"
+// claim; the zero time when it has none.
+const IssuedAtKey contextKey = "issuedAt"
+
 
 func AuthMW(secret []byte) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
@@ -52,7 +61,29 @@ func AuthMW(secret []byte) func(http.HandlerFunc) http.HandlerFunc {
 					userID := uint(subFloat)
 
 					
+					method, _ := claims["amr"].(string)
+					if method == "" {
+						method = "password"
+					}
+
+					// tokens minted for PATs or service accounts say so in
+					// "ptyp"; everything else is a signed-in user
+					principal, _ := claims["ptyp"].(string)
+					if principal != audit.PrincipalPAT && principal != audit.PrincipalServiceAccount {
+						principal = audit.PrincipalUser
+					}
+					if m := audit.MetaFrom(r.Context()); m != nil {
+						m.Principal = principal
+						m.AuthMethod = method
+					}
+
 					ctx := context.WithValue(r.Context(), UserIDKey, userID)
+					ctx = context.WithValue(ctx, AuthMethodKey, method)
+					var issuedAt time.Time
+					if iat, ok := claims["iat"].(float64); ok {
+						issuedAt = time.Unix(int64(iat), 0)
+					}
+					ctx = context.WithValue(ctx, IssuedAtKey, issuedAt)
 					r = r.WithContext(ctx)
 				} else {
 					http.Error(w, "user ID not found in token", http.StatusUnauthorized)
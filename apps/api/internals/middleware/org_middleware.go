@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+// OrgIDKey holds the organization (tenant) the request operates in.
+const OrgIDKey contextKey = "orgID"
+
+// OrgHeader selects the organization for a request; without it the user's
+// personal organization is used.
+const OrgHeader = "X-Org-ID"
+
+// OrgResolver decides which organization a user's request runs in.
+type OrgResolver interface {
+	ResolveOrg(userID, requestedOrgID uint, authMethod string) (uint, error)
+}
+
+// OrgMW must run after AuthMW. It resolves the tenant for the authenticated
+// user and stores it under OrgIDKey.
+func OrgMW(resolver OrgResolver) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDKey).(uint)
+			if !ok {
+				http.Error(w, "user ID not found", http.StatusUnauthorized)
+				return
+			}
+
+			var requested uint
+			if h := r.Header.Get(OrgHeader); h != "" {
+				id, err := strconv.ParseUint(h, 10, 64)
+				if err != nil {
+					http.Error(w, "invalid "+OrgHeader+" header", http.StatusBadRequest)
+					return
+				}
+				requested = uint(id)
+			}
+
+			method, _ := r.Context().Value(AuthMethodKey).(string)
+			orgID, err := resolver.ResolveOrg(userID, requested, method)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
+			if m := audit.MetaFrom(r.Context()); m != nil {
+				m.OrganizationID = orgID
+			}
+			ctx := context.WithValue(r.Context(), OrgIDKey, orgID)
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
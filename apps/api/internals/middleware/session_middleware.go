@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// SessionChecker decides whether a user's token may still be used, e.g.
+// after the user was suspended or their sessions revoked.
+type SessionChecker interface {
+	CheckSession(userID uint, issuedAt time.Time) error
+}
+
+// SessionMW must run after AuthMW. It refuses tokens the checker rejects.
+func SessionMW(checker SessionChecker) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDKey).(uint)
+			if !ok {
+				http.Error(w, "user ID not found", http.StatusUnauthorized)
+				return
+			}
+			issuedAt, _ := r.Context().Value(IssuedAtKey).(time.Time)
+			if err := checker.CheckSession(userID, issuedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// AccessCounter counts requests of one kind per user, for rules that watch
+// request rates rather than activities.
+type AccessCounter interface {
+	CountAccess(ctx context.Context, userID, orgID uint, action string)
+}
+
+// CountAccess must run after OrgMW. It reports each request to the counter
+// before handling it; counting never fails the request.
+func CountAccess(counter AccessCounter, action string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(UserIDKey).(uint)
+			orgID, _ := r.Context().Value(OrgIDKey).(uint)
+			if userID != 0 {
+				counter.CountAccess(r.Context(), userID, orgID, action)
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// AccessRequest is created when a non-owner tries to reveal an APIKey that
+// requires approval. An approver (key owner or team owner/admin) decides it,
+// and an approved request unlocks either a single reveal or a time-boxed window.
+type AccessRequest struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	APIKeyID       uint       `gorm:"not null;index" json:"api_key_id"`
+	RequesterID    uint       `gorm:"not null;index" json:"requester_id"`
+	Reason         string     `gorm:"size:500" json:"reason"`
+	Status         string     `gorm:"type:varchar(20);default:pending;index" json:"status"` // "pending" | "approved" | "denied" | "consumed"
+	GrantType      string     `gorm:"type:varchar(20)" json:"grant_type,omitempty"`         // "once" | "window"
+	ApproverID     *uint      `json:"approver_id,omitempty"`
+	DecisionReason string     `gorm:"size:500" json:"decision_reason,omitempty"`
+	DecidedAt      *time.Time `json:"decided_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	ConsumedAt     *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	APIKey    *APIKey `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+	Requester *User   `gorm:"foreignKey:RequesterID;constraint:OnDelete:CASCADE;" json:"-"`
+}
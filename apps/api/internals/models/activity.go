@@ -1,15 +1,156 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"gorm.io/gorm"
+)
+
+// auditChainLock is the advisory lock that serializes appends to the chain.
+const auditChainLock = 0x6175646974 // "audit"
+
+// LockAuditChain takes the chain lock until the end of tx.
+func LockAuditChain(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error
+}
 
 // Activity represents a user action (e.g., creating a team, adding/deleting an API key)
 // Stored to power dashboard metrics like recent activity and weekly counts.
+//
+// Activities form an append-only hash chain: Seq numbers them without gaps
+// and Hash covers the entry and the previous entry's hash (see package audit).
 type Activity struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	UserID    uint      `gorm:"index;not null" json:"user_id"`
-	Type      string    `gorm:"size:100;not null" json:"type"`      // e.g., "apikey_created", "apikey_deleted", "team_created"
-	Entity    string    `gorm:"size:100;not null" json:"entity"`    // e.g., "apikey", "team"
+	Type      string    `gorm:"size:100;not null" json:"type"`   // e.g., "apikey_created", "apikey_deleted", "team_created"
+	Entity    string    `gorm:"size:100;not null" json:"entity"` // e.g., "apikey", "team"
 	EntityID  uint      `gorm:"index" json:"entity_id"`
 	Message   string    `gorm:"size:255" json:"message"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-}
\ No newline at end of file
+
+	// request context, filled in from audit.Meta when left empty
+	OrganizationID uint   `gorm:"index" json:"organization_id"`
+	TeamID         *uint  `gorm:"index" json:"team_id,omitempty"`
+	Principal      string `gorm:"size:32;not null;default:''" json:"principal"`
+	AuthMethod     string `gorm:"size:32;not null;default:''" json:"auth_method"`
+	IP             string `gorm:"size:64;not null;default:''" json:"ip"`
+	UserAgent      string `gorm:"size:255;not null;default:''" json:"user_agent"`
+	RequestID      string `gorm:"size:64;not null;default:'';index" json:"request_id"`
+	Outcome        string `gorm:"size:16;not null;default:''" json:"outcome"` // audit.OutcomeSuccess, ...; empty on old rows
+	Reason         string `gorm:"size:255;not null;default:''" json:"reason,omitempty"`
+
+	// outbox event the activity was recorded from; unique when set, so a
+	// redelivered event is not logged twice
+	EventID string `gorm:"size:32;not null;default:''" json:"event_id,omitempty"`
+
+	Seq      int64  `gorm:"uniqueIndex" json:"seq"`
+	PrevHash string `gorm:"size:64;not null;default:''" json:"prev_hash"`
+	Hash     string `gorm:"size:64;not null;default:''" json:"hash"`
+}
+
+// BeforeCreate links the activity to the head of the chain. An activity
+// delivered through the outbox keeps the time it was enqueued at. The advisory
+// lock is held until the insert's transaction commits, so appends never race.
+// Insert activities one at a time: a batch would see the same head for every row.
+func (a *Activity) BeforeCreate(tx *gorm.DB) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	if err := LockAuditChain(db); err != nil {
+		return err
+	}
+	var head Activity
+	err := db.Select("seq", "hash").Where("hash <> ''").Order("seq DESC").Limit(1).Find(&head).Error
+	if err != nil {
+		return err
+	}
+
+	if a.TeamID == nil && a.Entity == "team" && a.EntityID != 0 {
+		teamID := a.EntityID
+		a.TeamID = &teamID
+	}
+	a.StampRequest(audit.MetaFrom(tx.Statement.Context))
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	a.CreatedAt = a.CreatedAt.UTC().Truncate(time.Microsecond)
+	a.Seq = head.Seq + 1
+	a.PrevHash = head.Hash
+	a.Hash = audit.Hash(a.PrevHash, a.AuditEntry())
+	return nil
+}
+
+// StampRequest fills in whatever the caller left empty from the request the
+// activity is logged in; without one it was a background job.
+func (a *Activity) StampRequest(m *audit.Meta) {
+	if a.Outcome == "" {
+		a.Outcome = audit.OutcomeSuccess
+	}
+	if m == nil {
+		if a.Principal == "" {
+			a.Principal = audit.PrincipalSystem
+		}
+		return
+	}
+	if a.Principal == "" {
+		a.Principal = m.Principal
+	}
+	if a.AuthMethod == "" {
+		a.AuthMethod = m.AuthMethod
+	}
+	if a.OrganizationID == 0 {
+		a.OrganizationID = m.OrganizationID
+	}
+	if a.IP == "" {
+		a.IP = m.IP
+	}
+	if a.UserAgent == "" {
+		a.UserAgent = m.UserAgent
+	}
+	if a.RequestID == "" {
+		a.RequestID = m.RequestID
+	}
+}
+
+// AuditEntry is the activity as the audit chain sees it.
+func (a *Activity) AuditEntry() audit.Entry {
+	var teamID uint
+	if a.TeamID != nil {
+		teamID = *a.TeamID
+	}
+	return audit.Entry{
+		Seq:       a.Seq,
+		PrevHash:  a.PrevHash,
+		Hash:      a.Hash,
+		UserID:    a.UserID,
+		Type:      a.Type,
+		Entity:    a.Entity,
+		EntityID:  a.EntityID,
+		Message:   a.Message,
+		CreatedAt: a.CreatedAt,
+
+		OrganizationID: a.OrganizationID,
+		TeamID:         teamID,
+		Principal:      a.Principal,
+		AuthMethod:     a.AuthMethod,
+		IP:             a.IP,
+		UserAgent:      a.UserAgent,
+		RequestID:      a.RequestID,
+		Outcome:        a.Outcome,
+		Reason:         a.Reason,
+		EventID:        a.EventID,
+	}
+}
+
+// AuditCheckpoint is a signed statement of the chain head at some point.
+type AuditCheckpoint struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Seq       int64     `gorm:"uniqueIndex;not null" json:"seq"`
+	Hash      string    `gorm:"size:64;not null" json:"hash"`
+	SignedAt  time.Time `gorm:"not null" json:"signed_at"`
+	KeyID     string    `gorm:"size:32;not null" json:"key_id"`
+	Signature string    `gorm:"size:128;not null" json:"signature"`
+}
+
+func (c *AuditCheckpoint) Checkpoint() audit.Checkpoint {
+	return audit.Checkpoint{Seq: c.Seq, Hash: c.Hash, SignedAt: c.SignedAt, KeyID: c.KeyID, Signature: c.Signature}
+}
@@ -5,15 +5,29 @@ import "time"
 
 type APIKey struct {
     ID         uint      `gorm:"primaryKey" json:"id"`
-    Name       string    `gorm:"size:255;not null" json:"name"`
+    Name       string    `gorm:"size:255;not null;uniqueIndex:idx_env_secret_name" json:"name"`
+    OrganizationID uint  `gorm:"not null;default:0;index" json:"organizationId"`
+    ProjectID  *uint     `gorm:"index" json:"projectId,omitempty"`
+    EnvironmentID *uint  `gorm:"uniqueIndex:idx_env_secret_name" json:"environmentId,omitempty"` // nil for keys outside any project
+    Path       string    `gorm:"size:512;not null;default:'';index" json:"path"` // e.g. "payments/stripe/live"; Name is its last segment
     OwnerID    uint      `gorm:"not null;index" json:"ownerId"` // FK to users.id (if you want)
 	Owner      User      `gorm:"foreignKey:OwnerID;constraint:OnDelete:CASCADE;" json:"-"`
     Ciphertext string    `gorm:"type:text;not null" json:"-"`   // base64 ciphertext
     Nonce      string    `gorm:"size:64;not null" json:"-"`     // base64 nonce
+    Fingerprint string   `gorm:"size:64" json:"fingerprint,omitempty"` // keyed hash of the value, safe to compare
     Description string   `gorm:"size:1024" json:"description,omitempty"`
-    Tags       string    `gorm:"size:255" json:"tags,omitempty"` // comma-separated or separate table
+    Tags       string    `gorm:"size:255" json:"tags,omitempty"` // comma-separated mirror of TagList for older clients
+    TagsBackfilled bool  `gorm:"not null;default:false" json:"-"` // legacy Tags were copied into the tag table
+    RequiresApproval bool `gorm:"not null;default:false" json:"requiresApproval"` // non-owner reveals need an approved AccessRequest
+    BreakGlassEnabled bool `gorm:"not null;default:false" json:"breakGlassEnabled"` // emergency access allowed with justification
+    ExpiresAt  *time.Time `gorm:"index" json:"expiresAt,omitempty"`
+    RotateEveryDays int  `gorm:"not null;default:0" json:"rotateEveryDays,omitempty"` // 0 = no rotation schedule
+    LastRotatedAt *time.Time `json:"lastRotatedAt,omitempty"` // nil means never rotated since creation
+    LastReminderAt *time.Time `json:"-"` // last expiry/rotation reminder, to send at most one a day
     CreatedAt  time.Time `json:"createdAt"`
     UpdatedAt  time.Time `json:"updatedAt"`
     Teams      []Team `gorm:"many2many:api_key_teams;" json:"teams"`
+    TagList    []Tag  `gorm:"many2many:api_key_tags;" json:"tagList,omitempty"`
+    CustomFields []CustomField `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"customFields,omitempty"`
     
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// BreakGlassAccess records an emergency, time-boxed reveal grant on a
+// break-glass enabled APIKey. Every grant must be acknowledged by the key
+// owner or a team owner once the incident is over.
+type BreakGlassAccess struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	APIKeyID       uint       `gorm:"not null;index" json:"api_key_id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	Justification  string     `gorm:"type:text;not null" json:"justification"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcknowledgedBy *uint      `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `gorm:"index" json:"acknowledged_at,omitempty"`
+	AckNote        string     `gorm:"size:1024" json:"ack_note,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	APIKey *APIKey `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+	User   *User   `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// DynamicRole describes how to mint short-lived users at a target database.
+// The connection settings hold admin credentials and are stored encrypted.
+type DynamicRole struct {
+	ID                   uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID       uint      `gorm:"not null;uniqueIndex:idx_org_dynamic_role" json:"organization_id"`
+	Name                 string    `gorm:"size:100;not null;uniqueIndex:idx_org_dynamic_role" json:"name"`
+	Engine               string    `gorm:"size:50;not null" json:"engine"` // "postgres"
+	ConfigCiphertext     string    `gorm:"type:text;not null" json:"-"`
+	ConfigNonce          string    `gorm:"size:64;not null" json:"-"`
+	CreationStatements   string    `gorm:"type:text" json:"creation_statements,omitempty"` // empty = engine default
+	RenewalStatements    string    `gorm:"type:text" json:"renewal_statements,omitempty"`
+	RevocationStatements string    `gorm:"type:text" json:"revocation_statements,omitempty"`
+	DefaultTTLSeconds    int       `gorm:"not null" json:"default_ttl_seconds"`
+	MaxTTLSeconds        int       `gorm:"not null" json:"max_ttl_seconds"`
+	TeamID               *uint     `gorm:"index" json:"team_id,omitempty"` // members may request credentials; nil = org admins only
+	CreatedBy            uint      `gorm:"not null" json:"created_by"`
+	CreatedAt            time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Lease states.
+const (
+	LeaseActive  = "active"
+	LeaseRevoked = "revoked"
+	LeaseExpired = "expired"
+)
+
+// DynamicLease tracks one minted user until it is dropped again. The
+// password is returned once and never stored.
+type DynamicLease struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	LeaseID        string     `gorm:"size:64;not null;uniqueIndex" json:"lease_id"`
+	OrganizationID uint       `gorm:"not null;index" json:"organization_id"`
+	RoleID         uint       `gorm:"not null;index" json:"role_id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	Username       string     `gorm:"size:63;not null" json:"username"`
+	Status         string     `gorm:"size:20;not null;index" json:"status"`
+	ExpiresAt      time.Time  `gorm:"not null;index" json:"expires_at"`
+	MaxExpiresAt   time.Time  `gorm:"not null" json:"max_expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	RevokeAttempts int        `gorm:"not null;default:0" json:"revoke_attempts,omitempty"`
+	LastError      string     `gorm:"size:1024" json:"last_error,omitempty"`
+	LockedUntil    *time.Time `json:"-"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	Role *DynamicRole `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE;" json:"role,omitempty"`
+	User *User        `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
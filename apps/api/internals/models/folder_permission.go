@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// FolderPermission grants a team read or write access on a folder path. The
+// grant is inherited by every secret and subfolder beneath it.
+type FolderPermission struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_folder_team" json:"organization_id"`
+	Path           string    `gorm:"size:512;not null;uniqueIndex:idx_folder_team" json:"path"` // "" is the root
+	TeamID         uint      `gorm:"not null;uniqueIndex:idx_folder_team;index" json:"team_id"`
+	Access         string    `gorm:"type:varchar(10);not null" json:"access"` // "read" | "write"
+	GrantedBy      uint      `gorm:"not null" json:"granted_by"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	Team *Team `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE;" json:"-"`
+}
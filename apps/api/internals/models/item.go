@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Item is a typed vault entry (login, database credentials, certificate, SSH
+// key, secure note). Items of type "api_key" are backed by an APIKey row, which
+// keeps holding the value so approvals, break-glass, paths and tags still apply.
+type Item struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;index" json:"organization_id"`
+	OwnerID        uint      `gorm:"not null;index" json:"owner_id"`
+	Type           string    `gorm:"type:varchar(20);not null;index" json:"type"`
+	Name           string    `gorm:"size:255;not null" json:"name"`
+	Description    string    `gorm:"size:1024" json:"description,omitempty"`
+	APIKeyID       *uint     `gorm:"uniqueIndex" json:"api_key_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Fields []ItemField `gorm:"foreignKey:ItemID;constraint:OnDelete:CASCADE;" json:"-"`
+	APIKey *APIKey     `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+	Owner  User        `gorm:"foreignKey:OwnerID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// ItemField is one named value of an item. Concealed fields are encrypted and
+// only returned through a per-field reveal; plain fields are stored as-is.
+type ItemField struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ItemID     uint      `gorm:"not null;uniqueIndex:idx_item_field" json:"item_id"`
+	Name       string    `gorm:"size:100;not null;uniqueIndex:idx_item_field" json:"name"`
+	Concealed  bool      `gorm:"not null" json:"concealed"`
+	Value      string    `gorm:"type:text" json:"-"` // plain fields only
+	Ciphertext string    `gorm:"type:text" json:"-"` // concealed fields only, base64
+	Nonce      string    `gorm:"size:64" json:"-"`
+	Position   int       `gorm:"not null;default:0" json:"position"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Notification is an in-app message delivered to a single user, e.g. a
+// break-glass alert for a team owner or a rotation reminder.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Type      string     `gorm:"size:100;not null" json:"type"` // e.g., "break_glass_alert"
+	Title     string     `gorm:"size:255;not null" json:"title"`
+	Body      string     `gorm:"type:text" json:"body"`
+	Entity    string     `gorm:"size:100" json:"entity,omitempty"`
+	EntityID  uint       `json:"entity_id,omitempty"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
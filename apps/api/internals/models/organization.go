@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Organization is the tenant boundary: it owns teams and secrets, and every
+// user belongs to at least their personal organization.
+type Organization struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"size:255;not null" json:"name"`
+	Slug string `gorm:"size:100;not null;uniqueIndex" json:"slug"`
+
+	// Settings
+	AllowedAuthMethods string `gorm:"size:255;not null;default:password" json:"allowed_auth_methods"` // comma-separated, e.g. "password"
+	PasswordMinLength  int    `gorm:"not null;default:8" json:"password_min_length"`
+	PasswordRequireMix bool   `gorm:"not null;default:false" json:"password_require_mixed_case"`
+	PasswordRequireNum bool   `gorm:"not null;default:false" json:"password_require_number"`
+	PasswordRequireSym bool   `gorm:"not null;default:false" json:"password_require_symbol"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrganizationMember links a user to an organization with an org-level role.
+type OrganizationMember struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_member" json:"organization_id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_org_member;index" json:"user_id"`
+	Role           string    `gorm:"type:varchar(20);not null;default:member" json:"role"` // "owner" | "admin" | "member"
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	Organization *Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE;" json:"-"`
+	User         *User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// OutboxEvent is written in the same transaction as the change it describes,
+// so the event exists exactly when the change does. The dispatcher then
+// delivers it to every interested subscriber at least once; EventID lets
+// subscribers drop repeats.
+type OutboxEvent struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	EventID     string     `gorm:"size:32;not null;uniqueIndex" json:"event_id"`
+	Topic       string     `gorm:"size:100;not null;index" json:"topic"` // e.g. "activity.recorded"
+	Payload     string     `gorm:"type:text;not null" json:"payload"`    // JSON
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	AvailableAt time.Time  `gorm:"not null;index" json:"available_at"` // not retried before
+	LockedUntil *time.Time `json:"-"`                                  // set while a dispatcher holds it
+	LastError   string     `gorm:"size:1024" json:"last_error,omitempty"`
+	DeliveredAt *time.Time `gorm:"index" json:"delivered_at,omitempty"` // every subscriber has it
+	DeadAt      *time.Time `json:"dead_at,omitempty"`                   // gave up retrying
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// OutboxDelivery marks an event as delivered to one subscriber, so a retry
+// after a partial failure only goes to the subscribers that missed it.
+type OutboxDelivery struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	EventID     string    `gorm:"size:32;not null;uniqueIndex:idx_outbox_delivery" json:"event_id"`
+	Subscriber  string    `gorm:"size:64;not null;uniqueIndex:idx_outbox_delivery" json:"subscriber"`
+	DeliveredAt time.Time `gorm:"not null" json:"delivered_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Project groups the secrets of one service or application inside an organization.
+type Project struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_project_name" json:"organization_id"`
+	Name           string    `gorm:"size:100;not null;uniqueIndex:idx_org_project_name" json:"name"`
+	Description    string    `gorm:"size:500" json:"description,omitempty"`
+	CreatedBy      uint      `gorm:"not null;index" json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Environments []Environment `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE;" json:"environments,omitempty"`
+}
+
+// Environment is a stage of a project (dev, staging, prod). Each secret name in
+// a project can hold a different value per environment.
+type Environment struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProjectID uint      `gorm:"not null;uniqueIndex:idx_project_env_name" json:"project_id"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex:idx_project_env_name" json:"name"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EnvironmentPermission grants a team read or write access to the secrets of
+// one environment.
+type EnvironmentPermission struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	EnvironmentID uint      `gorm:"not null;uniqueIndex:idx_env_team" json:"environment_id"`
+	TeamID        uint      `gorm:"not null;uniqueIndex:idx_env_team;index" json:"team_id"`
+	Access        string    `gorm:"type:varchar(10);not null" json:"access"` // "read" | "write"
+	GrantedBy     uint      `gorm:"not null" json:"granted_by"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	Environment *Environment `gorm:"foreignKey:EnvironmentID;constraint:OnDelete:CASCADE;" json:"-"`
+	Team        *Team        `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE;" json:"-"`
+}
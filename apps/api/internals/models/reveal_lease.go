@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// RevealLease tells a client how long it may cache a revealed value. It is
+// tied to the value's fingerprint, so it stops being valid as soon as the key
+// is rotated or changed, or when it is revoked.
+type RevealLease struct {
+	ID             uint       `gorm:"primaryKey" json:"-"`
+	LeaseID        string     `gorm:"size:64;not null;uniqueIndex" json:"lease_id"`
+	OrganizationID uint       `gorm:"not null;index" json:"-"`
+	APIKeyID       uint       `gorm:"not null;index" json:"api_key_id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	Version        int        `gorm:"not null" json:"version"`
+	Fingerprint    string     `gorm:"size:64;not null" json:"-"`
+	Renewable      bool       `gorm:"not null" json:"renewable"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	MaxExpiresAt   time.Time  `gorm:"not null" json:"max_expires_at"`
+	RevokedAt      *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokeReason   string     `gorm:"size:50" json:"revoke_reason,omitempty"` // "revoked", "rotated", "value_changed", "access_removed"
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	APIKey *APIKey `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+	User   *User   `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
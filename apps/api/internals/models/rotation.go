@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// RotationPolicy configures automatic rotation of one APIKey. The rotator
+// settings may hold credentials (an admin DSN, a webhook secret), so they are
+// stored encrypted.
+type RotationPolicy struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID   uint       `gorm:"not null;index" json:"organization_id"`
+	APIKeyID         uint       `gorm:"not null;uniqueIndex" json:"api_key_id"`
+	Type             string     `gorm:"size:50;not null" json:"type"` // "webhook", "postgres"
+	ConfigCiphertext string     `gorm:"type:text;not null" json:"-"`
+	ConfigNonce      string     `gorm:"size:64;not null" json:"-"`
+	EveryDays        int        `gorm:"not null;default:0" json:"every_days"` // 0 = manual only
+	MaxAttempts      int        `gorm:"not null;default:3" json:"max_attempts"`
+	Enabled          bool       `gorm:"not null" json:"enabled"`
+	NextRunAt        *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	FailedAttempts   int        `gorm:"not null;default:0" json:"failed_attempts"`
+	LastRunAt        *time.Time `json:"last_run_at,omitempty"`
+	LastStatus       string     `gorm:"size:20" json:"last_status,omitempty"`
+	LastError        string     `gorm:"size:1024" json:"last_error,omitempty"`
+	LockedUntil      *time.Time `json:"-"` // set while a worker is rotating the key
+	CreatedBy        uint       `gorm:"not null" json:"created_by"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	APIKey *APIKey `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// RotationRun records one rotation or rollback attempt.
+type RotationRun struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	APIKeyID    uint       `gorm:"not null;index" json:"api_key_id"`
+	Kind        string     `gorm:"size:20;not null" json:"kind"`   // "rotate", "rollback"
+	Status      string     `gorm:"size:20;not null" json:"status"` // "running", "promoted", "rolled_back", "failed"
+	Attempt     int        `gorm:"not null;default:1" json:"attempt"`
+	FromVersion int        `json:"from_version"`
+	ToVersion   int        `json:"to_version,omitempty"`
+	Error       string     `gorm:"size:1024" json:"error,omitempty"`
+	TriggeredBy *uint      `json:"triggered_by,omitempty"` // nil for the scheduler
+	StartedAt   time.Time  `gorm:"not null" json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	APIKey *APIKey `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+}
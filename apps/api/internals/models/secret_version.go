@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Secret version states. A key has exactly one current version; a rotation
+// stages the next one and, once verified, demotes the current one to
+// previous so it can be rolled back to.
+const (
+	VersionStaged     = "staged"
+	VersionCurrent    = "current"
+	VersionPrevious   = "previous"
+	VersionRetired    = "retired"
+	VersionFailed     = "failed"
+	VersionRolledBack = "rolled_back"
+)
+
+// SecretVersion is one value an APIKey has held, encrypted like the key itself.
+type SecretVersion struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	APIKeyID    uint       `gorm:"not null;uniqueIndex:idx_secret_version" json:"api_key_id"`
+	Version     int        `gorm:"not null;uniqueIndex:idx_secret_version" json:"version"`
+	State       string     `gorm:"size:20;not null;index" json:"state"`
+	Ciphertext  string     `gorm:"type:text;not null" json:"-"`
+	Nonce       string     `gorm:"size:64;not null" json:"-"`
+	Fingerprint string     `gorm:"size:64" json:"fingerprint"`
+	CreatedBy   *uint      `json:"created_by,omitempty"` // nil for scheduled rotations and values recorded from the key
+	PromotedAt  *time.Time `json:"promoted_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	APIKey *APIKey `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+}
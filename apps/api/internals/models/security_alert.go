@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Security alert states. Open and acknowledged alerts absorb repeats of the
+// same finding; resolved and dismissed ones are closed.
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+	AlertDismissed    = "dismissed" // a false positive
+)
+
+// SecurityAlert is raised by the anomaly detector when a user's access to
+// secrets looks out of place (see package anomaly). Repeats of an open
+// alert's rule for the same user bump Occurrences instead of raising a new
+// one.
+type SecurityAlert struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID    uint       `gorm:"not null;index" json:"organization_id"`
+	UserID            uint       `gorm:"not null;index" json:"user_id"` // whose access raised it
+	Rule              string     `gorm:"size:50;not null;index" json:"rule"`
+	Severity          string     `gorm:"size:16;not null;index" json:"severity"`
+	Score             float64    `gorm:"not null" json:"score"` // highest so far, 0-100
+	Summary           string     `gorm:"size:255;not null" json:"summary"`
+	Details           string     `gorm:"type:text" json:"details"` // JSON
+	IP                string     `gorm:"size:64;not null;default:''" json:"ip,omitempty"`
+	EventID           string     `gorm:"size:32;not null;default:'';index" json:"event_id,omitempty"` // outbox event that raised it
+	LastEventID       string     `gorm:"size:32;not null;default:''" json:"last_event_id,omitempty"`
+	Occurrences       int        `gorm:"not null;default:1" json:"occurrences"`
+	LastSeenAt        time.Time  `gorm:"not null" json:"last_seen_at"`
+	Status            string     `gorm:"size:16;not null;default:'open';index" json:"status"`
+	SessionsSuspended bool       `gorm:"not null;default:false" json:"sessions_suspended"`
+	Note              string     `gorm:"size:1000" json:"note,omitempty"`
+	ResolvedBy        *uint      `json:"resolved_by,omitempty"`
+	ResolvedAt        *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// AccessCounter counts a user's calls of one kind (e.g. key listings) in a
+// fixed window, for rules that watch requests rather than activities.
+type AccessCounter struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_access_counter"`
+	Action      string    `gorm:"size:50;not null;uniqueIndex:idx_access_counter"`
+	WindowStart time.Time `gorm:"not null;uniqueIndex:idx_access_counter"`
+	Count       int       `gorm:"not null"`
+}
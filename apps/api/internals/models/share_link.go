@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ShareLink hands one APIKey value to someone without an account. The value
+// is encrypted under a key that only lives in the link's URL fragment, so
+// the stored ciphertext is useless on its own; it is wiped when the link is
+// burned.
+type ShareLink struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	TokenHash      string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	OrganizationID uint       `gorm:"not null;index" json:"-"`
+	APIKeyID       uint       `gorm:"not null;index" json:"api_key_id"`
+	CreatedBy      uint       `gorm:"not null;index" json:"created_by"`
+	Ciphertext     string     `gorm:"type:text" json:"-"`
+	Nonce          string     `gorm:"size:64" json:"-"`
+	PassphraseHash string     `gorm:"size:255" json:"-"`
+	HasPassphrase  bool       `gorm:"not null;default:false" json:"has_passphrase"`
+	Note           string     `gorm:"size:500" json:"note,omitempty"`
+	MaxViews       int        `gorm:"not null;default:1" json:"max_views"`
+	Views          int        `gorm:"not null;default:0" json:"views"`
+	FailedAttempts int        `gorm:"not null;default:0" json:"failed_attempts"`
+	ExpiresAt      time.Time  `gorm:"not null;index" json:"expires_at"`
+	BurnedAt       *time.Time `json:"burned_at,omitempty"`
+	BurnReason     string     `gorm:"size:50" json:"burn_reason,omitempty"` // "used", "revoked", "expired", "too_many_attempts"
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	APIKey *APIKey `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE;" json:"-"`
+}
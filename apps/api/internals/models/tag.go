@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Tag is an organization-wide label, either a bare key ("deprecated") or a
+// key:value pair ("env:prod"). Secrets reference tags through api_key_tags.
+type Tag struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_tag" json:"organization_id"`
+	Key            string    `gorm:"size:100;not null;uniqueIndex:idx_org_tag" json:"key"`
+	Value          string    `gorm:"size:155;not null;default:'';uniqueIndex:idx_org_tag" json:"value,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CustomField is a typed piece of metadata on a secret, such as an owner
+// contact, a cost center or a vendor URL.
+type CustomField struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	APIKeyID  uint      `gorm:"not null;uniqueIndex:idx_key_field" json:"api_key_id"`
+	Name      string    `gorm:"size:100;not null;uniqueIndex:idx_key_field" json:"name"`
+	Type      string    `gorm:"type:varchar(10);not null" json:"type"` // "string" | "number" | "bool" | "url" | "email" | "date"
+	Value     string    `gorm:"size:1024;not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
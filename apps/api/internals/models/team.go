@@ -5,10 +5,13 @@ import "time"
 
 type Team struct {
 	ID          uint      `gorm:"primaryKey"`
+	OrganizationID uint   `gorm:"not null;default:0;index"`
 	Name        string    `gorm:"size:100;not null"`
 	Description string    `gorm:"size:500"`
 	OwnerID     uint      `gorm:"not null;index"`
+	ArchivedAt  *time.Time `gorm:"index"`
 	CreatedAt   time.Time
+	UpdatedAt   time.Time
 	Owner 		User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;foreignKey:OwnerID;references:ID"`
 	APIKeys    []APIKey `gorm:"many2many:api_key_teams;" json:"apiKeys"`
 	
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TeamInvitation invites an email address (registered or not) to join a team.
+// Only the SHA-256 of the single-use token is stored.
+type TeamInvitation struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID uint       `gorm:"not null;default:0;index" json:"organization_id"`
+	TeamID         uint       `gorm:"not null;index" json:"team_id"`
+	Email          string     `gorm:"size:255;not null;index" json:"email"`
+	Role           string     `gorm:"type:varchar(50);default:member" json:"role"`
+	TokenHash      string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	InvitedBy      uint       `gorm:"not null;index" json:"invited_by"`
+	Status         string     `gorm:"type:varchar(20);default:pending;index" json:"status"` // "pending" | "accepted" | "declined" | "revoked"
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	RespondedAt    *time.Time `json:"responded_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	Team *Team `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE;" json:"-"`
+}
@@ -6,7 +6,7 @@ type TeamMembership struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	TeamID    uint      `gorm:"not null;index" json:"team_id"`
 	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	Role      string    `gorm:"type:varchar(50);default:member" json:"role"` // "owner" | "member"
+	Role      string    `gorm:"type:varchar(50);default:member" json:"role"` // "owner" | "admin" | "member"
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 
 	// Relations (optional, for preload)
@@ -9,6 +9,12 @@ type User struct {
 	PasswordHash string    `gorm:"not null"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+
+	// set by the anomaly detector: tokens issued before SessionsRevokedAt are
+	// refused, and while SuspendedAt is set the user cannot sign in at all
+	SessionsRevokedAt *time.Time `json:"-"`
+	SuspendedAt       *time.Time `json:"-"`
+	SuspendedReason   string     `gorm:"size:255;not null;default:''" json:"-"`
 }
 
 
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // gave up retrying
+)
+
+// Webhook is an endpoint that receives activities as signed HTTP POSTs. A
+// personal webhook gets its owner's activities and those on keys they own; a
+// team webhook (TeamID set) gets the team's activities and those on keys
+// shared with it. The signing secret is encrypted under the master key.
+type Webhook struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	OrganizationID   uint   `gorm:"not null;index" json:"organization_id"`
+	OwnerID          uint   `gorm:"not null;index" json:"owner_id"`
+	TeamID           *uint  `gorm:"index" json:"team_id,omitempty"`
+	URL              string `gorm:"size:2048;not null" json:"url"`
+	Description      string `gorm:"size:255" json:"description,omitempty"`
+	Events           string `gorm:"type:text;not null" json:"-"` // comma-separated types or patterns, see EventList
+	SecretCiphertext string `gorm:"type:text;not null" json:"-"`
+	SecretNonce      string `gorm:"size:64;not null" json:"-"`
+
+	Active              bool       `gorm:"not null;default:true;index" json:"active"`
+	ConsecutiveFailures int        `gorm:"not null;default:0" json:"consecutive_failures"` // failed attempts since the last success
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      string     `gorm:"size:255" json:"disabled_reason,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	EventList []string `gorm:"-" json:"events"`
+}
+
+// AfterFind splits Events for the API.
+func (w *Webhook) AfterFind(tx *gorm.DB) error {
+	w.EventList = nil
+	if w.Events != "" {
+		w.EventList = strings.Split(w.Events, ",")
+	}
+	return nil
+}
+
+// WebhookDelivery is one event sent (or to be sent) to one webhook, with the
+// outcome of its latest attempt. A manual redelivery is a new row pointing
+// at the original through RedeliveryOf.
+type WebhookDelivery struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	WebhookID     uint       `gorm:"not null;index:idx_webhook_delivery_event" json:"webhook_id"`
+	EventID       string     `gorm:"size:32;not null;index:idx_webhook_delivery_event" json:"event_id"`
+	EventType     string     `gorm:"size:100;not null" json:"event_type"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"` // JSON body, signed afresh on each attempt
+	Status        string     `gorm:"size:16;not null;default:'pending';index" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"` // set while pending
+	LockedUntil   *time.Time `json:"-"`                                      // set while a dispatcher holds it
+	ResponseCode  int        `json:"response_code,omitempty"`
+	ResponseBody  string     `gorm:"size:1024" json:"response_body,omitempty"` // truncated
+	Error         string     `gorm:"size:1024" json:"error,omitempty"`
+	DurationMs    int64      `json:"duration_ms,omitempty"`
+	RedeliveryOf  *uint      `json:"redelivery_of,omitempty"`
+	Manual        bool       `gorm:"not null;default:false" json:"manual"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+
+	Webhook *Webhook `gorm:"foreignKey:WebhookID;constraint:OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,93 @@
+// Package pgsql runs administrative statements against PostgreSQL servers
+// that are managed by the vault rather than used as its own database.
+package pgsql
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// WithConn opens a short-lived connection to dsn for fn.
+func WithConn(ctx context.Context, dsn string, fn func(db *gorm.DB) error) error {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	return fn(db.WithContext(ctx))
+}
+
+// Dialer opens the network connections of WithDialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// WithDialer is WithConn with every connection opened through dialer, which
+// can refuse addresses a user-supplied dsn must not reach.
+func WithDialer(ctx context.Context, dsn string, dialer Dialer, fn func(db *gorm.DB) error) error {
+	if dialer == nil {
+		return WithConn(ctx, dsn, fn)
+	}
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return err
+	}
+	cfg.DialFunc = dialer.DialContext
+	sqlDB := stdlib.OpenDB(*cfg)
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return err
+	}
+	return fn(db.WithContext(ctx))
+}
+
+// ExecAll runs the statements in order inside one transaction, connecting
+// through dialer as WithDialer does.
+func ExecAll(ctx context.Context, dsn string, dialer Dialer, stmts []string) error {
+	return WithDialer(ctx, dsn, dialer, func(db *gorm.DB) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range stmts {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// SplitStatements splits a ';'-separated script into trimmed, non-empty
+// statements. It does not parse SQL, so ';' must not appear inside literals.
+func SplitStatements(script string) []string {
+	var out []string
+	for _, s := range strings.Split(script, ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// QuoteIdent quotes s as an identifier. Utility statements such as ALTER
+// ROLE take no bind parameters, so values are quoted in the SQL text.
+func QuoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// QuoteLiteral quotes s as a string literal, relying on
+// standard_conforming_strings (the default since 9.1).
+func QuoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
@@ -0,0 +1,23 @@
+package pgsql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	if got := QuoteIdent(`we"ird`); got != `"we""ird"` {
+		t.Fatalf("QuoteIdent = %s", got)
+	}
+	if got := QuoteLiteral(`it's`); got != `'it''s'` {
+		t.Fatalf("QuoteLiteral = %s", got)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := SplitStatements("CREATE ROLE a;\n GRANT x TO a ;;\n")
+	want := []string{"CREATE ROLE a", "GRANT x TO a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SplitStatements = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type AccessRequestRepository interface {
+	Create(db *gorm.DB, req *models.AccessRequest) error
+	GetByID(db *gorm.DB, id uint) (*models.AccessRequest, error)
+	Update(db *gorm.DB, req *models.AccessRequest) error
+	FindPending(db *gorm.DB, apiKeyID, requesterID uint) (*models.AccessRequest, error)
+	FindActiveGrant(db *gorm.DB, apiKeyID, requesterID uint, now time.Time) (*models.AccessRequest, error)
+	Consume(db *gorm.DB, id uint, now time.Time) (bool, error)
+	Decide(db *gorm.DB, req *models.AccessRequest) (bool, error)
+	ListByRequester(db *gorm.DB, orgID, requesterID uint) ([]models.AccessRequest, error)
+	ListPendingForApprover(db *gorm.DB, orgID, approverID uint) ([]models.AccessRequest, error)
+}
+
+type accessRequestRepo struct{}
+
+func NewAccessRequestRepository() AccessRequestRepository { return &accessRequestRepo{} }
+
+func (r *accessRequestRepo) Create(db *gorm.DB, req *models.AccessRequest) error {
+	return db.Create(req).Error
+}
+
+func (r *accessRequestRepo) GetByID(db *gorm.DB, id uint) (*models.AccessRequest, error) {
+	var req models.AccessRequest
+	if err := db.First(&req, id).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *accessRequestRepo) Update(db *gorm.DB, req *models.AccessRequest) error {
+	return db.Save(req).Error
+}
+
+func (r *accessRequestRepo) FindPending(db *gorm.DB, apiKeyID, requesterID uint) (*models.AccessRequest, error) {
+	var req models.AccessRequest
+	err := db.Where("api_key_id = ? AND requester_id = ? AND status = ?", apiKeyID, requesterID, "pending").
+		Order("created_at DESC").
+		First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// FindActiveGrant returns an approved request that still unlocks a reveal at the given time.
+func (r *accessRequestRepo) FindActiveGrant(db *gorm.DB, apiKeyID, requesterID uint, now time.Time) (*models.AccessRequest, error) {
+	var req models.AccessRequest
+	err := db.Where("api_key_id = ? AND requester_id = ? AND status = ?", apiKeyID, requesterID, "approved").
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("decided_at DESC").
+		First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Consume marks an approved one-time grant used. It reports false when the
+// grant was no longer approved, e.g. because a concurrent reveal took it.
+func (r *accessRequestRepo) Consume(db *gorm.DB, id uint, now time.Time) (bool, error) {
+	res := db.Model(&models.AccessRequest{}).
+		Where("id = ? AND status = ?", id, "approved").
+		Updates(map[string]interface{}{"status": "consumed", "consumed_at": now})
+	return res.RowsAffected == 1, res.Error
+}
+
+// Decide records the decision on req if it is still pending, and reports
+// whether it was; a request can only be decided once.
+func (r *accessRequestRepo) Decide(db *gorm.DB, req *models.AccessRequest) (bool, error) {
+	res := db.Model(&models.AccessRequest{}).
+		Where("id = ? AND status = ?", req.ID, "pending").
+		Updates(map[string]interface{}{
+			"status":          req.Status,
+			"grant_type":      req.GrantType,
+			"approver_id":     req.ApproverID,
+			"decision_reason": req.DecisionReason,
+			"decided_at":      req.DecidedAt,
+			"expires_at":      req.ExpiresAt,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// ListByRequester returns the user's requests on keys in the organization.
+func (r *accessRequestRepo) ListByRequester(db *gorm.DB, orgID, requesterID uint) ([]models.AccessRequest, error) {
+	var reqs []models.AccessRequest
+	err := db.Where("requester_id = ? AND api_key_id IN (?)", requesterID, orgKeyIDs(db, orgID)).
+		Order("created_at DESC").
+		Find(&reqs).Error
+	return reqs, err
+}
+
+// ListPendingForApprover returns pending requests on keys the user owns or manages through a team.
+func (r *accessRequestRepo) ListPendingForApprover(db *gorm.DB, orgID, approverID uint) ([]models.AccessRequest, error) {
+	var reqs []models.AccessRequest
+	managed := managedKeyIDs(db, orgID, approverID)
+	err := db.Where("status = ? AND api_key_id IN (?)", "pending", managed).
+		Order("created_at ASC").
+		Find(&reqs).Error
+	return reqs, err
+}
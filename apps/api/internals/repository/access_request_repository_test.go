@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+)
+
+func TestConsumeUsesAGrantOnce(t *testing.T) {
+	db := testDB(t, &models.AccessRequest{})
+	repo := NewAccessRequestRepository()
+
+	grant := &models.AccessRequest{APIKeyID: 1, RequesterID: 2, Status: "approved", GrantType: "once"}
+	if err := repo.Create(db, grant); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	now := time.Now()
+	if ok, err := repo.Consume(db, grant.ID, now); err != nil || !ok {
+		t.Fatalf("first Consume = %v, %v; want true", ok, err)
+	}
+	if ok, err := repo.Consume(db, grant.ID, now); err != nil || ok {
+		t.Fatalf("second Consume = %v, %v; want false", ok, err)
+	}
+
+	got, err := repo.GetByID(db, grant.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "consumed" || got.ConsumedAt == nil {
+		t.Errorf("grant after Consume = %+v", got)
+	}
+	if _, err := repo.FindActiveGrant(db, 1, 2, now); err == nil {
+		t.Error("consumed grant is still active")
+	}
+}
+
+func TestDecideOnlyOnce(t *testing.T) {
+	db := testDB(t, &models.AccessRequest{})
+	repo := NewAccessRequestRepository()
+
+	req := &models.AccessRequest{APIKeyID: 1, RequesterID: 2, Status: "pending"}
+	if err := repo.Create(db, req); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	approver, now := uint(3), time.Now()
+	approve := *req
+	approve.Status, approve.GrantType, approve.ApproverID, approve.DecidedAt = "approved", "once", &approver, &now
+	if ok, err := repo.Decide(db, &approve); err != nil || !ok {
+		t.Fatalf("approve = %v, %v; want true", ok, err)
+	}
+	deny := *req
+	deny.Status, deny.ApproverID, deny.DecidedAt = "denied", &approver, &now
+	if ok, err := repo.Decide(db, &deny); err != nil || ok {
+		t.Fatalf("deny after approve = %v, %v; want false", ok, err)
+	}
+
+	got, err := repo.GetByID(db, req.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "approved" || got.GrantType != "once" {
+		t.Errorf("request after decisions = %+v", got)
+	}
+}
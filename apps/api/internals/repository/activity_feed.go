@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+)
+
+// HeadSeq is the seq of the newest activity in the chain.
+func (r *ActivityRepository) HeadSeq() (int64, error) {
+	var head int64
+	err := r.db.Model(&models.Activity{}).Select("COALESCE(MAX(seq), 0)").Scan(&head).Error
+	return head, err
+}
+
+// ListAfterSeq returns up to limit activities following seq after, in
+// chain order.
+func (r *ActivityRepository) ListAfterSeq(after int64, limit int) ([]models.Activity, error) {
+	var activities []models.Activity
+	err := r.db.Where("seq > ?", after).Order("seq").Limit(limit).Find(&activities).Error
+	return activities, err
+}
+
+// ListFeed is ListAfterSeq for one user's feed in an organization, stopping
+// at seq through: the user's own activities plus, for each of teamIDs, what a
+// team-scoped Search would return.
+func (r *ActivityRepository) ListFeed(orgID, userID uint, teamIDs []uint, after, through int64, limit int) ([]models.Activity, error) {
+	scope := r.db.Where("user_id = ?", userID)
+	if len(teamIDs) > 0 {
+		scope = scope.Or("team_id IN ?", teamIDs).
+			Or("entity = 'apikey' AND entity_id IN (?)",
+				r.db.Table("api_key_teams").Select("api_key_id").Where("team_id IN ?", teamIDs))
+	}
+	var activities []models.Activity
+	err := r.db.Where("seq > ? AND seq <= ? AND organization_id = ?", after, through, orgID).
+		Where(scope).
+		Order("seq").
+		Limit(limit).
+		Find(&activities).Error
+	return activities, err
+}
+
+// KeyTeams maps each of the keys to the teams it is shared with.
+func (r *ActivityRepository) KeyTeams(keyIDs []uint) (map[uint][]uint, error) {
+	out := make(map[uint][]uint)
+	if len(keyIDs) == 0 {
+		return out, nil
+	}
+	var rows []models.APIKeyTeam
+	if err := r.db.Where("api_key_id IN ?", keyIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		out[row.APIKeyID] = append(out[row.APIKeyID], row.TeamID)
+	}
+	return out, nil
+}
+
+// ManagedTeamIDs lists the teams in the organization that the user owns or
+// administers.
+func (r *ActivityRepository) ManagedTeamIDs(orgID, userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Raw(`
+		SELECT id FROM teams WHERE organization_id = ? AND owner_id = ?
+		UNION
+		SELECT teams.id FROM teams
+		JOIN team_memberships ON team_memberships.team_id = teams.id
+		WHERE teams.organization_id = ? AND team_memberships.user_id = ? AND team_memberships.role IN ('owner', 'admin')`,
+		orgID, userID, orgID, userID).Scan(&ids).Error
+	return ids, err
+}
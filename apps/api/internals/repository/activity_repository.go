@@ -1,12 +1,31 @@
 package repository
 
 import (
+	"strings"
 	"time"
 
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
 	"gorm.io/gorm"
 )
 
+// securityEventTypes are activity types surfaced as security events on the
+// dashboard. Any denied or failed action counts as well (see securityEvent).
+var securityEventTypes = []string{
+	"apikey_revealed",
+	"apikey_deleted",
+	"item_field_revealed",
+	"access_denied",
+	"break_glass_access",
+	"user_signin_failed",
+	"share_link_passphrase_failed",
+	"security_alert",
+	"sessions_suspended",
+}
+
+// securityEvent is the SQL condition for a security event.
+const securityEvent = "(type IN ? OR outcome IN ('denied', 'failure'))"
+
 type ActivityRepository struct {
 	db *gorm.DB
 }
@@ -14,15 +33,44 @@ type ActivityRepository struct {
 type ActivityRepositoryInterface interface {
 	Create(activity *models.Activity) error
 	List(limit, offset int) ([]models.Activity, error)
-	ListByUser(userID uint, limit, offset int) ([]models.Activity, error)
+	ListByUser(orgID, userID uint, limit, offset int) ([]models.Activity, error)
 	GetByID(id string) (*models.Activity, error)
 	CountAll() (int64, error)
-	CountByUser(userID uint) (int64, error)
+	CountByUser(orgID, userID uint) (int64, error)
 	CountToday() (int64, error)
-	CountTodayByUser(userID uint) (int64, error)
-	CountWeekByUser(userID uint) (int64, error)
+	CountTodayByUser(orgID, userID uint) (int64, error)
+	CountWeekByUser(orgID, userID uint) (int64, error)
 	CountUniqueUsers() (int64, error)
 	CountSecurityEvents() (int64, error)
+	CountSince(orgID, userID uint, since ...time.Time) ([]int64, error)
+	CountByType(q ActivityRange) ([]ActivityBucket, error)
+	CountByDay(q ActivityRange) ([]ActivityBucket, error)
+	CountByWeekday(q ActivityRange) ([]ActivityBucket, error)
+	CountByHour(q ActivityRange) ([]ActivityBucket, error)
+	CountSecurityByType(q ActivityRange) ([]ActivityBucket, error)
+	Search(q ActivityQuery) ([]models.Activity, *ActivityCursor, error)
+}
+
+// ActivityRange selects one user's activities in an organization in
+// [From, To). Day, weekday and hour buckets follow the clock in Timezone, an
+// IANA zone name.
+type ActivityRange struct {
+	OrganizationID uint
+	UserID         uint
+	From           time.Time
+	To             time.Time
+	Timezone       string
+}
+
+// userActivity is the SQL condition for one user's activities in an
+// organization.
+const userActivity = "organization_id = ? AND user_id = ?"
+
+// ActivityBucket is one group of an aggregation: an activity type, a
+// YYYY-MM-DD day, a weekday (0 is Sunday) or an hour of the day.
+type ActivityBucket struct {
+	Key   string
+	Count int64
 }
 
 func NewActivityRepository(db *gorm.DB) *ActivityRepository{
@@ -64,7 +112,7 @@ func (r *ActivityRepository) CountAll() (int64, error) {
 // Count today’s activities
 func (r *ActivityRepository) CountToday() (int64, error) {
 	var count int64
-	startOfDay := time.Now().Truncate(24 * time.Hour)
+	startOfDay := utils.StartOfDay(time.Now(), time.Local)
 	err := r.db.Model(&models.Activity{}).
 		Where("created_at >= ?", startOfDay).
 		Count(&count).Error
@@ -80,20 +128,20 @@ func (r *ActivityRepository) CountUniqueUsers() (int64, error) {
 	return count, err
 }
 
-// Count security-related events (example: reveal/delete)
+// Count security-related events (reveals, deletions, denials, break-glass access)
 func (r *ActivityRepository) CountSecurityEvents() (int64, error) {
 	var count int64
 	err := r.db.Model(&models.Activity{}).
-		Where("action IN ?", []string{"apikey.reveal", "apikey.delete"}).
+		Where(securityEvent, securityEventTypes).
 		Count(&count).Error
 	return count, err
 }
 
 // List activities by user with pagination
-func (r *ActivityRepository) ListByUser(userID uint, limit, offset int) ([]models.Activity, error) {
+func (r *ActivityRepository) ListByUser(orgID, userID uint, limit, offset int) ([]models.Activity, error) {
 	var activities []models.Activity
 	err := r.db.
-		Where("user_id = ?", userID).
+		Where(userActivity, orgID, userID).
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -102,30 +150,116 @@ func (r *ActivityRepository) ListByUser(userID uint, limit, offset int) ([]model
 }
 
 // Count activities by user
-func (r *ActivityRepository) CountByUser(userID uint) (int64, error) {
+func (r *ActivityRepository) CountByUser(orgID, userID uint) (int64, error) {
 	var count int64
 	err := r.db.Model(&models.Activity{}).
-		Where("user_id = ?", userID).
+		Where(userActivity, orgID, userID).
 		Count(&count).Error
 	return count, err
 }
 
 // Count today's activities by user
-func (r *ActivityRepository) CountTodayByUser(userID uint) (int64, error) {
+func (r *ActivityRepository) CountTodayByUser(orgID, userID uint) (int64, error) {
 	var count int64
-	startOfDay := time.Now().Truncate(24 * time.Hour)
+	startOfDay := utils.StartOfDay(time.Now(), time.Local)
 	err := r.db.Model(&models.Activity{}).
-		Where("user_id = ? AND created_at >= ?", userID, startOfDay).
+		Where(userActivity, orgID, userID).
+		Where("created_at >= ?", startOfDay).
 		Count(&count).Error
 	return count, err
 }
 
 // Count this week's activities by user
-func (r *ActivityRepository) CountWeekByUser(userID uint) (int64, error) {
+func (r *ActivityRepository) CountWeekByUser(orgID, userID uint) (int64, error) {
 	var count int64
 	weekAgo := time.Now().AddDate(0, 0, -7)
 	err := r.db.Model(&models.Activity{}).
-		Where("user_id = ? AND created_at >= ?", userID, weekAgo).
+		Where(userActivity, orgID, userID).
+		Where("created_at >= ?", weekAgo).
 		Count(&count).Error
 	return count, err
 }
+
+// CountSince counts the user's activities in the organization at or after
+// each of the given times, all in one scan: CountSince(org, id, today, week)
+// returns the counts for today and for the week.
+func (r *ActivityRepository) CountSince(orgID, userID uint, since ...time.Time) ([]int64, error) {
+	if len(since) == 0 {
+		return nil, nil
+	}
+	cols := make([]string, len(since))
+	args := make([]interface{}, len(since))
+	counts := make([]int64, len(since))
+	dest := make([]interface{}, len(since))
+	for i, t := range since {
+		cols[i] = "COUNT(*) FILTER (WHERE created_at >= ?)"
+		args[i] = t
+		dest[i] = &counts[i]
+	}
+	err := r.db.Model(&models.Activity{}).
+		Select(strings.Join(cols, ", "), args...).
+		Where(userActivity, orgID, userID).
+		Where("created_at >= ?", minTime(since)).
+		Row().Scan(dest...)
+	return counts, err
+}
+
+// CountByType counts activities in the range per type, most frequent first.
+func (r *ActivityRepository) CountByType(q ActivityRange) ([]ActivityBucket, error) {
+	return r.buckets(q, "type", "count DESC, key")
+}
+
+// CountByDay counts activities per local day. Days without activity are
+// left out.
+func (r *ActivityRepository) CountByDay(q ActivityRange) ([]ActivityBucket, error) {
+	return r.buckets(q, "to_char(created_at AT TIME ZONE ?, 'YYYY-MM-DD')", "key", q.Timezone)
+}
+
+// CountByWeekday counts activities per local weekday, 0 (Sunday) to 6.
+func (r *ActivityRepository) CountByWeekday(q ActivityRange) ([]ActivityBucket, error) {
+	return r.buckets(q, "EXTRACT(DOW FROM created_at AT TIME ZONE ?)::int::text", "key", q.Timezone)
+}
+
+// CountByHour counts activities per local hour of the day, 0 to 23.
+func (r *ActivityRepository) CountByHour(q ActivityRange) ([]ActivityBucket, error) {
+	return r.buckets(q, "EXTRACT(HOUR FROM created_at AT TIME ZONE ?)::int::text", "key", q.Timezone)
+}
+
+// CountSecurityByType counts the security events in the range per type.
+func (r *ActivityRepository) CountSecurityByType(q ActivityRange) ([]ActivityBucket, error) {
+	var rows []ActivityBucket
+	err := r.inRange(q).
+		Select("type AS key, COUNT(*) AS count").
+		Where(securityEvent, securityEventTypes).
+		Group("type").
+		Order("count DESC, key").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// buckets groups the range by a key expression, which may take args.
+func (r *ActivityRepository) buckets(q ActivityRange, key, order string, args ...interface{}) ([]ActivityBucket, error) {
+	var rows []ActivityBucket
+	err := r.inRange(q).
+		Select(key+" AS key, COUNT(*) AS count", args...).
+		Group("key").
+		Order(order).
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *ActivityRepository) inRange(q ActivityRange) *gorm.DB {
+	return r.db.Model(&models.Activity{}).
+		Where(userActivity, q.OrganizationID, q.UserID).
+		Where("created_at >= ? AND created_at < ?", q.From, q.To)
+}
+
+func minTime(ts []time.Time) time.Time {
+	min := ts[0]
+	for _, t := range ts[1:] {
+		if t.Before(min) {
+			min = t
+		}
+	}
+	return min
+}
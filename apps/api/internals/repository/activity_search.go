@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+)
+
+// ActivityQuery filters and pages the activity log, newest first. Without
+// TeamID it covers the user's own activities; with TeamID, every activity on
+// the team and on the keys shared with it (callers check the user may see them).
+type ActivityQuery struct {
+	OrganizationID uint
+	UserID         uint
+	TeamID         uint
+	Types          []string
+	Entity         string
+	EntityID       uint
+	ActorID        uint
+	From           *time.Time // inclusive
+	To             *time.Time // exclusive
+	Text           string     // case-insensitive substring of the message
+	Limit          int
+	After          *ActivityCursor
+}
+
+// ActivityCursor is the position after the last row of a page.
+type ActivityCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"id"`
+}
+
+// Search returns one page of activities and the cursor of the next page (nil
+// on the last page).
+func (r *ActivityRepository) Search(q ActivityQuery) ([]models.Activity, *ActivityCursor, error) {
+	db := r.db.Model(&models.Activity{}).Where("organization_id = ?", q.OrganizationID)
+	if q.TeamID != 0 {
+		db = db.Where("(team_id = ? OR (entity = 'apikey' AND entity_id IN (?)))", q.TeamID,
+			r.db.Table("api_key_teams").Select("api_key_id").Where("team_id = ?", q.TeamID))
+	} else {
+		db = db.Where("user_id = ?", q.UserID)
+	}
+	if len(q.Types) > 0 {
+		db = db.Where("type IN ?", q.Types)
+	}
+	if q.Entity != "" {
+		db = db.Where("entity = ?", q.Entity)
+	}
+	if q.EntityID != 0 {
+		db = db.Where("entity_id = ?", q.EntityID)
+	}
+	if q.ActorID != 0 {
+		db = db.Where("user_id = ?", q.ActorID)
+	}
+	if q.From != nil {
+		db = db.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where("created_at < ?", *q.To)
+	}
+	if q.Text != "" {
+		db = db.Where(`message ILIKE ? ESCAPE '\'`, "%"+escapeLike(q.Text)+"%")
+	}
+	if q.After != nil {
+		db = db.Where("(created_at, id) < (?, ?)", q.After.CreatedAt, q.After.ID)
+	}
+
+	var activities []models.Activity
+	err := db.Order("created_at DESC, id DESC").Limit(q.Limit + 1).Find(&activities).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(activities) <= q.Limit {
+		return activities, nil, nil
+	}
+	activities = activities[:q.Limit]
+	last := &activities[len(activities)-1]
+	return activities, &ActivityCursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+// rotationDueExpr is the moment a key's next rotation is due.
+const rotationDueExpr = "COALESCE(api_keys.last_rotated_at, api_keys.created_at) + api_keys.rotate_every_days * INTERVAL '1 day'"
+
+// Key state conditions; the first two take now, the last two now and the
+// horizon.
+const (
+	expiredCond         = "api_keys.expires_at <= ?"
+	rotationOverdueCond = "api_keys.rotate_every_days > 0 AND " + rotationDueExpr + " <= ?"
+	expiringSoonCond    = "api_keys.expires_at > ? AND api_keys.expires_at <= ?"
+	rotationDueSoonCond = "api_keys.rotate_every_days > 0 AND " + rotationDueExpr + " > ? AND " + rotationDueExpr + " <= ?"
+
+	// expired or overdue; NULL expiries make the conditions NULL, not false
+	unhealthyCond = "COALESCE((" + expiredCond + ") OR (" + rotationOverdueCond + "), false)"
+)
+
+// KeyHealth counts a set of keys by expiry and rotation state. A key can be
+// in several states; Unhealthy and AtRisk count each key once, AtRisk only
+// keys that are not already unhealthy.
+type KeyHealth struct {
+	Total           int64 `json:"total"`
+	Expired         int64 `json:"expired"`
+	ExpiringSoon    int64 `json:"expiringSoon"`
+	RotationOverdue int64 `json:"rotationOverdue"`
+	RotationDueSoon int64 `json:"rotationDueSoon"`
+	Unhealthy       int64 `json:"unhealthy"` // expired or overdue for rotation
+	AtRisk          int64 `json:"atRisk"`    // will be within the horizon
+}
+
+// ListDueForReminder returns keys that expire or are due for rotation before
+// horizon and have not been reminded about since remindedBefore.
+func (r *apiKeyRepo) ListDueForReminder(db *gorm.DB, horizon, remindedBefore time.Time) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := db.Where("api_keys.last_reminder_at IS NULL OR api_keys.last_reminder_at < ?", remindedBefore).
+		Where("(api_keys.expires_at IS NOT NULL AND api_keys.expires_at <= ?) OR (api_keys.rotate_every_days > 0 AND "+rotationDueExpr+" <= ?)", horizon, horizon).
+		Order("api_keys.id ASC").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepo) MarkReminded(db *gorm.DB, ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Model(&models.APIKey{}).Where("id IN ?", ids).UpdateColumn("last_reminder_at", at).Error
+}
+
+// SetLifecycle sets a key's expiry and rotation interval.
+func (r *apiKeyRepo) SetLifecycle(db *gorm.DB, id uint, expiresAt *time.Time, rotateEveryDays int) error {
+	return db.Model(&models.APIKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"expires_at":        expiresAt,
+		"rotate_every_days": rotateEveryDays,
+		"last_reminder_at":  nil,
+	}).Error
+}
+
+// ListDueForOwner returns the user's keys that are expired, overdue, or will be within the lead time.
+func (r *apiKeyRepo) ListDueForOwner(db *gorm.DB, orgID, ownerID uint, horizon time.Time) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := db.Where("api_keys.organization_id = ? AND api_keys.owner_id = ?", orgID, ownerID).
+		Where("(api_keys.expires_at IS NOT NULL AND api_keys.expires_at <= ?) OR (api_keys.rotate_every_days > 0 AND "+rotationDueExpr+" <= ?)", horizon, horizon).
+		Order("api_keys.expires_at ASC NULLS LAST, api_keys.id ASC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// HealthForOwner counts the user's keys by expiry and rotation state.
+func (r *apiKeyRepo) HealthForOwner(db *gorm.DB, orgID, ownerID uint, now, horizon time.Time) (*KeyHealth, error) {
+	var h KeyHealth
+	err := db.Model(&models.APIKey{}).
+		Select(`COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE `+expiredCond+`) AS expired,
+			COUNT(*) FILTER (WHERE `+expiringSoonCond+`) AS expiring_soon,
+			COUNT(*) FILTER (WHERE `+rotationOverdueCond+`) AS rotation_overdue,
+			COUNT(*) FILTER (WHERE `+rotationDueSoonCond+`) AS rotation_due_soon,
+			COUNT(*) FILTER (WHERE `+unhealthyCond+`) AS unhealthy,
+			COUNT(*) FILTER (WHERE NOT `+unhealthyCond+` AND ((`+expiringSoonCond+`) OR (`+rotationDueSoonCond+`))) AS at_risk`,
+			now, now, horizon, now, now, horizon,
+			now, now,
+			now, now, now, horizon, now, horizon).
+		Where("api_keys.organization_id = ? AND api_keys.owner_id = ?", orgID, ownerID).
+		Scan(&h).Error
+	return &h, err
+}
@@ -1,16 +1,45 @@
 package repository
 
 import (
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/jackc/pgx/v5/pgconn"
+
     "gorm.io/gorm"
     "github.com/intojhanurag/One-Password/apps/api/internals/models"
 )
 
 type APIKeyRepository interface {
     Create(db *gorm.DB, k *models.APIKey) error
-    ListByOwner(db *gorm.DB, ownerID uint) ([]models.APIKey, error)
-    GetByID(db *gorm.DB, ownerID, id uint) (*models.APIKey, error)
-    FindByOwnerAndName(db *gorm.DB, ownerID uint, name string) (*models.APIKey, error) 
-    Delete(db *gorm.DB, ownerID uint, name string) error
+    ListByOwner(db *gorm.DB, orgID, ownerID uint) ([]models.APIKey, error)
+    Search(db *gorm.DB, q KeySearchQuery) ([]models.APIKey, int64, *KeyCursor, error)
+    GetByID(db *gorm.DB, orgID, ownerID, id uint) (*models.APIKey, error)
+    FindByOwnerAndPath(db *gorm.DB, orgID, ownerID uint, path string) (*models.APIKey, error)
+    Delete(db *gorm.DB, orgID, ownerID uint, path string) error
+    FindAccessibleByID(db *gorm.DB, orgID, userID, id uint) (*models.APIKey, error)
+    ListSharedIDs(db *gorm.DB, orgID, userID uint) ([]uint, error)
+    CanManage(db *gorm.DB, orgID, userID, id uint) (bool, error)
+    SetRequiresApproval(db *gorm.DB, orgID, ownerID uint, path string, required bool) error
+    SetBreakGlassEnabled(db *gorm.DB, orgID, ownerID uint, path string, enabled bool) error
+    FindByID(db *gorm.DB, orgID, id uint) (*models.APIKey, error)
+    ListOwnerIDs(db *gorm.DB, id uint) ([]uint, error)
+    ListByEnvironment(db *gorm.DB, envID uint) ([]models.APIKey, error)
+    FindInEnvironment(db *gorm.DB, envID uint, name string) (*models.APIKey, error)
+    Update(db *gorm.DB, k *models.APIKey) error
+    DeleteByID(db *gorm.DB, id uint) error
+    FindByPath(db *gorm.DB, orgID uint, path string) (*models.APIKey, error)
+    ListUnderPath(db *gorm.DB, orgID uint, folder string) ([]models.APIKey, error)
+    MovePath(db *gorm.DB, orgID uint, from, to string) (int64, error)
+    BackfillPaths(db *gorm.DB) error
+    ListDueForReminder(db *gorm.DB, horizon, remindedBefore time.Time) ([]models.APIKey, error)
+    MarkReminded(db *gorm.DB, ids []uint, at time.Time) error
+    SetLifecycle(db *gorm.DB, id uint, expiresAt *time.Time, rotateEveryDays int) error
+    ListDueForOwner(db *gorm.DB, orgID, ownerID uint, horizon time.Time) ([]models.APIKey, error)
+    HealthForOwner(db *gorm.DB, orgID, ownerID uint, now, horizon time.Time) (*KeyHealth, error)
 }
 
 type apiKeyRepo struct{}
@@ -19,28 +48,260 @@ func NewAPIKeyRepository() APIKeyRepository {
     return &apiKeyRepo{}
 }
 
+// Create stores the key and, unless it belongs to a project environment, the
+// api_key item that lists it among the user's items. A path already taken in
+// the organization fails with gorm.ErrDuplicatedKey.
 func (r *apiKeyRepo) Create(db *gorm.DB, k *models.APIKey) error {
-    return db.Create(k).Error
+    if err := db.Create(k).Error; err != nil {
+        return pathConflict(err)
+    }
+    if k.EnvironmentID != nil {
+        return nil
+    }
+    return createAPIKeyItem(db, k.ID)
 }
 
-func (r *apiKeyRepo) ListByOwner(db *gorm.DB, ownerID uint) ([]models.APIKey, error) {
+func (r *apiKeyRepo) ListByOwner(db *gorm.DB, orgID, ownerID uint) ([]models.APIKey, error) {
     var keys []models.APIKey
-    err := db.Where("owner_id = ?", ownerID).Find(&keys).Error
+    err := db.Where("organization_id = ? AND owner_id = ? AND environment_id IS NULL", orgID, ownerID).
+        Preload("TagList").
+        Preload("CustomFields").
+        Find(&keys).Error
     return keys, err
 }
 
-func (r *apiKeyRepo) GetByID(db *gorm.DB, ownerID, id uint) (*models.APIKey, error) {
+func (r *apiKeyRepo) GetByID(db *gorm.DB, orgID, ownerID, id uint) (*models.APIKey, error) {
+    var key models.APIKey
+    err := db.Where("organization_id = ? AND owner_id = ? AND id = ?", orgID, ownerID, id).First(&key).Error
+    return &key, err
+}
+
+// FindByOwnerAndPath finds one of the owner's keys by its full path. Names
+// are only the last segment and repeat across folders.
+func (r *apiKeyRepo) FindByOwnerAndPath(db *gorm.DB, orgID, ownerID uint, path string) (*models.APIKey, error) {
+    var key models.APIKey
+    err := db.Where("organization_id = ? AND owner_id = ? AND path = ? AND environment_id IS NULL", orgID, ownerID, path).First(&key).Error
+    return &key, err
+}
+
+func (r *apiKeyRepo) Delete(db *gorm.DB, orgID, ownerID uint, path string) error {
+    res := db.Where("organization_id = ? AND owner_id = ? AND path = ? AND environment_id IS NULL", orgID, ownerID, path).Delete(&models.APIKey{})
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return gorm.ErrRecordNotFound
+    }
+    return nil
+}
+
+// FindAccessibleByID returns the key if the user owns it or belongs to a team it is attached to.
+func (r *apiKeyRepo) FindAccessibleByID(db *gorm.DB, orgID, userID, id uint) (*models.APIKey, error) {
+    var key models.APIKey
+    err := db.Where("organization_id = ? AND id = ?", orgID, id).
+        Where("owner_id = ? OR id IN (?)", userID, teamKeyIDs(db, userID, nil)).
+        First(&key).Error
+    return &key, err
+}
+
+// ListSharedIDs returns the ids of the organization's keys shared with teams the user is a member of.
+func (r *apiKeyRepo) ListSharedIDs(db *gorm.DB, orgID, userID uint) ([]uint, error) {
+    var ids []uint
+    err := db.Model(&models.APIKey{}).
+        Where("organization_id = ? AND id IN (?)", orgID, teamKeyIDs(db, userID, nil)).
+        Pluck("id", &ids).Error
+    return ids, err
+}
+
+// CanManage reports whether the user owns the key or is an owner/admin of a team it is attached to.
+func (r *apiKeyRepo) CanManage(db *gorm.DB, orgID, userID, id uint) (bool, error) {
+    var count int64
+    err := db.Model(&models.APIKey{}).
+        Where("organization_id = ? AND id = ?", orgID, id).
+        Where("owner_id = ? OR id IN (?)", userID, teamKeyIDs(db, userID, []string{"owner", "admin"})).
+        Count(&count).Error
+    return count > 0, err
+}
+
+func (r *apiKeyRepo) SetRequiresApproval(db *gorm.DB, orgID, ownerID uint, path string, required bool) error {
+    return updateOwnedFlag(db, orgID, ownerID, path, "requires_approval", required)
+}
+
+func (r *apiKeyRepo) SetBreakGlassEnabled(db *gorm.DB, orgID, ownerID uint, path string, enabled bool) error {
+    return updateOwnedFlag(db, orgID, ownerID, path, "break_glass_enabled", enabled)
+}
+
+func (r *apiKeyRepo) FindByID(db *gorm.DB, orgID, id uint) (*models.APIKey, error) {
     var key models.APIKey
-    err := db.Where("owner_id = ? AND id = ?", ownerID, id).First(&key).Error
+    err := db.Where("organization_id = ? AND id = ?", orgID, id).First(&key).Error
     return &key, err
 }
 
-func (r *apiKeyRepo) FindByOwnerAndName(db *gorm.DB, ownerID uint, name string) (*models.APIKey, error) {
+// ListOwnerIDs returns the key owner plus the owners of every team the key is attached to.
+func (r *apiKeyRepo) ListOwnerIDs(db *gorm.DB, id uint) ([]uint, error) {
+    var ids []uint
+    err := db.Raw(`
+        SELECT owner_id FROM api_keys WHERE id = ?
+        UNION
+        SELECT team_memberships.user_id FROM api_key_teams
+        JOIN team_memberships ON team_memberships.team_id = api_key_teams.team_id
+        WHERE api_key_teams.api_key_id = ? AND team_memberships.role = 'owner'`, id, id).
+        Scan(&ids).Error
+    return ids, err
+}
+
+// ListByEnvironment returns the secrets of one project environment ordered by name.
+func (r *apiKeyRepo) ListByEnvironment(db *gorm.DB, envID uint) ([]models.APIKey, error) {
+    var keys []models.APIKey
+    err := db.Where("environment_id = ?", envID).Order("name ASC").Find(&keys).Error
+    return keys, err
+}
+
+func (r *apiKeyRepo) FindInEnvironment(db *gorm.DB, envID uint, name string) (*models.APIKey, error) {
     var key models.APIKey
-    err := db.Where("owner_id = ? AND name = ?", ownerID, name).First(&key).Error
+    err := db.Where("environment_id = ? AND name = ?", envID, name).First(&key).Error
     return &key, err
 }
 
-func (r *apiKeyRepo) Delete(db *gorm.DB, ownerID uint, name string) error {
-    return db.Where("owner_id = ? AND name = ?", ownerID, name).Delete(&models.APIKey{}).Error
+func (r *apiKeyRepo) Update(db *gorm.DB, k *models.APIKey) error {
+    return db.Save(k).Error
+}
+
+func (r *apiKeyRepo) DeleteByID(db *gorm.DB, id uint) error {
+    return db.Delete(&models.APIKey{}, id).Error
+}
+
+// FindByPath returns the key stored at an exact path outside any project environment.
+func (r *apiKeyRepo) FindByPath(db *gorm.DB, orgID uint, path string) (*models.APIKey, error) {
+    var key models.APIKey
+    err := db.Where("organization_id = ? AND path = ? AND environment_id IS NULL", orgID, path).First(&key).Error
+    return &key, err
+}
+
+// ListUnderPath returns the keys at or beneath a folder, ordered by path. An
+// empty folder lists the whole organization.
+func (r *apiKeyRepo) ListUnderPath(db *gorm.DB, orgID uint, folder string) ([]models.APIKey, error) {
+    var keys []models.APIKey
+    q := db.Where("organization_id = ? AND environment_id IS NULL", orgID)
+    if folder != "" {
+        q = q.Where("path = ? OR path LIKE ? ESCAPE '\\'", folder, likePrefix(folder+"/"))
+    }
+    err := q.Order("path ASC").Find(&keys).Error
+    return keys, err
+}
+
+// MovePath rewrites the path prefix of a key or a whole subtree. The name of
+// every moved key is kept in sync with the last path segment. A move onto a
+// taken path fails with gorm.ErrDuplicatedKey.
+func (r *apiKeyRepo) MovePath(db *gorm.DB, orgID uint, from, to string) (int64, error) {
+    res := db.Exec(`
+        UPDATE api_keys
+        SET path = ? || substring(path from ?),
+            name = regexp_replace(? || substring(path from ?), '^.*/', ''),
+            updated_at = NOW()
+        WHERE organization_id = ? AND environment_id IS NULL
+          AND (path = ? OR path LIKE ? ESCAPE '\')`,
+        to, pathOffset(from), to, pathOffset(from), orgID, from, likePrefix(from+"/"))
+    return res.RowsAffected, pathConflict(res.Error)
+}
+
+// keyPathIndex keeps paths unique within an organization. Keys in project
+// environments are addressed by environment and name instead.
+const keyPathIndex = "idx_api_keys_org_path"
+
+// BackfillPaths gives keys created before paths existed their name as path,
+// renames keys whose path another key in the organization already has, and
+// then builds the unique path index. A clashing key gets its id appended
+// ("stripe-42") and is otherwise left alone; the oldest key keeps the path.
+func (r *apiKeyRepo) BackfillPaths(db *gorm.DB) error {
+    return db.Transaction(func(tx *gorm.DB) error {
+        err := tx.Exec(`
+            UPDATE api_keys k
+            SET path = CASE WHEN EXISTS (
+                    SELECT 1 FROM api_keys o
+                    WHERE o.organization_id = k.organization_id AND o.environment_id IS NULL AND o.id <> k.id
+                      AND (o.path = k.name OR (o.path = '' AND o.name = k.name AND o.id < k.id))
+                ) THEN k.name || '-' || k.id ELSE k.name END,
+                name = CASE WHEN EXISTS (
+                    SELECT 1 FROM api_keys o
+                    WHERE o.organization_id = k.organization_id AND o.environment_id IS NULL AND o.id <> k.id
+                      AND (o.path = k.name OR (o.path = '' AND o.name = k.name AND o.id < k.id))
+                ) THEN k.name || '-' || k.id ELSE k.name END
+            WHERE k.path = '' AND k.environment_id IS NULL`).Error
+        if err != nil {
+            return err
+        }
+        err = tx.Exec(`
+            UPDATE api_keys k
+            SET path = k.path || '-' || k.id, name = k.name || '-' || k.id, updated_at = NOW()
+            WHERE k.environment_id IS NULL AND EXISTS (
+                SELECT 1 FROM api_keys o
+                WHERE o.organization_id = k.organization_id AND o.environment_id IS NULL
+                  AND o.path = k.path AND o.id < k.id)`).Error
+        if err != nil {
+            return err
+        }
+        return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ` + keyPathIndex + `
+            ON api_keys (organization_id, path) WHERE environment_id IS NULL`).Error
+    })
+}
+
+// pathOffset is where the rest of a path starts after the prefix, counted in
+// characters as Postgres' substring does.
+func pathOffset(prefix string) int {
+    return utf8.RuneCountInString(prefix) + 1
+}
+
+// pathConflict reports a violation of the unique path index as
+// gorm.ErrDuplicatedKey.
+func pathConflict(err error) error {
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == keyPathIndex {
+        return fmt.Errorf("%w: %s", gorm.ErrDuplicatedKey, pgErr.Detail)
+    }
+    return err
+}
+
+// likePrefix escapes LIKE wildcards in prefix and appends '%'.
+func likePrefix(prefix string) string {
+    return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(prefix) + "%"
+}
+
+func updateOwnedFlag(db *gorm.DB, orgID, ownerID uint, path, column string, value bool) error {
+    res := db.Model(&models.APIKey{}).
+        Where("organization_id = ? AND owner_id = ? AND path = ? AND environment_id IS NULL", orgID, ownerID, path).
+        Update(column, value)
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return gorm.ErrRecordNotFound
+    }
+    return nil
+}
+
+// orgKeyIDs selects the ids of the organization's keys.
+func orgKeyIDs(db *gorm.DB, orgID uint) *gorm.DB {
+    return db.Model(&models.APIKey{}).Select("id").Where("organization_id = ?", orgID)
+}
+
+// teamKeyIDs selects the ids of keys shared with teams the user is a member of,
+// optionally restricted to the given team roles.
+func teamKeyIDs(db *gorm.DB, userID uint, roles []string) *gorm.DB {
+    q := db.Table("api_key_teams").
+        Select("api_key_teams.api_key_id").
+        Joins("JOIN team_memberships ON team_memberships.team_id = api_key_teams.team_id").
+        Where("team_memberships.user_id = ?", userID)
+    if len(roles) > 0 {
+        q = q.Where("team_memberships.role IN ?", roles)
+    }
+    return q
+}
+
+// managedKeyIDs selects the ids of keys in the org that the user owns or manages as a team owner/admin.
+func managedKeyIDs(db *gorm.DB, orgID, userID uint) *gorm.DB {
+    return db.Model(&models.APIKey{}).
+        Select("id").
+        Where("organization_id = ?", orgID).
+        Where("owner_id = ? OR id IN (?)", userID, teamKeyIDs(db, userID, []string{"owner", "admin"}))
 }
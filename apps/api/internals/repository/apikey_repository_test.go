@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+func createKey(t *testing.T, db *gorm.DB, path string) *models.APIKey {
+	t.Helper()
+	k := &models.APIKey{Name: utils.PathBase(path), Path: path, OrganizationID: 1, OwnerID: 1, Ciphertext: "ct", Nonce: "nonce"}
+	if err := NewAPIKeyRepository().Create(db, k); err != nil {
+		t.Fatalf("Create %s: %v", path, err)
+	}
+	return k
+}
+
+func TestKeysAreAddressedByPath(t *testing.T) {
+	db := testDB(t, &models.APIKey{}, &models.Item{})
+	repo := NewAPIKeyRepository()
+	if err := repo.BackfillPaths(db); err != nil {
+		t.Fatalf("BackfillPaths: %v", err)
+	}
+
+	a := createKey(t, db, "a/live")
+	b := createKey(t, db, "b/live")
+
+	got, err := repo.FindByOwnerAndPath(db, 1, 1, "b/live")
+	if err != nil || got.ID != b.ID {
+		t.Fatalf("FindByOwnerAndPath(b/live) = %+v, %v; want key %d", got, err, b.ID)
+	}
+	if err := repo.SetRequiresApproval(db, 1, 1, "a/live", true); err != nil {
+		t.Fatalf("SetRequiresApproval: %v", err)
+	}
+	got, _ = repo.FindByID(db, 1, b.ID)
+	if got.RequiresApproval {
+		t.Error("setting a flag on a/live changed b/live")
+	}
+	if err := repo.Delete(db, 1, 1, "live"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Delete by bare name = %v, want ErrRecordNotFound", err)
+	}
+	if err := repo.Delete(db, 1, 1, "a/live"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.FindByID(db, 1, a.ID); err == nil {
+		t.Error("a/live still exists")
+	}
+	if _, err := repo.FindByID(db, 1, b.ID); err != nil {
+		t.Errorf("b/live was deleted too: %v", err)
+	}
+
+	dup := &models.APIKey{Name: "live", Path: "b/live", OrganizationID: 1, OwnerID: 2, Ciphertext: "ct", Nonce: "nonce"}
+	if err := repo.Create(db, dup); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("Create on a taken path = %v, want ErrDuplicatedKey", err)
+	}
+}
+
+func TestMovePathCountsCharacters(t *testing.T) {
+	db := testDB(t, &models.APIKey{}, &models.Item{}, &models.FolderPermission{})
+	repo := NewAPIKeyRepository()
+
+	createKey(t, db, "zahlungsfähig/stripe")
+	createKey(t, db, "zahlungsfähig/prüfung/adyen")
+	if err := db.Create(&models.FolderPermission{OrganizationID: 1, Path: "zahlungsfähig/prüfung", TeamID: 1, Access: "read", GrantedBy: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := repo.MovePath(db, 1, "zahlungsfähig", "billing")
+	if err != nil || n != 2 {
+		t.Fatalf("MovePath = %d, %v; want 2", n, err)
+	}
+	if err := NewFolderPermissionRepository().MovePath(db, 1, "zahlungsfähig", "billing"); err != nil {
+		t.Fatalf("folder MovePath: %v", err)
+	}
+
+	keys, err := repo.ListUnderPath(db, 1, "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"billing/prüfung/adyen", "billing/stripe"}
+	if len(keys) != len(want) {
+		t.Fatalf("moved keys = %+v", keys)
+	}
+	for i, k := range keys {
+		if k.Path != want[i] || k.Name != utils.PathBase(want[i]) {
+			t.Errorf("key %d = %s (%s), want %s", i, k.Path, k.Name, want[i])
+		}
+	}
+	var perm models.FolderPermission
+	if err := db.First(&perm).Error; err != nil || perm.Path != "billing/prüfung" {
+		t.Errorf("folder grant moved to %q, %v; want billing/prüfung", perm.Path, err)
+	}
+}
+
+func TestBackfillPathsRenamesClashes(t *testing.T) {
+	db := testDB(t, &models.APIKey{})
+	legacy := []models.APIKey{
+		{Name: "stripe", OrganizationID: 1, OwnerID: 1, Ciphertext: "ct", Nonce: "n"},
+		{Name: "stripe", OrganizationID: 1, OwnerID: 2, Ciphertext: "ct", Nonce: "n"},
+		{Name: "stripe", OrganizationID: 2, OwnerID: 3, Ciphertext: "ct", Nonce: "n"},
+		{Name: "live", Path: "a/live", OrganizationID: 1, OwnerID: 1, Ciphertext: "ct", Nonce: "n"},
+		{Name: "live", Path: "a/live", OrganizationID: 1, OwnerID: 2, Ciphertext: "ct", Nonce: "n"},
+	}
+	if err := db.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAPIKeyRepository().BackfillPaths(db); err != nil {
+		t.Fatalf("BackfillPaths: %v", err)
+	}
+
+	var keys []models.APIKey
+	db.Order("id").Find(&keys)
+	want := []string{"stripe", "stripe-" + strconv.FormatUint(uint64(keys[1].ID), 10), "stripe", "a/live", "a/live-" + strconv.FormatUint(uint64(keys[4].ID), 10)}
+	for i, k := range keys {
+		if k.Path != want[i] || k.Name != utils.PathBase(want[i]) {
+			t.Errorf("key %d = %s (%s), want %s", k.ID, k.Path, k.Name, want[i])
+		}
+	}
+	// running it again changes nothing and keeps the index
+	if err := NewAPIKeyRepository().BackfillPaths(db); err != nil {
+		t.Fatalf("second BackfillPaths: %v", err)
+	}
+}
+
+func TestHealthCountsEachKeyOnce(t *testing.T) {
+	db := testDB(t, &models.APIKey{}, &models.Item{})
+	repo := NewAPIKeyRepository()
+	now := time.Now()
+	past, soon, later := now.Add(-time.Hour), now.Add(time.Hour), now.AddDate(0, 1, 0)
+	rotatedLongAgo := now.AddDate(0, 0, -40)
+
+	for i, k := range []models.APIKey{
+		{ExpiresAt: &past, RotateEveryDays: 30, LastRotatedAt: &rotatedLongAgo}, // expired and overdue
+		{ExpiresAt: &soon, RotateEveryDays: 30, LastRotatedAt: &rotatedLongAgo}, // overdue and expiring soon
+		{ExpiresAt: &soon},  // expiring soon
+		{ExpiresAt: &later}, // fine
+		{},                  // fine
+	} {
+		key := createKey(t, db, "k"+strconv.Itoa(i))
+		err := db.Model(key).Updates(map[string]interface{}{
+			"expires_at": k.ExpiresAt, "rotate_every_days": k.RotateEveryDays, "last_rotated_at": k.LastRotatedAt,
+		}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := repo.HealthForOwner(db, 1, 1, now, now.Add(7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("HealthForOwner: %v", err)
+	}
+	if h.Total != 5 || h.Expired != 1 || h.RotationOverdue != 2 || h.ExpiringSoon != 2 {
+		t.Errorf("counts = %+v", h)
+	}
+	if h.Unhealthy != 2 || h.AtRisk != 1 {
+		t.Errorf("unhealthy = %d, at risk = %d; want 2 and 1", h.Unhealthy, h.AtRisk)
+	}
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+// KeySearchQuery filters, sorts and pages a user's keys outside project environments.
+type KeySearchQuery struct {
+	OrgID          uint
+	UserID         uint
+	TeamID         uint // when set, list the keys shared with this team (if the user is a member) instead of the user's own
+	Text           string
+	Tags           utils.TagExpr
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	ExpiringBefore *time.Time
+	Sort           string // one of KeySortFields, or "relevance" with Text
+	Desc           bool
+	Limit          int
+	After          *KeyCursor
+}
+
+// KeyCursor is the position after the last row of a page. Relevance-sorted
+// pages use Offset since ranks are not stable keys.
+type KeyCursor struct {
+	Sort   string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Value  string `json:"v,omitempty"`
+	ID     uint   `json:"id,omitempty"`
+	Offset int    `json:"o,omitempty"`
+}
+
+type keySort struct {
+	expr string // SQL expression the rows are ordered by
+	cast string // type the cursor value is cast to
+	// value returns the cursor value of a row
+	value func(k *models.APIKey) string
+}
+
+// KeySortFields are the columns /apikeys/list can be sorted by.
+var KeySortFields = map[string]keySort{
+	"created_at": {"api_keys.created_at", "timestamptz", func(k *models.APIKey) string { return k.CreatedAt.Format(time.RFC3339Nano) }},
+	"updated_at": {"api_keys.updated_at", "timestamptz", func(k *models.APIKey) string { return k.UpdatedAt.Format(time.RFC3339Nano) }},
+	"name":       {"api_keys.name", "text", func(k *models.APIKey) string { return k.Name }},
+	"expires_at": {"COALESCE(api_keys.expires_at, 'infinity'::timestamptz)", "timestamptz", func(k *models.APIKey) string {
+		if k.ExpiresAt == nil {
+			return "infinity"
+		}
+		return k.ExpiresAt.Format(time.RFC3339Nano)
+	}},
+}
+
+// Search returns one page of keys, the total number of matches and the cursor
+// of the next page (nil on the last page).
+func (r *apiKeyRepo) Search(db *gorm.DB, q KeySearchQuery) ([]models.APIKey, int64, *KeyCursor, error) {
+	base := db.Model(&models.APIKey{}).Where("api_keys.organization_id = ? AND api_keys.environment_id IS NULL", q.OrgID)
+	if q.TeamID != 0 {
+		base = base.Where("api_keys.id IN (?)", db.Table("api_key_teams").Select("api_key_id").
+			Where("team_id = ? AND team_id IN (?)", q.TeamID,
+				db.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", q.UserID)))
+	} else {
+		base = base.Where("api_keys.owner_id = ?", q.UserID)
+	}
+	if q.Text != "" {
+		base = base.Where("api_keys.search_vector @@ websearch_to_tsquery('simple', ?)", q.Text)
+	}
+	if q.Tags != nil {
+		cond, args := tagExprSQL(q.Tags)
+		base = base.Where(cond, args...)
+	}
+	if q.CreatedAfter != nil {
+		base = base.Where("api_keys.created_at >= ?", *q.CreatedAfter)
+	}
+	if q.CreatedBefore != nil {
+		base = base.Where("api_keys.created_at < ?", *q.CreatedBefore)
+	}
+	if q.ExpiringBefore != nil {
+		base = base.Where("api_keys.expires_at IS NOT NULL AND api_keys.expires_at <= ?", *q.ExpiringBefore)
+	}
+
+	var total int64
+	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	dir := "ASC"
+	cmp := ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	page := base.Session(&gorm.Session{}).Preload("TagList").Preload("CustomFields").Limit(q.Limit + 1)
+	offset := 0
+	var sort keySort
+	if q.Sort == "relevance" {
+		page = page.Order(gorm.Expr("ts_rank(api_keys.search_vector, websearch_to_tsquery('simple', ?)) DESC, api_keys.id DESC", q.Text))
+		if q.After != nil {
+			offset = q.After.Offset
+			page = page.Offset(offset)
+		}
+	} else {
+		sort = KeySortFields[q.Sort]
+		if q.After != nil {
+			page = page.Where(fmt.Sprintf("(%s, api_keys.id) %s (CAST(? AS %s), ?)", sort.expr, cmp, sort.cast), q.After.Value, q.After.ID)
+		}
+		page = page.Order(fmt.Sprintf("%s %s, api_keys.id %s", sort.expr, dir, dir))
+	}
+
+	var keys []models.APIKey
+	if err := page.Find(&keys).Error; err != nil {
+		return nil, 0, nil, err
+	}
+	if len(keys) <= q.Limit {
+		return keys, total, nil, nil
+	}
+
+	keys = keys[:q.Limit]
+	next := &KeyCursor{Sort: q.Sort, Desc: q.Desc}
+	if q.Sort == "relevance" {
+		next.Offset = offset + q.Limit
+	} else {
+		last := &keys[len(keys)-1]
+		next.Value = sort.value(last)
+		next.ID = last.ID
+	}
+	return keys, total, next, nil
+}
+
+// tagExprSQL compiles a tag expression into a WHERE condition on api_keys.
+func tagExprSQL(e utils.TagExpr) (string, []interface{}) {
+	switch e := e.(type) {
+	case utils.TagAnd:
+		l, la := tagExprSQL(e.Left)
+		r, ra := tagExprSQL(e.Right)
+		return "(" + l + " AND " + r + ")", append(la, ra...)
+	case utils.TagOr:
+		l, la := tagExprSQL(e.Left)
+		r, ra := tagExprSQL(e.Right)
+		return "(" + l + " OR " + r + ")", append(la, ra...)
+	case utils.TagNot:
+		inner, args := tagExprSQL(e.Inner)
+		return "NOT " + inner, args
+	case utils.TagTerm:
+		cond := "EXISTS (SELECT 1 FROM api_key_tags JOIN tags ON tags.id = api_key_tags.tag_id " +
+			"WHERE api_key_tags.api_key_id = api_keys.id AND tags.key = ?"
+		args := []interface{}{e.Key}
+		if e.Value != "" {
+			cond += " AND tags.value = ?"
+			args = append(args, e.Value)
+		}
+		return cond + ")", args
+	default:
+		panic(fmt.Sprintf("unknown tag expression node %T", e))
+	}
+}
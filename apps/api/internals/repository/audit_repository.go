@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type AuditRepository interface {
+	// Head returns the last sealed activity's sequence number and hash; 0 and "" for an empty chain.
+	Head(db *gorm.DB) (int64, string, error)
+	ListUnsealed(db *gorm.DB) ([]models.Activity, error)
+	Seal(db *gorm.DB, a *models.Activity) error
+	EachSealed(db *gorm.DB, batch int, fn func([]models.Activity) error) error
+	EachForExport(db *gorm.DB, f audit.ExportFilter, through int64, batch int, fn func([]models.Activity) error) error
+	LastCheckpoint(db *gorm.DB) (*models.AuditCheckpoint, error)
+	CreateCheckpoint(db *gorm.DB, c *models.AuditCheckpoint) error
+	ListCheckpoints(db *gorm.DB) ([]models.AuditCheckpoint, error)
+}
+
+type auditRepo struct{}
+
+func NewAuditRepository() AuditRepository { return &auditRepo{} }
+
+func (r *auditRepo) Head(db *gorm.DB) (int64, string, error) {
+	var head models.Activity
+	err := db.Select("seq", "hash").Where("hash <> ''").Order("seq DESC").Take(&head).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return head.Seq, head.Hash, nil
+}
+
+// ListUnsealed returns activities outside the chain, oldest first: rows from
+// before the chain existed, or ones inserted behind the application's back.
+func (r *auditRepo) ListUnsealed(db *gorm.DB) ([]models.Activity, error) {
+	var out []models.Activity
+	err := db.Where("seq IS NULL OR hash = ''").Order("id ASC").Find(&out).Error
+	return out, err
+}
+
+// Seal writes an activity's chain columns, which the append-only trigger
+// allows only once.
+func (r *auditRepo) Seal(db *gorm.DB, a *models.Activity) error {
+	return db.Model(a).UpdateColumns(map[string]interface{}{
+		"seq":       a.Seq,
+		"prev_hash": a.PrevHash,
+		"hash":      a.Hash,
+	}).Error
+}
+
+// EachSealed walks the chain in sequence order, batch entries at a time.
+func (r *auditRepo) EachSealed(db *gorm.DB, batch int, fn func([]models.Activity) error) error {
+	var after int64
+	for {
+		var page []models.Activity
+		err := db.Where("seq > ? AND hash <> ''", after).Order("seq ASC").Limit(batch).Find(&page).Error
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		after = page[len(page)-1].Seq
+	}
+}
+
+// EachForExport walks the sealed activities matching f, up to entry through,
+// in sequence order, batch entries at a time.
+func (r *auditRepo) EachForExport(db *gorm.DB, f audit.ExportFilter, through int64, batch int, fn func([]models.Activity) error) error {
+	q := db.Model(&models.Activity{}).Where("seq <= ? AND hash <> ''", through)
+	if f.OrganizationID != 0 {
+		q = q.Where("organization_id = ?", f.OrganizationID)
+	}
+	if f.UserID != 0 {
+		q = q.Where("user_id = ?", f.UserID)
+	}
+	if len(f.Types) > 0 {
+		q = q.Where("type IN ?", f.Types)
+	}
+	if f.Entity != "" {
+		q = q.Where("entity = ?", f.Entity)
+	}
+	if f.From != nil {
+		q = q.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("created_at < ?", *f.To)
+	}
+
+	var after int64
+	for {
+		var page []models.Activity
+		err := q.Session(&gorm.Session{}).Where("seq > ?", after).Order("seq ASC").Limit(batch).Find(&page).Error
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		after = page[len(page)-1].Seq
+	}
+}
+
+func (r *auditRepo) LastCheckpoint(db *gorm.DB) (*models.AuditCheckpoint, error) {
+	var c models.AuditCheckpoint
+	if err := db.Order("seq DESC").First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *auditRepo) CreateCheckpoint(db *gorm.DB, c *models.AuditCheckpoint) error {
+	return db.Create(c).Error
+}
+
+func (r *auditRepo) ListCheckpoints(db *gorm.DB) ([]models.AuditCheckpoint, error) {
+	var out []models.AuditCheckpoint
+	err := db.Order("seq ASC").Find(&out).Error
+	return out, err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type BreakGlassRepository interface {
+	Create(db *gorm.DB, b *models.BreakGlassAccess) error
+	GetByID(db *gorm.DB, id uint) (*models.BreakGlassAccess, error)
+	Update(db *gorm.DB, b *models.BreakGlassAccess) error
+	FindActive(db *gorm.DB, id, userID uint, now time.Time) (*models.BreakGlassAccess, error)
+	ListByUser(db *gorm.DB, orgID, userID uint) ([]models.BreakGlassAccess, error)
+	ListUnacknowledgedForManager(db *gorm.DB, orgID, managerID uint) ([]models.BreakGlassAccess, error)
+}
+
+type breakGlassRepo struct{}
+
+func NewBreakGlassRepository() BreakGlassRepository { return &breakGlassRepo{} }
+
+func (r *breakGlassRepo) Create(db *gorm.DB, b *models.BreakGlassAccess) error {
+	return db.Create(b).Error
+}
+
+func (r *breakGlassRepo) GetByID(db *gorm.DB, id uint) (*models.BreakGlassAccess, error) {
+	var b models.BreakGlassAccess
+	if err := db.First(&b, id).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *breakGlassRepo) Update(db *gorm.DB, b *models.BreakGlassAccess) error {
+	return db.Save(b).Error
+}
+
+// FindActive returns the grant if it belongs to the user and its window is still open.
+func (r *breakGlassRepo) FindActive(db *gorm.DB, id, userID uint, now time.Time) (*models.BreakGlassAccess, error) {
+	var b models.BreakGlassAccess
+	err := db.Where("id = ? AND user_id = ? AND expires_at > ?", id, userID, now).First(&b).Error
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListByUser returns the user's break-glass grants on keys in the organization.
+func (r *breakGlassRepo) ListByUser(db *gorm.DB, orgID, userID uint) ([]models.BreakGlassAccess, error) {
+	var bs []models.BreakGlassAccess
+	err := db.Where("user_id = ? AND api_key_id IN (?)", userID, orgKeyIDs(db, orgID)).Order("created_at DESC").Find(&bs).Error
+	return bs, err
+}
+
+// ListUnacknowledgedForManager returns grants awaiting post-incident acknowledgment
+// on keys the user owns or manages through a team.
+func (r *breakGlassRepo) ListUnacknowledgedForManager(db *gorm.DB, orgID, managerID uint) ([]models.BreakGlassAccess, error) {
+	var bs []models.BreakGlassAccess
+	managed := managedKeyIDs(db, orgID, managerID)
+	err := db.Where("acknowledged_at IS NULL AND api_key_id IN (?)", managed).
+		Order("created_at ASC").
+		Find(&bs).Error
+	return bs, err
+}
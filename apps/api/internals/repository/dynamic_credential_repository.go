@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type DynamicCredentialRepository interface {
+	SaveRole(db *gorm.DB, role *models.DynamicRole) error
+	GetRole(db *gorm.DB, orgID uint, name string) (*models.DynamicRole, error)
+	GetRoleByID(db *gorm.DB, id uint) (*models.DynamicRole, error)
+	ListRoles(db *gorm.DB, orgID uint) ([]models.DynamicRole, error)
+	DeleteRole(db *gorm.DB, id uint) error
+	CountActiveLeases(db *gorm.DB, roleID uint) (int64, error)
+	IsTeamMember(db *gorm.DB, teamID, userID uint) (bool, error)
+	CreateLease(db *gorm.DB, l *models.DynamicLease) error
+	GetLease(db *gorm.DB, orgID uint, leaseID string) (*models.DynamicLease, error)
+	UpdateLease(db *gorm.DB, l *models.DynamicLease) error
+	ListLeases(db *gorm.DB, orgID, userID uint) ([]models.DynamicLease, error)
+	ListExpiredLeases(db *gorm.DB, now time.Time, limit int) ([]models.DynamicLease, error)
+	ClaimLease(db *gorm.DB, id uint, now, until time.Time) (bool, error)
+}
+
+type dynamicCredentialRepo struct{}
+
+func NewDynamicCredentialRepository() DynamicCredentialRepository { return &dynamicCredentialRepo{} }
+
+func (r *dynamicCredentialRepo) SaveRole(db *gorm.DB, role *models.DynamicRole) error {
+	return db.Save(role).Error
+}
+
+func (r *dynamicCredentialRepo) GetRole(db *gorm.DB, orgID uint, name string) (*models.DynamicRole, error) {
+	var role models.DynamicRole
+	if err := db.Where("organization_id = ? AND name = ?", orgID, name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *dynamicCredentialRepo) GetRoleByID(db *gorm.DB, id uint) (*models.DynamicRole, error) {
+	var role models.DynamicRole
+	if err := db.First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *dynamicCredentialRepo) ListRoles(db *gorm.DB, orgID uint) ([]models.DynamicRole, error) {
+	var roles []models.DynamicRole
+	err := db.Where("organization_id = ?", orgID).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *dynamicCredentialRepo) DeleteRole(db *gorm.DB, id uint) error {
+	return db.Delete(&models.DynamicRole{}, id).Error
+}
+
+func (r *dynamicCredentialRepo) CountActiveLeases(db *gorm.DB, roleID uint) (int64, error) {
+	var n int64
+	err := db.Model(&models.DynamicLease{}).Where("role_id = ? AND status = ?", roleID, models.LeaseActive).Count(&n).Error
+	return n, err
+}
+
+func (r *dynamicCredentialRepo) IsTeamMember(db *gorm.DB, teamID, userID uint) (bool, error) {
+	var n int64
+	err := db.Model(&models.TeamMembership{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&n).Error
+	return n > 0, err
+}
+
+func (r *dynamicCredentialRepo) CreateLease(db *gorm.DB, l *models.DynamicLease) error {
+	return db.Create(l).Error
+}
+
+func (r *dynamicCredentialRepo) GetLease(db *gorm.DB, orgID uint, leaseID string) (*models.DynamicLease, error) {
+	var l models.DynamicLease
+	if err := db.Where("organization_id = ? AND lease_id = ?", orgID, leaseID).First(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *dynamicCredentialRepo) UpdateLease(db *gorm.DB, l *models.DynamicLease) error {
+	return db.Omit("Role", "User").Save(l).Error
+}
+
+// ListLeases returns the user's active leases in the org, soonest to expire first.
+func (r *dynamicCredentialRepo) ListLeases(db *gorm.DB, orgID, userID uint) ([]models.DynamicLease, error) {
+	var ls []models.DynamicLease
+	err := db.Where("organization_id = ? AND user_id = ? AND status = ?", orgID, userID, models.LeaseActive).
+		Preload("Role").
+		Order("expires_at").
+		Find(&ls).Error
+	return ls, err
+}
+
+// ListExpiredLeases returns active leases past their expiry that no sweeper holds.
+func (r *dynamicCredentialRepo) ListExpiredLeases(db *gorm.DB, now time.Time, limit int) ([]models.DynamicLease, error) {
+	var ls []models.DynamicLease
+	err := db.Where("status = ? AND expires_at <= ? AND (locked_until IS NULL OR locked_until < ?)", models.LeaseActive, now, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&ls).Error
+	return ls, err
+}
+
+// ClaimLease locks an active lease until the given time, unless someone else
+// holds it. It reports whether the lock was taken.
+func (r *dynamicCredentialRepo) ClaimLease(db *gorm.DB, id uint, now, until time.Time) (bool, error) {
+	res := db.Model(&models.DynamicLease{}).
+		Where("id = ? AND status = ? AND (locked_until IS NULL OR locked_until < ?)", id, models.LeaseActive, now).
+		Update("locked_until", until)
+	return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FolderPermissionRepository interface {
+	Upsert(db *gorm.DB, p *models.FolderPermission) error
+	Delete(db *gorm.DB, orgID uint, path string, teamID uint) error
+	ListOnPaths(db *gorm.DB, orgID uint, paths []string) ([]FolderPermissionRow, error)
+	ListForUser(db *gorm.DB, orgID, userID uint) ([]models.FolderPermission, error)
+	MovePath(db *gorm.DB, orgID uint, from, to string) error
+}
+
+// FolderPermissionRow is a folder grant joined with the team name.
+type FolderPermissionRow struct {
+	Path     string `json:"path"`
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Access   string `json:"access"`
+}
+
+type folderPermissionRepo struct{}
+
+func NewFolderPermissionRepository() FolderPermissionRepository { return &folderPermissionRepo{} }
+
+// Upsert creates the team's grant on the folder or replaces its access level.
+func (r *folderPermissionRepo) Upsert(db *gorm.DB, p *models.FolderPermission) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}, {Name: "path"}, {Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "granted_by"}),
+	}).Create(p).Error
+}
+
+func (r *folderPermissionRepo) Delete(db *gorm.DB, orgID uint, path string, teamID uint) error {
+	res := db.Where("organization_id = ? AND path = ? AND team_id = ?", orgID, path, teamID).
+		Delete(&models.FolderPermission{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListOnPaths returns the grants set directly on any of the given folders.
+func (r *folderPermissionRepo) ListOnPaths(db *gorm.DB, orgID uint, paths []string) ([]FolderPermissionRow, error) {
+	var rows []FolderPermissionRow
+	err := db.Table("folder_permissions").
+		Select("folder_permissions.path, folder_permissions.team_id, teams.name AS team_name, folder_permissions.access").
+		Joins("JOIN teams ON teams.id = folder_permissions.team_id").
+		Where("folder_permissions.organization_id = ? AND folder_permissions.path IN ?", orgID, paths).
+		Order("folder_permissions.path ASC, teams.name ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ListForUser returns every folder grant in the org held by an active team the user belongs to.
+func (r *folderPermissionRepo) ListForUser(db *gorm.DB, orgID, userID uint) ([]models.FolderPermission, error) {
+	var perms []models.FolderPermission
+	err := db.Joins("JOIN team_memberships ON team_memberships.team_id = folder_permissions.team_id").
+		Joins("JOIN teams ON teams.id = folder_permissions.team_id").
+		Where("folder_permissions.organization_id = ? AND team_memberships.user_id = ?", orgID, userID).
+		Where("teams.archived_at IS NULL").
+		Find(&perms).Error
+	return perms, err
+}
+
+// MovePath carries the grants on a folder and its subfolders over to the new location.
+func (r *folderPermissionRepo) MovePath(db *gorm.DB, orgID uint, from, to string) error {
+	return db.Exec(`
+		UPDATE folder_permissions
+		SET path = ? || substring(path from ?)
+		WHERE organization_id = ? AND (path = ? OR path LIKE ? ESCAPE '\')`,
+		to, pathOffset(from), orgID, from, likePrefix(from+"/")).Error
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type ItemRepository interface {
+	Create(db *gorm.DB, item *models.Item) error
+	GetByID(db *gorm.DB, orgID, ownerID, id uint) (*models.Item, error)
+	GetByAPIKeyID(db *gorm.DB, apiKeyID uint) (*models.Item, error)
+	List(db *gorm.DB, orgID, ownerID uint, itemType string) ([]models.Item, error)
+	Update(db *gorm.DB, item *models.Item) error
+	SaveField(db *gorm.DB, f *models.ItemField) error
+	Delete(db *gorm.DB, id uint) error
+	SyncAPIKeyItems(db *gorm.DB, orgID, ownerID uint) error
+	RepairPlainFields(db *gorm.DB) (int64, error)
+}
+
+type itemRepo struct{}
+
+func NewItemRepository() ItemRepository { return &itemRepo{} }
+
+func (r *itemRepo) Create(db *gorm.DB, item *models.Item) error {
+	return db.Create(item).Error
+}
+
+func (r *itemRepo) GetByID(db *gorm.DB, orgID, ownerID, id uint) (*models.Item, error) {
+	var item models.Item
+	err := db.Where("organization_id = ? AND owner_id = ? AND id = ?", orgID, ownerID, id).
+		Preload("Fields", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("APIKey").
+		First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *itemRepo) GetByAPIKeyID(db *gorm.DB, apiKeyID uint) (*models.Item, error) {
+	var item models.Item
+	if err := db.Where("api_key_id = ?", apiKeyID).Preload("APIKey").First(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *itemRepo) List(db *gorm.DB, orgID, ownerID uint, itemType string) ([]models.Item, error) {
+	var items []models.Item
+	q := db.Where("organization_id = ? AND owner_id = ?", orgID, ownerID)
+	if itemType != "" {
+		q = q.Where("type = ?", itemType)
+	}
+	err := q.Preload("Fields", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("APIKey").
+		Order("name ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
+
+func (r *itemRepo) Update(db *gorm.DB, item *models.Item) error {
+	return db.Omit("Fields", "APIKey", "Owner").Save(item).Error
+}
+
+func (r *itemRepo) SaveField(db *gorm.DB, f *models.ItemField) error {
+	return db.Save(f).Error
+}
+
+func (r *itemRepo) Delete(db *gorm.DB, id uint) error {
+	return db.Delete(&models.Item{}, id).Error
+}
+
+// SyncAPIKeyItems creates the api_key item of every key outside project
+// environments that does not have one yet. Zero ids sync every org and owner.
+// New keys get their item from APIKeyRepository.Create; this is for keys
+// created before items existed.
+func (r *itemRepo) SyncAPIKeyItems(db *gorm.DB, orgID, ownerID uint) error {
+	return db.Exec(apiKeyItemInsert+`
+		  AND (? = 0 OR k.organization_id = ?)
+		  AND (? = 0 OR k.owner_id = ?)`,
+		orgID, orgID, ownerID, ownerID).Error
+}
+
+// apiKeyItemInsert creates the missing api_key items of the keys selected by
+// further conditions on k.
+const apiKeyItemInsert = `
+		INSERT INTO items (organization_id, owner_id, type, name, description, api_key_id, created_at, updated_at)
+		SELECT k.organization_id, k.owner_id, 'api_key', k.name, COALESCE(k.description, ''), k.id, k.created_at, NOW()
+		FROM api_keys k
+		WHERE k.environment_id IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM items i WHERE i.api_key_id = k.id)`
+
+// createAPIKeyItem gives one new key its api_key item.
+func createAPIKeyItem(db *gorm.DB, keyID uint) error {
+	return db.Exec(apiKeyItemInsert+`
+		  AND k.id = ?`, keyID).Error
+}
+
+// RepairPlainFields clears the concealed flag of fields that were stored as
+// plain text but flagged concealed by an earlier column default. A concealed
+// field always has ciphertext, so any without one is plain.
+func (r *itemRepo) RepairPlainFields(db *gorm.DB) (int64, error) {
+	res := db.Model(&models.ItemField{}).
+		Where("concealed AND (ciphertext IS NULL OR ciphertext = '')").
+		Update("concealed", false)
+	return res.RowsAffected, res.Error
+}
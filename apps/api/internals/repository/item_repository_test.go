@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+)
+
+func TestCreateKeepsPlainFieldsPlain(t *testing.T) {
+	db := testDB(t, &models.Item{}, &models.ItemField{})
+	repo := NewItemRepository()
+
+	item := &models.Item{
+		OrganizationID: 1,
+		OwnerID:        1,
+		Type:           "login",
+		Name:           "mail",
+		Fields: []models.ItemField{
+			{Name: "username", Concealed: false, Value: "me@example.com"},
+			{Name: "password", Concealed: true, Ciphertext: "ct", Nonce: "nonce", Position: 1},
+		},
+	}
+	if err := repo.Create(db, item); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.GetByID(db, 1, 1, item.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if len(got.Fields) != 2 {
+		t.Fatalf("read back %d fields, want 2", len(got.Fields))
+	}
+	if got.Fields[0].Concealed || got.Fields[0].Value != "me@example.com" {
+		t.Errorf("plain field read back as %+v", got.Fields[0])
+	}
+	if !got.Fields[1].Concealed {
+		t.Error("concealed field read back as plain")
+	}
+}
+
+func TestCreateAPIKeyAddsItem(t *testing.T) {
+	db := testDB(t, &models.APIKey{}, &models.Item{}, &models.ItemField{})
+	keys := NewAPIKeyRepository()
+	items := NewItemRepository()
+
+	key := &models.APIKey{Name: "live", Path: "stripe/live", OrganizationID: 1, OwnerID: 1, Ciphertext: "ct", Nonce: "nonce"}
+	if err := keys.Create(db, key); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	item, err := items.GetByAPIKeyID(db, key.ID)
+	if err != nil {
+		t.Fatalf("no api_key item for a new key: %v", err)
+	}
+	if item.Type != "api_key" || item.Name != "live" {
+		t.Errorf("item = %+v", item)
+	}
+
+	envID := uint(3)
+	inEnv := &models.APIKey{Name: "db", Path: "db", OrganizationID: 1, OwnerID: 1, Ciphertext: "ct", Nonce: "nonce", EnvironmentID: &envID}
+	if err := keys.Create(db, inEnv); err != nil {
+		t.Fatalf("Create in environment: %v", err)
+	}
+	if _, err := items.GetByAPIKeyID(db, inEnv.ID); err == nil {
+		t.Error("a project environment key got an item")
+	}
+}
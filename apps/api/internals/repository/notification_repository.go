@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type NotificationRepository interface {
+	Create(db *gorm.DB, n *models.Notification) error
+	ListByUser(db *gorm.DB, userID uint, unreadOnly bool, limit int) ([]models.Notification, error)
+	MarkRead(db *gorm.DB, userID uint, ids []uint) error
+}
+
+type notificationRepo struct{}
+
+func NewNotificationRepository() NotificationRepository { return &notificationRepo{} }
+
+func (r *notificationRepo) Create(db *gorm.DB, n *models.Notification) error {
+	return db.Create(n).Error
+}
+
+func (r *notificationRepo) ListByUser(db *gorm.DB, userID uint, unreadOnly bool, limit int) ([]models.Notification, error) {
+	var ns []models.Notification
+	q := db.Where("user_id = ?", userID)
+	if unreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+	err := q.Order("created_at DESC").Limit(limit).Find(&ns).Error
+	return ns, err
+}
+
+// MarkRead marks the given notifications as read; an empty id list marks all of them.
+func (r *notificationRepo) MarkRead(db *gorm.DB, userID uint, ids []uint) error {
+	q := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+	return q.Update("read_at", time.Now()).Error
+}
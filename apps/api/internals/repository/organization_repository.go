@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository interface {
+	Create(db *gorm.DB, org *models.Organization) error
+	GetByID(db *gorm.DB, id uint) (*models.Organization, error)
+	Update(db *gorm.DB, org *models.Organization) error
+	SlugExists(db *gorm.DB, slug string) (bool, error)
+	ListByUser(db *gorm.DB, userID uint) ([]OrgMembershipRow, error)
+	FindDefaultForUser(db *gorm.DB, userID uint) (*models.OrganizationMember, error)
+	AddMember(db *gorm.DB, m *models.OrganizationMember) error
+	GetMember(db *gorm.DB, orgID, userID uint) (*models.OrganizationMember, error)
+	ListMembers(db *gorm.DB, orgID uint) ([]OrgMemberRow, error)
+	UpdateMemberRole(db *gorm.DB, orgID, userID uint, role string) error
+	RemoveMember(db *gorm.DB, orgID, userID uint) error
+	CountOwners(db *gorm.DB, orgID uint) (int64, error)
+	ListUsersWithoutOrg(db *gorm.DB) ([]models.User, error)
+}
+
+// OrgMembershipRow is an organization as seen by one of its members.
+type OrgMembershipRow struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	Role string `json:"role"`
+}
+
+// OrgMemberRow is an entry in an organization's member directory.
+type OrgMemberRow struct {
+	UserID   uint   `json:"user_id"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+}
+
+type organizationRepo struct{}
+
+func NewOrganizationRepository() OrganizationRepository { return &organizationRepo{} }
+
+func (r *organizationRepo) Create(db *gorm.DB, org *models.Organization) error {
+	return db.Create(org).Error
+}
+
+func (r *organizationRepo) GetByID(db *gorm.DB, id uint) (*models.Organization, error) {
+	var org models.Organization
+	if err := db.First(&org, id).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *organizationRepo) Update(db *gorm.DB, org *models.Organization) error {
+	return db.Save(org).Error
+}
+
+func (r *organizationRepo) SlugExists(db *gorm.DB, slug string) (bool, error) {
+	var count int64
+	err := db.Model(&models.Organization{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *organizationRepo) ListByUser(db *gorm.DB, userID uint) ([]OrgMembershipRow, error) {
+	var rows []OrgMembershipRow
+	err := db.Table("organization_members").
+		Select("organizations.id, organizations.name, organizations.slug, organization_members.role").
+		Joins("JOIN organizations ON organizations.id = organization_members.organization_id").
+		Where("organization_members.user_id = ?", userID).
+		Order("organization_members.created_at ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// FindDefaultForUser returns the user's oldest membership, which is their personal organization.
+func (r *organizationRepo) FindDefaultForUser(db *gorm.DB, userID uint) (*models.OrganizationMember, error) {
+	var m models.OrganizationMember
+	err := db.Where("user_id = ?", userID).Order("created_at ASC, id ASC").First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *organizationRepo) AddMember(db *gorm.DB, m *models.OrganizationMember) error {
+	return db.Create(m).Error
+}
+
+func (r *organizationRepo) GetMember(db *gorm.DB, orgID, userID uint) (*models.OrganizationMember, error) {
+	var m models.OrganizationMember
+	err := db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *organizationRepo) ListMembers(db *gorm.DB, orgID uint) ([]OrgMemberRow, error) {
+	var rows []OrgMemberRow
+	err := db.Table("organization_members").
+		Select("organization_members.user_id, users.full_name, users.email, organization_members.role").
+		Joins("JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ?", orgID).
+		Order("users.full_name ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *organizationRepo) UpdateMemberRole(db *gorm.DB, orgID, userID uint, role string) error {
+	res := db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *organizationRepo) RemoveMember(db *gorm.DB, orgID, userID uint) error {
+	res := db.Where("organization_id = ? AND user_id = ?", orgID, userID).Delete(&models.OrganizationMember{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *organizationRepo) CountOwners(db *gorm.DB, orgID uint) (int64, error) {
+	var count int64
+	err := db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", orgID, "owner").
+		Count(&count).Error
+	return count, err
+}
+
+// ListUsersWithoutOrg returns users created before organizations existed.
+func (r *organizationRepo) ListUsersWithoutOrg(db *gorm.DB) ([]models.User, error) {
+	var users []models.User
+	err := db.Where("id NOT IN (?)", db.Model(&models.OrganizationMember{}).Select("user_id")).
+		Find(&users).Error
+	return users, err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	Create(db *gorm.DB, e *models.OutboxEvent) error
+	Update(db *gorm.DB, e *models.OutboxEvent) error
+	ListDue(db *gorm.DB, now time.Time, limit int) ([]models.OutboxEvent, error)
+	Claim(db *gorm.DB, id uint, now, until time.Time) (bool, error)
+	DeliveredTo(db *gorm.DB, eventID string) (map[string]bool, error)
+	MarkDelivered(db *gorm.DB, eventID, subscriber string, at time.Time) error
+	PurgeDelivered(db *gorm.DB, before time.Time) error
+}
+
+type outboxRepo struct{}
+
+func NewOutboxRepository() OutboxRepository { return &outboxRepo{} }
+
+func (r *outboxRepo) Create(db *gorm.DB, e *models.OutboxEvent) error {
+	return db.Create(e).Error
+}
+
+func (r *outboxRepo) Update(db *gorm.DB, e *models.OutboxEvent) error {
+	return db.Save(e).Error
+}
+
+// ListDue returns undelivered events whose retry time has come and that no
+// dispatcher currently holds, oldest first.
+func (r *outboxRepo) ListDue(db *gorm.DB, now time.Time, limit int) ([]models.OutboxEvent, error) {
+	var out []models.OutboxEvent
+	err := db.Where("delivered_at IS NULL AND dead_at IS NULL AND available_at <= ? AND (locked_until IS NULL OR locked_until < ?)", now, now).
+		Order("id").
+		Limit(limit).
+		Find(&out).Error
+	return out, err
+}
+
+// Claim takes the event's lock until the given time. It reports false when
+// another dispatcher got there first.
+func (r *outboxRepo) Claim(db *gorm.DB, id uint, now, until time.Time) (bool, error) {
+	res := db.Model(&models.OutboxEvent{}).
+		Where("id = ? AND delivered_at IS NULL AND (locked_until IS NULL OR locked_until < ?)", id, now).
+		Update("locked_until", until)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *outboxRepo) DeliveredTo(db *gorm.DB, eventID string) (map[string]bool, error) {
+	var subs []string
+	err := db.Model(&models.OutboxDelivery{}).Where("event_id = ?", eventID).Pluck("subscriber", &subs).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(subs))
+	for _, s := range subs {
+		out[s] = true
+	}
+	return out, nil
+}
+
+func (r *outboxRepo) MarkDelivered(db *gorm.DB, eventID, subscriber string, at time.Time) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.OutboxDelivery{EventID: eventID, Subscriber: subscriber, DeliveredAt: at}).Error
+}
+
+// PurgeDelivered drops fully delivered events, and their delivery marks,
+// older than before. Dead events are kept for inspection.
+func (r *outboxRepo) PurgeDelivered(db *gorm.DB, before time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		old := tx.Model(&models.OutboxEvent{}).Select("event_id").Where("delivered_at < ?", before)
+		if err := tx.Where("event_id IN (?)", old).Delete(&models.OutboxDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("delivered_at < ?", before).Delete(&models.OutboxEvent{}).Error
+	})
+}
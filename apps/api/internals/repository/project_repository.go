@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProjectRepository interface {
+	CreateProject(db *gorm.DB, p *models.Project) error
+	GetProject(db *gorm.DB, orgID, id uint) (*models.Project, error)
+	ListProjects(db *gorm.DB, orgID uint) ([]models.Project, error)
+	CreateEnvironment(db *gorm.DB, e *models.Environment) error
+	GetEnvironment(db *gorm.DB, orgID, id uint) (*models.Environment, error)
+	ListEnvironments(db *gorm.DB, projectID uint) ([]models.Environment, error)
+	UpsertPermission(db *gorm.DB, p *models.EnvironmentPermission) error
+	DeletePermission(db *gorm.DB, envID, teamID uint) error
+	ListPermissions(db *gorm.DB, envID uint) ([]EnvPermissionRow, error)
+	ListUserAccess(db *gorm.DB, envID, userID uint) ([]string, error)
+}
+
+// EnvPermissionRow is an environment grant joined with the team name.
+type EnvPermissionRow struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Access   string `json:"access"`
+}
+
+type projectRepo struct{}
+
+func NewProjectRepository() ProjectRepository { return &projectRepo{} }
+
+func (r *projectRepo) CreateProject(db *gorm.DB, p *models.Project) error {
+	return db.Create(p).Error
+}
+
+func (r *projectRepo) GetProject(db *gorm.DB, orgID, id uint) (*models.Project, error) {
+	var p models.Project
+	if err := db.Where("organization_id = ? AND id = ?", orgID, id).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *projectRepo) ListProjects(db *gorm.DB, orgID uint) ([]models.Project, error) {
+	var projects []models.Project
+	err := db.Where("organization_id = ?", orgID).
+		Preload("Environments", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC, id ASC") }).
+		Order("name ASC").
+		Find(&projects).Error
+	return projects, err
+}
+
+func (r *projectRepo) CreateEnvironment(db *gorm.DB, e *models.Environment) error {
+	return db.Create(e).Error
+}
+
+// GetEnvironment returns the environment only if its project belongs to the org.
+func (r *projectRepo) GetEnvironment(db *gorm.DB, orgID, id uint) (*models.Environment, error) {
+	var e models.Environment
+	err := db.Joins("JOIN projects ON projects.id = environments.project_id").
+		Where("projects.organization_id = ? AND environments.id = ?", orgID, id).
+		First(&e).Error
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *projectRepo) ListEnvironments(db *gorm.DB, projectID uint) ([]models.Environment, error) {
+	var envs []models.Environment
+	err := db.Where("project_id = ?", projectID).Order("position ASC, id ASC").Find(&envs).Error
+	return envs, err
+}
+
+// UpsertPermission creates the team's grant on the environment or replaces its access level.
+func (r *projectRepo) UpsertPermission(db *gorm.DB, p *models.EnvironmentPermission) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "environment_id"}, {Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "granted_by"}),
+	}).Create(p).Error
+}
+
+func (r *projectRepo) DeletePermission(db *gorm.DB, envID, teamID uint) error {
+	res := db.Where("environment_id = ? AND team_id = ?", envID, teamID).Delete(&models.EnvironmentPermission{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *projectRepo) ListPermissions(db *gorm.DB, envID uint) ([]EnvPermissionRow, error) {
+	var rows []EnvPermissionRow
+	err := db.Table("environment_permissions").
+		Select("environment_permissions.team_id, teams.name AS team_name, environment_permissions.access").
+		Joins("JOIN teams ON teams.id = environment_permissions.team_id").
+		Where("environment_permissions.environment_id = ?", envID).
+		Order("teams.name ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ListUserAccess returns the access levels granted on the environment to teams the user belongs to.
+func (r *projectRepo) ListUserAccess(db *gorm.DB, envID, userID uint) ([]string, error) {
+	var access []string
+	err := db.Table("environment_permissions").
+		Select("environment_permissions.access").
+		Joins("JOIN team_memberships ON team_memberships.team_id = environment_permissions.team_id").
+		Joins("JOIN teams ON teams.id = environment_permissions.team_id").
+		Where("environment_permissions.environment_id = ? AND team_memberships.user_id = ?", envID, userID).
+		Where("teams.archived_at IS NULL").
+		Scan(&access).Error
+	return access, err
+}
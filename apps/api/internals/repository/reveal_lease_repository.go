@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type RevealLeaseRepository interface {
+	Create(db *gorm.DB, l *models.RevealLease) error
+	Get(db *gorm.DB, orgID uint, leaseID string) (*models.RevealLease, error)
+	Update(db *gorm.DB, l *models.RevealLease) error
+	RevokeForKey(db *gorm.DB, apiKeyID uint, reason string, at time.Time) (int64, error)
+}
+
+type revealLeaseRepo struct{}
+
+func NewRevealLeaseRepository() RevealLeaseRepository { return &revealLeaseRepo{} }
+
+func (r *revealLeaseRepo) Create(db *gorm.DB, l *models.RevealLease) error {
+	return db.Create(l).Error
+}
+
+func (r *revealLeaseRepo) Get(db *gorm.DB, orgID uint, leaseID string) (*models.RevealLease, error) {
+	var l models.RevealLease
+	if err := db.Where("organization_id = ? AND lease_id = ?", orgID, leaseID).First(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *revealLeaseRepo) Update(db *gorm.DB, l *models.RevealLease) error {
+	return db.Save(l).Error
+}
+
+// RevokeForKey revokes every unexpired, unrevoked lease on the key.
+func (r *revealLeaseRepo) RevokeForKey(db *gorm.DB, apiKeyID uint, reason string, at time.Time) (int64, error) {
+	res := db.Model(&models.RevealLease{}).
+		Where("api_key_id = ? AND revoked_at IS NULL AND expires_at > ?", apiKeyID, at).
+		Updates(map[string]interface{}{"revoked_at": at, "revoke_reason": reason})
+	return res.RowsAffected, res.Error
+}
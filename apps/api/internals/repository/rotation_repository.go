@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type RotationRepository interface {
+	GetPolicy(db *gorm.DB, apiKeyID uint) (*models.RotationPolicy, error)
+	SavePolicy(db *gorm.DB, p *models.RotationPolicy) error
+	ListDuePolicies(db *gorm.DB, now time.Time, limit int) ([]models.RotationPolicy, error)
+	ClaimPolicy(db *gorm.DB, id uint, now, until time.Time) (bool, error)
+	ReleasePolicy(db *gorm.DB, id uint) error
+	CreateRun(db *gorm.DB, run *models.RotationRun) error
+	UpdateRun(db *gorm.DB, run *models.RotationRun) error
+	ListRuns(db *gorm.DB, apiKeyID uint, limit int) ([]models.RotationRun, error)
+}
+
+type rotationRepo struct{}
+
+func NewRotationRepository() RotationRepository { return &rotationRepo{} }
+
+func (r *rotationRepo) GetPolicy(db *gorm.DB, apiKeyID uint) (*models.RotationPolicy, error) {
+	var p models.RotationPolicy
+	if err := db.Where("api_key_id = ?", apiKeyID).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *rotationRepo) SavePolicy(db *gorm.DB, p *models.RotationPolicy) error {
+	return db.Save(p).Error
+}
+
+// ListDuePolicies returns enabled policies whose next run has passed and
+// that no worker currently holds.
+func (r *rotationRepo) ListDuePolicies(db *gorm.DB, now time.Time, limit int) ([]models.RotationPolicy, error) {
+	var ps []models.RotationPolicy
+	err := db.Where("enabled AND next_run_at <= ? AND (locked_until IS NULL OR locked_until < ?)", now, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&ps).Error
+	return ps, err
+}
+
+// ClaimPolicy takes the policy's lock until the given time. It reports false
+// when another worker got there first.
+func (r *rotationRepo) ClaimPolicy(db *gorm.DB, id uint, now, until time.Time) (bool, error) {
+	res := db.Model(&models.RotationPolicy{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", id, now).
+		Update("locked_until", until)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *rotationRepo) ReleasePolicy(db *gorm.DB, id uint) error {
+	return db.Model(&models.RotationPolicy{}).Where("id = ?", id).Update("locked_until", nil).Error
+}
+
+func (r *rotationRepo) CreateRun(db *gorm.DB, run *models.RotationRun) error {
+	return db.Create(run).Error
+}
+
+func (r *rotationRepo) UpdateRun(db *gorm.DB, run *models.RotationRun) error {
+	return db.Save(run).Error
+}
+
+func (r *rotationRepo) ListRuns(db *gorm.DB, apiKeyID uint, limit int) ([]models.RotationRun, error) {
+	var runs []models.RotationRun
+	err := db.Where("api_key_id = ?", apiKeyID).Order("started_at DESC, id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+)
+
+func TestSavePolicyKeepsDisabled(t *testing.T) {
+	db := testDB(t, &models.RotationPolicy{})
+	repo := NewRotationRepository()
+
+	due := time.Now().Add(-time.Hour)
+	p := &models.RotationPolicy{
+		OrganizationID:   1,
+		APIKeyID:         7,
+		Type:             "webhook",
+		ConfigCiphertext: "ct",
+		ConfigNonce:      "nonce",
+		EveryDays:        30,
+		MaxAttempts:      3,
+		Enabled:          false,
+		NextRunAt:        &due,
+		CreatedBy:        1,
+	}
+	if err := repo.SavePolicy(db, p); err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+
+	got, err := repo.GetPolicy(db, 7)
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	if got.Enabled {
+		t.Error("a policy created disabled was read back enabled")
+	}
+	dueNow, err := repo.ListDuePolicies(db, time.Now(), 10)
+	if err != nil {
+		t.Fatalf("ListDuePolicies: %v", err)
+	}
+	if len(dueNow) != 0 {
+		t.Errorf("disabled policy is due: %+v", dueNow)
+	}
+
+	got.Enabled = true
+	if err := repo.SavePolicy(db, got); err != nil {
+		t.Fatal(err)
+	}
+	if dueNow, _ = repo.ListDuePolicies(db, time.Now(), 10); len(dueNow) != 1 {
+		t.Errorf("enabled policy not due: %+v", dueNow)
+	}
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type SecretVersionRepository interface {
+	Create(db *gorm.DB, v *models.SecretVersion) error
+	Update(db *gorm.DB, v *models.SecretVersion) error
+	FindByState(db *gorm.DB, apiKeyID uint, state string) (*models.SecretVersion, error)
+	LatestNumber(db *gorm.DB, apiKeyID uint) (int, error)
+	List(db *gorm.DB, apiKeyID uint) ([]models.SecretVersion, error)
+	RetireState(db *gorm.DB, apiKeyID uint, state string) error
+}
+
+type secretVersionRepo struct{}
+
+func NewSecretVersionRepository() SecretVersionRepository { return &secretVersionRepo{} }
+
+func (r *secretVersionRepo) Create(db *gorm.DB, v *models.SecretVersion) error {
+	return db.Create(v).Error
+}
+
+func (r *secretVersionRepo) Update(db *gorm.DB, v *models.SecretVersion) error {
+	return db.Save(v).Error
+}
+
+// FindByState returns the newest version of the key in the given state.
+func (r *secretVersionRepo) FindByState(db *gorm.DB, apiKeyID uint, state string) (*models.SecretVersion, error) {
+	var v models.SecretVersion
+	err := db.Where("api_key_id = ? AND state = ?", apiKeyID, state).Order("version DESC").First(&v).Error
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *secretVersionRepo) LatestNumber(db *gorm.DB, apiKeyID uint) (int, error) {
+	var n int
+	err := db.Model(&models.SecretVersion{}).
+		Where("api_key_id = ?", apiKeyID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&n).Error
+	return n, err
+}
+
+func (r *secretVersionRepo) List(db *gorm.DB, apiKeyID uint) ([]models.SecretVersion, error) {
+	var vs []models.SecretVersion
+	err := db.Where("api_key_id = ?", apiKeyID).Order("version DESC").Find(&vs).Error
+	return vs, err
+}
+
+// RetireState moves every version of the key in the given state to retired.
+func (r *secretVersionRepo) RetireState(db *gorm.DB, apiKeyID uint, state string) error {
+	return db.Model(&models.SecretVersion{}).
+		Where("api_key_id = ? AND state = ?", apiKeyID, state).
+		Update("state", models.VersionRetired).Error
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+// alertLock namespaces the advisory locks that serialize alerts per user.
+const alertLock = 0x616c7274 // "alrt"
+
+// AlertQuery filters an organization's alerts. Empty fields match anything.
+type AlertQuery struct {
+	OrganizationID uint
+	Status         string
+	Severity       string
+	Rule           string
+	UserID         uint
+	Suspended      bool // only alerts that suspended sessions
+	BeforeID       uint
+	Limit          int
+}
+
+type SecurityAlertRepository interface {
+	Create(db *gorm.DB, a *models.SecurityAlert) error
+	Update(db *gorm.DB, a *models.SecurityAlert) error
+	GetByID(db *gorm.DB, orgID, id uint) (*models.SecurityAlert, error)
+	List(db *gorm.DB, q AlertQuery) ([]models.SecurityAlert, error)
+	LockUser(tx *gorm.DB, userID uint) error
+	FindOpen(db *gorm.DB, orgID, userID uint, rule string, since time.Time) (*models.SecurityAlert, error)
+	HasEvent(db *gorm.DB, eventID, rule string) (bool, error)
+	HasOpenSuspension(db *gorm.DB, orgID, userID uint) (bool, error)
+
+	CountAccess(db *gorm.DB, userID uint, action string, window time.Time) (int, error)
+	PurgeCounters(db *gorm.DB, before time.Time) error
+
+	// history read from the activity log, for the detector's baselines
+	CountWindows(db *gorm.DB, userID uint, types []string, until time.Time, window time.Duration, n int) ([]int, error)
+	Addresses(db *gorm.DB, userID uint, since, until time.Time) (map[string]int, error)
+	HourHistogram(db *gorm.DB, userID uint, types []string, since, until time.Time) ([24]int, error)
+}
+
+type securityAlertRepo struct{}
+
+func NewSecurityAlertRepository() SecurityAlertRepository { return &securityAlertRepo{} }
+
+func (r *securityAlertRepo) Create(db *gorm.DB, a *models.SecurityAlert) error {
+	return db.Create(a).Error
+}
+
+func (r *securityAlertRepo) Update(db *gorm.DB, a *models.SecurityAlert) error {
+	return db.Save(a).Error
+}
+
+func (r *securityAlertRepo) GetByID(db *gorm.DB, orgID, id uint) (*models.SecurityAlert, error) {
+	var a models.SecurityAlert
+	if err := db.Where("organization_id = ? AND id = ?", orgID, id).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// List pages through alerts, newest first.
+func (r *securityAlertRepo) List(db *gorm.DB, q AlertQuery) ([]models.SecurityAlert, error) {
+	tx := db.Where("organization_id = ?", q.OrganizationID)
+	if q.Status != "" {
+		tx = tx.Where("status = ?", q.Status)
+	}
+	if q.Severity != "" {
+		tx = tx.Where("severity = ?", q.Severity)
+	}
+	if q.Rule != "" {
+		tx = tx.Where("rule = ?", q.Rule)
+	}
+	if q.UserID != 0 {
+		tx = tx.Where("user_id = ?", q.UserID)
+	}
+	if q.Suspended {
+		tx = tx.Where("sessions_suspended")
+	}
+	if q.BeforeID != 0 {
+		tx = tx.Where("id < ?", q.BeforeID)
+	}
+	var out []models.SecurityAlert
+	err := tx.Order("id DESC").Limit(q.Limit).Find(&out).Error
+	return out, err
+}
+
+// LockUser serializes alerts about one user until the end of tx, so
+// concurrent events fold into one alert rather than racing to create two.
+func (r *securityAlertRepo) LockUser(tx *gorm.DB, userID uint) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", alertLock, int32(userID)).Error
+}
+
+// FindOpen returns the user's latest open or acknowledged alert for the
+// rule seen since the given time.
+func (r *securityAlertRepo) FindOpen(db *gorm.DB, orgID, userID uint, rule string, since time.Time) (*models.SecurityAlert, error) {
+	var a models.SecurityAlert
+	err := db.Where("organization_id = ? AND user_id = ? AND rule = ?", orgID, userID, rule).
+		Where("status IN ? AND last_seen_at >= ?", []string{models.AlertOpen, models.AlertAcknowledged}, since).
+		Order("id DESC").
+		First(&a).Error
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// HasEvent reports whether the event already raised or bumped an alert for
+// the rule, so a redelivered event is not counted twice.
+func (r *securityAlertRepo) HasEvent(db *gorm.DB, eventID, rule string) (bool, error) {
+	var n int64
+	err := db.Model(&models.SecurityAlert{}).
+		Where("(event_id = ? OR last_event_id = ?) AND rule = ?", eventID, eventID, rule).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// HasOpenSuspension reports whether an open or acknowledged alert in the
+// organization suspended the user.
+func (r *securityAlertRepo) HasOpenSuspension(db *gorm.DB, orgID, userID uint) (bool, error) {
+	var n int64
+	err := db.Model(&models.SecurityAlert{}).
+		Where("organization_id = ? AND user_id = ? AND sessions_suspended", orgID, userID).
+		Where("status IN ?", []string{models.AlertOpen, models.AlertAcknowledged}).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// CountAccess adds one to the user's counter for the action in the window
+// starting at the given time and returns the new count.
+func (r *securityAlertRepo) CountAccess(db *gorm.DB, userID uint, action string, window time.Time) (int, error) {
+	var n int
+	err := db.Raw(`
+		INSERT INTO access_counters (user_id, action, window_start, count) VALUES (?, ?, ?, 1)
+		ON CONFLICT (user_id, action, window_start) DO UPDATE SET count = access_counters.count + 1
+		RETURNING count`, userID, action, window).Scan(&n).Error
+	return n, err
+}
+
+func (r *securityAlertRepo) PurgeCounters(db *gorm.DB, before time.Time) error {
+	return db.Where("window_start < ?", before).Delete(&models.AccessCounter{}).Error
+}
+
+// accessHistory is the part of the activity log a user's baselines are
+// built from: their own successful requests.
+const accessHistory = "user_id = ? AND principal <> 'system' AND outcome IN ('', 'success')"
+
+// CountWindows counts the user's activities of the given types in n+1
+// consecutive windows ending just before until: index 0 is the latest
+// window, index k the one k windows earlier.
+func (r *securityAlertRepo) CountWindows(db *gorm.DB, userID uint, types []string, until time.Time, window time.Duration, n int) ([]int, error) {
+	var rows []struct {
+		Bucket int
+		Count  int
+	}
+	err := db.Model(&models.Activity{}).
+		Select("FLOOR(EXTRACT(EPOCH FROM (?::timestamptz - created_at)) / ?)::int AS bucket, COUNT(*) AS count", until, window.Seconds()).
+		Where(accessHistory, userID).
+		Where("type IN ? AND created_at > ? AND created_at < ?", types, until.Add(-time.Duration(n+1)*window), until).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int, n+1)
+	for _, row := range rows {
+		if row.Bucket >= 0 && row.Bucket <= n {
+			counts[row.Bucket] += row.Count
+		}
+	}
+	return counts, nil
+}
+
+// Addresses counts the user's activities per client address in the range.
+// Only the 500 most used addresses are returned.
+func (r *securityAlertRepo) Addresses(db *gorm.DB, userID uint, since, until time.Time) (map[string]int, error) {
+	var rows []struct {
+		IP    string
+		Count int
+	}
+	err := db.Model(&models.Activity{}).
+		Select("ip, COUNT(*) AS count").
+		Where(accessHistory, userID).
+		Where("ip <> '' AND created_at >= ? AND created_at < ?", since, until).
+		Group("ip").
+		Order("count DESC").
+		Limit(500).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int, len(rows))
+	for _, row := range rows {
+		out[row.IP] = row.Count
+	}
+	return out, nil
+}
+
+// HourHistogram counts the user's activities of the given types in the range
+// per hour of the day, in UTC.
+func (r *securityAlertRepo) HourHistogram(db *gorm.DB, userID uint, types []string, since, until time.Time) ([24]int, error) {
+	var hist [24]int
+	var rows []struct {
+		Hour  int
+		Count int
+	}
+	err := db.Model(&models.Activity{}).
+		Select("EXTRACT(HOUR FROM created_at AT TIME ZONE 'UTC')::int AS hour, COUNT(*) AS count").
+		Where(accessHistory, userID).
+		Where("type IN ? AND created_at >= ? AND created_at < ?", types, since, until).
+		Group("hour").
+		Scan(&rows).Error
+	if err != nil {
+		return hist, err
+	}
+	for _, row := range rows {
+		if row.Hour >= 0 && row.Hour < 24 {
+			hist[row.Hour] = row.Count
+		}
+	}
+	return hist, nil
+}
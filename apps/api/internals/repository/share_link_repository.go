@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ShareLinkRepository interface {
+	Create(db *gorm.DB, l *models.ShareLink) error
+	Update(db *gorm.DB, l *models.ShareLink) error
+	GetByID(db *gorm.DB, orgID, id uint) (*models.ShareLink, error)
+	FindByTokenHash(db *gorm.DB, tokenHash string) (*models.ShareLink, error)
+	LockByTokenHash(tx *gorm.DB, tokenHash string) (*models.ShareLink, error)
+	ListForKey(db *gorm.DB, apiKeyID uint) ([]models.ShareLink, error)
+	PurgeExpired(db *gorm.DB, now time.Time) error
+}
+
+type shareLinkRepo struct{}
+
+func NewShareLinkRepository() ShareLinkRepository { return &shareLinkRepo{} }
+
+func (r *shareLinkRepo) Create(db *gorm.DB, l *models.ShareLink) error {
+	return db.Create(l).Error
+}
+
+func (r *shareLinkRepo) Update(db *gorm.DB, l *models.ShareLink) error {
+	return db.Save(l).Error
+}
+
+func (r *shareLinkRepo) GetByID(db *gorm.DB, orgID, id uint) (*models.ShareLink, error) {
+	var l models.ShareLink
+	if err := db.Where("organization_id = ? AND id = ?", orgID, id).First(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *shareLinkRepo) FindByTokenHash(db *gorm.DB, tokenHash string) (*models.ShareLink, error) {
+	var l models.ShareLink
+	if err := db.Where("token_hash = ?", tokenHash).First(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// LockByTokenHash loads the link with a row lock so concurrent redemptions
+// are counted one at a time. Must run inside a transaction.
+func (r *shareLinkRepo) LockByTokenHash(tx *gorm.DB, tokenHash string) (*models.ShareLink, error) {
+	var l models.ShareLink
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&l).Error
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *shareLinkRepo) ListForKey(db *gorm.DB, apiKeyID uint) ([]models.ShareLink, error) {
+	var ls []models.ShareLink
+	err := db.Where("api_key_id = ?", apiKeyID).Order("created_at DESC").Find(&ls).Error
+	return ls, err
+}
+
+// PurgeExpired burns links that ran out unused and drops their ciphertext.
+func (r *shareLinkRepo) PurgeExpired(db *gorm.DB, now time.Time) error {
+	return db.Model(&models.ShareLink{}).
+		Where("burned_at IS NULL AND expires_at <= ?", now).
+		Updates(map[string]interface{}{
+			"burned_at":   now,
+			"burn_reason": "expired",
+			"ciphertext":  "",
+			"nonce":       "",
+		}).Error
+}
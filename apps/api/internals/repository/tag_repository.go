@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagRepository interface {
+	FindOrCreate(db *gorm.DB, orgID uint, key, value string) (*models.Tag, error)
+	GetByID(db *gorm.DB, orgID, id uint) (*models.Tag, error)
+	Find(db *gorm.DB, orgID uint, key, value string) (*models.Tag, error)
+	Update(db *gorm.DB, t *models.Tag) error
+	ListByOrg(db *gorm.DB, orgID uint) ([]TagUsageRow, error)
+	SetKeyTags(db *gorm.DB, keyID uint, tagIDs []uint) error
+	KeyIDsWithTag(db *gorm.DB, tagID uint) ([]uint, error)
+	Merge(db *gorm.DB, fromID, intoID uint) error
+	RefreshMirror(db *gorm.DB, keyIDs []uint) error
+	ListUnmigratedKeys(db *gorm.DB) ([]models.APIKey, error)
+	MarkTagsBackfilled(db *gorm.DB, keyID uint, invalid []string) error
+	UpsertField(db *gorm.DB, f *models.CustomField) error
+	DeleteField(db *gorm.DB, keyID uint, name string) error
+	ListFields(db *gorm.DB, keyID uint) ([]models.CustomField, error)
+}
+
+// TagUsageRow is a tag with the number of secrets carrying it.
+type TagUsageRow struct {
+	ID       uint   `json:"id"`
+	Key      string `json:"key"`
+	Value    string `json:"value,omitempty"`
+	KeyCount int64  `json:"key_count"`
+}
+
+type tagRepo struct{}
+
+func NewTagRepository() TagRepository { return &tagRepo{} }
+
+func (r *tagRepo) FindOrCreate(db *gorm.DB, orgID uint, key, value string) (*models.Tag, error) {
+	t := models.Tag{OrganizationID: orgID, Key: key, Value: value}
+	err := db.Where("organization_id = ? AND key = ? AND value = ?", orgID, key, value).FirstOrCreate(&t).Error
+	return &t, err
+}
+
+func (r *tagRepo) GetByID(db *gorm.DB, orgID, id uint) (*models.Tag, error) {
+	var t models.Tag
+	if err := db.Where("organization_id = ? AND id = ?", orgID, id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tagRepo) Find(db *gorm.DB, orgID uint, key, value string) (*models.Tag, error) {
+	var t models.Tag
+	if err := db.Where("organization_id = ? AND key = ? AND value = ?", orgID, key, value).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tagRepo) Update(db *gorm.DB, t *models.Tag) error {
+	return db.Save(t).Error
+}
+
+func (r *tagRepo) ListByOrg(db *gorm.DB, orgID uint) ([]TagUsageRow, error) {
+	var rows []TagUsageRow
+	err := db.Table("tags").
+		Select("tags.id, tags.key, tags.value, COUNT(api_key_tags.api_key_id) AS key_count").
+		Joins("LEFT JOIN api_key_tags ON api_key_tags.tag_id = tags.id").
+		Where("tags.organization_id = ?", orgID).
+		Group("tags.id").
+		Order("tags.key ASC, tags.value ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// SetKeyTags replaces the tags on a secret.
+func (r *tagRepo) SetKeyTags(db *gorm.DB, keyID uint, tagIDs []uint) error {
+	if err := db.Exec("DELETE FROM api_key_tags WHERE api_key_id = ?", keyID).Error; err != nil {
+		return err
+	}
+	for _, tagID := range tagIDs {
+		err := db.Exec("INSERT INTO api_key_tags (api_key_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING", keyID, tagID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *tagRepo) KeyIDsWithTag(db *gorm.DB, tagID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Table("api_key_tags").Where("tag_id = ?", tagID).Pluck("api_key_id", &ids).Error
+	return ids, err
+}
+
+// Merge moves every use of one tag onto another and deletes the first.
+func (r *tagRepo) Merge(db *gorm.DB, fromID, intoID uint) error {
+	err := db.Exec(`
+		INSERT INTO api_key_tags (api_key_id, tag_id)
+		SELECT api_key_id, ? FROM api_key_tags WHERE tag_id = ?
+		ON CONFLICT DO NOTHING`, intoID, fromID).Error
+	if err != nil {
+		return err
+	}
+	if err := db.Exec("DELETE FROM api_key_tags WHERE tag_id = ?", fromID).Error; err != nil {
+		return err
+	}
+	return db.Delete(&models.Tag{}, fromID).Error
+}
+
+// RefreshMirror rewrites the comma-separated api_keys.tags column from the tag
+// table so older clients keep seeing current tags.
+func (r *tagRepo) RefreshMirror(db *gorm.DB, keyIDs []uint) error {
+	if len(keyIDs) == 0 {
+		return nil
+	}
+	return db.Exec(`
+		UPDATE api_keys SET tags = LEFT(COALESCE((
+			SELECT string_agg(CASE WHEN tags.value = '' THEN tags.key ELSE tags.key || ':' || tags.value END, ',' ORDER BY tags.key, tags.value)
+			FROM api_key_tags JOIN tags ON tags.id = api_key_tags.tag_id
+			WHERE api_key_tags.api_key_id = api_keys.id
+		), ''), 255)
+		WHERE id IN ?`, keyIDs).Error
+}
+
+// ListUnmigratedKeys returns keys whose legacy tag string has not been copied into the tag table.
+func (r *tagRepo) ListUnmigratedKeys(db *gorm.DB) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := db.Where("tags <> '' AND NOT tags_backfilled").
+		Where("NOT EXISTS (SELECT 1 FROM api_key_tags WHERE api_key_tags.api_key_id = api_keys.id)").
+		Find(&keys).Error
+	return keys, err
+}
+
+// MarkTagsBackfilled records that the key's legacy tags were copied. Entries
+// that did not parse are put back after the mirror, so they are not lost.
+func (r *tagRepo) MarkTagsBackfilled(db *gorm.DB, keyID uint, invalid []string) error {
+	updates := map[string]interface{}{"tags_backfilled": true}
+	if len(invalid) > 0 {
+		updates["tags"] = gorm.Expr("LEFT(CONCAT_WS(',', NULLIF(tags, ''), ?::text), 255)", strings.Join(invalid, ","))
+	}
+	return db.Model(&models.APIKey{}).Where("id = ?", keyID).UpdateColumns(updates).Error
+}
+
+// UpsertField creates the named field on the secret or replaces its type and value.
+func (r *tagRepo) UpsertField(db *gorm.DB, f *models.CustomField) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "api_key_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"type", "value", "updated_at"}),
+	}).Create(f).Error
+}
+
+func (r *tagRepo) DeleteField(db *gorm.DB, keyID uint, name string) error {
+	res := db.Where("api_key_id = ? AND name = ?", keyID, name).Delete(&models.CustomField{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *tagRepo) ListFields(db *gorm.DB, keyID uint) ([]models.CustomField, error) {
+	var fields []models.CustomField
+	err := db.Where("api_key_id = ?", keyID).Order("name ASC").Find(&fields).Error
+	return fields, err
+}
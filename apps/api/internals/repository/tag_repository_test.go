@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+)
+
+func TestMarkTagsBackfilledKeepsInvalidEntries(t *testing.T) {
+	db := testDB(t, &models.APIKey{}, &models.Item{}, &models.Tag{})
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS api_key_tags (api_key_id bigint, tag_id bigint)").Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := NewTagRepository()
+
+	key := createKey(t, db, "legacy")
+	if err := db.Model(key).UpdateColumn("tags", "env:prod").Error; err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := repo.ListUnmigratedKeys(db); err != nil || len(keys) != 1 {
+		t.Fatalf("ListUnmigratedKeys = %d keys, %v; want 1", len(keys), err)
+	}
+
+	if err := repo.MarkTagsBackfilled(db, key.ID, []string{"bad tag", "(x)"}); err != nil {
+		t.Fatalf("MarkTagsBackfilled: %v", err)
+	}
+	var got models.APIKey
+	if err := db.First(&got, key.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Tags != "env:prod,bad tag,(x)" || !got.TagsBackfilled {
+		t.Errorf("key after backfill: tags %q, backfilled %v", got.Tags, got.TagsBackfilled)
+	}
+	if keys, err := repo.ListUnmigratedKeys(db); err != nil || len(keys) != 0 {
+		t.Fatalf("ListUnmigratedKeys after backfill = %d keys, %v; want 0", len(keys), err)
+	}
+}
@@ -9,7 +9,11 @@ type TeamMembershipRepository interface {
 	Create(m *models.TeamMembership) error
 	ListByTeam(teamID uint) ([]models.TeamMembership, error)
 	ListByUser(userID uint) ([]models.TeamMembership, error)
+	ListByUserInOrg(orgID, userID uint) ([]models.TeamMembership, error)
 	Delete(teamID, userID uint) error
+	FindRole(teamID, userID uint) (string, error)
+	UpdateRole(teamID, userID uint, role string) error
+	WithDB(db *gorm.DB) TeamMembershipRepository
 }
 
 type teamMembershipRepo struct {
@@ -36,6 +40,41 @@ func (r *teamMembershipRepo) ListByUser(userID uint) ([]models.TeamMembership, e
 	return memberships, err
 }
 
+func (r *teamMembershipRepo) ListByUserInOrg(orgID, userID uint) ([]models.TeamMembership, error) {
+	var memberships []models.TeamMembership
+	err := r.db.Where("user_id = ?", userID).
+		Where("team_id IN (?)", r.db.Model(&models.Team{}).Select("id").Where("organization_id = ?", orgID)).
+		Find(&memberships).Error
+	return memberships, err
+}
+
 func (r *teamMembershipRepo) Delete(teamID, userID uint) error {
 	return r.db.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMembership{}).Error
 }
+
+// FindRole returns the user's role in the team, or gorm.ErrRecordNotFound if they are not a member.
+func (r *teamMembershipRepo) FindRole(teamID, userID uint) (string, error) {
+	var m models.TeamMembership
+	if err := r.db.Where("team_id = ? AND user_id = ?", teamID, userID).First(&m).Error; err != nil {
+		return "", err
+	}
+	return m.Role, nil
+}
+
+func (r *teamMembershipRepo) UpdateRole(teamID, userID uint, role string) error {
+	res := r.db.Model(&models.TeamMembership{}).
+		Where("team_id = ? AND user_id = ?", teamID, userID).
+		Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// WithDB returns a copy of the repository bound to db, typically a transaction.
+func (r *teamMembershipRepo) WithDB(db *gorm.DB) TeamMembershipRepository {
+	return &teamMembershipRepo{db: db}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+type TeamInvitationRepository interface {
+	Create(db *gorm.DB, inv *models.TeamInvitation) error
+	GetByID(db *gorm.DB, id uint) (*models.TeamInvitation, error)
+	FindByTokenHash(db *gorm.DB, hash string) (*models.TeamInvitation, error)
+	FindPending(db *gorm.DB, teamID uint, email string) (*models.TeamInvitation, error)
+	Update(db *gorm.DB, inv *models.TeamInvitation) error
+	ListByEmail(db *gorm.DB, email string) ([]models.TeamInvitation, error)
+	ListForManager(db *gorm.DB, orgID, userID uint) ([]models.TeamInvitation, error)
+}
+
+type teamInvitationRepo struct{}
+
+func NewTeamInvitationRepository() TeamInvitationRepository { return &teamInvitationRepo{} }
+
+func (r *teamInvitationRepo) Create(db *gorm.DB, inv *models.TeamInvitation) error {
+	return db.Create(inv).Error
+}
+
+func (r *teamInvitationRepo) GetByID(db *gorm.DB, id uint) (*models.TeamInvitation, error) {
+	var inv models.TeamInvitation
+	if err := db.First(&inv, id).Error; err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *teamInvitationRepo) FindByTokenHash(db *gorm.DB, hash string) (*models.TeamInvitation, error) {
+	var inv models.TeamInvitation
+	if err := db.Where("token_hash = ?", hash).First(&inv).Error; err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *teamInvitationRepo) FindPending(db *gorm.DB, teamID uint, email string) (*models.TeamInvitation, error) {
+	var inv models.TeamInvitation
+	err := db.Where("team_id = ? AND email = ? AND status = ?", teamID, email, "pending").First(&inv).Error
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *teamInvitationRepo) Update(db *gorm.DB, inv *models.TeamInvitation) error {
+	return db.Save(inv).Error
+}
+
+// ListByEmail returns the invitations received by an address, newest first.
+func (r *teamInvitationRepo) ListByEmail(db *gorm.DB, email string) ([]models.TeamInvitation, error) {
+	var invs []models.TeamInvitation
+	err := db.Where("email = ?", email).Order("created_at DESC").Find(&invs).Error
+	return invs, err
+}
+
+// ListForManager returns invitations the user sent or that belong to teams they own or administer.
+func (r *teamInvitationRepo) ListForManager(db *gorm.DB, orgID, userID uint) ([]models.TeamInvitation, error) {
+	var invs []models.TeamInvitation
+	managed := db.Model(&models.TeamMembership{}).
+		Select("team_id").
+		Where("user_id = ? AND role IN ?", userID, []string{"owner", "admin"})
+	err := db.Where("organization_id = ?", orgID).
+		Where("invited_by = ? OR team_id IN (?)", userID, managed).
+		Order("created_at DESC").
+		Find(&invs).Error
+	return invs, err
+}
@@ -7,6 +7,20 @@ import (
 
 type TeamRepository interface {
 	Create(db *gorm.DB, t *models.Team) error
+	GetByID(db *gorm.DB, orgID, id uint) (*models.Team, error)
+	ListByMember(db *gorm.DB, orgID, userID uint, includeArchived bool) ([]models.Team, error)
+	Update(db *gorm.DB, t *models.Team) error
+	Delete(db *gorm.DB, id uint) error
+	ListMembers(db *gorm.DB, teamID uint) ([]TeamMemberRow, error)
+	ListAPIKeys(db *gorm.DB, teamID uint) ([]models.APIKey, error)
+}
+
+// TeamMemberRow is a team membership joined with the member's profile.
+type TeamMemberRow struct {
+	UserID   uint   `json:"user_id"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
 }
 
 type teamRepo struct{}
@@ -17,5 +31,60 @@ func (r *teamRepo) Create(db *gorm.DB, t *models.Team) error {
 	return db.Create(t).Error
 }
 
+func (r *teamRepo) GetByID(db *gorm.DB, orgID, id uint) (*models.Team, error) {
+	var t models.Team
+	if err := db.Where("organization_id = ? AND id = ?", orgID, id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListByMember returns the teams in the org the user belongs to in any role.
+func (r *teamRepo) ListByMember(db *gorm.DB, orgID, userID uint, includeArchived bool) ([]models.Team, error) {
+	var teams []models.Team
+	q := db.Where("organization_id = ?", orgID).
+		Where("id IN (?)", db.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", userID))
+	if !includeArchived {
+		q = q.Where("archived_at IS NULL")
+	}
+	err := q.Order("name ASC").Find(&teams).Error
+	return teams, err
+}
 
+func (r *teamRepo) Update(db *gorm.DB, t *models.Team) error {
+	return db.Save(t).Error
+}
 
+// Delete removes the team after detaching its keys, memberships and invitations.
+// Callers should run it inside a transaction.
+func (r *teamRepo) Delete(db *gorm.DB, id uint) error {
+	if err := db.Where("team_id = ?", id).Delete(&models.APIKeyTeam{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&models.TeamMembership{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&models.TeamInvitation{}).Error; err != nil {
+		return err
+	}
+	return db.Delete(&models.Team{}, id).Error
+}
+
+func (r *teamRepo) ListMembers(db *gorm.DB, teamID uint) ([]TeamMemberRow, error) {
+	var rows []TeamMemberRow
+	err := db.Table("team_memberships").
+		Select("team_memberships.user_id, users.full_name, users.email, team_memberships.role").
+		Joins("JOIN users ON users.id = team_memberships.user_id").
+		Where("team_memberships.team_id = ?", teamID).
+		Order("team_memberships.created_at ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *teamRepo) ListAPIKeys(db *gorm.DB, teamID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := db.Where("id IN (?)", db.Model(&models.APIKeyTeam{}).Select("api_key_id").Where("team_id = ?", teamID)).
+		Order("name ASC").
+		Find(&keys).Error
+	return keys, err
+}
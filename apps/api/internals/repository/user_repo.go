@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
 )
@@ -8,6 +10,9 @@ import (
 type UserRepository interface {
 	Create(db *gorm.DB, user *models.User) error
 	FindByEmail(db *gorm.DB, email string) (*models.User, error)
+	FindByID(db *gorm.DB, id uint) (*models.User, error)
+	Suspend(db *gorm.DB, id uint, at time.Time, reason string) error
+	Unsuspend(db *gorm.DB, id uint) error
 }
 
 type userRepository struct{}
@@ -24,4 +29,30 @@ func (r *userRepository) FindByEmail(db *gorm.DB, email string) (*models.User, e
 		return nil, err
 	}
 	return &u, nil
-}
\ No newline at end of file
+}
+
+func (r *userRepository) FindByID(db *gorm.DB, id uint) (*models.User, error) {
+	var u models.User
+	if err := db.First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Suspend revokes the user's sessions as of at and blocks sign-in.
+func (r *userRepository) Suspend(db *gorm.DB, id uint, at time.Time, reason string) error {
+	return db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sessions_revoked_at": at,
+		"suspended_at":        at,
+		"suspended_reason":    reason,
+	}).Error
+}
+
+// Unsuspend lets the user sign in again. Sessions revoked by the suspension
+// stay revoked.
+func (r *userRepository) Unsuspend(db *gorm.DB, id uint) error {
+	return db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"suspended_at":     nil,
+		"suspended_reason": "",
+	}).Error
+}
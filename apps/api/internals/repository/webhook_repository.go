@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	Create(db *gorm.DB, w *models.Webhook) error
+	Update(db *gorm.DB, w *models.Webhook) error
+	Delete(db *gorm.DB, id uint) error
+	GetByID(db *gorm.DB, orgID, id uint) (*models.Webhook, error)
+	LockByID(tx *gorm.DB, id uint) (*models.Webhook, error)
+	ListPersonal(db *gorm.DB, orgID, ownerID uint) ([]models.Webhook, error)
+	ListForTeam(db *gorm.DB, orgID, teamID uint) ([]models.Webhook, error)
+	ListActive(db *gorm.DB, orgID uint) ([]models.Webhook, error)
+	KeyAudience(db *gorm.DB, keyID uint) (uint, []uint, error)
+
+	CreateDelivery(db *gorm.DB, d *models.WebhookDelivery) error
+	UpdateDelivery(db *gorm.DB, d *models.WebhookDelivery) error
+	GetDelivery(db *gorm.DB, id uint) (*models.WebhookDelivery, error)
+	HasDelivery(db *gorm.DB, webhookID uint, eventID string) (bool, error)
+	ListDeliveries(db *gorm.DB, webhookID uint, status string, beforeID uint, limit int) ([]models.WebhookDelivery, error)
+	ListDueDeliveries(db *gorm.DB, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	ClaimDelivery(db *gorm.DB, id uint, now, until time.Time) (bool, error)
+	PurgeDeliveries(db *gorm.DB, before time.Time) error
+}
+
+type webhookRepo struct{}
+
+func NewWebhookRepository() WebhookRepository { return &webhookRepo{} }
+
+func (r *webhookRepo) Create(db *gorm.DB, w *models.Webhook) error {
+	return db.Create(w).Error
+}
+
+func (r *webhookRepo) Update(db *gorm.DB, w *models.Webhook) error {
+	return db.Save(w).Error
+}
+
+func (r *webhookRepo) Delete(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Webhook{}, id).Error
+	})
+}
+
+func (r *webhookRepo) GetByID(db *gorm.DB, orgID, id uint) (*models.Webhook, error) {
+	var w models.Webhook
+	if err := db.Where("organization_id = ? AND id = ?", orgID, id).First(&w).Error; err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// LockByID loads the webhook with a row lock, so failure counts from
+// concurrent deliveries add up. Must run inside a transaction.
+func (r *webhookRepo) LockByID(tx *gorm.DB, id uint) (*models.Webhook, error) {
+	var w models.Webhook
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&w, id).Error; err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *webhookRepo) ListPersonal(db *gorm.DB, orgID, ownerID uint) ([]models.Webhook, error) {
+	var ws []models.Webhook
+	err := db.Where("organization_id = ? AND owner_id = ? AND team_id IS NULL", orgID, ownerID).
+		Order("created_at DESC").Find(&ws).Error
+	return ws, err
+}
+
+func (r *webhookRepo) ListForTeam(db *gorm.DB, orgID, teamID uint) ([]models.Webhook, error) {
+	var ws []models.Webhook
+	err := db.Where("organization_id = ? AND team_id = ?", orgID, teamID).
+		Order("created_at DESC").Find(&ws).Error
+	return ws, err
+}
+
+func (r *webhookRepo) ListActive(db *gorm.DB, orgID uint) ([]models.Webhook, error) {
+	var ws []models.Webhook
+	err := db.Where("organization_id = ? AND active", orgID).Order("id").Find(&ws).Error
+	return ws, err
+}
+
+// KeyAudience returns a key's owner and the teams it is shared with.
+func (r *webhookRepo) KeyAudience(db *gorm.DB, keyID uint) (uint, []uint, error) {
+	var ownerIDs []uint
+	if err := db.Model(&models.APIKey{}).Where("id = ?", keyID).Pluck("owner_id", &ownerIDs).Error; err != nil {
+		return 0, nil, err
+	}
+	var teamIDs []uint
+	if err := db.Model(&models.APIKeyTeam{}).Where("api_key_id = ?", keyID).Pluck("team_id", &teamIDs).Error; err != nil {
+		return 0, nil, err
+	}
+	var owner uint
+	if len(ownerIDs) > 0 {
+		owner = ownerIDs[0]
+	}
+	return owner, teamIDs, nil
+}
+
+func (r *webhookRepo) CreateDelivery(db *gorm.DB, d *models.WebhookDelivery) error {
+	return db.Create(d).Error
+}
+
+func (r *webhookRepo) UpdateDelivery(db *gorm.DB, d *models.WebhookDelivery) error {
+	return db.Save(d).Error
+}
+
+func (r *webhookRepo) GetDelivery(db *gorm.DB, id uint) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	if err := db.First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// HasDelivery reports whether the event was already queued for the webhook,
+// not counting manual redeliveries.
+func (r *webhookRepo) HasDelivery(db *gorm.DB, webhookID uint, eventID string) (bool, error) {
+	var n int64
+	err := db.Model(&models.WebhookDelivery{}).
+		Where("webhook_id = ? AND event_id = ? AND NOT manual", webhookID, eventID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// ListDeliveries pages through a webhook's deliveries, newest first.
+func (r *webhookRepo) ListDeliveries(db *gorm.DB, webhookID uint, status string, beforeID uint, limit int) ([]models.WebhookDelivery, error) {
+	q := db.Where("webhook_id = ?", webhookID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if beforeID != 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var ds []models.WebhookDelivery
+	err := q.Order("id DESC").Limit(limit).Find(&ds).Error
+	return ds, err
+}
+
+// ListDueDeliveries returns pending deliveries of active webhooks whose
+// next attempt has come and that no dispatcher holds, oldest first, with
+// their webhook loaded. Deliveries of a disabled webhook wait until it is
+// enabled again.
+func (r *webhookRepo) ListDueDeliveries(db *gorm.DB, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var out []models.WebhookDelivery
+	err := db.Preload("Webhook").
+		Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id AND webhooks.active").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", models.DeliveryPending, now).
+		Where("webhook_deliveries.locked_until IS NULL OR webhook_deliveries.locked_until < ?", now).
+		Order("webhook_deliveries.next_attempt_at, webhook_deliveries.id").
+		Limit(limit).
+		Find(&out).Error
+	return out, err
+}
+
+// ClaimDelivery takes the delivery's lock until the given time. It reports
+// false when another dispatcher got there first.
+func (r *webhookRepo) ClaimDelivery(db *gorm.DB, id uint, now, until time.Time) (bool, error) {
+	res := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND (locked_until IS NULL OR locked_until < ?)", id, models.DeliveryPending, now).
+		Update("locked_until", until)
+	return res.RowsAffected == 1, res.Error
+}
+
+// PurgeDeliveries drops finished deliveries created before the given time.
+func (r *webhookRepo) PurgeDeliveries(db *gorm.DB, before time.Time) error {
+	return db.Where("status <> ? AND created_at < ?", models.DeliveryPending, before).
+		Delete(&models.WebhookDelivery{}).Error
+}
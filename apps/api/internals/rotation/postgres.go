@@ -0,0 +1,79 @@
+package rotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/pgsql"
+	"github.com/intojhanurag/One-Password/apps/api/internals/webhook"
+	"gorm.io/gorm"
+)
+
+// PostgresConfig rotates the password of a PostgreSQL role. DSN is a
+// postgres:// URL for a user allowed to ALTER ROLE; it is also used, with the
+// role's credentials swapped in, to check that the new password works.
+// Unless rotators may reach private addresses, connections to loopback,
+// private and link-local hosts (and unix sockets) are refused.
+type PostgresConfig struct {
+	DSN    string `json:"dsn"`
+	Role   string `json:"role"`
+	Length int    `json:"length,omitempty"`
+}
+
+type PostgresRotator struct {
+	Config PostgresConfig
+	Dialer pgsql.Dialer // nil connects anywhere
+}
+
+func NewPostgresRotator(c PostgresConfig, opts Options) (*PostgresRotator, error) {
+	u, err := url.Parse(c.DSN)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, errors.New("postgres rotator needs a postgres:// dsn")
+	}
+	if c.Role == "" {
+		return nil, errors.New("postgres rotator needs a role")
+	}
+	if c.Length == 0 {
+		c.Length = 32
+	}
+	if c.Length < 16 {
+		return nil, errors.New("password length must be at least 16")
+	}
+	p := &PostgresRotator{Config: c}
+	if !opts.AllowPrivate {
+		p.Dialer = webhook.NewDialer(false)
+	}
+	return p, nil
+}
+
+func (p *PostgresRotator) Rotate(ctx context.Context, current string) (string, error) {
+	next, err := randomPassword(p.Config.Length)
+	if err != nil {
+		return "", err
+	}
+	if err := p.setPassword(ctx, next); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+func (p *PostgresRotator) Verify(ctx context.Context, next string) error {
+	u, _ := url.Parse(p.Config.DSN)
+	u.User = url.UserPassword(p.Config.Role, next)
+	return pgsql.WithDialer(ctx, u.String(), p.Dialer, func(db *gorm.DB) error {
+		return db.Exec("SELECT 1").Error
+	})
+}
+
+func (p *PostgresRotator) Rollback(ctx context.Context, restore, discard string) error {
+	return p.setPassword(ctx, restore)
+}
+
+func (p *PostgresRotator) setPassword(ctx context.Context, password string) error {
+	stmt := fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s", pgsql.QuoteIdent(p.Config.Role), pgsql.QuoteLiteral(password))
+	return pgsql.WithDialer(ctx, p.Config.DSN, p.Dialer, func(db *gorm.DB) error {
+		return db.Exec(stmt).Error
+	})
+}
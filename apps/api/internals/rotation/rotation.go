@@ -0,0 +1,92 @@
+// Package rotation replaces secret values at the system that issued them.
+//
+// A rotation runs in two phases. Rotate creates a new credential at the
+// target while the current one keeps working, Verify checks that the new
+// value is usable, and only then is it promoted to the stored value. If
+// verification or promotion fails, Rollback restores the previous value at
+// the target.
+package rotation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// Rotator issues and retires credentials for one kind of target system.
+type Rotator interface {
+	// Rotate creates a replacement for current and returns it.
+	Rotate(ctx context.Context, current string) (string, error)
+	// Verify checks that a value returned by Rotate is accepted by the target.
+	Verify(ctx context.Context, next string) error
+	// Rollback makes restore the active credential again and drops discard.
+	Rollback(ctx context.Context, restore, discard string) error
+}
+
+// Options apply to every rotator, whatever its settings.
+type Options struct {
+	// AllowPrivate lets rotators reach loopback, private and link-local
+	// addresses, for development against local targets. Without it, a user
+	// who can configure rotation could point it at internal services.
+	AllowPrivate bool
+}
+
+// Factory builds a Rotator from its JSON settings.
+type Factory func(config json.RawMessage, opts Options) (Rotator, error)
+
+var ErrUnknownType = errors.New("unknown rotator type")
+
+var factories = map[string]Factory{
+	"webhook": func(config json.RawMessage, opts Options) (Rotator, error) {
+		var c WebhookConfig
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewWebhookRotator(c, opts)
+	},
+	"postgres": func(config json.RawMessage, opts Options) (Rotator, error) {
+		var c PostgresConfig
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewPostgresRotator(c, opts)
+	},
+}
+
+// New builds the rotator registered under kind.
+func New(kind string, config json.RawMessage, opts Options) (Rotator, error) {
+	f, ok := factories[kind]
+	if !ok {
+		return nil, ErrUnknownType
+	}
+	return f(config, opts)
+}
+
+// Types lists the registered rotator kinds.
+func Types() []string {
+	out := make([]string, 0, len(factories))
+	for k := range factories {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+const passwordAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// randomPassword returns n characters drawn uniformly from passwordAlphabet.
+func randomPassword(n int) (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		j, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordAlphabet[j.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,111 @@
+package rotation
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/webhook"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body when the
+// webhook has a signing secret.
+const SignatureHeader = "X-Rotation-Signature"
+
+// WebhookConfig points a rotator at an HTTP endpoint that owns the credential.
+//
+// Every call is a POST with a JSON body whose "action" is "rotate", "verify"
+// or "rollback". A rotate call must answer 2xx with {"value": "<new secret>"};
+// the other actions only need a 2xx status. The stored secret is never sent:
+// the endpoint issues the new value while its current one keeps working, and
+// a rollback names only the value to discard ("discard").
+type WebhookConfig struct {
+	URL        string `json:"url"`
+	Secret     string `json:"secret,omitempty"`
+	TimeoutSec int    `json:"timeout_sec,omitempty"`
+}
+
+type WebhookRotator struct {
+	Config WebhookConfig
+	Client *http.Client
+}
+
+// NewWebhookRotator accepts only https endpoints on public addresses unless
+// opts.AllowPrivate is set; names are checked again when connecting.
+func NewWebhookRotator(c WebhookConfig, opts Options) (*WebhookRotator, error) {
+	if _, err := webhook.CheckURL(c.URL, opts.AllowPrivate); err != nil {
+		return nil, fmt.Errorf("webhook rotator: %w", err)
+	}
+	timeout := 10 * time.Second
+	if c.TimeoutSec > 0 {
+		timeout = time.Duration(c.TimeoutSec) * time.Second
+	}
+	return &WebhookRotator{Config: c, Client: webhook.NewClient(timeout, opts.AllowPrivate)}, nil
+}
+
+func (w *WebhookRotator) Rotate(ctx context.Context, current string) (string, error) {
+	var out struct {
+		Value string `json:"value"`
+	}
+	if err := w.call(ctx, map[string]string{"action": "rotate"}, &out); err != nil {
+		return "", err
+	}
+	if out.Value == "" {
+		return "", errors.New("webhook returned no value")
+	}
+	if out.Value == current {
+		return "", errors.New("webhook returned the current value")
+	}
+	return out.Value, nil
+}
+
+func (w *WebhookRotator) Verify(ctx context.Context, next string) error {
+	return w.call(ctx, map[string]string{"action": "verify", "value": next}, nil)
+}
+
+// Rollback asks the endpoint to drop discard. The endpoint kept the previous
+// credential working, so restore is not sent.
+func (w *WebhookRotator) Rollback(ctx context.Context, restore, discard string) error {
+	return w.call(ctx, map[string]string{"action": "rollback", "discard": discard}, nil)
+}
+
+func (w *WebhookRotator) call(ctx context.Context, payload map[string]string, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Config.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with %s", payload["action"], resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("invalid webhook response: %w", err)
+	}
+	return nil
+}
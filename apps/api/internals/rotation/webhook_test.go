@@ -0,0 +1,185 @@
+package rotation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/webhook"
+)
+
+// fakeIssuer is a credential owner that accepts exactly one active value.
+type fakeIssuer struct {
+	active  string
+	prev    string
+	next    string
+	calls   []string
+	failOn  string
+	secret  string
+	badSigs int
+}
+
+func (f *fakeIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if f.secret != "" {
+		mac := hmac.New(sha256.New, []byte(f.secret))
+		mac.Write(body)
+		if r.Header.Get(SignatureHeader) != hex.EncodeToString(mac.Sum(nil)) {
+			f.badSigs++
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+	}
+	var req map[string]string
+	json.Unmarshal(body, &req)
+	f.calls = append(f.calls, req["action"])
+	if req["action"] == f.failOn {
+		http.Error(w, "nope", http.StatusInternalServerError)
+		return
+	}
+	switch req["action"] {
+	case "rotate":
+		if _, ok := req["current"]; ok {
+			http.Error(w, "current value sent", http.StatusBadRequest)
+			return
+		}
+		f.prev, f.active = f.active, f.next
+		json.NewEncoder(w).Encode(map[string]string{"value": f.next})
+	case "verify":
+		if req["value"] != f.active {
+			http.Error(w, "invalid", http.StatusUnauthorized)
+		}
+	case "rollback":
+		if _, ok := req["restore"]; ok {
+			http.Error(w, "restored value sent", http.StatusBadRequest)
+			return
+		}
+		if req["discard"] == f.active {
+			f.active = f.prev
+		}
+	}
+}
+
+func newTestWebhook(t *testing.T, f *fakeIssuer) Rotator {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cfg, _ := json.Marshal(WebhookConfig{URL: srv.URL, Secret: f.secret})
+	r, err := New("webhook", cfg, Options{AllowPrivate: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+func TestWebhookRotateVerifyRollback(t *testing.T) {
+	f := &fakeIssuer{active: "old", next: "new", secret: "s3cret"}
+	r := newTestWebhook(t, f)
+	ctx := context.Background()
+
+	next, err := r.Rotate(ctx, "old")
+	if err != nil || next != "new" {
+		t.Fatalf("Rotate = %q, %v", next, err)
+	}
+	if err := r.Verify(ctx, next); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := r.Rollback(ctx, "old", next); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if f.active != "old" {
+		t.Fatalf("active after rollback = %q", f.active)
+	}
+	if f.badSigs != 0 {
+		t.Fatalf("%d requests had a bad signature", f.badSigs)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	ctx := context.Background()
+
+	f := &fakeIssuer{active: "old", next: "new", failOn: "rotate"}
+	if _, err := newTestWebhook(t, f).Rotate(ctx, "old"); err == nil {
+		t.Fatal("expected an error on a 500 response")
+	}
+
+	f = &fakeIssuer{active: "old", next: "old"}
+	if _, err := newTestWebhook(t, f).Rotate(ctx, "old"); err == nil {
+		t.Fatal("expected an error when the value does not change")
+	}
+
+	f = &fakeIssuer{active: "other", next: "new"}
+	r := newTestWebhook(t, f)
+	if err := r.Verify(ctx, "new"); err == nil {
+		t.Fatal("expected verify to fail for an inactive value")
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	if _, err := New("ftp", nil, Options{}); err != ErrUnknownType {
+		t.Fatalf("err = %v, want ErrUnknownType", err)
+	}
+	if _, err := New("webhook", json.RawMessage(`{"url":"file:///etc/passwd"}`), Options{}); err == nil {
+		t.Fatal("expected a non-http url to be rejected")
+	}
+	if _, err := New("postgres", json.RawMessage(`{"dsn":"postgres://localhost/db"}`), Options{}); err == nil {
+		t.Fatal("expected a missing role to be rejected")
+	}
+}
+
+func TestRotatorsRefusePrivateAddresses(t *testing.T) {
+	ctx := context.Background()
+	for _, url := range []string{"http://example.com/rotate", "https://127.0.0.1/rotate", "https://169.254.169.254/latest"} {
+		cfg, _ := json.Marshal(WebhookConfig{URL: url})
+		if _, err := New("webhook", cfg, Options{}); err == nil {
+			t.Errorf("accepted webhook url %s", url)
+		}
+	}
+
+	f := &fakeIssuer{active: "old", next: "new"}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	cfg, _ := json.Marshal(WebhookConfig{URL: srv.URL})
+	if _, err := New("webhook", cfg, Options{}); err == nil {
+		t.Error("accepted an http loopback url")
+	}
+	// a name is only resolved when connecting
+	cfg, _ = json.Marshal(WebhookConfig{URL: strings.Replace(srv.URL, "http://127.0.0.1", "https://localhost", 1)})
+	w, err := New("webhook", cfg, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Rotate(ctx, "old"); !errors.Is(err, webhook.ErrPrivateAddress) {
+		t.Errorf("Rotate against a loopback name = %v, want ErrPrivateAddress", err)
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("the endpoint was called %d times", len(f.calls))
+	}
+
+	p, err := New("postgres", json.RawMessage(`{"dsn":"postgres://u:p@127.0.0.1:1/db?connect_timeout=2","role":"app"}`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Verify(ctx, "whatever-password"); !errors.Is(err, webhook.ErrPrivateAddress) {
+		t.Errorf("Verify against loopback = %v, want ErrPrivateAddress", err)
+	}
+}
+
+func TestRandomPassword(t *testing.T) {
+	a, err := randomPassword(32)
+	if err != nil || len(a) != 32 {
+		t.Fatalf("randomPassword = %q, %v", a, err)
+	}
+	b, _ := randomPassword(32)
+	if a == b {
+		t.Fatal("two passwords were identical")
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
+)
+
+// Approved "once" grants must be used within this period.
+const defaultOnceGrantTTL = 24 * time.Hour
+
+var (
+	ErrNotApprover = errors.New("only the key owner or a team owner/admin can decide this request")
+	ErrGrantUsed   = errors.New("this one-time approval has already been used")
+	ErrDecided     = errors.New("this request has already been decided")
+)
+
+type AccessRequestService struct {
+	Repo       repository.AccessRequestRepository
+	APIKeyRepo repository.APIKeyRepository
+	DB         *gorm.DB
+}
+
+type DecideAccessRequestInput struct {
+	OrganizationID uint
+	ApproverID     uint
+	RequestID      uint
+	Reason         string
+	GrantType      string // "once" | "window", approvals only
+	WindowMinutes  int    // required for "window" grants
+}
+
+func NewAccessRequestService(repo repository.AccessRequestRepository, akRepo repository.APIKeyRepository, db *gorm.DB) *AccessRequestService {
+	return &AccessRequestService{Repo: repo, APIKeyRepo: akRepo, DB: db}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *AccessRequestService) WithContext(ctx context.Context) *AccessRequestService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+// ListMine lists the user's requests on keys in the organization.
+func (s *AccessRequestService) ListMine(orgID, userID uint) ([]models.AccessRequest, error) {
+	return s.Repo.ListByRequester(s.DB, orgID, userID)
+}
+
+func (s *AccessRequestService) ListPendingApprovals(orgID, userID uint) ([]models.AccessRequest, error) {
+	return s.Repo.ListPendingForApprover(s.DB, orgID, userID)
+}
+
+func (s *AccessRequestService) Approve(in DecideAccessRequestInput) (*models.AccessRequest, error) {
+	req, err := s.pendingForApprover(in.OrganizationID, in.ApproverID, in.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var expiresAt time.Time
+	switch in.GrantType {
+	case "", "once":
+		in.GrantType = "once"
+		expiresAt = now.Add(defaultOnceGrantTTL)
+	case "window":
+		if in.WindowMinutes <= 0 {
+			return nil, errors.New("window_minutes required for window grants")
+		}
+		expiresAt = now.Add(time.Duration(in.WindowMinutes) * time.Minute)
+	default:
+		return nil, errors.New("grant_type must be once or window")
+	}
+
+	req.Status = "approved"
+	req.GrantType = in.GrantType
+	req.ApproverID = &in.ApproverID
+	req.DecisionReason = in.Reason
+	req.DecidedAt = &now
+	req.ExpiresAt = &expiresAt
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.decide(tx, req); err != nil {
+			return err
+		}
+		return enqueueActivity(tx, &models.Activity{
+			UserID:   in.ApproverID,
+			Type:     "access_approved",
+			Entity:   "access_request",
+			EntityID: req.ID,
+			Message:  fmt.Sprintf("Access request #%d approved (%s) until %s", req.ID, req.GrantType, expiresAt.Format(time.RFC3339)),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (s *AccessRequestService) Deny(in DecideAccessRequestInput) (*models.AccessRequest, error) {
+	if in.Reason == "" {
+		return nil, errors.New("reason required when denying a request")
+	}
+	req, err := s.pendingForApprover(in.OrganizationID, in.ApproverID, in.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	req.Status = "denied"
+	req.ApproverID = &in.ApproverID
+	req.DecisionReason = in.Reason
+	req.DecidedAt = &now
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.decide(tx, req); err != nil {
+			return err
+		}
+		return enqueueActivity(tx, &models.Activity{
+			UserID:   in.ApproverID,
+			Type:     "access_request_denied",
+			Entity:   "access_request",
+			EntityID: req.ID,
+			Message:  fmt.Sprintf("Access request #%d denied: %s", req.ID, in.Reason),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (s *AccessRequestService) pendingForApprover(orgID, approverID, requestID uint) (*models.AccessRequest, error) {
+	req, err := s.Repo.GetByID(s.DB, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != "pending" {
+		return nil, fmt.Errorf("%w: it is %s", ErrDecided, req.Status)
+	}
+	if req.RequesterID == approverID {
+		return nil, errors.New("requesters cannot decide their own request")
+	}
+
+	ok, err := s.APIKeyRepo.CanManage(s.DB, orgID, approverID, req.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotApprover
+	}
+	return req, nil
+}
+
+// decide records the decision unless another approver decided the request
+// since it was loaded.
+func (s *AccessRequestService) decide(tx *gorm.DB, req *models.AccessRequest) error {
+	ok, err := s.Repo.Decide(tx, req)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDecided
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+)
+
+func newAccessRequestTest(t *testing.T) (*AccessRequestService, *models.AccessRequest) {
+	t.Helper()
+	db := testDB(t, &models.APIKey{}, &models.Team{}, &models.TeamMembership{}, &models.APIKeyTeam{}, &models.AccessRequest{})
+	key := createKey(t, db, &models.APIKey{OrganizationID: 1, OwnerID: 10, Name: "stripe", RequiresApproval: true}, "sk_live_1")
+	req := &models.AccessRequest{APIKeyID: key.ID, RequesterID: 20, Status: "pending"}
+	if err := db.Create(req).Error; err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	return NewAccessRequestService(repository.NewAccessRequestRepository(), repository.NewAPIKeyRepository(), db), req
+}
+
+func TestApproveRejectsNonApprovers(t *testing.T) {
+	svc, req := newAccessRequestTest(t)
+
+	if _, err := svc.Approve(DecideAccessRequestInput{OrganizationID: 1, ApproverID: 30, RequestID: req.ID}); !errors.Is(err, ErrNotApprover) {
+		t.Errorf("approve by a non-manager = %v, want ErrNotApprover", err)
+	}
+	if _, err := svc.Approve(DecideAccessRequestInput{OrganizationID: 1, ApproverID: 20, RequestID: req.ID}); err == nil {
+		t.Error("requester approved their own request")
+	}
+
+	got, err := svc.Repo.GetByID(svc.DB, req.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "pending" {
+		t.Errorf("status = %q after refused approvals, want pending", got.Status)
+	}
+}
+
+func TestRequestIsDecidedOnce(t *testing.T) {
+	svc, req := newAccessRequestTest(t)
+	stale := *req
+
+	if _, err := svc.Approve(DecideAccessRequestInput{OrganizationID: 1, ApproverID: 10, RequestID: req.ID}); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	_, err := svc.Deny(DecideAccessRequestInput{OrganizationID: 1, ApproverID: 10, RequestID: req.ID, Reason: "changed my mind"})
+	if !errors.Is(err, ErrDecided) {
+		t.Fatalf("Deny after Approve = %v, want ErrDecided", err)
+	}
+
+	// a second approver who loaded the request before the first decided
+	stale.Status = "denied"
+	if err := svc.decide(svc.DB, &stale); !errors.Is(err, ErrDecided) {
+		t.Fatalf("decide on a stale request = %v, want ErrDecided", err)
+	}
+
+	got, err := svc.Repo.GetByID(svc.DB, req.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "approved" {
+		t.Errorf("status = %q, want approved", got.Status)
+	}
+}
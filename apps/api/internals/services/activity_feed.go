@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/database"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+)
+
+const (
+	feedBuffer = 256              // activities a slow stream may fall behind by before it catches up from the database
+	feedBatch  = 500              // activities read per query
+	feedPoll   = 30 * time.Second // looks for new activities even without a notification
+)
+
+// ActivityFeed pushes activities to live streams as they are committed. Each
+// API instance runs one: a LISTEN on database.ActivityFeedChannel wakes it,
+// it reads the new activities once, in chain order, and hands each to the
+// subscriptions allowed to see it.
+type ActivityFeed struct {
+	Repo *repository.ActivityRepository
+	DSN  string
+
+	mu   sync.Mutex
+	subs map[*FeedSubscription]struct{}
+	head int64 // seq of the last activity handed out
+	wake chan struct{}
+}
+
+func NewActivityFeed(repo *repository.ActivityRepository, dsn string) *ActivityFeed {
+	return &ActivityFeed{
+		Repo: repo,
+		DSN:  dsn,
+		subs: make(map[*FeedSubscription]struct{}),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// FeedSubscription receives the activities one stream may see: the user's
+// own and those of the given teams, as Search scopes them, in one
+// organization.
+type FeedSubscription struct {
+	OrganizationID uint
+	UserID         uint
+	Since          int64 // the feed's head when subscribed; every later activity arrives on C
+	C              <-chan models.Activity
+
+	c      chan models.Activity
+	mu     sync.Mutex
+	teams  []uint
+	lagged bool
+}
+
+// Teams returns the teams the subscription covers.
+func (s *FeedSubscription) Teams() []uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.teams
+}
+
+// SetTeams replaces the teams, e.g. after the user's roles change.
+func (s *FeedSubscription) SetTeams(teamIDs []uint) {
+	s.mu.Lock()
+	s.teams = teamIDs
+	s.mu.Unlock()
+}
+
+// Lagged reports, once, that activities were dropped because C was full.
+// The stream should then catch up from the database.
+func (s *FeedSubscription) Lagged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lagged := s.lagged
+	s.lagged = false
+	return lagged
+}
+
+func (s *FeedSubscription) push(a models.Activity, keyTeams map[uint][]uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.visible(&a, keyTeams) {
+		return
+	}
+	select {
+	case s.c <- a:
+	default:
+		s.lagged = true
+	}
+}
+
+func (s *FeedSubscription) visible(a *models.Activity, keyTeams map[uint][]uint) bool {
+	if a.OrganizationID != s.OrganizationID {
+		return false
+	}
+	if a.UserID == s.UserID {
+		return true
+	}
+	for _, t := range s.teams {
+		if a.TeamID != nil && *a.TeamID == t {
+			return true
+		}
+		if a.Entity == "apikey" {
+			for _, kt := range keyTeams[a.EntityID] {
+				if kt == t {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Subscribe registers a stream. Call Unsubscribe when it ends.
+func (f *ActivityFeed) Subscribe(orgID, userID uint, teamIDs []uint) *FeedSubscription {
+	c := make(chan models.Activity, feedBuffer)
+	s := &FeedSubscription{OrganizationID: orgID, UserID: userID, C: c, c: c, teams: teamIDs}
+	f.mu.Lock()
+	s.Since = f.head
+	f.subs[s] = struct{}{}
+	f.mu.Unlock()
+	return s
+}
+
+func (f *ActivityFeed) Unsubscribe(s *FeedSubscription) {
+	f.mu.Lock()
+	delete(f.subs, s)
+	f.mu.Unlock()
+}
+
+// Head is the seq of the last activity handed out.
+func (f *ActivityFeed) Head() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head
+}
+
+// Start begins at the current head of the chain and follows it until ctx is
+// done.
+func (f *ActivityFeed) Start(ctx context.Context) error {
+	head, err := f.Repo.HeadSeq()
+	if err != nil {
+		return err
+	}
+	f.head = head
+	go database.Listen(ctx, f.DSN, database.ActivityFeedChannel, func(string) { f.signal() })
+	go func() {
+		ticker := time.NewTicker(feedPoll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-f.wake:
+			case <-ticker.C:
+			}
+			if err := f.poll(); err != nil {
+				fmt.Printf("activity feed: %v\n", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (f *ActivityFeed) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// poll hands out the activities committed since the last poll. Without
+// subscribers it only moves the head forward.
+func (f *ActivityFeed) poll() error {
+	f.mu.Lock()
+	idle := len(f.subs) == 0
+	head := f.head
+	f.mu.Unlock()
+
+	if idle {
+		latest, err := f.Repo.HeadSeq()
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		// a stream may have subscribed meanwhile; it must not miss anything
+		if len(f.subs) == 0 && latest > f.head {
+			f.head = latest
+		}
+		idle = len(f.subs) == 0
+		f.mu.Unlock()
+		if idle {
+			return nil
+		}
+	}
+
+	for {
+		batch, err := f.Repo.ListAfterSeq(head, feedBatch)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		var keyIDs []uint
+		for i := range batch {
+			if batch[i].Entity == "apikey" {
+				keyIDs = append(keyIDs, batch[i].EntityID)
+			}
+		}
+		keyTeams, err := f.Repo.KeyTeams(keyIDs)
+		if err != nil {
+			return err
+		}
+
+		f.mu.Lock()
+		for _, a := range batch {
+			for s := range f.subs {
+				s.push(a, keyTeams)
+			}
+			f.head = a.Seq
+		}
+		f.mu.Unlock()
+
+		head = batch[len(batch)-1].Seq
+		if len(batch) < feedBatch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+)
+
+func TestFeedSubscriptionSeesOnlyItsScope(t *testing.T) {
+	feed := NewActivityFeed(nil, "")
+	sub := feed.Subscribe(1, 10, []uint{5})
+	defer feed.Unsubscribe(sub)
+
+	team5, team6 := uint(5), uint(6)
+	keyTeams := map[uint][]uint{100: {5}, 200: {6}}
+	for _, a := range []models.Activity{
+		{Seq: 1, OrganizationID: 1, UserID: 10, Type: "apikey_created"},                                   // own
+		{Seq: 2, OrganizationID: 2, UserID: 10, Type: "apikey_created"},                                   // own, other org
+		{Seq: 3, OrganizationID: 1, UserID: 20, Type: "team_updated", TeamID: &team5},                     // the user's team
+		{Seq: 4, OrganizationID: 1, UserID: 20, Type: "team_updated", TeamID: &team6},                     // another team
+		{Seq: 5, OrganizationID: 1, UserID: 20, Type: "apikey_revealed", Entity: "apikey", EntityID: 100}, // key shared with the team
+		{Seq: 6, OrganizationID: 1, UserID: 20, Type: "apikey_revealed", Entity: "apikey", EntityID: 200}, // key shared elsewhere
+		{Seq: 7, OrganizationID: 1, UserID: 20, Type: "apikey_revealed", Entity: "apikey", EntityID: 300}, // key not shared
+		{Seq: 8, OrganizationID: 2, UserID: 20, Type: "team_updated", TeamID: &team5},                     // same team id, other org
+	} {
+		sub.push(a, keyTeams)
+	}
+
+	var got []int64
+	for len(sub.C) > 0 {
+		got = append(got, (<-sub.C).Seq)
+	}
+	want := []int64{1, 3, 5}
+	if len(got) != len(want) {
+		t.Fatalf("delivered seqs %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("delivered seqs %v, want %v", got, want)
+		}
+	}
+
+	// after leaving the team its activities stop arriving
+	sub.SetTeams(nil)
+	sub.push(models.Activity{Seq: 9, OrganizationID: 1, UserID: 20, TeamID: &team5}, keyTeams)
+	if len(sub.C) != 0 {
+		t.Error("activity of a team the user left was delivered")
+	}
+}
+
+func TestFeedSubscriptionReportsLagOnce(t *testing.T) {
+	feed := NewActivityFeed(nil, "")
+	sub := feed.Subscribe(1, 10, nil)
+	defer feed.Unsubscribe(sub)
+
+	for i := 0; i <= feedBuffer; i++ {
+		sub.push(models.Activity{Seq: int64(i + 1), OrganizationID: 1, UserID: 10}, nil)
+	}
+	if !sub.Lagged() {
+		t.Fatal("overflowing the buffer was not reported")
+	}
+	if sub.Lagged() {
+		t.Error("lag reported twice")
+	}
+}
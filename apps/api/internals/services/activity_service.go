@@ -1,16 +1,46 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
 	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+
+	defaultActivityPageSize = 50
+	maxActivityPageSize     = 200
+)
+
+var (
+	ErrInvalidStatsRange = errors.New("invalid range: from and to must be YYYY-MM-DD dates, from not after to")
+	ErrStatsRangeTooLong = fmt.Errorf("range cannot exceed %d days", maxStatsDays)
+
+	ErrTeamActivityForbidden = errors.New("only team owners and admins can view team activity")
+	ErrInvalidActivityRange  = errors.New("from must be before to")
+	ErrFeedUnavailable       = errors.New("live activity feed is not running")
 )
 
 type ActivityService struct {
-	repo *repository.ActivityRepository
+	repo    *repository.ActivityRepository
+	teams   repository.TeamRepository
+	members repository.TeamMembershipRepository
+	db      *gorm.DB
+
+	Feed *ActivityFeed // live activity streams; nil disables them
 }
 
-func NewActivityService(repo *repository.ActivityRepository) *ActivityService {
-	return &ActivityService{repo: repo}
+func NewActivityService(repo *repository.ActivityRepository, teams repository.TeamRepository, members repository.TeamMembershipRepository, db *gorm.DB) *ActivityService {
+	return &ActivityService{repo: repo, teams: teams, members: members, db: db}
 }
 
 func (s *ActivityService) GetDashboard(limit, offset int) (*models.ActivityDashboard, error) {
@@ -38,3 +68,316 @@ func (s *ActivityService) GetDashboard(limit, offset int) (*models.ActivityDashb
 func (s *ActivityService) GetByID(id string) (*models.Activity, error) {
 	return s.repo.GetByID(id)
 }
+
+// ActivitySearchInput filters the activity log. Without TeamID it lists the
+// user's own activities; with TeamID, everything on that team and its shared
+// keys, which only the team's owners and admins may see.
+type ActivitySearchInput struct {
+	OrganizationID uint
+	UserID         uint
+	TeamID         uint
+	Types          []string
+	Entity         string
+	EntityID       uint
+	ActorID        uint
+	From           *time.Time
+	To             *time.Time
+	Query          string
+	Limit          int
+	Cursor         string
+}
+
+type ActivitySearchResult struct {
+	Items      []models.Activity
+	NextCursor string
+}
+
+// Search returns one page of activities, newest first.
+func (s *ActivityService) Search(in ActivitySearchInput) (*ActivitySearchResult, error) {
+	if in.TeamID != 0 {
+		if err := s.requireTeamManager(in.OrganizationID, in.TeamID, in.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if in.From != nil && in.To != nil && !in.From.Before(*in.To) {
+		return nil, ErrInvalidActivityRange
+	}
+
+	q := repository.ActivityQuery{
+		OrganizationID: in.OrganizationID,
+		UserID:         in.UserID,
+		TeamID:         in.TeamID,
+		Types:          in.Types,
+		Entity:         in.Entity,
+		EntityID:       in.EntityID,
+		ActorID:        in.ActorID,
+		From:           in.From,
+		To:             in.To,
+		Text:           strings.TrimSpace(in.Query),
+		Limit:          in.Limit,
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultActivityPageSize
+	}
+	if q.Limit > maxActivityPageSize {
+		q.Limit = maxActivityPageSize
+	}
+	if in.Cursor != "" {
+		var after repository.ActivityCursor
+		if err := utils.DecodeCursor(in.Cursor, &after); err != nil {
+			return nil, err
+		}
+		q.After = &after
+	}
+
+	items, next, err := s.repo.Search(q)
+	if err != nil {
+		return nil, err
+	}
+	res := &ActivitySearchResult{Items: items}
+	if next != nil {
+		if res.NextCursor, err = utils.EncodeCursor(next); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Subscribe opens a live feed of the user's activities and those of the
+// teams in the organization they own or administer.
+func (s *ActivityService) Subscribe(orgID, userID uint) (*FeedSubscription, error) {
+	if s.Feed == nil {
+		return nil, ErrFeedUnavailable
+	}
+	teams, err := s.repo.ManagedTeamIDs(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Feed.Subscribe(orgID, userID, teams), nil
+}
+
+func (s *ActivityService) Unsubscribe(sub *FeedSubscription) {
+	s.Feed.Unsubscribe(sub)
+}
+
+// RefreshFeedTeams picks up changes to the user's team roles.
+func (s *ActivityService) RefreshFeedTeams(sub *FeedSubscription, orgID uint) error {
+	teams, err := s.repo.ManagedTeamIDs(orgID, sub.UserID)
+	if err != nil {
+		return err
+	}
+	sub.SetTeams(teams)
+	return nil
+}
+
+// FeedHead is the seq of the newest activity handed to live feeds.
+func (s *ActivityService) FeedHead() int64 {
+	return s.Feed.Head()
+}
+
+// Replay returns up to limit of the subscription's activities with a seq
+// after after and up to through, oldest first: the ones a stream missed.
+func (s *ActivityService) Replay(sub *FeedSubscription, after, through int64, limit int) ([]models.Activity, error) {
+	return s.repo.ListFeed(sub.OrganizationID, sub.UserID, sub.Teams(), after, through, limit)
+}
+
+// requireTeamManager checks the team is in the organization and the user
+// owns or administers it.
+func (s *ActivityService) requireTeamManager(orgID, teamID, userID uint) error {
+	ok, err := isTeamManager(s.teams, s.members, s.db, orgID, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTeamActivityForbidden
+	}
+	return nil
+}
+
+// ActivityStatsInput selects the days to report on. From and To are
+// YYYY-MM-DD dates in Timezone, both included; by default the last 30 days
+// up to today.
+type ActivityStatsInput struct {
+	OrganizationID uint
+	UserID         uint
+	From           string
+	To             string
+	Timezone       string
+}
+
+type DailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD in the requested timezone
+	Count int64  `json:"count"`
+}
+
+// ActivityStats describes a user's activity. The headline counts cover all
+// time, today, the last 7 days and this month; the rest cover the range.
+type ActivityStats struct {
+	Timezone string    `json:"timezone"`
+	From     time.Time `json:"from"` // first instant of the range
+	To       time.Time `json:"to"`   // end of the range, exclusive
+
+	TotalActivities     int64 `json:"totalActivities"`
+	ActivitiesToday     int64 `json:"activitiesToday"`
+	ActivitiesThisWeek  int64 `json:"activitiesThisWeek"` // today and the 6 days before
+	ActivitiesThisMonth int64 `json:"activitiesThisMonth"`
+	ActivitiesInRange   int64 `json:"activitiesInRange"`
+
+	ActivityTypes  map[string]int64 `json:"activityTypes"`
+	Daily          []DailyCount     `json:"daily"` // every day of the range, oldest first
+	ByWeekday      map[string]int64 `json:"byWeekday"`
+	ByHour         [24]int64        `json:"byHour"`
+	MostActiveDay  string           `json:"mostActiveDay"`  // weekday name; empty without activity
+	MostActiveDate string           `json:"mostActiveDate"` // busiest single day
+	MostActiveHour *int             `json:"mostActiveHour"` // 0-23; null without activity
+
+	SecurityEvents     int64            `json:"securityEvents"`
+	SecurityEventTypes map[string]int64 `json:"securityEventTypes"`
+}
+
+// Stats aggregates the user's activity with day boundaries, weekdays and
+// hours taken in the requested timezone.
+func (s *ActivityService) Stats(in ActivityStatsInput) (*ActivityStats, error) {
+	loc, err := utils.LoadTimezone(in.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	today := utils.StartOfDay(now, loc)
+	from, to, err := statsRange(in, today, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &ActivityStats{
+		Timezone:           loc.String(),
+		From:               from,
+		To:                 to,
+		ActivityTypes:      map[string]int64{},
+		ByWeekday:          map[string]int64{},
+		SecurityEventTypes: map[string]int64{},
+	}
+
+	counts, err := s.repo.CountSince(in.OrganizationID, in.UserID, time.Time{}, today, utils.AddDays(today, -6), utils.StartOfMonth(now, loc))
+	if err != nil {
+		return nil, err
+	}
+	out.TotalActivities, out.ActivitiesToday, out.ActivitiesThisWeek, out.ActivitiesThisMonth = counts[0], counts[1], counts[2], counts[3]
+
+	q := repository.ActivityRange{OrganizationID: in.OrganizationID, UserID: in.UserID, From: from, To: to, Timezone: loc.String()}
+
+	types, err := s.repo.CountByType(q)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range types {
+		out.ActivityTypes[b.Key] = b.Count
+		out.ActivitiesInRange += b.Count
+	}
+
+	days, err := s.repo.CountByDay(q)
+	if err != nil {
+		return nil, err
+	}
+	out.Daily, out.MostActiveDate = dailyHistogram(from, to, days)
+
+	weekdays, err := s.repo.CountByWeekday(q)
+	if err != nil {
+		return nil, err
+	}
+	var byWeekday [7]int64
+	for _, b := range weekdays {
+		if d, err := strconv.Atoi(b.Key); err == nil && d >= 0 && d < 7 {
+			byWeekday[d] = b.Count
+		}
+	}
+	for d, n := range byWeekday {
+		out.ByWeekday[time.Weekday(d).String()] = n
+	}
+	if d, ok := busiest(byWeekday[:], 1); ok {
+		out.MostActiveDay = time.Weekday(d).String()
+	}
+
+	hours, err := s.repo.CountByHour(q)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range hours {
+		if h, err := strconv.Atoi(b.Key); err == nil && h >= 0 && h < 24 {
+			out.ByHour[h] = b.Count
+		}
+	}
+	if h, ok := busiest(out.ByHour[:], 0); ok {
+		out.MostActiveHour = &h
+	}
+
+	security, err := s.repo.CountSecurityByType(q)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range security {
+		out.SecurityEventTypes[b.Key] = b.Count
+		out.SecurityEvents += b.Count
+	}
+	return out, nil
+}
+
+// statsRange turns the requested dates into [from, to) local midnights.
+func statsRange(in ActivityStatsInput, today time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	to := utils.AddDays(today, 1)
+	if in.To != "" {
+		d, err := utils.ParseDay(in.To, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidStatsRange
+		}
+		to = utils.AddDays(d, 1)
+	}
+	from := utils.AddDays(to, -defaultStatsDays)
+	if in.From != "" {
+		d, err := utils.ParseDay(in.From, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidStatsRange
+		}
+		from = d
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, ErrInvalidStatsRange
+	}
+	if utils.AddDays(from, maxStatsDays).Before(to) {
+		return time.Time{}, time.Time{}, ErrStatsRangeTooLong
+	}
+	return from, to, nil
+}
+
+// dailyHistogram lays the per-day counts out over every day in [from, to)
+// and picks the busiest day (the earliest, on a tie).
+func dailyHistogram(from, to time.Time, days []repository.ActivityBucket) ([]DailyCount, string) {
+	byDate := make(map[string]int64, len(days))
+	for _, b := range days {
+		byDate[b.Key] = b.Count
+	}
+	out := []DailyCount{}
+	var busiest DailyCount
+	for d := from; d.Before(to); d = utils.AddDays(d, 1) {
+		c := DailyCount{Date: d.Format("2006-01-02"), Count: byDate[d.Format("2006-01-02")]}
+		if c.Count > busiest.Count {
+			busiest = c
+		}
+		out = append(out, c)
+	}
+	return out, busiest.Date
+}
+
+// busiest returns the index of the largest count, looking from start and
+// wrapping around so that ties go to the first index from start (weeks
+// start on Monday). It reports false when every count is zero.
+func busiest(counts []int64, start int) (int, bool) {
+	best, found := 0, false
+	for i := range counts {
+		j := (start + i) % len(counts)
+		if counts[j] > 0 && (!found || counts[j] > counts[best]) {
+			best, found = j, true
+		}
+	}
+	return best, found
+}
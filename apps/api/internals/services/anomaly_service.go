@@ -0,0 +1,622 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/anomaly"
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountSuspended = errors.New("account suspended after a security alert; an organization admin must lift it")
+	ErrSessionRevoked   = errors.New("session revoked; sign in again")
+	ErrNotSuspended     = errors.New("user is not suspended")
+	ErrNotSuspendedHere = errors.New("no open alert in this organization suspended the user")
+)
+
+// AccessListKeys is the access counted for each key listing, see
+// middleware.CountAccess.
+const AccessListKeys = "apikeys_list"
+
+const (
+	alertDedupWindow  = time.Hour        // repeats within this fold into the open alert
+	sessionCacheTTL   = 15 * time.Second // how stale a suspension may be on another instance
+	sessionCacheMax   = 10000
+	counterRetention  = 24 * time.Hour
+	counterPurgeEvery = time.Hour
+
+	defaultAlertPageSize = 50
+	maxAlertPageSize     = 200
+)
+
+// AnomalyService watches secret access for behaviour that is out of line
+// with a user's history. It is an outbox subscriber: each reveal or sign-in
+// is scored by the rules in package anomaly, and whatever they find becomes
+// a security alert for the organization's admins, logged as a
+// "security_alert" activity. When an event's combined score reaches the
+// suspend threshold, the user's sessions are revoked and sign-in is blocked
+// until an admin lifts the suspension.
+type AnomalyService struct {
+	Repo          repository.SecurityAlertRepository
+	Users         repository.UserRepository
+	Orgs          repository.OrganizationRepository
+	Notifications *NotificationService
+	DB            *gorm.DB
+	Thresholds    anomaly.Thresholds
+	Geo           anomaly.Geo // nil without a country table
+
+	sessions *sessionCache
+}
+
+func NewAnomalyService(repo repository.SecurityAlertRepository, users repository.UserRepository, orgs repository.OrganizationRepository, notifications *NotificationService, db *gorm.DB, thresholds anomaly.Thresholds, geo anomaly.Geo) *AnomalyService {
+	return &AnomalyService{
+		Repo:          repo,
+		Users:         users,
+		Orgs:          orgs,
+		Notifications: notifications,
+		DB:            db,
+		Thresholds:    thresholds,
+		Geo:           geo,
+		sessions:      &sessionCache{users: make(map[uint]sessionState)},
+	}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *AnomalyService) WithContext(ctx context.Context) *AnomalyService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+func (s *AnomalyService) Name() string { return "anomaly_detector" }
+
+func (s *AnomalyService) Handles(topic string) bool { return topic == TopicActivity }
+
+// Deliver scores one activity. Only successful reveals and sign-ins by
+// users, within an organization, are looked at.
+func (s *AnomalyService) Deliver(ctx context.Context, e Event) error {
+	var a models.Activity
+	if err := json.Unmarshal(e.Payload, &a); err != nil {
+		return err
+	}
+	if a.OrganizationID == 0 || a.UserID == 0 || a.Principal == audit.PrincipalSystem ||
+		(a.Outcome != "" && a.Outcome != audit.OutcomeSuccess) || !anomaly.IsAccess(a.Type) {
+		return nil
+	}
+	db := s.DB.WithContext(ctx)
+	findings, err := s.evaluate(db, &a)
+	if err != nil || len(findings) == 0 {
+		return err
+	}
+	return s.raise(db, alertSubject{
+		OrganizationID: a.OrganizationID,
+		UserID:         a.UserID,
+		EventID:        e.ID,
+		IP:             a.IP,
+		At:             a.CreatedAt,
+	}, findings)
+}
+
+// evaluate runs the activity-based rules against the user's history before
+// the activity.
+func (s *AnomalyService) evaluate(db *gorm.DB, a *models.Activity) ([]anomaly.Finding, error) {
+	t := s.Thresholds
+	at := a.CreatedAt
+	since := at.AddDate(0, 0, -t.HistoryDays)
+	var findings []anomaly.Finding
+
+	if anomaly.IsReveal(a.Type) {
+		counts, err := s.Repo.CountWindows(db, a.UserID, anomaly.RevealTypes, at, t.Window, t.BaselineDays)
+		if err != nil {
+			return nil, err
+		}
+		// the activity itself may not be in the log yet
+		if f, ok := t.Volume(counts[0]+1, anomaly.NewBaseline(counts[1:])); ok {
+			findings = append(findings, f)
+		}
+
+		seen, err := s.Repo.Addresses(db, a.UserID, since, at)
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := t.NewAddress(a.IP, seen, s.Geo); ok {
+			findings = append(findings, f)
+		}
+	}
+
+	hist, err := s.Repo.HourHistogram(db, a.UserID, anomaly.AccessTypes, since, at)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := t.UnusualHour(hist, at.UTC().Hour()); ok {
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// CountAccess counts a request towards the user's rate for the action and
+// raises an enumeration alert when it crosses the limit. Counting is best
+// effort: errors are logged, never returned to the request.
+func (s *AnomalyService) CountAccess(ctx context.Context, userID, orgID uint, action string) {
+	t := s.Thresholds
+	now := time.Now()
+	n, err := s.Repo.CountAccess(s.DB, userID, action, now.Truncate(t.ListWindow))
+	if err != nil {
+		fmt.Printf("access counter: %v\n", err)
+		return
+	}
+	f, ok := t.Enumeration(n)
+	if !ok || orgID == 0 {
+		return
+	}
+	subject := alertSubject{OrganizationID: orgID, UserID: userID, At: now}
+	if m := audit.MetaFrom(ctx); m != nil {
+		subject.IP = m.IP
+	}
+	if err := s.raise(s.DB, subject, []anomaly.Finding{f}); err != nil {
+		fmt.Printf("failed to raise %s alert: %v\n", f.Rule, err)
+	}
+}
+
+// alertSubject is the user and event the findings are about. EventID is
+// empty for findings on requests rather than activities.
+type alertSubject struct {
+	OrganizationID uint
+	UserID         uint
+	EventID        string
+	IP             string
+	At             time.Time
+}
+
+// raise records the findings on one event as alerts, folding each into the
+// user's open alert for the rule if there is one, and suspends the user's
+// sessions when the combined score calls for it. New alerts are logged and
+// sent to the organization's admins in the same transaction.
+func (s *AnomalyService) raise(db *gorm.DB, subject alertSubject, findings []anomaly.Finding) error {
+	suspended := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.LockUser(tx, subject.UserID); err != nil {
+			return err
+		}
+		var alerts, created []*models.SecurityAlert
+		var scores []float64
+		for _, f := range findings {
+			if subject.EventID != "" {
+				dup, err := s.Repo.HasEvent(tx, subject.EventID, f.Rule)
+				if err != nil {
+					return err
+				}
+				if dup {
+					continue
+				}
+			}
+			a, isNew, err := s.upsertAlert(tx, subject, f)
+			if err != nil {
+				return err
+			}
+			alerts = append(alerts, a)
+			if isNew {
+				created = append(created, a)
+			}
+			scores = append(scores, f.Score)
+		}
+		if len(alerts) == 0 {
+			return nil
+		}
+
+		members, err := s.Orgs.ListMembers(tx, subject.OrganizationID)
+		if err != nil {
+			return err
+		}
+		var who string
+		var admins []uint
+		subjectAdmin := false
+		for _, m := range members {
+			isAdmin := m.Role == "owner" || m.Role == "admin"
+			if m.UserID == subject.UserID {
+				who = m.Email
+				subjectAdmin = isAdmin
+			} else if isAdmin {
+				admins = append(admins, m.UserID)
+			}
+		}
+		if who == "" {
+			who = fmt.Sprintf("user %d", subject.UserID)
+		}
+		// the user under suspicion only hears of it when nobody else can
+		recipients := admins
+		if len(recipients) == 0 && subjectAdmin {
+			recipients = []uint{subject.UserID}
+		}
+
+		for _, a := range created {
+			if err := s.announce(tx, a, who, recipients); err != nil {
+				return err
+			}
+		}
+
+		score := anomaly.Combine(scores...)
+		if s.Thresholds.SuspendScore <= 0 || score < s.Thresholds.SuspendScore {
+			return nil
+		}
+		// a suspension nobody could lift would lock the organization out
+		if len(admins) == 0 {
+			fmt.Printf("anomaly: not suspending user %d (score %.0f): no other admin in organization %d\n", subject.UserID, score, subject.OrganizationID)
+			return nil
+		}
+		suspended, err = s.suspend(tx, subject, alerts, score, who, admins)
+		return err
+	})
+	if suspended {
+		s.sessions.forget(subject.UserID)
+	}
+	return err
+}
+
+func (s *AnomalyService) upsertAlert(tx *gorm.DB, subject alertSubject, f anomaly.Finding) (*models.SecurityAlert, bool, error) {
+	details, err := json.Marshal(f.Details)
+	if err != nil {
+		return nil, false, err
+	}
+	existing, err := s.Repo.FindOpen(tx, subject.OrganizationID, subject.UserID, f.Rule, subject.At.Add(-alertDedupWindow))
+	if err == nil {
+		existing.Occurrences++
+		existing.LastEventID = subject.EventID
+		if subject.At.After(existing.LastSeenAt) {
+			existing.LastSeenAt = subject.At
+		}
+		if f.Score > existing.Score {
+			existing.Score = f.Score
+			existing.Severity = f.Severity()
+			existing.Summary = truncate(f.Summary, 255)
+			existing.Details = string(details)
+			existing.IP = subject.IP
+		}
+		return existing, false, s.Repo.Update(tx, existing)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	a := &models.SecurityAlert{
+		OrganizationID: subject.OrganizationID,
+		UserID:         subject.UserID,
+		Rule:           f.Rule,
+		Severity:       f.Severity(),
+		Score:          f.Score,
+		Summary:        truncate(f.Summary, 255),
+		Details:        string(details),
+		IP:             subject.IP,
+		EventID:        subject.EventID,
+		LastEventID:    subject.EventID,
+		Occurrences:    1,
+		LastSeenAt:     subject.At,
+		Status:         models.AlertOpen,
+	}
+	return a, true, s.Repo.Create(tx, a)
+}
+
+// announce logs a new alert and notifies the admins. The activity is not
+// attributed to the user under suspicion, so it does not show in their own
+// feed or personal webhooks.
+func (s *AnomalyService) announce(tx *gorm.DB, a *models.SecurityAlert, who string, recipients []uint) error {
+	err := enqueueActivity(tx, &models.Activity{
+		OrganizationID: a.OrganizationID,
+		Type:           "security_alert",
+		Entity:         "security_alert",
+		EntityID:       a.ID,
+		Message:        truncate(fmt.Sprintf("Security alert (%s) for %s: %s", a.Severity, who, a.Summary), 255),
+	})
+	if err != nil {
+		return err
+	}
+	return s.Notifications.NotifyTx(tx, recipients, models.Notification{
+		Type:     "security_alert",
+		Title:    truncate(fmt.Sprintf("Security alert (%s): %s", a.Severity, who), 255),
+		Body:     a.Summary,
+		Entity:   "security_alert",
+		EntityID: a.ID,
+	})
+}
+
+// suspend revokes the user's sessions and blocks sign-in. It reports false
+// when the user was already suspended.
+func (s *AnomalyService) suspend(tx *gorm.DB, subject alertSubject, alerts []*models.SecurityAlert, score float64, who string, admins []uint) (bool, error) {
+	user, err := s.Users.FindByID(tx, subject.UserID)
+	if err != nil {
+		return false, err
+	}
+	if user.SuspendedAt != nil {
+		return false, nil
+	}
+	reason := truncate(alerts[0].Summary, 255)
+	if err := s.Users.Suspend(tx, user.ID, time.Now(), reason); err != nil {
+		return false, err
+	}
+	for _, a := range alerts {
+		a.SessionsSuspended = true
+		if err := s.Repo.Update(tx, a); err != nil {
+			return false, err
+		}
+	}
+	err = enqueueActivity(tx, &models.Activity{
+		OrganizationID: subject.OrganizationID,
+		Type:           "sessions_suspended",
+		Entity:         "user",
+		EntityID:       user.ID,
+		Message:        truncate(fmt.Sprintf("Suspended sessions of %s (score %.0f): %s", who, score, reason), 255),
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, s.Notifications.NotifyTx(tx, admins, models.Notification{
+		Type:     "sessions_suspended",
+		Title:    truncate("Sessions suspended: "+who, 255),
+		Body:     fmt.Sprintf("%s was signed out and cannot sign in until an admin lifts the suspension (score %.0f): %s", who, score, reason),
+		Entity:   "security_alert",
+		EntityID: alerts[0].ID,
+	})
+}
+
+// Start purges old access counters until ctx is done.
+func (s *AnomalyService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(counterPurgeEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.Repo.PurgeCounters(s.DB, time.Now().Add(-counterRetention)); err != nil {
+				fmt.Printf("access counter purge failed: %v\n", err)
+			}
+		}
+	}()
+}
+
+// CheckSession implements middleware.SessionChecker: suspended users and
+// tokens issued before the user's sessions were revoked are refused. The
+// user's state is cached briefly, so a suspension made by another instance
+// takes up to sessionCacheTTL to apply here.
+func (s *AnomalyService) CheckSession(userID uint, issuedAt time.Time) error {
+	st, ok := s.sessions.get(userID)
+	if !ok {
+		user, err := s.Users.FindByID(s.DB, userID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return err
+		default:
+			st.suspended = user.SuspendedAt != nil
+			if user.SessionsRevokedAt != nil {
+				st.revokedAt = *user.SessionsRevokedAt
+			}
+		}
+		s.sessions.put(userID, st)
+	}
+	if st.suspended {
+		return ErrAccountSuspended
+	}
+	// iat has whole seconds; a token issued in the second of the revocation
+	// is let through rather than refusing the sign-in that follows it
+	if !st.revokedAt.IsZero() && issuedAt.Before(st.revokedAt.Truncate(time.Second)) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+type sessionState struct {
+	suspended bool
+	revokedAt time.Time
+	expires   time.Time
+}
+
+type sessionCache struct {
+	mu    sync.Mutex
+	users map[uint]sessionState
+}
+
+func (c *sessionCache) get(userID uint) (sessionState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.users[userID]
+	if !ok || time.Now().After(st.expires) {
+		return sessionState{}, false
+	}
+	return st, true
+}
+
+func (c *sessionCache) put(userID uint, st sessionState) {
+	now := time.Now()
+	st.expires = now.Add(sessionCacheTTL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.users) >= sessionCacheMax {
+		for id, old := range c.users {
+			if now.After(old.expires) {
+				delete(c.users, id)
+			}
+		}
+	}
+	c.users[userID] = st
+}
+
+func (c *sessionCache) forget(userID uint) {
+	c.mu.Lock()
+	delete(c.users, userID)
+	c.mu.Unlock()
+}
+
+type AlertListInput struct {
+	OrganizationID uint
+	UserID         uint // the admin asking
+	Status         string
+	Severity       string
+	Rule           string
+	SubjectID      uint // only alerts about this user
+	Suspended      bool
+	Before         uint
+	Limit          int
+}
+
+// ListAlerts pages through the organization's alerts, newest first. Org
+// owners and admins only.
+func (s *AnomalyService) ListAlerts(in AlertListInput) ([]models.SecurityAlert, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, in.OrganizationID, in.UserID); err != nil {
+		return nil, err
+	}
+	if in.Status != "" && !validAlertStatus(in.Status) {
+		return nil, fmt.Errorf("invalid status %q", in.Status)
+	}
+	switch in.Severity {
+	case "", anomaly.SeverityLow, anomaly.SeverityMedium, anomaly.SeverityHigh, anomaly.SeverityCritical:
+	default:
+		return nil, fmt.Errorf("invalid severity %q", in.Severity)
+	}
+	if in.Limit <= 0 {
+		in.Limit = defaultAlertPageSize
+	}
+	if in.Limit > maxAlertPageSize {
+		in.Limit = maxAlertPageSize
+	}
+	return s.Repo.List(s.DB, repository.AlertQuery{
+		OrganizationID: in.OrganizationID,
+		Status:         in.Status,
+		Severity:       in.Severity,
+		Rule:           in.Rule,
+		UserID:         in.SubjectID,
+		Suspended:      in.Suspended,
+		BeforeID:       in.Before,
+		Limit:          in.Limit,
+	})
+}
+
+func (s *AnomalyService) GetAlert(orgID, userID, id uint) (*models.SecurityAlert, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetByID(s.DB, orgID, id)
+}
+
+type UpdateAlertInput struct {
+	OrganizationID uint
+	UserID         uint
+	ID             uint
+	Status         string  // empty leaves it alone
+	Note           *string // nil leaves it alone
+}
+
+// UpdateAlert triages an alert: acknowledge it, resolve or dismiss it, or
+// reopen it, optionally with a note.
+func (s *AnomalyService) UpdateAlert(in UpdateAlertInput) (*models.SecurityAlert, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, in.OrganizationID, in.UserID); err != nil {
+		return nil, err
+	}
+	if in.Status != "" && !validAlertStatus(in.Status) {
+		return nil, fmt.Errorf("invalid status %q", in.Status)
+	}
+	if in.Note != nil && len(*in.Note) > 1000 {
+		return nil, errors.New("note must be at most 1000 characters")
+	}
+
+	var alert *models.SecurityAlert
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		a, err := s.Repo.GetByID(tx, in.OrganizationID, in.ID)
+		if err != nil {
+			return err
+		}
+		if in.Note != nil {
+			a.Note = *in.Note
+		}
+		if in.Status != "" && in.Status != a.Status {
+			a.Status = in.Status
+			switch in.Status {
+			case models.AlertResolved, models.AlertDismissed:
+				now := time.Now()
+				a.ResolvedBy = &in.UserID
+				a.ResolvedAt = &now
+			default:
+				a.ResolvedBy = nil
+				a.ResolvedAt = nil
+			}
+		}
+		if err := s.Repo.Update(tx, a); err != nil {
+			return err
+		}
+		alert = a
+		return enqueueActivity(tx, &models.Activity{
+			UserID:         in.UserID,
+			OrganizationID: in.OrganizationID,
+			Type:           "security_alert_updated",
+			Entity:         "security_alert",
+			EntityID:       a.ID,
+			Message:        truncate(fmt.Sprintf("Marked security alert #%d (%s) %s", a.ID, a.Rule, a.Status), 255),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// LiftSuspension lets a suspended member of the organization sign in again.
+// Only an organization with an open or acknowledged alert that suspended the
+// user can lift it. Their old sessions stay revoked.
+func (s *AnomalyService) LiftSuspension(orgID, userID, memberID uint) error {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return err
+	}
+	if _, err := s.Orgs.GetMember(s.DB, orgID, memberID); err != nil {
+		return err
+	}
+	user, err := s.Users.FindByID(s.DB, memberID)
+	if err != nil {
+		return err
+	}
+	if user.SuspendedAt == nil {
+		return ErrNotSuspended
+	}
+	ok, err := s.Repo.HasOpenSuspension(s.DB, orgID, user.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotSuspendedHere
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Users.Unsuspend(tx, user.ID); err != nil {
+			return err
+		}
+		return enqueueActivity(tx, &models.Activity{
+			UserID:         userID,
+			OrganizationID: orgID,
+			Type:           "suspension_lifted",
+			Entity:         "user",
+			EntityID:       user.ID,
+			Message:        truncate("Lifted the suspension of "+user.Email, 255),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.sessions.forget(user.ID)
+	return nil
+}
+
+func validAlertStatus(status string) bool {
+	switch status {
+	case models.AlertOpen, models.AlertAcknowledged, models.AlertResolved, models.AlertDismissed:
+		return true
+	}
+	return false
+}
@@ -4,20 +4,30 @@ import (
 	"errors"
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
 	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
 )
 
 type APIKeyTeamService struct {
 	Repo repository.APIKeyTeamRepository
+	TeamRepo repository.TeamRepository
+	MembershipRepo repository.TeamMembershipRepository
+	APIKeyRepo repository.APIKeyRepository
+	DB *gorm.DB
 }
 
-func NewAPIKeyTeamService(repo repository.APIKeyTeamRepository) *APIKeyTeamService {
-	return &APIKeyTeamService{Repo: repo}
+func NewAPIKeyTeamService(repo repository.APIKeyTeamRepository, teamRepo repository.TeamRepository, membershipRepo repository.TeamMembershipRepository, akRepo repository.APIKeyRepository, db *gorm.DB) *APIKeyTeamService {
+	return &APIKeyTeamService{Repo: repo, TeamRepo: teamRepo, MembershipRepo: membershipRepo, APIKeyRepo: akRepo, DB: db}
 }
 
-func (s *APIKeyTeamService) Attach(teamID, apiKeyID uint) error {
+// Attach shares a key with a team. The user must be able to manage the key
+// and the team, so nobody can share someone else's key into their own team.
+func (s *APIKeyTeamService) Attach(orgID, userID, teamID, apiKeyID uint) error {
 	if teamID == 0 || apiKeyID == 0 {
 		return errors.New("team_id and api_key_id are required")
 	}
+	if err := s.requireManage(orgID, userID, teamID, apiKeyID); err != nil {
+		return err
+	}
 	at := &models.APIKeyTeam{
 		TeamID:   teamID,
 		APIKeyID: apiKeyID,
@@ -25,14 +35,49 @@ func (s *APIKeyTeamService) Attach(teamID, apiKeyID uint) error {
 	return s.Repo.Attach(at)
 }
 
-func (s *APIKeyTeamService) ListByTeam(teamID uint) ([]models.APIKeyTeam, error) {
+func (s *APIKeyTeamService) ListByTeam(orgID, teamID uint) ([]models.APIKeyTeam, error) {
+	if _, err := s.TeamRepo.GetByID(s.DB, orgID, teamID); err != nil {
+		return nil, err
+	}
 	return s.Repo.ListByTeam(teamID)
 }
 
-func (s *APIKeyTeamService) ListByAPIKey(apiKeyID uint) ([]models.APIKeyTeam, error) {
+func (s *APIKeyTeamService) ListByAPIKey(orgID, apiKeyID uint) ([]models.APIKeyTeam, error) {
+	if _, err := s.APIKeyRepo.FindByID(s.DB, orgID, apiKeyID); err != nil {
+		return nil, err
+	}
 	return s.Repo.ListByAPIKey(apiKeyID)
 }
 
-func (s *APIKeyTeamService) Detach(teamID, apiKeyID uint) error {
+// Detach stops sharing a key with a team, under the same rules as Attach.
+func (s *APIKeyTeamService) Detach(orgID, userID, teamID, apiKeyID uint) error {
+	if err := s.requireManage(orgID, userID, teamID, apiKeyID); err != nil {
+		return err
+	}
 	return s.Repo.Detach(teamID, apiKeyID)
 }
+
+// requireManage checks that both sides live in the organization and that the
+// user manages the key and owns or administers the team.
+func (s *APIKeyTeamService) requireManage(orgID, userID, teamID, apiKeyID uint) error {
+	if _, err := s.TeamRepo.GetByID(s.DB, orgID, teamID); err != nil {
+		return err
+	}
+	if _, err := s.APIKeyRepo.FindByID(s.DB, orgID, apiKeyID); err != nil {
+		return err
+	}
+	ok, err := s.APIKeyRepo.CanManage(s.DB, orgID, userID, apiKeyID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCannotManageKey
+	}
+	if ok, err = isTeamManager(s.TeamRepo, s.MembershipRepo, s.DB, orgID, teamID, userID); err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotTeamManager
+	}
+	return nil
+}
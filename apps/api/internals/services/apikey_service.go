@@ -1,8 +1,13 @@
 package services
 
 import (
+    "context"
     "errors"
     "fmt"
+    "strings"
+    "time"
+
+    "github.com/intojhanurag/One-Password/apps/api/internals/audit"
     "github.com/intojhanurag/One-Password/apps/api/internals/models"
     "github.com/intojhanurag/One-Password/apps/api/internals/repository"
     "github.com/intojhanurag/One-Password/apps/api/internals/utils"
@@ -11,35 +16,108 @@ import (
 
 type APIKeyService struct {
     Repo repository.APIKeyRepository
+    AccessRepo repository.AccessRequestRepository
+    Tags *TagService
+    Leases *RevealLeaseService
+    Versions repository.SecretVersionRepository
     DB   *gorm.DB
     MasterKey []byte
 }
 
 type CreateAPIKeyInput struct {
     Name string
+    Path string // optional folder path including the name, e.g. "payments/stripe/live"
     Key  string 
     Description string
     Tags string
+    RequiresApproval bool
+    ExpiresAt *time.Time
+    RotateEveryDays int
+    OrganizationID uint
     OwnerID uint
 }
 
 type CreateAPIKeyResult struct {
     ID uint
     Name string
+    Path string
     Description string
     Tags string
     CreatedAt string
     PlaintextKey string 
 }
 
-func NewAPIKeyService(repo repository.APIKeyRepository, db *gorm.DB, masterKey []byte) *APIKeyService {
-    return &APIKeyService{Repo: repo, DB: db, MasterKey: masterKey}
+const (
+    defaultKeyPageSize = 50
+    maxKeyPageSize = 200
+)
+
+type KeySearchInput struct {
+    OrganizationID uint
+    UserID uint
+    TeamID uint
+    Query string // full-text over name, path, description and tags
+    Tags []string // every tag must match
+    TagExpr string // e.g. "env:prod AND vendor:stripe"
+    CreatedAfter *time.Time
+    CreatedBefore *time.Time
+    ExpiringWithin time.Duration
+    Sort string // "created_at", "-name", "relevance", ...
+    Limit int
+    Cursor string
+}
+
+type KeySearchResult struct {
+    Items []models.APIKey
+    Total int64
+    NextCursor string
+}
+
+// RevealResult carries either the plaintext of a revealed key or, when the key
+// requires approval, the pending AccessRequest the reveal attempt was filed under.
+type RevealResult struct {
+    Plaintext string
+    Lease *models.RevealLease
+    PendingRequest *models.AccessRequest
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, accessRepo repository.AccessRequestRepository, tags *TagService, leases *RevealLeaseService, versions repository.SecretVersionRepository, db *gorm.DB, masterKey []byte) *APIKeyService {
+    return &APIKeyService{Repo: repo, AccessRepo: accessRepo, Tags: tags, Leases: leases, Versions: versions, DB: db, MasterKey: masterKey}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *APIKeyService) WithContext(ctx context.Context) *APIKeyService {
+    c := *s
+    c.DB = requestDB(s.DB, ctx)
+    c.Tags = s.Tags.WithContext(ctx)
+    c.Leases = s.Leases.WithContext(ctx)
+    return &c
 }
 
 func (s *APIKeyService) Create(in CreateAPIKeyInput) (*CreateAPIKeyResult, error) {
-    if in.Name == "" || in.Key == "" {
+    if in.Path == "" {
+        in.Path = in.Name
+    }
+    path, err := utils.NormalizePath(in.Path)
+    if err != nil {
+        return nil, err
+    }
+    if path == "" || in.Key == "" {
         return nil, errors.New("name and key required")
     }
+    if in.RotateEveryDays < 0 {
+        return nil, errors.New("rotate_every must not be negative")
+    }
+    tags, err := utils.SplitTagList(in.Tags)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := s.Repo.FindByPath(s.DB, in.OrganizationID, path); err == nil {
+        return nil, ErrPathTaken
+    } else if !errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, err
+    }
 
     ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, in.Key)
     if err != nil {
@@ -47,32 +125,46 @@ func (s *APIKeyService) Create(in CreateAPIKeyInput) (*CreateAPIKeyResult, error
     }
 
     k := &models.APIKey{
-        Name: in.Name,
+        Name: utils.PathBase(path),
+        Path: path,
+        OrganizationID: in.OrganizationID,
         OwnerID: in.OwnerID,
         Ciphertext: ct,
         Nonce: nonce,
+        Fingerprint: utils.Fingerprint(s.MasterKey, in.Key),
         Description: in.Description,
-        Tags: in.Tags,
+        Tags: strings.Join(tags, ","),
+        RequiresApproval: in.RequiresApproval,
+        ExpiresAt: in.ExpiresAt,
+        RotateEveryDays: in.RotateEveryDays,
     }
 
-    if err := s.Repo.Create(s.DB, k); err != nil {
+    err = s.DB.Transaction(func(tx *gorm.DB) error {
+        if err := s.Repo.Create(tx, k); err != nil {
+            if errors.Is(err, gorm.ErrDuplicatedKey) {
+                return ErrPathTaken
+            }
+            return err
+        }
+        if _, err := s.Tags.ApplyTags(tx, in.OrganizationID, k.ID, tags); err != nil {
+            return err
+        }
+        return enqueueActivity(tx, &models.Activity{
+            UserID: in.OwnerID,
+            Type: "apikey_created",
+            Entity: "apikey",
+            EntityID: k.ID,
+            Message: "API key created: " + k.Name,
+        })
+    })
+    if err != nil {
         return nil, err
     }
 
-    activity:= &models.Activity{
-        UserID: in.OwnerID,
-        Type: "apikey_created",
-        Entity: "apikey",
-        EntityID: k.ID,
-        Message: "API key created: " + k.Name,
-    }
-
-    s.DB.Create(activity)
-
-
     return &CreateAPIKeyResult{
         ID: k.ID,
         Name: k.Name,
+        Path: k.Path,
         Description: k.Description,
         Tags: k.Tags,
         PlaintextKey: in.Key, 
@@ -80,61 +172,376 @@ func (s *APIKeyService) Create(in CreateAPIKeyInput) (*CreateAPIKeyResult, error
 }
 
 
-func (s *APIKeyService) List(ownerID uint) ([]models.APIKey, error) {
-    return s.Repo.ListByOwner(s.DB, ownerID)
-}
+// Search lists the user's keys (or a team's) matching the input, one page at a time.
+func (s *APIKeyService) Search(in KeySearchInput) (*KeySearchResult, error) {
+    q := repository.KeySearchQuery{
+        OrgID: in.OrganizationID,
+        UserID: in.UserID,
+        TeamID: in.TeamID,
+        Text: strings.TrimSpace(in.Query),
+        CreatedAfter: in.CreatedAfter,
+        CreatedBefore: in.CreatedBefore,
+        Limit: in.Limit,
+    }
+
+    exprs := append([]string{}, in.Tags...)
+    if in.TagExpr != "" {
+        exprs = append(exprs, "("+in.TagExpr+")")
+    }
+    if len(exprs) > 0 {
+        expr, err := utils.ParseTagExpr(strings.Join(exprs, " AND "))
+        if err != nil {
+            return nil, err
+        }
+        q.Tags = expr
+    }
+
+    if in.ExpiringWithin > 0 {
+        before := time.Now().Add(in.ExpiringWithin)
+        q.ExpiringBefore = &before
+    }
+
+    q.Sort, q.Desc = strings.TrimPrefix(in.Sort, "-"), strings.HasPrefix(in.Sort, "-")
+    if q.Sort == "" {
+        q.Sort = "created_at"
+    }
+    if q.Sort == "relevance" {
+        if q.Text == "" {
+            return nil, errors.New("sort=relevance requires a search query")
+        }
+    } else if _, ok := repository.KeySortFields[q.Sort]; !ok {
+        return nil, fmt.Errorf("cannot sort by %q", q.Sort)
+    }
 
-func (s *APIKeyService) GetByName(ownerID uint, name string) (string, error) {
-    key, err := s.Repo.FindByOwnerAndName(s.DB, ownerID, name) 
+    if q.Limit <= 0 {
+        q.Limit = defaultKeyPageSize
+    }
+    if q.Limit > maxKeyPageSize {
+        q.Limit = maxKeyPageSize
+    }
+
+    if in.Cursor != "" {
+        var after repository.KeyCursor
+        if err := utils.DecodeCursor(in.Cursor, &after); err != nil {
+            return nil, err
+        }
+        if after.Sort != q.Sort || after.Desc != q.Desc {
+            return nil, utils.ErrInvalidCursor
+        }
+        q.After = &after
+    }
+
+    keys, total, next, err := s.Repo.Search(s.DB, q)
     if err != nil {
-        return "", err
+        return nil, err
     }
 
-    plaintext, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+    res := &KeySearchResult{Items: keys, Total: total}
+    if next != nil {
+        if res.NextCursor, err = utils.EncodeCursor(next); err != nil {
+            return nil, err
+        }
+    }
+    return res, nil
+}
+
+// GetByPath reveals one of the user's own keys by its full path, e.g.
+// "payments/stripe/live"; a top-level key's path is its name.
+func (s *APIKeyService) GetByPath(orgID, ownerID uint, path string) (*RevealResult, error) {
+    path, err := utils.NormalizePath(path)
     if err != nil {
-        return "", err
+        return nil, err
     }
+    key, err := s.Repo.FindByOwnerAndPath(s.DB, orgID, ownerID, path)
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            logDenied(s.DB, ownerID, "apikey", 0, "not_found", "Reveal denied, no API key at: "+path)
+        }
+        return nil, err
+    }
+    return s.revealLeased(orgID, ownerID, key, LeaseOptions{Renewable: true})
+}
 
-    // Log activity
-    activity := &models.Activity{
-        UserID:   ownerID,
-        Type:     "apikey_revealed",
-        Entity:   "apikey",
-        EntityID: key.ID,
-        Message:  "API key revealed: " + name,
+// DeleteByPath deletes one of the user's own keys by its full path.
+func (s *APIKeyService) DeleteByPath(orgID, ownerID uint, path string) error {
+    path, err := utils.NormalizePath(path)
+    if err != nil {
+        return err
     }
+    return s.DB.Transaction(func(tx *gorm.DB) error {
+        if err := s.Repo.Delete(tx, orgID, ownerID, path); err != nil {
+            return err
+        }
+        return enqueueActivity(tx, &models.Activity{
+            UserID: ownerID,
+            Type: "apikey_deleted",
+            Entity: "apikey",
+            Message: "API key deleted: " + path,
+        })
+    })
+}
 
-    if err := s.DB.Create(activity).Error; err != nil {
-        fmt.Printf("failed to log activity: %v\n", err)
+func (s *APIKeyService) GetDecrypted(orgID, ownerID, id uint) (string, error) {
+    rec, err := s.Repo.GetByID(s.DB, orgID, ownerID, id)
+    if err != nil {
+        return "", err
     }
+    return utils.DecryptAPIKey(s.MasterKey, rec.Ciphertext, rec.Nonce)
+}
 
-    return plaintext, nil
+// SetRequiresApproval switches approvals for one of the user's own keys,
+// addressed by its full path.
+func (s *APIKeyService) SetRequiresApproval(orgID, ownerID uint, path string, required bool) error {
+    path, err := utils.NormalizePath(path)
+    if err != nil {
+        return err
+    }
+    return s.DB.Transaction(func(tx *gorm.DB) error {
+        if err := s.Repo.SetRequiresApproval(tx, orgID, ownerID, path, required); err != nil {
+            return err
+        }
+        return logFlagChange(tx, ownerID, path, "approval", "Approval requirement", required)
+    })
 }
 
-func (s *APIKeyService) DeleteByName(ownerID uint, name string) error {
-    err:= s.Repo.Delete(s.DB, ownerID, name)
+// SetBreakGlassEnabled switches break-glass access for one of the user's own
+// keys, addressed by its full path.
+func (s *APIKeyService) SetBreakGlassEnabled(orgID, ownerID uint, path string, enabled bool) error {
+    path, err := utils.NormalizePath(path)
+    if err != nil {
+        return err
+    }
+    return s.DB.Transaction(func(tx *gorm.DB) error {
+        if err := s.Repo.SetBreakGlassEnabled(tx, orgID, ownerID, path, enabled); err != nil {
+            return err
+        }
+        return logFlagChange(tx, ownerID, path, "break_glass", "Break-glass access", enabled)
+    })
+}
 
-    if err!=nil {
+// SetLifecycle sets when a key expires and how often it should be rotated
+// (0 days clears the schedule). Owners and team owners/admins can change it.
+func (s *APIKeyService) SetLifecycle(orgID, userID, id uint, expiresAt *time.Time, rotateEveryDays int) error {
+    if rotateEveryDays < 0 {
+        return errors.New("rotate_every must not be negative")
+    }
+    ok, err := s.Repo.CanManage(s.DB, orgID, userID, id)
+    if err != nil {
         return err
     }
+    if !ok {
+        return gorm.ErrRecordNotFound
+    }
+
+    msg := fmt.Sprintf("Rotation every %d days", rotateEveryDays)
+    if expiresAt != nil {
+        msg += ", expires " + expiresAt.Format("2006-01-02")
+    }
+    return s.DB.Transaction(func(tx *gorm.DB) error {
+        if err := s.Repo.SetLifecycle(tx, id, expiresAt, rotateEveryDays); err != nil {
+            return err
+        }
+        return enqueueActivity(tx, &models.Activity{
+            UserID: userID,
+            Type: "apikey_lifecycle_updated",
+            Entity: "apikey",
+            EntityID: id,
+            Message: msg,
+        })
+    })
+}
 
+func logFlagChange(tx *gorm.DB, ownerID uint, name, flag, label string, on bool) error {
+    state := "disabled"
+    if on {
+        state = "enabled"
+    }
     activity := &models.Activity{
         UserID: ownerID,
-        Type: "apikey_deleted",
+        Type: "apikey_" + flag + "_" + state,
         Entity: "apikey",
-        Message: "API key deleted" + name,
-    }  
-    if err := s.DB.Create(activity).Error; err != nil {
-        fmt.Printf("failed to log activity: %v\n", err)
+        Message: label + " " + state + ": " + name,
     }
-    return nil
+    return enqueueActivity(tx, activity)
 }
 
-func (s *APIKeyService) GetDecrypted(ownerID, id uint) (string, error) {
-    rec, err := s.Repo.GetByID(s.DB, ownerID, id)
+// RevealByID reveals a key the user owns or that is shared with one of their teams.
+// If the key requires approval and the user is not its owner, the reveal only
+// succeeds under an approved AccessRequest; otherwise a pending request is filed
+// (or the existing one returned) and no plaintext is released.
+func (s *APIKeyService) RevealByID(orgID, userID, id uint, reason string) (*RevealResult, error) {
+    key, err := s.Repo.FindAccessibleByID(s.DB, orgID, userID, id)
     if err != nil {
-        return "", err
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            logDenied(s.DB, userID, "apikey", id, "no_access", fmt.Sprintf("Reveal denied for API key #%d: not found or not shared with the user", id))
+        }
+        return nil, err
     }
-    return utils.DecryptAPIKey(s.MasterKey, rec.Ciphertext, rec.Nonce)
+    return s.revealAccessible(orgID, userID, key, reason)
+}
+
+// revealAccessible reveals a key the user has already been found to have access
+// to, holding it behind an approved AccessRequest when it requires approval.
+func (s *APIKeyService) revealAccessible(orgID, userID uint, key *models.APIKey, reason string) (*RevealResult, error) {
+    if key.OwnerID == userID || !key.RequiresApproval {
+        return s.revealLeased(orgID, userID, key, LeaseOptions{Renewable: true})
+    }
+
+    grant, err := s.AccessRepo.FindActiveGrant(s.DB, key.ID, userID, time.Now())
+    if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, err
+    }
+    if grant != nil {
+        if grant.GrantType == "once" {
+            ok, err := s.AccessRepo.Consume(s.DB, grant.ID, time.Now())
+            if err != nil {
+                return nil, err
+            }
+            if !ok {
+                logDenied(s.DB, userID, "apikey", key.ID, "grant_used", fmt.Sprintf("Reveal denied, access request #%d was already used: %s", grant.ID, key.Name))
+                return nil, ErrGrantUsed
+            }
+        }
+
+        // a one-time grant can't be stretched by renewing its lease, and a
+        // window grant's lease ends with the window
+        res, err := s.revealLeased(orgID, userID, key, LeaseOptions{
+            Renewable: grant.GrantType != "once",
+            NotAfter: grant.ExpiresAt,
+        })
+        if err != nil {
+            return nil, err
+        }
+
+        activity := &models.Activity{
+            UserID: userID,
+            Type: "access_request_used",
+            Entity: "access_request",
+            EntityID: grant.ID,
+            Message: fmt.Sprintf("Access request #%d used to reveal API key: %s", grant.ID, key.Name),
+        }
+        recordActivity(s.DB, activity)
+        return res, nil
+    }
+
+    pending, err := s.AccessRepo.FindPending(s.DB, key.ID, userID)
+    if err == nil {
+        logDenied(s.DB, userID, "apikey", key.ID, "approval_pending", fmt.Sprintf("Reveal denied, access request #%d still pending: %s", pending.ID, key.Name))
+        return &RevealResult{PendingRequest: pending}, nil
+    }
+    if !errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, err
+    }
+
+    req := &models.AccessRequest{
+        APIKeyID: key.ID,
+        RequesterID: userID,
+        Reason: reason,
+        Status: "pending",
+    }
+    err = s.DB.Transaction(func(tx *gorm.DB) error {
+        if err := s.AccessRepo.Create(tx, req); err != nil {
+            return err
+        }
+        return enqueueActivity(tx, &models.Activity{
+            UserID: userID,
+            Type: "access_requested",
+            Entity: "access_request",
+            EntityID: req.ID,
+            Message: fmt.Sprintf("Access request #%d filed for API key: %s", req.ID, key.Name),
+            // the reveal itself was refused until the request is approved
+            Outcome: audit.OutcomeDenied,
+            Reason: "approval_required",
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &RevealResult{PendingRequest: req}, nil
+}
+
+// updateValue replaces the key's value inside tx. The new value becomes the
+// current secret version and the replaced one the previous, so it can still be
+// rolled back, and leases on the old value are invalidated.
+func (s *APIKeyService) updateValue(tx *gorm.DB, key *models.APIKey, value string, actorID uint) error {
+    old, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+    if err != nil {
+        return err
+    }
+    cur, err := currentSecretVersion(tx, s.Versions, s.MasterKey, key, old)
+    if err != nil {
+        return err
+    }
+    ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, value)
+    if err != nil {
+        return err
+    }
+    latest, err := s.Versions.LatestNumber(tx, key.ID)
+    if err != nil {
+        return err
+    }
+
+    now := time.Now()
+    if err := s.Versions.RetireState(tx, key.ID, models.VersionPrevious); err != nil {
+        return err
+    }
+    cur.State = models.VersionPrevious
+    if err := s.Versions.Update(tx, cur); err != nil {
+        return err
+    }
+    next := &models.SecretVersion{
+        APIKeyID: key.ID,
+        Version: latest + 1,
+        State: models.VersionCurrent,
+        Ciphertext: ct,
+        Nonce: nonce,
+        Fingerprint: utils.Fingerprint(s.MasterKey, value),
+        CreatedBy: &actorID,
+        PromotedAt: &now,
+    }
+    if err := s.Versions.Create(tx, next); err != nil {
+        return err
+    }
+
+    key.Ciphertext = ct
+    key.Nonce = nonce
+    key.Fingerprint = next.Fingerprint
+    key.LastRotatedAt = &now
+    key.LastReminderAt = nil
+    if err := s.Repo.Update(tx, key); err != nil {
+        return err
+    }
+    // clients holding the old value must refetch
+    _, err = s.Leases.Invalidate(tx, key.ID, "updated")
+    return err
 }
 
+// revealLeased decrypts the key, logs the reveal and attaches a lease.
+func (s *APIKeyService) revealLeased(orgID, userID uint, key *models.APIKey, opts LeaseOptions) (*RevealResult, error) {
+    plaintext, err := s.reveal(userID, key)
+    if err != nil {
+        return nil, err
+    }
+    lease, err := s.Leases.Issue(orgID, userID, key, plaintext, opts)
+    if err != nil {
+        return nil, err
+    }
+    return &RevealResult{Plaintext: plaintext, Lease: lease}, nil
+}
+
+func (s *APIKeyService) reveal(userID uint, key *models.APIKey) (string, error) {
+    plaintext, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+    if err != nil {
+        return "", err
+    }
+
+    activity := &models.Activity{
+        UserID:   userID,
+        Type:     "apikey_revealed",
+        Entity:   "apikey",
+        EntityID: key.ID,
+        Message:  "API key revealed: " + key.Name,
+    }
+    recordActivity(s.DB, activity)
+    return plaintext, nil
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/siem"
+	"gorm.io/gorm"
+)
+
+const (
+	auditVerifyBatch = 1000
+	auditExportBatch = 1000
+)
+
+// AuditService keeps the activity log tamper-evident. Activities chain
+// themselves as they are inserted (see models.Activity); this service seals
+// rows from before the chain existed, signs the chain head every Interval
+// and verifies the whole log on request.
+type AuditService struct {
+	Repo       repository.AuditRepository
+	Orgs       repository.OrganizationRepository
+	DB         *gorm.DB
+	SigningKey ed25519.PrivateKey
+	Interval   time.Duration
+	Sinks      []*siem.Forwarder // external sinks activities are streamed to
+}
+
+func NewAuditService(repo repository.AuditRepository, orgs repository.OrganizationRepository, db *gorm.DB, signingKey ed25519.PrivateKey, interval time.Duration) *AuditService {
+	return &AuditService{Repo: repo, Orgs: orgs, DB: db, SigningKey: signingKey, Interval: interval}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *AuditService) WithContext(ctx context.Context) *AuditService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+// AuditPublicKey is what auditors need to check checkpoints themselves.
+type AuditPublicKey struct {
+	KeyID     string `json:"key_id"`
+	PublicKey []byte `json:"public_key"` // base64 in JSON
+	Algorithm string `json:"algorithm"`
+}
+
+func (s *AuditService) PublicKey() AuditPublicKey {
+	pub := s.SigningKey.Public().(ed25519.PublicKey)
+	return AuditPublicKey{KeyID: audit.KeyID(pub), PublicKey: pub, Algorithm: "Ed25519"}
+}
+
+// SinkHealth reports on the external audit sinks, for org owners and admins.
+func (s *AuditService) SinkHealth(orgID, userID uint) ([]siem.Health, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	out := []siem.Health{}
+	for _, f := range s.Sinks {
+		out = append(out, f.Health())
+	}
+	return out, nil
+}
+
+// SealLegacy appends activities that are not yet in the chain, in id order.
+// It runs at startup so rows logged before the chain existed are covered too.
+func (s *AuditService) SealLegacy() (int, error) {
+	sealed := 0
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.LockAuditChain(tx); err != nil {
+			return err
+		}
+		seq, hash, err := s.Repo.Head(tx)
+		if err != nil {
+			return err
+		}
+		rows, err := s.Repo.ListUnsealed(tx)
+		if err != nil {
+			return err
+		}
+		for i := range rows {
+			a := &rows[i]
+			seq++
+			a.Seq = seq
+			a.PrevHash = hash
+			a.Hash = audit.Hash(a.PrevHash, a.AuditEntry())
+			if err := s.Repo.Seal(tx, a); err != nil {
+				return err
+			}
+			hash = a.Hash
+			sealed++
+		}
+		return nil
+	})
+	return sealed, err
+}
+
+// Start signs a checkpoint now and then every Interval until ctx is done.
+func (s *AuditService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Checkpoint(time.Now()); err != nil {
+				fmt.Printf("audit checkpoint failed: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Checkpoint signs the current chain head unless it is already signed. It
+// returns nil for an empty log.
+func (s *AuditService) Checkpoint(now time.Time) (*models.AuditCheckpoint, error) {
+	seq, hash, err := s.Repo.Head(s.DB)
+	if err != nil || seq == 0 {
+		return nil, err
+	}
+	last, err := s.Repo.LastCheckpoint(s.DB)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if last != nil && last.Seq >= seq {
+		return last, nil
+	}
+
+	c := audit.Checkpoint{Seq: seq, Hash: hash, SignedAt: now.UTC().Truncate(time.Microsecond)}
+	audit.Sign(s.SigningKey, &c)
+	cp := &models.AuditCheckpoint{Seq: c.Seq, Hash: c.Hash, SignedAt: c.SignedAt, KeyID: c.KeyID, Signature: c.Signature}
+	if err := s.Repo.CreateCheckpoint(s.DB, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// Checkpoints lists every signed checkpoint, for verifying an export offline.
+func (s *AuditService) Checkpoints() ([]models.AuditCheckpoint, error) {
+	return s.Repo.ListCheckpoints(s.DB)
+}
+
+// Verify checks the whole log for an org owner or admin and records that it did.
+func (s *AuditService) Verify(orgID, userID uint) (*audit.Report, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	report, err := s.VerifyChain()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("Audit log verified: %d entries intact, signed through #%d", report.Entries, report.VerifiedThrough)
+	if !report.OK {
+		msg = fmt.Sprintf("Audit log verification found %d problems in %d entries", report.ProblemCount, report.Entries)
+	}
+	activity := &models.Activity{
+		UserID:  userID,
+		Type:    "audit_verified",
+		Entity:  "audit",
+		Message: msg,
+	}
+	recordActivity(s.DB, activity)
+	return report, nil
+}
+
+// VerifyChain checks every activity against the chain and the signed checkpoints.
+func (s *AuditService) VerifyChain() (*audit.Report, error) {
+	return VerifyAuditLog(s.DB, s.Repo, s.SigningKey.Public().(ed25519.PublicKey))
+}
+
+// VerifyAuditLog checks the log in db against checkpoints signed by pub. It
+// needs only read access, so the offline verifier shares it.
+func VerifyAuditLog(db *gorm.DB, repo repository.AuditRepository, pub ed25519.PublicKey) (*audit.Report, error) {
+	cps, err := repo.ListCheckpoints(db)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]audit.Checkpoint, len(cps))
+	for i := range cps {
+		checkpoints[i] = cps[i].Checkpoint()
+	}
+	v := audit.NewVerifier(pub, checkpoints)
+
+	unsealed, err := repo.ListUnsealed(db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range unsealed {
+		v.Add(unsealed[i].AuditEntry())
+	}
+	err = repo.EachSealed(db, auditVerifyBatch, func(page []models.Activity) error {
+		for i := range page {
+			v.Add(page[i].AuditEntry())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report := v.Finish()
+	return &report, nil
+}
+
+// AuditExportInput selects what to export. The organization is always the
+// caller's.
+type AuditExportInput struct {
+	Format string // audit.FormatCSV, FormatJSONL or FormatCEF
+	Filter audit.ExportFilter
+	Bundle bool // zip with a signed manifest instead of the bare file
+}
+
+// AuditExport is an export ready to stream. Everything that can be checked
+// up front has been, so the response headers can be sent before Stream.
+type AuditExport struct {
+	Filename    string
+	ContentType string
+
+	svc     *AuditService
+	userID  uint
+	options AuditExportOptions
+}
+
+// Export checks the caller may export the org's audit log and fixes the
+// point of the chain the export runs to.
+func (s *AuditService) Export(orgID, userID uint, in AuditExportInput) (*AuditExport, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	if err := audit.CheckFormat(in.Format); err != nil {
+		return nil, err
+	}
+	f := in.Filter
+	f.OrganizationID = orgID
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return nil, ErrInvalidActivityRange
+	}
+
+	now := time.Now().UTC()
+	exp := &AuditExport{
+		Filename:    fmt.Sprintf("audit-org%d-%s.%s", orgID, now.Format("20060102T150405Z"), in.Format),
+		ContentType: audit.ContentType(in.Format),
+		svc:         s,
+		userID:      userID,
+		options:     AuditExportOptions{Format: in.Format, Filter: f, Now: now},
+	}
+	if in.Bundle {
+		exp.Filename = fmt.Sprintf("audit-org%d-%s.zip", orgID, now.Format("20060102T150405Z"))
+		exp.ContentType = "application/zip"
+		exp.options.SigningKey = s.SigningKey
+	}
+	return exp, nil
+}
+
+// Stream writes the export to w and records that it was taken. An error
+// part-way leaves w truncated; a bundle is then missing its manifest and
+// fails verification.
+func (e *AuditExport) Stream(w io.Writer) error {
+	s := e.svc
+	m, err := ExportAuditLog(s.DB, s.Repo, w, e.options)
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Audit log exported as %s: %d entries", e.options.Format, m.Entries)
+	if e.options.SigningKey != nil {
+		msg += " in a signed bundle"
+	}
+	activity := &models.Activity{
+		UserID:         e.userID,
+		Type:           "audit_exported",
+		Entity:         "audit",
+		Message:        truncate(msg, 255),
+		OrganizationID: e.options.Filter.OrganizationID,
+	}
+	recordActivity(s.DB, activity)
+	return nil
+}
+
+// AuditExportOptions configure ExportAuditLog.
+type AuditExportOptions struct {
+	Format     string
+	Filter     audit.ExportFilter
+	SigningKey ed25519.PrivateKey // when set, write a signed bundle
+	Now        time.Time
+}
+
+// ExportAuditLog streams the sealed activities matching the filter to w, up
+// to the chain head as it was when the export started, a batch at a time. It
+// is shared with the audit-export command and returns the export's manifest;
+// without a signing key only its counts are filled in.
+func ExportAuditLog(db *gorm.DB, repo repository.AuditRepository, w io.Writer, o AuditExportOptions) (*audit.Manifest, error) {
+	if err := audit.CheckFormat(o.Format); err != nil {
+		return nil, err
+	}
+	headSeq, headHash, err := repo.Head(db)
+	if err != nil {
+		return nil, err
+	}
+	m := &audit.Manifest{
+		CreatedAt: o.Now.UTC().Truncate(time.Microsecond),
+		Format:    o.Format,
+		Filter:    o.Filter,
+		Complete:  o.Filter.IsZero(),
+		HeadSeq:   headSeq,
+		HeadHash:  headHash,
+	}
+
+	var bundle *audit.Bundle
+	out := w
+	if o.SigningKey != nil {
+		bundle = audit.NewBundle(w, o.SigningKey, *m)
+		m = &bundle.Manifest
+		if out, err = bundle.Create("activities." + o.Format); err != nil {
+			return nil, err
+		}
+	}
+	ew, err := audit.NewExportWriter(out, o.Format)
+	if err != nil {
+		return nil, err
+	}
+	err = repo.EachForExport(db, o.Filter, headSeq, auditExportBatch, func(page []models.Activity) error {
+		for i := range page {
+			if err := ew.Write(page[i].AuditEntry()); err != nil {
+				return err
+			}
+			if m.Entries == 0 {
+				m.FirstSeq = page[i].Seq
+			}
+			m.LastSeq = page[i].Seq
+			m.Entries++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ew.Close(); err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		return m, nil
+	}
+
+	// the checkpoints covering the export, for verifying the chain offline
+	cps, err := repo.ListCheckpoints(db)
+	if err != nil {
+		return nil, err
+	}
+	signed := []audit.Checkpoint{}
+	for i := range cps {
+		if cps[i].Seq <= headSeq {
+			signed = append(signed, cps[i].Checkpoint())
+		}
+	}
+	cw, err := bundle.Create("checkpoints.json")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(cw).Encode(signed); err != nil {
+		return nil, err
+	}
+	if err := bundle.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
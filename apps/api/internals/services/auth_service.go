@@ -1,9 +1,11 @@
 package services
 
 import (
+	"context"
 	"errors"
 
 	"gorm.io/gorm"
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
 	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
 	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
@@ -13,12 +15,15 @@ type AuthService struct {
 	Repo      repository.UserRepository
 	DB        *gorm.DB
 	BCryptCost int
+	Invitations *TeamInvitationService
+	Orgs        *OrganizationService
 }
 
 type SignupInput struct {
 	FullName string
 	Email    string
 	Password string
+	InviteToken string // optional; the new user joins the inviting team
 }
 
 type SignupResult struct {
@@ -37,8 +42,18 @@ type SigninResult struct {
 }
 
 
-func NewAuthService(repo repository.UserRepository, db *gorm.DB, cost int) *AuthService {
-	return &AuthService{Repo: repo, DB: db, BCryptCost: cost}
+func NewAuthService(repo repository.UserRepository, db *gorm.DB, cost int, invitations *TeamInvitationService, orgs *OrganizationService) *AuthService {
+	return &AuthService{Repo: repo, DB: db, BCryptCost: cost, Invitations: invitations, Orgs: orgs}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *AuthService) WithContext(ctx context.Context) *AuthService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	c.Invitations = s.Invitations.WithContext(ctx)
+	c.Orgs = s.Orgs.WithContext(ctx)
+	return &c
 }
 
 func (s *AuthService) Signup(secret string, jwtExpMin int, in SignupInput) (*SignupResult, error) {
@@ -53,13 +68,38 @@ func (s *AuthService) Signup(secret string, jwtExpMin int, in SignupInput) (*Sig
 		return nil, err
 	}
 
+	// invited users meet the inviting organization's policy, everyone else
+	// that of the personal organization they are about to get
+	if in.InviteToken != "" {
+		if err := s.Invitations.CheckSignupToken(in.InviteToken, in.Email, in.Password); err != nil {
+			return nil, err
+		}
+	} else if err := defaultPasswordPolicy.Validate(in.Password); err != nil {
+		return nil, err
+	}
+
 	hash, err := utils.HashPassword(in.Password, s.BCryptCost)
 	if err != nil {
 		return nil, err
 	}
 
+	// every user starts with a personal organization, and joins the team
+	// they were invited to
 	user := &models.User{FullName: in.FullName, Email: in.Email, PasswordHash: hash}
-	if err := s.Repo.Create(s.DB, user); err != nil {
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Create(tx, user); err != nil {
+			return err
+		}
+		if _, err := s.Orgs.CreatePersonal(tx, user); err != nil {
+			return err
+		}
+		if in.InviteToken == "" {
+			return nil
+		}
+		_, err := s.Invitations.AcceptTx(tx, user, in.InviteToken)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -82,6 +122,7 @@ func (s *AuthService) Signin(secret string, jwtExpMin int, in SigninInput) (*Sig
 	user, err := s.Repo.FindByEmail(s.DB, in.Email)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logSignin(0, in.Email, audit.OutcomeFailure, "unknown_email")
 			return nil, errors.New("invalid email or password")
 		}
 		return nil, err
@@ -89,14 +130,44 @@ func (s *AuthService) Signin(secret string, jwtExpMin int, in SigninInput) (*Sig
 
 	
 	if !utils.CheckPassword(user.PasswordHash,in.Password) {
+		s.logSignin(user.ID, in.Email, audit.OutcomeFailure, "wrong_password")
 		return nil, errors.New("invalid email or password")
 	}
 
+	// suspended by the anomaly detector until an organization admin lifts it
+	if user.SuspendedAt != nil {
+		s.logSignin(user.ID, in.Email, audit.OutcomeDenied, "suspended")
+		return nil, ErrAccountSuspended
+	}
+
 	
 	token, err := utils.GenerateJWT(secret, user.ID, jwtExpMin)
 	if err != nil {
 		return nil, err
 	}
 
+	s.logSignin(user.ID, in.Email, audit.OutcomeSuccess, "")
 	return &SigninResult{User: user, Token: token}, nil
 }
+
+// logSignin records a sign-in attempt. Failed attempts for unknown emails
+// are logged against user 0.
+func (s *AuthService) logSignin(userID uint, email, outcome, reason string) {
+	activity := &models.Activity{
+		UserID:   userID,
+		Type:     "user_signed_in",
+		Entity:   "user",
+		EntityID: userID,
+		Message:  truncate("Signed in as "+email, 255),
+		Outcome:  outcome,
+		Reason:   reason,
+	}
+	if outcome == audit.OutcomeSuccess {
+		activity.Principal = audit.PrincipalUser
+		activity.AuthMethod = "password"
+	} else {
+		activity.Type = "user_signin_failed"
+		activity.Message = truncate("Failed sign-in for "+email, 255)
+	}
+	recordActivity(s.DB, activity)
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBreakGlassWindow    = 30 * time.Minute
+	maxBreakGlassWindow        = 4 * time.Hour
+	minBreakGlassJustification = 20
+)
+
+var ErrBreakGlassDisabled = errors.New("break-glass access is not enabled for this key")
+
+type BreakGlassService struct {
+	Repo          repository.BreakGlassRepository
+	APIKeyRepo    repository.APIKeyRepository
+	APIKeys       *APIKeyService
+	Notifications *NotificationService
+	DB            *gorm.DB
+}
+
+type InvokeBreakGlassInput struct {
+	OrganizationID uint
+	UserID         uint
+	APIKeyID       uint
+	Justification  string
+	Minutes        int
+}
+
+func NewBreakGlassService(repo repository.BreakGlassRepository, akRepo repository.APIKeyRepository, apiKeys *APIKeyService, notifications *NotificationService, db *gorm.DB) *BreakGlassService {
+	return &BreakGlassService{Repo: repo, APIKeyRepo: akRepo, APIKeys: apiKeys, Notifications: notifications, DB: db}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *BreakGlassService) WithContext(ctx context.Context) *BreakGlassService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	c.APIKeys = s.APIKeys.WithContext(ctx)
+	return &c
+}
+
+// Invoke opens an emergency reveal window on a break-glass enabled key the
+// user owns or reaches through a team, and alerts every owner of the key and
+// of the teams it is shared with.
+func (s *BreakGlassService) Invoke(in InvokeBreakGlassInput) (*models.BreakGlassAccess, error) {
+	justification := strings.TrimSpace(in.Justification)
+	if len(justification) < minBreakGlassJustification {
+		return nil, fmt.Errorf("justification must be at least %d characters", minBreakGlassJustification)
+	}
+
+	window := defaultBreakGlassWindow
+	if in.Minutes > 0 {
+		window = time.Duration(in.Minutes) * time.Minute
+	}
+	if window > maxBreakGlassWindow {
+		return nil, fmt.Errorf("break-glass window cannot exceed %s", maxBreakGlassWindow)
+	}
+
+	key, err := s.APIKeyRepo.FindAccessibleByID(s.DB, in.OrganizationID, in.UserID, in.APIKeyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logDenied(s.DB, in.UserID, "apikey", in.APIKeyID, "no_access", fmt.Sprintf("Break-glass denied for API key #%d: not found or not shared with the user", in.APIKeyID))
+		}
+		return nil, err
+	}
+	if !key.BreakGlassEnabled {
+		return nil, ErrBreakGlassDisabled
+	}
+
+	grant := &models.BreakGlassAccess{
+		APIKeyID:      key.ID,
+		UserID:        in.UserID,
+		Justification: justification,
+		ExpiresAt:     time.Now().Add(window),
+	}
+	owners, err := s.APIKeyRepo.ListOwnerIDs(s.DB, key.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the grant, its audit entry and the owners' alert commit together:
+	// there is no break-glass access nobody hears about
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Create(tx, grant); err != nil {
+			return err
+		}
+		err := enqueueActivity(tx, &models.Activity{
+			UserID:   in.UserID,
+			Type:     "break_glass_access",
+			Entity:   "break_glass",
+			EntityID: grant.ID,
+			Message:  fmt.Sprintf("Break-glass access #%d on API key %s: %s", grant.ID, key.Name, justification),
+		})
+		if err != nil {
+			return err
+		}
+		return s.Notifications.NotifyTx(tx, owners, models.Notification{
+			Type:     "break_glass_alert",
+			Title:    "Break-glass access used on " + key.Name,
+			Body:     fmt.Sprintf("User #%d opened emergency access until %s.\nJustification: %s\nPlease acknowledge this event once the incident is resolved.", in.UserID, grant.ExpiresAt.Format(time.RFC3339), justification),
+			Entity:   "break_glass",
+			EntityID: grant.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// Reveal reveals the key behind an open break-glass grant owned by the user,
+// as long as the user still has access to the key and break-glass is still
+// enabled on it. The lease ends with the grant's window.
+func (s *BreakGlassService) Reveal(orgID, userID, grantID uint) (*RevealResult, error) {
+	grant, err := s.Repo.FindActive(s.DB, grantID, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.APIKeyRepo.FindAccessibleByID(s.DB, orgID, userID, grant.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !key.BreakGlassEnabled {
+		logDenied(s.DB, userID, "apikey", key.ID, "break_glass_disabled", fmt.Sprintf("Reveal via break-glass #%d denied, break-glass was disabled: %s", grant.ID, key.Name))
+		return nil, ErrBreakGlassDisabled
+	}
+
+	res, err := s.APIKeys.revealLeased(orgID, userID, key, LeaseOptions{Renewable: true, NotAfter: &grant.ExpiresAt})
+	if err != nil {
+		return nil, err
+	}
+
+	activity := &models.Activity{
+		UserID:   userID,
+		Type:     "break_glass_used",
+		Entity:   "break_glass",
+		EntityID: grant.ID,
+		Message:  fmt.Sprintf("Break-glass access #%d used to reveal API key: %s", grant.ID, key.Name),
+	}
+	recordActivity(s.DB, activity)
+	return res, nil
+}
+
+// Acknowledge closes out a break-glass event after the incident review.
+func (s *BreakGlassService) Acknowledge(orgID, userID, grantID uint, note string) (*models.BreakGlassAccess, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, errors.New("acknowledgment note required")
+	}
+
+	grant, err := s.Repo.GetByID(s.DB, grantID)
+	if err != nil {
+		return nil, err
+	}
+	if grant.AcknowledgedAt != nil {
+		return nil, errors.New("break-glass event already acknowledged")
+	}
+	if grant.UserID == userID {
+		return nil, errors.New("the user who broke the glass cannot acknowledge it")
+	}
+
+	ok, err := s.APIKeyRepo.CanManage(s.DB, orgID, userID, grant.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotApprover
+	}
+
+	now := time.Now()
+	grant.AcknowledgedBy = &userID
+	grant.AcknowledgedAt = &now
+	grant.AckNote = note
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, grant); err != nil {
+			return err
+		}
+		return enqueueActivity(tx, &models.Activity{
+			UserID:   userID,
+			Type:     "break_glass_acknowledged",
+			Entity:   "break_glass",
+			EntityID: grant.ID,
+			Message:  fmt.Sprintf("Break-glass access #%d acknowledged: %s", grant.ID, note),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// ListMine lists the user's grants on keys in the organization.
+func (s *BreakGlassService) ListMine(orgID, userID uint) ([]models.BreakGlassAccess, error) {
+	return s.Repo.ListByUser(s.DB, orgID, userID)
+}
+
+func (s *BreakGlassService) ListPendingAcknowledgment(orgID, userID uint) ([]models.BreakGlassAccess, error) {
+	return s.Repo.ListUnacknowledgedForManager(s.DB, orgID, userID)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
+)
+
+func newBreakGlassTest(t *testing.T) (*BreakGlassService, *models.APIKey) {
+	t.Helper()
+	db := testDB(t, &models.APIKey{}, &models.Team{}, &models.TeamMembership{}, &models.APIKeyTeam{}, &models.BreakGlassAccess{}, &models.Notification{})
+	key := createKey(t, db, &models.APIKey{OrganizationID: 1, OwnerID: 10, Name: "prod-db", BreakGlassEnabled: true}, "hunter2")
+	notifications := NewNotificationService(repository.NewNotificationRepository(), db)
+	// no APIKeyService: these tests are refused before anything is revealed
+	return NewBreakGlassService(repository.NewBreakGlassRepository(), repository.NewAPIKeyRepository(), nil, notifications, db), key
+}
+
+func TestInvokeBreakGlassRequiresAccessToTheKey(t *testing.T) {
+	svc, key := newBreakGlassTest(t)
+
+	_, err := svc.Invoke(InvokeBreakGlassInput{
+		OrganizationID: 1,
+		UserID:         30,
+		APIKeyID:       key.ID,
+		Justification:  strings.Repeat("production is down ", 2),
+	})
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Invoke by a non-member = %v, want ErrRecordNotFound", err)
+	}
+	grants, err := svc.Repo.ListByUser(svc.DB, 1, 30)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(grants) != 0 {
+		t.Errorf("non-member holds %d break-glass grants", len(grants))
+	}
+}
+
+func TestBreakGlassRevealRefusedOnceDisabled(t *testing.T) {
+	svc, key := newBreakGlassTest(t)
+	shareWithTeam(t, svc.DB, key, 20, "member")
+
+	grant := &models.BreakGlassAccess{APIKeyID: key.ID, UserID: 20, Justification: "incident 42", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := svc.DB.Omit("APIKey", "User").Create(grant).Error; err != nil {
+		t.Fatalf("create grant: %v", err)
+	}
+
+	// someone else's grant
+	if _, err := svc.Reveal(1, 30, grant.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Reveal of another user's grant = %v, want ErrRecordNotFound", err)
+	}
+
+	if err := svc.DB.Model(key).Update("break_glass_enabled", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Reveal(1, 20, grant.ID); !errors.Is(err, ErrBreakGlassDisabled) {
+		t.Errorf("Reveal after break-glass was disabled = %v, want ErrBreakGlassDisabled", err)
+	}
+}
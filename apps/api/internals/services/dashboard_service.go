@@ -1,6 +1,7 @@
 package services
 
 import (
+    "math"
     "time"
 
     "gorm.io/gorm"
@@ -16,6 +17,8 @@ type DashboardData struct {
     ActivitiesThisWeek int64         `json:"activitiesThisWeek"`
     RecentAPIKeys     []models.APIKey `json:"recentApiKeys"`
     RecentlyUsedKeys  []models.APIKey `json:"recentlyUsedKeys"`
+    KeyHealth         *repository.KeyHealth `json:"keyHealth"`
+    DueKeys           []DueKey       `json:"dueKeys"`
 }
 
 type DashboardService struct {
@@ -29,34 +32,55 @@ func NewDashboardService(db *gorm.DB, akRepo repository.APIKeyRepository, teamRe
     return &DashboardService{db: db, apiKeyRepo: akRepo, teamRepo: teamRepo, activityRepo: activityRepo}
 }
 
-func (s *DashboardService) GetDashboard(userID uint) (*DashboardData, error) {
+func (s *DashboardService) GetDashboard(orgID, userID uint) (*DashboardData, error) {
     var totalKeys int64
-    s.db.Model(&models.APIKey{}).Where("owner_id = ?", userID).Count(&totalKeys)
+    s.db.Model(&models.APIKey{}).Where("organization_id = ? AND owner_id = ?", orgID, userID).Count(&totalKeys)
 
     var totalTeams int64
-    s.db.Model(&models.Team{}).Where("owner_id = ?", userID).Count(&totalTeams)
+    s.db.Model(&models.Team{}).Where("organization_id = ? AND owner_id = ?", orgID, userID).Count(&totalTeams)
 
-    // Security % → always 100
-    securityPercent := 100.0
+    // Security % → share of keys that are neither expired nor overdue for
+    // rotation; keys that will be within the reminder lead time count half
+    now := time.Now()
+    health, err := s.apiKeyRepo.HealthForOwner(s.db, orgID, userID, now, now.Add(ReminderLeadTime))
+    if err != nil {
+        return nil, err
+    }
+    securityPercent := securityScore(health)
 
-    // Activities in last 7 days
-    weekAgo := time.Now().AddDate(0, 0, -7)
-    var activities int64
-    s.db.Model(&models.Activity{}).
-        Where("user_id = ? AND created_at >= ?", userID, weekAgo).
-        Count(&activities)
+    dueKeys := []DueKey{}
+    due, err := s.apiKeyRepo.ListDueForOwner(s.db, orgID, userID, now.Add(ReminderLeadTime))
+    if err != nil {
+        return nil, err
+    }
+    for i := range due {
+        if state, dueAt, ok := KeyDueState(&due[i], now); ok {
+            dueKeys = append(dueKeys, DueKey{ID: due[i].ID, Name: due[i].Name, State: state, DueAt: dueAt})
+        }
+    }
+
+    // Activities in this organization in the last 7 days
+    counts, err := s.activityRepo.CountSince(orgID, userID, now.AddDate(0, 0, -7))
+    if err != nil {
+        return nil, err
+    }
+    activities := counts[0]
 
     // Recent API keys (latest 5 created)
     var recentKeys []models.APIKey
-    s.db.Where("owner_id = ?", userID).
+    s.db.Where("organization_id = ? AND owner_id = ?", orgID, userID).
         Order("created_at desc").
         Limit(5).Find(&recentKeys)
 
-    // Recently used keys (based on Activity logs)
+    // Recently used keys: the 5 most recently revealed, by activity log
+    lastUsed := s.db.Model(&models.Activity{}).
+        Select("entity_id, MAX(created_at) AS last_used").
+        Where("entity = ? AND type = ?", "apikey", "apikey_revealed").
+        Group("entity_id")
     var usedKeys []models.APIKey
-    s.db.Joins("JOIN activities ON activities.api_key_id = api_keys.id").
-        Where("api_keys.owner_id = ?", userID).
-        Order("activities.created_at desc").
+    s.db.Joins("JOIN (?) AS used ON used.entity_id = api_keys.id", lastUsed).
+        Where("api_keys.organization_id = ? AND api_keys.owner_id = ?", orgID, userID).
+        Order("used.last_used DESC").
         Limit(5).
         Find(&usedKeys)
 
@@ -67,23 +91,42 @@ func (s *DashboardService) GetDashboard(userID uint) (*DashboardData, error) {
         ActivitiesThisWeek: activities,
         RecentAPIKeys:     recentKeys,
         RecentlyUsedKeys:  usedKeys,
+        KeyHealth:         health,
+        DueKeys:           dueKeys,
     }, nil
 }
 
+// securityScore is the percentage of keys in good standing. A key that is
+// expired or overdue for rotation scores 0, one that is close scores 0.5;
+// each key is counted once, by its worst state.
+func securityScore(h *repository.KeyHealth) float64 {
+    if h.Total == 0 {
+        return 100
+    }
+    soon := float64(h.AtRisk) / 2
+    score := 100 * (float64(h.Total-h.Unhealthy) - soon) / float64(h.Total)
+    if score < 0 {
+        score = 0
+    }
+    return math.Round(score*10) / 10
+}
+
 
 // services/dashboard_service.go
-func (s *DashboardService) GetTeamDashboard(userID uint) (map[string]interface{}, error) {
+func (s *DashboardService) GetTeamDashboard(orgID, userID uint) (map[string]interface{}, error) {
+    orgTeams := s.db.Model(&models.Team{}).Select("id").Where("organization_id = ?", orgID)
+
     // Total Teams (where user is a member)
     var totalTeams int64
     if err := s.db.Model(&models.TeamMembership{}).
-        Where("user_id = ?", userID).
+        Where("user_id = ? AND team_id IN (?)", userID, orgTeams).
         Count(&totalTeams).Error; err != nil {
         return nil, err
     }
     
     // Teams Owned
     var teamsOwned []models.Team
-    if err := s.db.Where("owner_id = ?", userID).Find(&teamsOwned).Error; err != nil {
+    if err := s.db.Where("organization_id = ? AND owner_id = ?", orgID, userID).Find(&teamsOwned).Error; err != nil {
         return nil, err
     }
     teamsOwnedCount := len(teamsOwned)
@@ -91,7 +134,7 @@ func (s *DashboardService) GetTeamDashboard(userID uint) (map[string]interface{}
     // Total Members across all owned teams
     var totalMembers int64
     if err := s.db.Model(&models.TeamMembership{}).
-        Where("team_id IN (?)", s.db.Model(&models.Team{}).Select("id").Where("owner_id = ?", userID)).
+        Where("team_id IN (?)", s.db.Model(&models.Team{}).Select("id").Where("organization_id = ? AND owner_id = ?", orgID, userID)).
         Count(&totalMembers).Error; err != nil {
         return nil, err
     }
@@ -99,7 +142,7 @@ func (s *DashboardService) GetTeamDashboard(userID uint) (map[string]interface{}
     // Shared Keys (API keys that belong to teams the user owns)
     var sharedKeys int64
     if err := s.db.Model(&models.APIKeyTeam{}).
-        Where("team_id IN (?)", s.db.Model(&models.Team{}).Select("id").Where("owner_id = ?", userID)).
+        Where("team_id IN (?)", s.db.Model(&models.Team{}).Select("id").Where("organization_id = ? AND owner_id = ?", orgID, userID)).
         Count(&sharedKeys).Error; err != nil {
         return nil, err
     }
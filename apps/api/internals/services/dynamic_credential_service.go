@@ -0,0 +1,514 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/dynamic"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLeaseNotActive     = errors.New("lease is no longer active")
+	ErrRoleHasLeases      = errors.New("role still has active leases")
+	ErrDynamicRoleDenied  = errors.New("you may not request credentials for this role")
+	ErrLeaseInUse         = errors.New("lease is being renewed or revoked")
+	dynamicRoleNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+)
+
+const (
+	leaseLock      = 2 * time.Minute
+	leaseBatch     = 50
+	maxLeaseTTL    = 30 * 24 * time.Hour
+	defaultRoleTTL = time.Hour
+)
+
+// DynamicCredentialService is the secrets engine for short-lived database
+// users: each request creates a fresh user, tracked by a lease, and the
+// sweeper drops it once the lease runs out.
+type DynamicCredentialService struct {
+	Repo          repository.DynamicCredentialRepository
+	Orgs          repository.OrganizationRepository
+	DB            *gorm.DB
+	MasterKey     []byte
+	SweepInterval time.Duration
+	NewEngine     func(kind string, config json.RawMessage) (dynamic.Engine, error)
+}
+
+// allowPrivate lets engines connect to private addresses; see dynamic.Options.
+func NewDynamicCredentialService(repo repository.DynamicCredentialRepository, orgs repository.OrganizationRepository, db *gorm.DB, masterKey []byte, allowPrivate bool, sweepInterval time.Duration) *DynamicCredentialService {
+	opts := dynamic.Options{AllowPrivate: allowPrivate}
+	return &DynamicCredentialService{
+		Repo:          repo,
+		Orgs:          orgs,
+		DB:            db,
+		MasterKey:     masterKey,
+		SweepInterval: sweepInterval,
+		NewEngine: func(kind string, config json.RawMessage) (dynamic.Engine, error) {
+			return dynamic.New(kind, config, opts)
+		},
+	}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *DynamicCredentialService) WithContext(ctx context.Context) *DynamicCredentialService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+type DynamicRoleInput struct {
+	Name       string
+	Engine     string
+	Config     json.RawMessage
+	Statements dynamic.Statements
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	TeamID     *uint
+}
+
+// IssuedCredentials is returned once when a lease is created.
+type IssuedCredentials struct {
+	LeaseID        string    `json:"lease_id"`
+	Username       string    `json:"username"`
+	Password       string    `json:"password"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	RenewableUntil time.Time `json:"renewable_until"`
+}
+
+// SaveRole creates or replaces a role. Org owners and admins only.
+func (s *DynamicCredentialService) SaveRole(orgID, userID uint, in DynamicRoleInput) (*models.DynamicRole, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	if !dynamicRoleNameRegexp.MatchString(in.Name) {
+		return nil, errors.New("role name must be lowercase letters, digits, '-' or '_'")
+	}
+	if in.DefaultTTL == 0 {
+		in.DefaultTTL = defaultRoleTTL
+	}
+	if in.MaxTTL == 0 {
+		in.MaxTTL = 24 * time.Hour
+	}
+	if in.DefaultTTL < time.Minute || in.MaxTTL < in.DefaultTTL || in.MaxTTL > maxLeaseTTL {
+		return nil, fmt.Errorf("ttl must be at least 1m and max_ttl between ttl and %s", maxLeaseTTL)
+	}
+	engine, err := s.NewEngine(in.Engine, in.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid engine config: %w", err)
+	}
+	if err := engine.Validate(in.Statements); err != nil {
+		return nil, err
+	}
+	ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, string(in.Config))
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.Repo.GetRole(s.DB, orgID, in.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		role = &models.DynamicRole{OrganizationID: orgID, Name: in.Name, CreatedBy: userID}
+	} else if err != nil {
+		return nil, err
+	}
+	role.Engine = in.Engine
+	role.ConfigCiphertext = ct
+	role.ConfigNonce = nonce
+	role.CreationStatements = in.Statements.Creation
+	role.RenewalStatements = in.Statements.Renewal
+	role.RevocationStatements = in.Statements.Revocation
+	role.DefaultTTLSeconds = int(in.DefaultTTL / time.Second)
+	role.MaxTTLSeconds = int(in.MaxTTL / time.Second)
+	role.TeamID = in.TeamID
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.SaveRole(tx, role); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "dynamic_role_saved", "dynamic_role", role.ID, "Dynamic credential role saved: "+role.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// ListRoles lists the org's roles, without their connection settings.
+func (s *DynamicCredentialService) ListRoles(orgID, userID uint) ([]models.DynamicRole, error) {
+	if _, err := s.Orgs.GetMember(s.DB, orgID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrgMember
+		}
+		return nil, err
+	}
+	return s.Repo.ListRoles(s.DB, orgID)
+}
+
+// DeleteRole removes a role that has no active leases left.
+func (s *DynamicCredentialService) DeleteRole(orgID, userID uint, name string) error {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return err
+	}
+	role, err := s.Repo.GetRole(s.DB, orgID, name)
+	if err != nil {
+		return err
+	}
+	n, err := s.Repo.CountActiveLeases(s.DB, role.ID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrRoleHasLeases
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.DeleteRole(tx, role.ID); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "dynamic_role_deleted", "dynamic_role", role.ID, "Dynamic credential role deleted: "+role.Name)
+	})
+}
+
+// Issue creates a new user for the role and returns its credentials. ttl of
+// 0 uses the role default; longer requests are capped at the role maximum.
+func (s *DynamicCredentialService) Issue(ctx context.Context, orgID, userID uint, roleName string, ttl time.Duration) (*IssuedCredentials, error) {
+	role, err := s.Repo.GetRole(s.DB, orgID, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireRoleAccess(role, orgID, userID); err != nil {
+		return nil, err
+	}
+	engine, err := s.engineFor(role)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	maxExp := now.Add(time.Duration(role.MaxTTLSeconds) * time.Second)
+	exp := leaseExpiry(now, ttl, role, maxExp)
+
+	leaseID, err := utils.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	password, err := utils.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	username, err := dynamicUsername(role.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lease is written first so that a crash after the user is created
+	// still leaves something for the sweeper to clean up.
+	lease := &models.DynamicLease{
+		LeaseID:        leaseID,
+		OrganizationID: orgID,
+		RoleID:         role.ID,
+		UserID:         userID,
+		Username:       username,
+		Status:         models.LeaseActive,
+		ExpiresAt:      exp,
+		MaxExpiresAt:   maxExp,
+	}
+	if err := s.Repo.CreateLease(s.DB, lease); err != nil {
+		return nil, err
+	}
+
+	creds := dynamic.Credentials{Username: username, Password: password}
+	if err := engine.Create(ctx, roleStatements(role), creds, exp); err != nil {
+		// nothing usable was created; end the lease now so the sweeper
+		// removes any partial user
+		lease.ExpiresAt = now
+		lease.LastError = truncate(err.Error(), 1024)
+		if uerr := s.Repo.UpdateLease(s.DB, lease); uerr != nil {
+			fmt.Printf("failed to update lease %d: %v\n", lease.ID, uerr)
+		}
+		return nil, fmt.Errorf("creating database user: %w", err)
+	}
+
+	err = s.logActivity(s.DB, userID, "dynamic_credentials_issued", "dynamic_lease", lease.ID,
+		fmt.Sprintf("Issued %s credentials %s until %s", role.Name, username, exp.Format(time.RFC3339)))
+	if err != nil {
+		fmt.Printf("failed to log activity: %v\n", err)
+	}
+	return &IssuedCredentials{
+		LeaseID:        leaseID,
+		Username:       username,
+		Password:       password,
+		ExpiresAt:      exp,
+		RenewableUntil: maxExp,
+	}, nil
+}
+
+// Renew extends a lease by increment (the role default when 0), never past
+// the maximum set when it was issued.
+func (s *DynamicCredentialService) Renew(ctx context.Context, orgID, userID uint, leaseID string, increment time.Duration) (*models.DynamicLease, error) {
+	lease, role, err := s.ownedLease(orgID, userID, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if lease.Status != models.LeaseActive || !lease.ExpiresAt.After(now) {
+		return nil, ErrLeaseNotActive
+	}
+	engine, err := s.engineFor(role)
+	if err != nil {
+		return nil, err
+	}
+
+	// hold the lease while the user is extended, so a concurrent revoke or
+	// sweep cannot drop it and then see the lease come back to life
+	ok, err := s.Repo.ClaimLease(s.DB, lease.ID, now, now.Add(leaseLock))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLeaseInUse
+	}
+
+	exp := leaseExpiry(now, increment, role, lease.MaxExpiresAt)
+	lease.LockedUntil = nil
+	if err := engine.Renew(ctx, roleStatements(role), lease.Username, exp); err != nil {
+		if uerr := s.Repo.UpdateLease(s.DB, lease); uerr != nil {
+			fmt.Printf("failed to release lease %d: %v\n", lease.ID, uerr)
+		}
+		return nil, fmt.Errorf("renewing database user: %w", err)
+	}
+	lease.ExpiresAt = exp
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.UpdateLease(tx, lease); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "dynamic_lease_renewed", "dynamic_lease", lease.ID,
+			fmt.Sprintf("Renewed %s until %s", lease.Username, exp.Format(time.RFC3339)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Revoke drops the lease's user immediately. If the target refuses, the
+// lease is marked expired-now and left for the sweeper to retry.
+func (s *DynamicCredentialService) Revoke(ctx context.Context, orgID, userID uint, leaseID string) error {
+	lease, role, err := s.ownedLease(orgID, userID, leaseID)
+	if err != nil {
+		return err
+	}
+	if lease.Status != models.LeaseActive {
+		return ErrLeaseNotActive
+	}
+	now := time.Now()
+	ok, err := s.Repo.ClaimLease(s.DB, lease.ID, now, now.Add(leaseLock))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseInUse
+	}
+
+	if err := s.revoke(ctx, lease, role, models.LeaseRevoked); err != nil {
+		return fmt.Errorf("revoking database user: %w", err)
+	}
+	if err := s.logActivity(s.DB, userID, "dynamic_lease_revoked", "dynamic_lease", lease.ID, "Revoked "+lease.Username); err != nil {
+		fmt.Printf("failed to log activity: %v\n", err)
+	}
+	return nil
+}
+
+func (s *DynamicCredentialService) ListLeases(orgID, userID uint) ([]models.DynamicLease, error) {
+	return s.Repo.ListLeases(s.DB, orgID, userID)
+}
+
+// Start sweeps expired leases now and then every SweepInterval until ctx is done.
+func (s *DynamicCredentialService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.SweepInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.Sweep(ctx, time.Now()); err != nil {
+				fmt.Printf("lease sweep failed: %v\n", err)
+			} else if n > 0 {
+				fmt.Printf("revoked %d expired leases\n", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Sweep drops the users of expired leases and returns how many were removed.
+// A failed revocation stays active and is retried with backoff.
+func (s *DynamicCredentialService) Sweep(ctx context.Context, now time.Time) (int, error) {
+	leases, err := s.Repo.ListExpiredLeases(s.DB, now, leaseBatch)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for i := range leases {
+		lease := &leases[i]
+		ok, err := s.Repo.ClaimLease(s.DB, lease.ID, now, now.Add(leaseLock))
+		if err != nil {
+			return revoked, err
+		}
+		if !ok {
+			continue
+		}
+		role, err := s.Repo.GetRoleByID(s.DB, lease.RoleID)
+		if err != nil {
+			return revoked, err
+		}
+		if err := s.revoke(ctx, lease, role, models.LeaseExpired); err != nil {
+			fmt.Printf("failed to revoke lease %d (%s): %v\n", lease.ID, lease.Username, err)
+			continue
+		}
+		if err := s.logActivity(s.DB, lease.UserID, "dynamic_lease_expired", "dynamic_lease", lease.ID, "Lease expired, dropped "+lease.Username); err != nil {
+			fmt.Printf("failed to log activity: %v\n", err)
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// revoke runs the role's revocation statements for a claimed lease and
+// records the outcome. On failure the lock doubles as the retry delay.
+func (s *DynamicCredentialService) revoke(ctx context.Context, lease *models.DynamicLease, role *models.DynamicRole, status string) error {
+	engine, err := s.engineFor(role)
+	if err == nil {
+		err = engine.Revoke(ctx, roleStatements(role), lease.Username)
+	}
+	now := time.Now()
+	if err != nil {
+		lease.RevokeAttempts++
+		lease.LastError = truncate(err.Error(), 1024)
+		if lease.ExpiresAt.After(now) {
+			lease.ExpiresAt = now
+		}
+		retry := now.Add(leaseRetryDelay(lease.RevokeAttempts))
+		lease.LockedUntil = &retry
+	} else {
+		lease.Status = status
+		lease.RevokedAt = &now
+		lease.LastError = ""
+		lease.LockedUntil = nil
+	}
+	if uerr := s.Repo.UpdateLease(s.DB, lease); uerr != nil {
+		fmt.Printf("failed to update lease %d: %v\n", lease.ID, uerr)
+	}
+	return err
+}
+
+// ownedLease loads a lease the user may manage: their own, or any lease in
+// the org for owners and admins.
+func (s *DynamicCredentialService) ownedLease(orgID, userID uint, leaseID string) (*models.DynamicLease, *models.DynamicRole, error) {
+	lease, err := s.Repo.GetLease(s.DB, orgID, leaseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lease.UserID != userID {
+		if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+			return nil, nil, gorm.ErrRecordNotFound
+		}
+	}
+	role, err := s.Repo.GetRoleByID(s.DB, lease.RoleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lease, role, nil
+}
+
+// requireRoleAccess allows org owners and admins, and members of the role's team.
+func (s *DynamicCredentialService) requireRoleAccess(role *models.DynamicRole, orgID, userID uint) error {
+	err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID)
+	if err == nil || !errors.Is(err, ErrNotOrgAdmin) {
+		return err
+	}
+	if role.TeamID == nil {
+		return ErrDynamicRoleDenied
+	}
+	ok, err := s.Repo.IsTeamMember(s.DB, *role.TeamID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDynamicRoleDenied
+	}
+	return nil
+}
+
+func (s *DynamicCredentialService) engineFor(role *models.DynamicRole) (dynamic.Engine, error) {
+	config, err := utils.DecryptAPIKey(s.MasterKey, role.ConfigCiphertext, role.ConfigNonce)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewEngine(role.Engine, json.RawMessage(config))
+}
+
+// logActivity queues an activity in db, normally the transaction that made
+// the change.
+func (s *DynamicCredentialService) logActivity(db *gorm.DB, userID uint, typ, entity string, id uint, msg string) error {
+	return enqueueActivity(db, &models.Activity{
+		UserID:   userID,
+		Type:     typ,
+		Entity:   entity,
+		EntityID: id,
+		Message:  truncate(msg, 255),
+	})
+}
+
+func roleStatements(role *models.DynamicRole) dynamic.Statements {
+	return dynamic.Statements{
+		Creation:   role.CreationStatements,
+		Renewal:    role.RenewalStatements,
+		Revocation: role.RevocationStatements,
+	}
+}
+
+func leaseExpiry(now time.Time, ttl time.Duration, role *models.DynamicRole, max time.Time) time.Time {
+	if ttl <= 0 {
+		ttl = time.Duration(role.DefaultTTLSeconds) * time.Second
+	}
+	exp := now.Add(ttl)
+	if exp.After(max) {
+		exp = max
+	}
+	return exp
+}
+
+// dynamicUsername builds a unique, recognisable user name such as
+// "op_readonly_3f9a1c2e7b4d5a60", well within Postgres' 63-byte limit.
+func dynamicUsername(roleName string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	prefix := strings.ReplaceAll(roleName, "-", "_")
+	if len(prefix) > 24 {
+		prefix = prefix[:24]
+	}
+	return "op_" + prefix + "_" + hex.EncodeToString(b), nil
+}
+
+// leaseRetryDelay backs off failed revocations from 30s up to 30 minutes.
+func leaseRetryDelay(attempts int) time.Duration {
+	d := 30 * time.Second << (attempts - 1)
+	if d <= 0 || d > 30*time.Minute {
+		return 30 * time.Minute
+	}
+	return d
+}
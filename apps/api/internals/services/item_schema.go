@@ -0,0 +1,89 @@
+package services
+
+import (
+	"encoding/base32"
+	"encoding/pem"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+)
+
+// ItemFieldSpec describes one field of an item type.
+type ItemFieldSpec struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"` // "text" | "multiline" | "url" | "port" | "totp" | "pem"
+	Concealed bool   `json:"concealed"`
+	Required  bool   `json:"required"`
+}
+
+// ItemSchemas lists the fields of every item type, in display order.
+var ItemSchemas = map[string][]ItemFieldSpec{
+	"api_key": {
+		{Name: "key", Kind: "text", Concealed: true, Required: true},
+	},
+	"login": {
+		{Name: "username", Kind: "text", Required: true},
+		{Name: "password", Kind: "text", Concealed: true, Required: true},
+		{Name: "url", Kind: "url"},
+		{Name: "totp_seed", Kind: "totp", Concealed: true},
+	},
+	"database": {
+		{Name: "host", Kind: "text", Required: true},
+		{Name: "port", Kind: "port", Required: true},
+		{Name: "database", Kind: "text"},
+		{Name: "username", Kind: "text", Required: true},
+		{Name: "password", Kind: "text", Concealed: true, Required: true},
+	},
+	"certificate": {
+		{Name: "certificate", Kind: "pem", Required: true},
+		{Name: "private_key", Kind: "pem", Concealed: true, Required: true},
+		{Name: "chain", Kind: "pem"},
+	},
+	"ssh_key": {
+		{Name: "public_key", Kind: "text", Required: true},
+		{Name: "private_key", Kind: "pem", Concealed: true, Required: true},
+		{Name: "passphrase", Kind: "text", Concealed: true},
+	},
+	"secure_note": {
+		{Name: "note", Kind: "multiline", Concealed: true, Required: true},
+	},
+}
+
+// fieldSpec looks up a field of an item type.
+func fieldSpec(itemType, name string) (ItemFieldSpec, bool) {
+	for _, spec := range ItemSchemas[itemType] {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return ItemFieldSpec{}, false
+}
+
+// normalizeItemField validates a value against its field kind.
+func normalizeItemField(spec ItemFieldSpec, value string) (string, error) {
+	switch spec.Kind {
+	case "url":
+		return utils.NormalizeFieldValue("url", value)
+	case "port":
+		port, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || port < 1 || port > 65535 {
+			return "", errors.New(spec.Name + " must be a port between 1 and 65535")
+		}
+		return strconv.Itoa(port), nil
+	case "totp":
+		seed := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(value), " ", ""))
+		if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(seed, "=")); err != nil || seed == "" {
+			return "", errors.New(spec.Name + " must be a base32 TOTP seed")
+		}
+		return seed, nil
+	case "pem":
+		if block, _ := pem.Decode([]byte(value)); block == nil {
+			return "", errors.New(spec.Name + " must be PEM encoded")
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var ErrAPIKeyItemManaged = errors.New("api_key items are managed through /apikeys")
+
+// ItemService stores typed vault items and reveals their concealed fields one at a time.
+type ItemService struct {
+	Repo      repository.ItemRepository
+	Keys      *APIKeyService
+	DB        *gorm.DB
+	MasterKey []byte
+}
+
+// ItemFieldInput sets one field. Concealed can hide a plain field but cannot
+// expose one the schema always conceals.
+type ItemFieldInput struct {
+	Value     string `json:"value"`
+	Concealed *bool  `json:"concealed,omitempty"`
+}
+
+type CreateItemInput struct {
+	OrganizationID uint
+	OwnerID        uint
+	Type           string
+	Name           string
+	Description    string
+	Fields         map[string]ItemFieldInput
+}
+
+type UpdateItemInput struct {
+	OrganizationID uint
+	OwnerID        uint
+	ID             uint
+	Name           *string
+	Description    *string
+	Fields         map[string]ItemFieldInput
+}
+
+// ItemFieldView is a field as listed: plain values are included, concealed
+// ones only say whether a value is set.
+type ItemFieldView struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Concealed bool   `json:"concealed"`
+	Value     string `json:"value,omitempty"`
+	HasValue  bool   `json:"has_value"`
+}
+
+type ItemView struct {
+	ID          uint            `json:"id"`
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	APIKeyID    *uint           `json:"api_key_id,omitempty"`
+	Fields      []ItemFieldView `json:"fields"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+func NewItemService(repo repository.ItemRepository, keys *APIKeyService, db *gorm.DB, masterKey []byte) *ItemService {
+	return &ItemService{Repo: repo, Keys: keys, DB: db, MasterKey: masterKey}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *ItemService) WithContext(ctx context.Context) *ItemService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	c.Keys = s.Keys.WithContext(ctx)
+	return &c
+}
+
+// Types returns the field schema of every item type.
+func (s *ItemService) Types() map[string][]ItemFieldSpec {
+	return ItemSchemas
+}
+
+func (s *ItemService) Create(in CreateItemInput) (*ItemView, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, errors.New("item name required")
+	}
+	if _, ok := ItemSchemas[in.Type]; !ok {
+		return nil, fmt.Errorf("unknown item type %q", in.Type)
+	}
+
+	if in.Type == "api_key" {
+		res, err := s.Keys.Create(CreateAPIKeyInput{
+			Name:           name,
+			Key:            in.Fields["key"].Value,
+			Description:    in.Description,
+			OrganizationID: in.OrganizationID,
+			OwnerID:        in.OwnerID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		item, err := s.Repo.GetByAPIKeyID(s.DB, res.ID)
+		if err != nil {
+			return nil, err
+		}
+		return viewItem(item), nil
+	}
+
+	for _, spec := range ItemSchemas[in.Type] {
+		if spec.Required && in.Fields[spec.Name].Value == "" {
+			return nil, fmt.Errorf("%s is required for %s items", spec.Name, in.Type)
+		}
+	}
+
+	item := &models.Item{
+		OrganizationID: in.OrganizationID,
+		OwnerID:        in.OwnerID,
+		Type:           in.Type,
+		Name:           name,
+		Description:    in.Description,
+	}
+	for _, fieldName := range sortedFieldNames(in.Fields) {
+		f, err := s.buildField(in.Type, fieldName, in.Fields[fieldName])
+		if err != nil {
+			return nil, err
+		}
+		item.Fields = append(item.Fields, *f)
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Create(tx, item); err != nil {
+			return err
+		}
+		return s.logActivity(tx, in.OwnerID, item.ID, "item_created", fmt.Sprintf("%s item created: %s", item.Type, item.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return viewItem(item), nil
+}
+
+func (s *ItemService) List(orgID, ownerID uint, itemType string) ([]ItemView, error) {
+	if itemType != "" {
+		if _, ok := ItemSchemas[itemType]; !ok {
+			return nil, fmt.Errorf("unknown item type %q", itemType)
+		}
+	}
+	items, err := s.Repo.List(s.DB, orgID, ownerID, itemType)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ItemView, 0, len(items))
+	for i := range items {
+		out = append(out, *viewItem(&items[i]))
+	}
+	return out, nil
+}
+
+func (s *ItemService) Get(orgID, ownerID, id uint) (*ItemView, error) {
+	item, err := s.Repo.GetByID(s.DB, orgID, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	return viewItem(item), nil
+}
+
+// Update renames an item or sets some of its fields; fields not mentioned are kept.
+func (s *ItemService) Update(in UpdateItemInput) (*ItemView, error) {
+	item, err := s.Repo.GetByID(s.DB, in.OrganizationID, in.OwnerID, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	if item.Type == "api_key" {
+		return nil, ErrAPIKeyItemManaged
+	}
+
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			return nil, errors.New("item name required")
+		}
+		item.Name = name
+	}
+	if in.Description != nil {
+		item.Description = *in.Description
+	}
+
+	existing := make(map[string]*models.ItemField, len(item.Fields))
+	for i := range item.Fields {
+		existing[item.Fields[i].Name] = &item.Fields[i]
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, fieldName := range sortedFieldNames(in.Fields) {
+			spec, _ := fieldSpec(item.Type, fieldName)
+			if spec.Required && in.Fields[fieldName].Value == "" {
+				return fmt.Errorf("%s is required for %s items", fieldName, item.Type)
+			}
+			f, err := s.buildField(item.Type, fieldName, in.Fields[fieldName])
+			if err != nil {
+				return err
+			}
+			if old, ok := existing[fieldName]; ok {
+				f.ID = old.ID
+			}
+			f.ItemID = item.ID
+			if err := s.Repo.SaveField(tx, f); err != nil {
+				return err
+			}
+		}
+		if err := s.Repo.Update(tx, item); err != nil {
+			return err
+		}
+		return s.logActivity(tx, in.OwnerID, item.ID, "item_updated", fmt.Sprintf("%s item updated: %s", item.Type, item.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(in.OrganizationID, in.OwnerID, item.ID)
+}
+
+// RevealField returns a single field's value. Concealed reveals are logged;
+// the key of an api_key item goes through the API key reveal, so approval
+// requirements still apply.
+func (s *ItemService) RevealField(orgID, userID, id uint, fieldName, reason string) (*RevealResult, error) {
+	item, err := s.Repo.GetByID(s.DB, orgID, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Type == "api_key" {
+		if fieldName != "key" {
+			return nil, fmt.Errorf("api_key items have no field %q", fieldName)
+		}
+		return s.Keys.RevealByID(orgID, userID, *item.APIKeyID, reason)
+	}
+
+	for _, f := range item.Fields {
+		if f.Name != fieldName {
+			continue
+		}
+		if !f.Concealed {
+			return &RevealResult{Plaintext: f.Value}, nil
+		}
+		plaintext, err := utils.DecryptAPIKey(s.MasterKey, f.Ciphertext, f.Nonce)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.logActivity(s.DB, userID, item.ID, "item_field_revealed", fmt.Sprintf("Field %s revealed: %s", f.Name, item.Name)); err != nil {
+			fmt.Printf("failed to log activity: %v\n", err)
+		}
+		return &RevealResult{Plaintext: plaintext}, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (s *ItemService) Delete(orgID, ownerID, id uint) error {
+	item, err := s.Repo.GetByID(s.DB, orgID, ownerID, id)
+	if err != nil {
+		return err
+	}
+	if item.Type == "api_key" {
+		return ErrAPIKeyItemManaged
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Delete(tx, item.ID); err != nil {
+			return err
+		}
+		return s.logActivity(tx, ownerID, item.ID, "item_deleted", fmt.Sprintf("%s item deleted: %s", item.Type, item.Name))
+	})
+}
+
+// BackfillAPIKeyItems gives every existing API key its api_key item.
+func (s *ItemService) BackfillAPIKeyItems() error {
+	return s.Repo.SyncAPIKeyItems(s.DB, 0, 0)
+}
+
+// RepairPlainFields fixes plain fields that were saved flagged as concealed.
+func (s *ItemService) RepairPlainFields() error {
+	n, err := s.Repo.RepairPlainFields(s.DB)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Printf("items: %d plain fields were flagged concealed and have been repaired\n", n)
+	}
+	return nil
+}
+
+// buildField validates and, if concealed, encrypts one field value.
+func (s *ItemService) buildField(itemType, name string, in ItemFieldInput) (*models.ItemField, error) {
+	spec, ok := fieldSpec(itemType, name)
+	if !ok {
+		return nil, fmt.Errorf("%s items have no field %q", itemType, name)
+	}
+	concealed := spec.Concealed
+	if in.Concealed != nil {
+		if spec.Concealed && !*in.Concealed {
+			return nil, fmt.Errorf("%s is always concealed", name)
+		}
+		concealed = *in.Concealed
+	}
+
+	value := in.Value
+	if value != "" {
+		var err error
+		if value, err = normalizeItemField(spec, value); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &models.ItemField{Name: name, Concealed: concealed, Position: fieldPosition(itemType, name)}
+	if !concealed {
+		f.Value = value
+		return f, nil
+	}
+	if value != "" {
+		ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, value)
+		if err != nil {
+			return nil, err
+		}
+		f.Ciphertext, f.Nonce = ct, nonce
+	}
+	return f, nil
+}
+
+func (s *ItemService) logActivity(db *gorm.DB, userID, itemID uint, activityType, message string) error {
+	return enqueueActivity(db, &models.Activity{
+		UserID:   userID,
+		Type:     activityType,
+		Entity:   "item",
+		EntityID: itemID,
+		Message:  message,
+	})
+}
+
+func viewItem(item *models.Item) *ItemView {
+	v := &ItemView{
+		ID:          item.ID,
+		Type:        item.Type,
+		Name:        item.Name,
+		Description: item.Description,
+		APIKeyID:    item.APIKeyID,
+		Fields:      []ItemFieldView{},
+		CreatedAt:   item.CreatedAt,
+		UpdatedAt:   item.UpdatedAt,
+	}
+
+	if item.Type == "api_key" {
+		// the key row is the source of truth for name and description
+		if item.APIKey != nil {
+			v.Name = item.APIKey.Name
+			v.Description = item.APIKey.Description
+		}
+		v.Fields = append(v.Fields, ItemFieldView{Name: "key", Kind: "text", Concealed: true, HasValue: true})
+		return v
+	}
+
+	for _, f := range item.Fields {
+		spec, _ := fieldSpec(item.Type, f.Name)
+		fv := ItemFieldView{Name: f.Name, Kind: spec.Kind, Concealed: f.Concealed}
+		if f.Concealed {
+			fv.HasValue = f.Ciphertext != ""
+		} else {
+			fv.Value = f.Value
+			fv.HasValue = f.Value != ""
+		}
+		v.Fields = append(v.Fields, fv)
+	}
+	return v
+}
+
+func fieldPosition(itemType, name string) int {
+	for i, spec := range ItemSchemas[itemType] {
+		if spec.Name == name {
+			return i
+		}
+	}
+	return len(ItemSchemas[itemType])
+}
+
+func sortedFieldNames(fields map[string]ItemFieldInput) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
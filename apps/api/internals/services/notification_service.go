@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
+)
+
+// NotificationChannel delivers a notification through one medium.
+type NotificationChannel interface {
+	Name() string
+	Send(n *models.Notification) error
+}
+
+// NotificationService always stores notifications in-app. Extra channels
+// (e.g. a chat webhook) are outbox subscribers (see ChannelSubscriber), so
+// they receive a copy of every notification once it is committed, with
+// retries.
+type NotificationService struct {
+	Repo repository.NotificationRepository
+	DB   *gorm.DB
+}
+
+func NewNotificationService(repo repository.NotificationRepository, db *gorm.DB) *NotificationService {
+	return &NotificationService{Repo: repo, DB: db}
+}
+
+// Notify stores the notification for each user and queues it for every channel.
+func (s *NotificationService) Notify(userIDs []uint, n models.Notification) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.NotifyTx(tx, userIDs, n)
+	})
+}
+
+// NotifyTx is Notify inside the caller's transaction, so the notification is
+// sent only if the change it announces commits.
+func (s *NotificationService) NotifyTx(tx *gorm.DB, userIDs []uint, n models.Notification) error {
+	for _, uid := range userIDs {
+		msg := n
+		msg.UserID = uid
+		if err := s.Repo.Create(tx, &msg); err != nil {
+			return err
+		}
+		if _, err := enqueueEvent(tx, TopicNotification, &msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) List(userID uint, unreadOnly bool) ([]models.Notification, error) {
+	return s.Repo.ListByUser(s.DB, userID, unreadOnly, 100)
+}
+
+func (s *NotificationService) MarkRead(userID uint, ids []uint) error {
+	return s.Repo.MarkRead(s.DB, userID, ids)
+}
+
+// WebhookChannel posts notifications as JSON to a chat-style incoming webhook
+// (Slack, Mattermost, Teams all accept a {"text": ...} body).
+type WebhookChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookChannel(url string) *WebhookChannel {
+	return &WebhookChannel{URL: url, Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+func (c *WebhookChannel) Send(n *models.Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"text":    fmt.Sprintf("*%s*\n%s", n.Title, n.Body),
+		"type":    n.Type,
+		"user_id": n.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Post(c.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotOrgMember     = errors.New("you are not a member of this organization")
+	ErrNotOrgAdmin      = errors.New("only organization owners and admins can do this")
+	ErrNotOrgOwner      = errors.New("only organization owners can do this")
+	ErrAuthMethodDenied = errors.New("this organization does not allow your sign-in method")
+)
+
+var supportedAuthMethods = map[string]bool{"password": true}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// defaultPasswordPolicy is a new organization's, and so also what a signup
+// without an invitation has to meet.
+var defaultPasswordPolicy = utils.PasswordPolicy{MinLength: 8}
+
+type OrganizationService struct {
+	Repo     repository.OrganizationRepository
+	UserRepo repository.UserRepository
+	DB       *gorm.DB
+}
+
+type OrgSettingsInput struct {
+	Name                  *string  `json:"name"`
+	AllowedAuthMethods    []string `json:"allowed_auth_methods"`
+	PasswordMinLength     *int     `json:"password_min_length"`
+	PasswordRequireMixed  *bool    `json:"password_require_mixed_case"`
+	PasswordRequireNumber *bool    `json:"password_require_number"`
+	PasswordRequireSymbol *bool    `json:"password_require_symbol"`
+}
+
+func NewOrganizationService(repo repository.OrganizationRepository, userRepo repository.UserRepository, db *gorm.DB) *OrganizationService {
+	return &OrganizationService{Repo: repo, UserRepo: userRepo, DB: db}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *OrganizationService) WithContext(ctx context.Context) *OrganizationService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+// ResolveOrg picks the organization a request runs in: the requested one if the
+// user belongs to it, otherwise the user's personal organization. It also checks
+// that the org allows the sign-in method the request was authenticated with.
+func (s *OrganizationService) ResolveOrg(userID, requestedOrgID uint, authMethod string) (uint, error) {
+	var orgID uint
+	if requestedOrgID != 0 {
+		if _, err := s.Repo.GetMember(s.DB, requestedOrgID, userID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, ErrNotOrgMember
+			}
+			return 0, err
+		}
+		orgID = requestedOrgID
+	} else {
+		m, err := s.Repo.FindDefaultForUser(s.DB, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, ErrNotOrgMember
+			}
+			return 0, err
+		}
+		orgID = m.OrganizationID
+	}
+
+	org, err := s.Repo.GetByID(s.DB, orgID)
+	if err != nil {
+		return 0, err
+	}
+	if !containsMethod(org.AllowedAuthMethods, authMethod) {
+		return 0, ErrAuthMethodDenied
+	}
+	return orgID, nil
+}
+
+// CreatePersonal creates the user's personal organization. Run it in the
+// transaction that creates the user.
+func (s *OrganizationService) CreatePersonal(tx *gorm.DB, user *models.User) (*models.Organization, error) {
+	return s.create(tx, user.ID, user.FullName+"'s Organization", fmt.Sprintf("user-%d", user.ID))
+}
+
+// Create makes a new organization owned by the user.
+func (s *OrganizationService) Create(userID uint, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name required")
+	}
+
+	var org *models.Organization
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		slug, err := s.uniqueSlug(tx, name)
+		if err != nil {
+			return err
+		}
+		if org, err = s.create(tx, userID, name, slug); err != nil {
+			return err
+		}
+		return s.logOrgActivity(tx, userID, org.ID, "org_created", "Organization created: "+org.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *OrganizationService) ListMine(userID uint) ([]repository.OrgMembershipRow, error) {
+	return s.Repo.ListByUser(s.DB, userID)
+}
+
+func (s *OrganizationService) Directory(orgID uint) ([]repository.OrgMemberRow, error) {
+	return s.Repo.ListMembers(s.DB, orgID)
+}
+
+// AddMember adds an existing user, found by email, to the organization.
+func (s *OrganizationService) AddMember(actorID, orgID uint, email, role string) (*models.OrganizationMember, error) {
+	if err := s.requireAdmin(orgID, actorID); err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = "member"
+	}
+	if role != "member" && role != "admin" {
+		return nil, errors.New("role must be member or admin")
+	}
+
+	user, err := s.UserRepo.FindByEmail(s.DB, email)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Repo.GetMember(s.DB, orgID, user.ID); err == nil {
+		return nil, errors.New("user is already a member of this organization")
+	}
+
+	m := &models.OrganizationMember{OrganizationID: orgID, UserID: user.ID, Role: role}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.AddMember(tx, m); err != nil {
+			return err
+		}
+		return s.logOrgActivity(tx, actorID, orgID, "org_member_added", fmt.Sprintf("Organization member added: %s as %s", user.Email, role))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EnsureMember adds the user to the organization as a member unless they already belong to it.
+func (s *OrganizationService) EnsureMember(tx *gorm.DB, orgID, userID uint) error {
+	if _, err := s.Repo.GetMember(tx, orgID, userID); err == nil {
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return s.Repo.AddMember(tx, &models.OrganizationMember{OrganizationID: orgID, UserID: userID, Role: "member"})
+}
+
+// UpdateMemberRole changes a member's role. Admins manage admins and members;
+// only owners can make someone an owner or change an owner's role.
+func (s *OrganizationService) UpdateMemberRole(actorID, orgID, userID uint, role string) error {
+	if err := s.requireAdmin(orgID, actorID); err != nil {
+		return err
+	}
+	if role != "owner" && role != "admin" && role != "member" {
+		return errors.New("role must be owner, admin or member")
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		current, err := s.Repo.GetMember(tx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if role == "owner" || current.Role == "owner" {
+			if err := s.requireOwner(tx, orgID, actorID); err != nil {
+				return err
+			}
+		}
+		if current.Role == "owner" && role != "owner" {
+			if err := s.requireAnotherOwner(tx, orgID); err != nil {
+				return err
+			}
+		}
+		if err := s.Repo.UpdateMemberRole(tx, orgID, userID, role); err != nil {
+			return err
+		}
+		return s.logOrgActivity(tx, actorID, orgID, "org_member_role_changed", fmt.Sprintf("Organization member %d is now %s", userID, role))
+	})
+}
+
+// RemoveMember removes a member, or lets one leave. Only owners remove other
+// owners, and the last owner cannot go.
+func (s *OrganizationService) RemoveMember(actorID, orgID, userID uint) error {
+	if actorID != userID {
+		if err := s.requireAdmin(orgID, actorID); err != nil {
+			return err
+		}
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		current, err := s.Repo.GetMember(tx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if current.Role == "owner" {
+			if actorID != userID {
+				if err := s.requireOwner(tx, orgID, actorID); err != nil {
+					return err
+				}
+			}
+			if err := s.requireAnotherOwner(tx, orgID); err != nil {
+				return err
+			}
+		}
+		if err := s.Repo.RemoveMember(tx, orgID, userID); err != nil {
+			return err
+		}
+		return s.logOrgActivity(tx, actorID, orgID, "org_member_removed", fmt.Sprintf("Organization member removed: %d", userID))
+	})
+}
+
+func (s *OrganizationService) GetSettings(orgID uint) (*models.Organization, error) {
+	return s.Repo.GetByID(s.DB, orgID)
+}
+
+func (s *OrganizationService) UpdateSettings(actorID, orgID uint, in OrgSettingsInput) (*models.Organization, error) {
+	if err := s.requireAdmin(orgID, actorID); err != nil {
+		return nil, err
+	}
+	org, err := s.Repo.GetByID(s.DB, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Name != nil {
+		if strings.TrimSpace(*in.Name) == "" {
+			return nil, errors.New("name required")
+		}
+		org.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.AllowedAuthMethods != nil {
+		if len(in.AllowedAuthMethods) == 0 {
+			return nil, errors.New("at least one auth method must be allowed")
+		}
+		for _, m := range in.AllowedAuthMethods {
+			if !supportedAuthMethods[m] {
+				return nil, fmt.Errorf("unsupported auth method: %s", m)
+			}
+		}
+		org.AllowedAuthMethods = strings.Join(in.AllowedAuthMethods, ",")
+	}
+	if in.PasswordMinLength != nil {
+		if *in.PasswordMinLength < 8 || *in.PasswordMinLength > 128 {
+			return nil, errors.New("password_min_length must be between 8 and 128")
+		}
+		org.PasswordMinLength = *in.PasswordMinLength
+	}
+	if in.PasswordRequireMixed != nil {
+		org.PasswordRequireMix = *in.PasswordRequireMixed
+	}
+	if in.PasswordRequireNumber != nil {
+		org.PasswordRequireNum = *in.PasswordRequireNumber
+	}
+	if in.PasswordRequireSymbol != nil {
+		org.PasswordRequireSym = *in.PasswordRequireSymbol
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, org); err != nil {
+			return err
+		}
+		return s.logOrgActivity(tx, actorID, orgID, "org_settings_updated", "Organization settings updated: "+org.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// PasswordPolicy returns the password requirements of the organization.
+func (s *OrganizationService) PasswordPolicy(orgID uint) (utils.PasswordPolicy, error) {
+	org, err := s.Repo.GetByID(s.DB, orgID)
+	if err != nil {
+		return utils.PasswordPolicy{}, err
+	}
+	return utils.PasswordPolicy{
+		MinLength:        org.PasswordMinLength,
+		RequireMixedCase: org.PasswordRequireMix,
+		RequireNumber:    org.PasswordRequireNum,
+		RequireSymbol:    org.PasswordRequireSym,
+	}, nil
+}
+
+// BackfillPersonalOrgs gives every user created before organizations existed a
+// personal organization and moves their existing keys and teams into it. A
+// moved team's members join its owner's organization so they keep access, and
+// keys shared with the team from another organization are detached from it.
+func (s *OrganizationService) BackfillPersonalOrgs() error {
+	users, err := s.Repo.ListUsersWithoutOrg(s.DB)
+	if err != nil {
+		return err
+	}
+	var teamIDs []uint
+	if err := s.DB.Model(&models.Team{}).Where("organization_id = 0").Pluck("id", &teamIDs).Error; err != nil {
+		return err
+	}
+
+	for i := range users {
+		user := users[i]
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			org, err := s.CreatePersonal(tx, &user)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.APIKey{}).
+				Where("owner_id = ? AND organization_id = 0", user.ID).
+				Update("organization_id", org.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Team{}).
+				Where("owner_id = ? AND organization_id = 0", user.ID).
+				Update("organization_id", org.ID).Error
+		})
+		if err != nil {
+			return fmt.Errorf("backfill organization for user %d: %w", user.ID, err)
+		}
+	}
+	if len(teamIDs) == 0 {
+		return nil
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var members []struct {
+			OrganizationID uint
+			UserID         uint
+		}
+		if err := tx.Table("team_memberships tm").
+			Select("DISTINCT t.organization_id, tm.user_id").
+			Joins("JOIN teams t ON t.id = tm.team_id").
+			Where("t.id IN ? AND t.organization_id <> 0", teamIDs).
+			Scan(&members).Error; err != nil {
+			return err
+		}
+		for _, m := range members {
+			if err := s.EnsureMember(tx, m.OrganizationID, m.UserID); err != nil {
+				return fmt.Errorf("backfill membership of user %d: %w", m.UserID, err)
+			}
+		}
+
+		res := tx.Exec(`DELETE FROM api_key_teams akt USING api_keys k, teams t
+			WHERE akt.api_key_id = k.id AND akt.team_id = t.id
+			AND t.id IN ? AND k.organization_id <> t.organization_id`, teamIDs)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			fmt.Printf("organization backfill: detached %d keys shared with teams of another organization\n", res.RowsAffected)
+		}
+		return nil
+	})
+}
+
+func (s *OrganizationService) create(tx *gorm.DB, ownerID uint, name, slug string) (*models.Organization, error) {
+	org := &models.Organization{
+		Name:               name,
+		Slug:               slug,
+		AllowedAuthMethods: "password",
+		PasswordMinLength:  defaultPasswordPolicy.MinLength,
+		PasswordRequireMix: defaultPasswordPolicy.RequireMixedCase,
+		PasswordRequireNum: defaultPasswordPolicy.RequireNumber,
+		PasswordRequireSym: defaultPasswordPolicy.RequireSymbol,
+	}
+	if err := s.Repo.Create(tx, org); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.AddMember(tx, &models.OrganizationMember{OrganizationID: org.ID, UserID: ownerID, Role: "owner"}); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *OrganizationService) uniqueSlug(tx *gorm.DB, name string) (string, error) {
+	base := strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if base == "" {
+		base = "org"
+	}
+	slug := base
+	for i := 2; ; i++ {
+		exists, err := s.Repo.SlugExists(tx, slug)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+func (s *OrganizationService) requireAdmin(orgID, userID uint) error {
+	return requireOrgAdmin(s.Repo, s.DB, orgID, userID)
+}
+
+// requireOrgAdmin fails unless the user is an owner or admin of the org.
+func requireOrgAdmin(orgs repository.OrganizationRepository, db *gorm.DB, orgID, userID uint) error {
+	m, err := orgs.GetMember(db, orgID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotOrgMember
+		}
+		return err
+	}
+	if m.Role != "owner" && m.Role != "admin" {
+		return ErrNotOrgAdmin
+	}
+	return nil
+}
+
+func (s *OrganizationService) requireOwner(tx *gorm.DB, orgID, userID uint) error {
+	m, err := s.Repo.GetMember(tx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if m.Role != "owner" {
+		return ErrNotOrgOwner
+	}
+	return nil
+}
+
+func (s *OrganizationService) requireAnotherOwner(tx *gorm.DB, orgID uint) error {
+	owners, err := s.Repo.CountOwners(tx, orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errors.New("an organization must keep at least one owner")
+	}
+	return nil
+}
+
+func (s *OrganizationService) logOrgActivity(db *gorm.DB, userID, orgID uint, activityType, message string) error {
+	return enqueueActivity(db, &models.Activity{
+		UserID:         userID,
+		Type:           activityType,
+		Entity:         "organization",
+		EntityID:       orgID,
+		Message:        message,
+		OrganizationID: orgID,
+	})
+}
+
+func containsMethod(allowed, method string) bool {
+	for _, m := range strings.Split(allowed, ",") {
+		if strings.TrimSpace(m) == method {
+			return true
+		}
+	}
+	return false
+}
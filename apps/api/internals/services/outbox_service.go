@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/siem"
+	"gorm.io/gorm"
+)
+
+// Outbox topics.
+const (
+	TopicActivity     = "activity.recorded"    // payload: models.Activity
+	TopicNotification = "notification.created" // payload: models.Notification
+)
+
+const (
+	outboxBatch       = 100
+	outboxLease       = 2 * time.Minute
+	outboxMaxBackoff  = time.Hour
+	outboxRetention   = 7 * 24 * time.Hour
+	outboxPurgeEvery  = time.Hour
+	outboxMaxAttempts = 25
+)
+
+// Event is an outbox event as subscribers see it. ID is stable across
+// redeliveries, so subscribers use it to drop repeats.
+type Event struct {
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Subscriber receives outbox events. Deliver may be called more than once for
+// the same event and must tolerate that. Name must stay stable: deliveries
+// are tracked by it.
+type Subscriber interface {
+	Name() string
+	Handles(topic string) bool
+	Deliver(ctx context.Context, e Event) error
+}
+
+// enqueueEvent writes an event in db, which should be the transaction making
+// the change the event describes.
+func enqueueEvent(db *gorm.DB, topic string, payload any) (string, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	e := &models.OutboxEvent{
+		EventID:     hex.EncodeToString(id),
+		Topic:       topic,
+		Payload:     string(b),
+		AvailableAt: time.Now(),
+	}
+	return e.EventID, db.Create(e).Error
+}
+
+// enqueueActivity queues an activity for the audit log in db, so it is
+// recorded exactly when db's transaction commits. The request context is
+// captured now, while it is still known.
+func enqueueActivity(db *gorm.DB, a *models.Activity) error {
+	a.StampRequest(audit.MetaFrom(db.Statement.Context))
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	}
+	_, err := enqueueEvent(db, TopicActivity, a)
+	return err
+}
+
+// recordActivity is enqueueActivity for callers outside a transaction, where
+// a failure to log should not fail the action.
+func recordActivity(db *gorm.DB, a *models.Activity) {
+	if err := enqueueActivity(db, a); err != nil {
+		fmt.Printf("failed to log activity: %v\n", err)
+	}
+}
+
+// OutboxService delivers outbox events to subscribers, at least once, retrying
+// failed deliveries with backoff. Several instances can run side by side:
+// each event is claimed by one dispatcher at a time.
+type OutboxService struct {
+	Repo        repository.OutboxRepository
+	DB          *gorm.DB
+	Subscribers []Subscriber
+	Interval    time.Duration
+}
+
+func NewOutboxService(repo repository.OutboxRepository, db *gorm.DB, interval time.Duration) *OutboxService {
+	return &OutboxService{Repo: repo, DB: db, Interval: interval}
+}
+
+// Subscribe adds a subscriber. Call it before Start.
+func (s *OutboxService) Subscribe(sub Subscriber) {
+	s.Subscribers = append(s.Subscribers, sub)
+}
+
+// Start dispatches due events every Interval until ctx is done, and drops
+// delivered events after a week.
+func (s *OutboxService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		var lastPurge time.Time
+		for {
+			now := time.Now()
+			if _, err := s.RunOnce(ctx, now); err != nil {
+				fmt.Printf("outbox dispatch failed: %v\n", err)
+			}
+			if now.Sub(lastPurge) >= outboxPurgeEvery {
+				if err := s.Repo.PurgeDelivered(s.DB, now.Add(-outboxRetention)); err != nil {
+					fmt.Printf("outbox purge failed: %v\n", err)
+				}
+				lastPurge = now
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce dispatches the events that are due, oldest first, and returns how
+// many were fully delivered.
+func (s *OutboxService) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	events, err := s.Repo.ListDue(s.DB, now, outboxBatch)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for i := range events {
+		e := &events[i]
+		ok, err := s.Repo.Claim(s.DB, e.ID, now, now.Add(outboxLease))
+		if err != nil {
+			return delivered, err
+		}
+		if !ok {
+			continue
+		}
+		if s.dispatch(ctx, e) {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// dispatch hands one claimed event to every subscriber that still needs it
+// and records the outcome. It reports whether the event is now delivered.
+func (s *OutboxService) dispatch(ctx context.Context, e *models.OutboxEvent) bool {
+	var failures []string
+	done, err := s.Repo.DeliveredTo(s.DB, e.EventID)
+	if err != nil {
+		failures = append(failures, err.Error())
+	}
+
+	ev := Event{ID: e.EventID, Topic: e.Topic, Payload: json.RawMessage(e.Payload), CreatedAt: e.CreatedAt}
+	for _, sub := range s.Subscribers {
+		if err != nil || done[sub.Name()] || !sub.Handles(e.Topic) {
+			continue
+		}
+		if derr := deliver(ctx, sub, ev); derr != nil {
+			failures = append(failures, sub.Name()+": "+derr.Error())
+			continue
+		}
+		if merr := s.Repo.MarkDelivered(s.DB, e.EventID, sub.Name(), time.Now()); merr != nil {
+			failures = append(failures, sub.Name()+": "+merr.Error())
+		}
+	}
+
+	now := time.Now()
+	e.LockedUntil = nil
+	if len(failures) == 0 {
+		e.DeliveredAt = &now
+		e.LastError = ""
+	} else {
+		e.Attempts++
+		e.LastError = truncate(strings.Join(failures, "; "), 1024)
+		if e.Attempts >= outboxMaxAttempts {
+			e.DeadAt = &now
+			fmt.Printf("outbox event %s (%s) dropped after %d attempts: %s\n", e.EventID, e.Topic, e.Attempts, e.LastError)
+		} else {
+			e.AvailableAt = now.Add(outboxBackoff(e.Attempts))
+		}
+	}
+	if err := s.Repo.Update(s.DB, e); err != nil {
+		fmt.Printf("failed to update outbox event %s: %v\n", e.EventID, err)
+	}
+	return e.DeliveredAt != nil
+}
+
+// deliver calls a subscriber, turning a panic into an error so one bad
+// subscriber cannot stop the dispatcher.
+func deliver(ctx context.Context, sub Subscriber, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.Deliver(ctx, e)
+}
+
+// outboxBackoff doubles from 2s up to an hour.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 11 {
+		return outboxMaxBackoff
+	}
+	d := time.Duration(1<<attempts) * time.Second
+	if d > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return d
+}
+
+// AuditStore is the subscriber that writes activities to the audit log.
+// Redelivered events are recognised by their event id and skipped.
+type AuditStore struct {
+	DB *gorm.DB
+}
+
+func NewAuditStore(db *gorm.DB) *AuditStore { return &AuditStore{DB: db} }
+
+func (s *AuditStore) Name() string { return "audit_store" }
+
+func (s *AuditStore) Handles(topic string) bool { return topic == TopicActivity }
+
+func (s *AuditStore) Deliver(ctx context.Context, e Event) error {
+	var a models.Activity
+	if err := json.Unmarshal(e.Payload, &a); err != nil {
+		return err
+	}
+	a.ID, a.Seq, a.PrevHash, a.Hash = 0, 0, "", ""
+	a.EventID = e.ID
+
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&models.Activity{}).Where("event_id = ?", e.ID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+		return tx.Create(&a).Error
+	})
+}
+
+// ChannelSubscriber sends notifications through an external channel such as
+// a chat webhook, with the outbox's retries.
+type ChannelSubscriber struct {
+	Channel NotificationChannel
+}
+
+func NewChannelSubscriber(ch NotificationChannel) *ChannelSubscriber {
+	return &ChannelSubscriber{Channel: ch}
+}
+
+func (s *ChannelSubscriber) Name() string { return "notify_" + s.Channel.Name() }
+
+func (s *ChannelSubscriber) Handles(topic string) bool { return topic == TopicNotification }
+
+func (s *ChannelSubscriber) Deliver(ctx context.Context, e Event) error {
+	var n models.Notification
+	if err := json.Unmarshal(e.Payload, &n); err != nil {
+		return err
+	}
+	return s.Channel.Send(&n)
+}
+
+// SinkSubscriber forwards activities to an external audit sink such as a
+// SIEM. It sends the row the audit store wrote, so the entry carries its
+// place in the chain (seq and hashes); the audit store is subscribed first,
+// and until the row exists the outbox retries. The forwarder buffers and
+// retries on its own; the outbox only retries an activity the forwarder
+// could not take in.
+type SinkSubscriber struct {
+	Forwarder *siem.Forwarder
+	DB        *gorm.DB
+}
+
+var errNotSealed = errors.New("activity is not in the audit log yet")
+
+func NewSinkSubscriber(f *siem.Forwarder, db *gorm.DB) *SinkSubscriber {
+	return &SinkSubscriber{Forwarder: f, DB: db}
+}
+
+func (s *SinkSubscriber) Name() string { return "sink_" + s.Forwarder.Name() }
+
+func (s *SinkSubscriber) Handles(topic string) bool { return topic == TopicActivity }
+
+func (s *SinkSubscriber) Deliver(ctx context.Context, e Event) error {
+	var a models.Activity
+	err := s.DB.WithContext(ctx).Where("event_id = ?", e.ID).First(&a).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && a.Hash == "") {
+		return errNotSealed
+	}
+	if err != nil {
+		return err
+	}
+	return s.Forwarder.Enqueue(a.AuditEntry())
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+)
+
+// countingSubscriber counts deliveries per event and fails the first fail of them.
+type countingSubscriber struct {
+	name  string
+	fail  int
+	calls map[string]int
+}
+
+func (s *countingSubscriber) Name() string { return s.name }
+
+func (s *countingSubscriber) Handles(topic string) bool { return topic == TopicActivity }
+
+func (s *countingSubscriber) Deliver(ctx context.Context, e Event) error {
+	s.calls[e.ID]++
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestOutboxRetrySkipsSubscribersAlreadyDelivered(t *testing.T) {
+	db := testDB(t)
+	svc := NewOutboxService(repository.NewOutboxRepository(), db, time.Second)
+	steady := &countingSubscriber{name: "steady", calls: map[string]int{}}
+	flaky := &countingSubscriber{name: "flaky", fail: 1, calls: map[string]int{}}
+	svc.Subscribe(steady)
+	svc.Subscribe(flaky)
+
+	if err := enqueueActivity(db, &models.Activity{UserID: 1, Type: "apikey_created", Message: "API key created: stripe"}); err != nil {
+		t.Fatalf("enqueueActivity: %v", err)
+	}
+
+	ctx, now := context.Background(), time.Now()
+	if n, err := svc.RunOnce(ctx, now); err != nil || n != 0 {
+		t.Fatalf("first RunOnce = %d, %v; want 0 delivered", n, err)
+	}
+	if n, err := svc.RunOnce(ctx, now.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("retry RunOnce = %d, %v; want 1 delivered", n, err)
+	}
+	if n, err := svc.RunOnce(ctx, now.Add(time.Hour)); err != nil || n != 0 {
+		t.Fatalf("RunOnce after delivery = %d, %v; want nothing left", n, err)
+	}
+
+	for _, sub := range []*countingSubscriber{steady, flaky} {
+		if len(sub.calls) != 1 {
+			t.Fatalf("%s saw %d events, want 1", sub.name, len(sub.calls))
+		}
+	}
+	for id, n := range steady.calls {
+		if n != 1 {
+			t.Errorf("steady got event %s %d times, want once", id, n)
+		}
+		if flaky.calls[id] != 2 {
+			t.Errorf("flaky got event %s %d times, want twice", id, flaky.calls[id])
+		}
+	}
+}
+
+func TestAuditStoreDropsRedeliveries(t *testing.T) {
+	db := testDB(t, &models.Activity{})
+	store := NewAuditStore(db)
+
+	a := &models.Activity{UserID: 1, Type: "apikey_revealed", Entity: "apikey", EntityID: 7, Message: "API key revealed: stripe"}
+	if err := enqueueActivity(db, a); err != nil {
+		t.Fatalf("enqueueActivity: %v", err)
+	}
+	var e models.OutboxEvent
+	if err := db.First(&e).Error; err != nil {
+		t.Fatalf("load event: %v", err)
+	}
+	ev := Event{ID: e.EventID, Topic: e.Topic, Payload: []byte(e.Payload), CreatedAt: e.CreatedAt}
+
+	for i := 0; i < 2; i++ {
+		if err := store.Deliver(context.Background(), ev); err != nil {
+			t.Fatalf("Deliver #%d: %v", i+1, err)
+		}
+	}
+	var n int64
+	if err := db.Model(&models.Activity{}).Where("event_id = ?", e.EventID).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d audit rows for one event, want 1", n)
+	}
+}
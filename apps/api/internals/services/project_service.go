@@ -0,0 +1,535 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEnvironmentForbidden = errors.New("you do not have access to this environment")
+	ErrNotProjectAdmin      = errors.New("only organization admins and the project creator can do this")
+)
+
+// defaultEnvironments are created with every project unless others are given.
+var defaultEnvironments = []string{"dev", "staging", "prod"}
+
+// Access levels for environment and folder grants, in increasing order of privilege.
+const (
+	AccessNone  = ""
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+type ProjectService struct {
+	Repo      repository.ProjectRepository
+	Keys      repository.APIKeyRepository
+	Orgs      repository.OrganizationRepository
+	Teams     repository.TeamRepository
+	DB        *gorm.DB
+	MasterKey []byte
+}
+
+type CreateProjectInput struct {
+	OrganizationID uint
+	UserID         uint
+	Name           string
+	Description    string
+	Environments   []string
+}
+
+type SetSecretInput struct {
+	OrganizationID uint
+	UserID         uint
+	EnvironmentID  uint
+	Name           string
+	Value          string
+	Description    string
+}
+
+type EnvironmentSummary struct {
+	models.Environment
+	Access string `json:"access"`
+}
+
+type ProjectDetail struct {
+	models.Project
+	Environments []EnvironmentSummary `json:"environments"`
+}
+
+// EnvSecretSummary describes a secret in an environment without its value.
+type EnvSecretSummary struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Fingerprint string    `json:"fingerprint"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EnvDiffEntry is one secret name in an environment comparison. Status is
+// "same", "differs", "missing_in_target" or "missing_in_source".
+type EnvDiffEntry struct {
+	Name              string `json:"name"`
+	Status            string `json:"status"`
+	SourceFingerprint string `json:"source_fingerprint,omitempty"`
+	TargetFingerprint string `json:"target_fingerprint,omitempty"`
+}
+
+type EnvComparison struct {
+	Source  models.Environment `json:"source"`
+	Target  models.Environment `json:"target"`
+	Entries []EnvDiffEntry     `json:"entries"`
+}
+
+func NewProjectService(repo repository.ProjectRepository, keys repository.APIKeyRepository, orgs repository.OrganizationRepository, teams repository.TeamRepository, db *gorm.DB, masterKey []byte) *ProjectService {
+	return &ProjectService{Repo: repo, Keys: keys, Orgs: orgs, Teams: teams, DB: db, MasterKey: masterKey}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *ProjectService) WithContext(ctx context.Context) *ProjectService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+func (s *ProjectService) CreateProject(in CreateProjectInput) (*models.Project, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, errors.New("project name required")
+	}
+	if _, err := s.orgRole(in.OrganizationID, in.UserID); err != nil {
+		return nil, err
+	}
+
+	envNames := in.Environments
+	if len(envNames) == 0 {
+		envNames = defaultEnvironments
+	}
+
+	p := &models.Project{
+		OrganizationID: in.OrganizationID,
+		Name:           name,
+		Description:    in.Description,
+		CreatedBy:      in.UserID,
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.CreateProject(tx, p); err != nil {
+			return err
+		}
+		for i, envName := range envNames {
+			envName = strings.TrimSpace(envName)
+			if envName == "" {
+				return errors.New("environment name required")
+			}
+			env := models.Environment{ProjectID: p.ID, Name: envName, Position: i}
+			if err := s.Repo.CreateEnvironment(tx, &env); err != nil {
+				return err
+			}
+			p.Environments = append(p.Environments, env)
+		}
+		return s.logActivity(tx, in.UserID, "project", p.ID, "project_created", "Project created: "+p.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *ProjectService) ListProjects(orgID, userID uint) ([]models.Project, error) {
+	if _, err := s.orgRole(orgID, userID); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListProjects(s.DB, orgID)
+}
+
+// GetProject returns the project with the caller's access level on each environment.
+func (s *ProjectService) GetProject(orgID, userID, projectID uint) (*ProjectDetail, error) {
+	p, err := s.Repo.GetProject(s.DB, orgID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	envs, err := s.Repo.ListEnvironments(s.DB, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &ProjectDetail{Project: *p, Environments: make([]EnvironmentSummary, 0, len(envs))}
+	for _, env := range envs {
+		access, err := s.envAccess(p, env.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		detail.Environments = append(detail.Environments, EnvironmentSummary{Environment: env, Access: access})
+	}
+	return detail, nil
+}
+
+func (s *ProjectService) AddEnvironment(orgID, userID, projectID uint, name string) (*models.Environment, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("environment name required")
+	}
+	p, err := s.Repo.GetProject(s.DB, orgID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireProjectAdmin(p, userID); err != nil {
+		return nil, err
+	}
+
+	envs, err := s.Repo.ListEnvironments(s.DB, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	env := &models.Environment{ProjectID: p.ID, Name: name, Position: len(envs)}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.CreateEnvironment(tx, env); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "project", p.ID, "environment_created", fmt.Sprintf("Environment %s added to project %s", env.Name, p.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// SetPermission grants a team read or write access on an environment; access
+// "none" removes the grant.
+func (s *ProjectService) SetPermission(orgID, userID, envID, teamID uint, access string) error {
+	env, p, err := s.environment(orgID, envID)
+	if err != nil {
+		return err
+	}
+	if err := s.requireProjectAdmin(p, userID); err != nil {
+		return err
+	}
+	team, err := s.Teams.GetByID(s.DB, orgID, teamID)
+	if err != nil {
+		return err
+	}
+
+	if access != "none" && access != AccessRead && access != AccessWrite {
+		return errors.New("access must be read, write or none")
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if access == "none" {
+			if err := s.Repo.DeletePermission(tx, env.ID, team.ID); err != nil {
+				return err
+			}
+		} else {
+			perm := &models.EnvironmentPermission{EnvironmentID: env.ID, TeamID: team.ID, Access: access, GrantedBy: userID}
+			if err := s.Repo.UpsertPermission(tx, perm); err != nil {
+				return err
+			}
+		}
+		return s.logActivity(tx, userID, "environment", env.ID, "environment_permission_changed",
+			fmt.Sprintf("Team %s access on %s/%s set to %s", team.Name, p.Name, env.Name, access))
+	})
+}
+
+func (s *ProjectService) ListPermissions(orgID, userID, envID uint) ([]repository.EnvPermissionRow, error) {
+	env, p, err := s.environment(orgID, envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireProjectAdmin(p, userID); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListPermissions(s.DB, env.ID)
+}
+
+// SetSecret creates the named secret in the environment or replaces its value.
+func (s *ProjectService) SetSecret(in SetSecretInput) (*EnvSecretSummary, error) {
+	if in.Name == "" || in.Value == "" {
+		return nil, errors.New("name and value required")
+	}
+	env, p, err := s.environment(in.OrganizationID, in.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireAccess(p, env.ID, in.UserID, AccessWrite); err != nil {
+		return nil, err
+	}
+
+	ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, in.Value)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := utils.Fingerprint(s.MasterKey, in.Value)
+
+	key, err := s.Keys.FindInEnvironment(s.DB, env.ID, in.Name)
+	activityType := "apikey_updated"
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		activityType = "apikey_created"
+		key = &models.APIKey{
+			Name:           in.Name,
+			OrganizationID: in.OrganizationID,
+			ProjectID:      &p.ID,
+			EnvironmentID:  &env.ID,
+			OwnerID:        in.UserID,
+			Ciphertext:     ct,
+			Nonce:          nonce,
+			Fingerprint:    fingerprint,
+			Description:    in.Description,
+		}
+	case err != nil:
+		return nil, err
+	default:
+		key.Ciphertext = ct
+		key.Nonce = nonce
+		key.Fingerprint = fingerprint
+		now := time.Now()
+		key.LastRotatedAt = &now
+		key.LastReminderAt = nil
+		if in.Description != "" {
+			key.Description = in.Description
+		}
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		save := s.Keys.Update
+		if key.ID == 0 {
+			save = s.Keys.Create
+		}
+		if err := save(tx, key); err != nil {
+			return err
+		}
+		return s.logActivity(tx, in.UserID, "apikey", key.ID, activityType, fmt.Sprintf("Secret %s set in %s/%s", key.Name, p.Name, env.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summarizeSecret(key), nil
+}
+
+func (s *ProjectService) ListSecrets(orgID, userID, envID uint) ([]EnvSecretSummary, error) {
+	env, p, err := s.environment(orgID, envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireAccess(p, env.ID, userID, AccessRead); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.Keys.ListByEnvironment(s.DB, env.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]EnvSecretSummary, 0, len(keys))
+	for i := range keys {
+		out = append(out, *summarizeSecret(&keys[i]))
+	}
+	return out, nil
+}
+
+func (s *ProjectService) RevealSecret(orgID, userID, envID uint, name string) (string, error) {
+	env, p, err := s.environment(orgID, envID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.requireAccess(p, env.ID, userID, AccessRead); err != nil {
+		if errors.Is(err, ErrEnvironmentForbidden) {
+			logDenied(s.DB, userID, "environment", env.ID, "no_access", fmt.Sprintf("Reveal denied for %s in %s/%s", name, p.Name, env.Name))
+		}
+		return "", err
+	}
+
+	key, err := s.Keys.FindInEnvironment(s.DB, env.ID, name)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.logActivity(s.DB, userID, "apikey", key.ID, "apikey_revealed", fmt.Sprintf("Secret revealed: %s in %s/%s", key.Name, p.Name, env.Name)); err != nil {
+		fmt.Printf("failed to log activity: %v\n", err)
+	}
+	return plaintext, nil
+}
+
+func (s *ProjectService) DeleteSecret(orgID, userID, envID uint, name string) error {
+	env, p, err := s.environment(orgID, envID)
+	if err != nil {
+		return err
+	}
+	if err := s.requireAccess(p, env.ID, userID, AccessWrite); err != nil {
+		return err
+	}
+
+	key, err := s.Keys.FindInEnvironment(s.DB, env.ID, name)
+	if err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Keys.DeleteByID(tx, key.ID); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "apikey", key.ID, "apikey_deleted", fmt.Sprintf("Secret deleted: %s in %s/%s", key.Name, p.Name, env.Name))
+	})
+}
+
+// Compare reports, by fingerprint only, which secrets are missing from or
+// differ between two environments of the same project.
+func (s *ProjectService) Compare(orgID, userID, sourceEnvID, targetEnvID uint) (*EnvComparison, error) {
+	source, p, err := s.environment(orgID, sourceEnvID)
+	if err != nil {
+		return nil, err
+	}
+	target, _, err := s.environment(orgID, targetEnvID)
+	if err != nil {
+		return nil, err
+	}
+	if source.ProjectID != target.ProjectID {
+		return nil, errors.New("environments belong to different projects")
+	}
+	for _, envID := range []uint{source.ID, target.ID} {
+		if err := s.requireAccess(p, envID, userID, AccessRead); err != nil {
+			return nil, err
+		}
+	}
+
+	sourceKeys, err := s.Keys.ListByEnvironment(s.DB, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	targetKeys, err := s.Keys.ListByEnvironment(s.DB, target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	targetByName := make(map[string]string, len(targetKeys))
+	for _, k := range targetKeys {
+		targetByName[k.Name] = k.Fingerprint
+	}
+
+	entries := make([]EnvDiffEntry, 0, len(sourceKeys)+len(targetKeys))
+	for _, k := range sourceKeys {
+		entry := EnvDiffEntry{Name: k.Name, SourceFingerprint: k.Fingerprint}
+		fp, ok := targetByName[k.Name]
+		switch {
+		case !ok:
+			entry.Status = "missing_in_target"
+		case fp == k.Fingerprint:
+			entry.Status = "same"
+			entry.TargetFingerprint = fp
+		default:
+			entry.Status = "differs"
+			entry.TargetFingerprint = fp
+		}
+		delete(targetByName, k.Name)
+		entries = append(entries, entry)
+	}
+	for _, k := range targetKeys {
+		if _, ok := targetByName[k.Name]; ok {
+			entries = append(entries, EnvDiffEntry{Name: k.Name, Status: "missing_in_source", TargetFingerprint: k.Fingerprint})
+		}
+	}
+
+	return &EnvComparison{Source: *source, Target: *target, Entries: entries}, nil
+}
+
+// environment loads an environment and its project, scoped to the org.
+func (s *ProjectService) environment(orgID, envID uint) (*models.Environment, *models.Project, error) {
+	env, err := s.Repo.GetEnvironment(s.DB, orgID, envID)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := s.Repo.GetProject(s.DB, orgID, env.ProjectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, p, nil
+}
+
+// envAccess resolves the user's access on an environment: org owners/admins and
+// the project creator can write everywhere, everyone else gets the highest
+// level granted to one of their teams.
+func (s *ProjectService) envAccess(p *models.Project, envID, userID uint) (string, error) {
+	role, err := s.orgRole(p.OrganizationID, userID)
+	if err != nil {
+		return AccessNone, err
+	}
+	if role == "owner" || role == "admin" || p.CreatedBy == userID {
+		return AccessWrite, nil
+	}
+
+	grants, err := s.Repo.ListUserAccess(s.DB, envID, userID)
+	if err != nil {
+		return AccessNone, err
+	}
+	access := AccessNone
+	for _, g := range grants {
+		if g == AccessWrite {
+			return AccessWrite, nil
+		}
+		if g == AccessRead {
+			access = AccessRead
+		}
+	}
+	return access, nil
+}
+
+func (s *ProjectService) requireAccess(p *models.Project, envID, userID uint, want string) error {
+	access, err := s.envAccess(p, envID, userID)
+	if err != nil {
+		return err
+	}
+	if access == AccessWrite || (want == AccessRead && access == AccessRead) {
+		return nil
+	}
+	return ErrEnvironmentForbidden
+}
+
+func (s *ProjectService) requireProjectAdmin(p *models.Project, userID uint) error {
+	role, err := s.orgRole(p.OrganizationID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "owner" || role == "admin" || p.CreatedBy == userID {
+		return nil
+	}
+	return ErrNotProjectAdmin
+}
+
+func (s *ProjectService) orgRole(orgID, userID uint) (string, error) {
+	m, err := s.Orgs.GetMember(s.DB, orgID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrNotOrgMember
+		}
+		return "", err
+	}
+	return m.Role, nil
+}
+
+func (s *ProjectService) logActivity(db *gorm.DB, userID uint, entity string, entityID uint, activityType, message string) error {
+	return enqueueActivity(db, &models.Activity{
+		UserID:   userID,
+		Type:     activityType,
+		Entity:   entity,
+		EntityID: entityID,
+		Message:  message,
+	})
+}
+
+func summarizeSecret(k *models.APIKey) *EnvSecretSummary {
+	return &EnvSecretSummary{
+		ID:          k.ID,
+		Name:        k.Name,
+		Description: k.Description,
+		Fingerprint: k.Fingerprint,
+		UpdatedAt:   k.UpdatedAt,
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
+)
+
+// ReminderLeadTime is how far ahead of an expiry or rotation date reminders start.
+const ReminderLeadTime = 7 * 24 * time.Hour
+
+// reminderRepeat is the minimum gap between two reminders about the same key.
+const reminderRepeat = 24 * time.Hour
+
+// Key due states, most urgent first.
+const (
+	DueExpired         = "expired"
+	DueRotationOverdue = "rotation_overdue"
+	DueExpiringSoon    = "expiring_soon"
+	DueRotationSoon    = "rotation_due_soon"
+)
+
+// DueKey is a key that needs attention, as shown on the dashboard.
+type DueKey struct {
+	ID    uint      `json:"id"`
+	Name  string    `json:"name"`
+	State string    `json:"state"`
+	DueAt time.Time `json:"dueAt"`
+}
+
+// ReminderService periodically notifies key owners about keys that are
+// expiring or due for rotation, and logs a rotation_due or expiry_due
+// activity for each reminder.
+type ReminderService struct {
+	Keys          repository.APIKeyRepository
+	Notifications *NotificationService
+	DB            *gorm.DB
+	Interval      time.Duration
+}
+
+func NewReminderService(keys repository.APIKeyRepository, notifications *NotificationService, db *gorm.DB, interval time.Duration) *ReminderService {
+	return &ReminderService{Keys: keys, Notifications: notifications, DB: db, Interval: interval}
+}
+
+// Start runs a reminder pass now and then every Interval until ctx is done.
+func (s *ReminderService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			n, err := s.RunOnce(time.Now())
+			if err != nil {
+				fmt.Printf("rotation reminders failed: %v\n", err)
+			}
+			if n > 0 {
+				fmt.Printf("sent %d rotation/expiry reminders\n", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce notifies the owners of every key that is due or overdue and has not
+// been reminded about in the last day. A key that fails is retried on the next
+// pass without holding back the others. It returns the number of keys
+// reminded and the failures.
+func (s *ReminderService) RunOnce(now time.Time) (int, error) {
+	keys, err := s.Keys.ListDueForReminder(s.DB, now.Add(ReminderLeadTime), now.Add(-reminderRepeat))
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := make([]uint, 0, len(keys))
+	var errs []error
+	for i := range keys {
+		k := &keys[i]
+		state, dueAt, ok := KeyDueState(k, now)
+		if !ok {
+			continue
+		}
+		owners, err := s.Keys.ListOwnerIDs(s.DB, k.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", k.ID, err))
+			continue
+		}
+		title, body := reminderText(k, state, dueAt)
+		err = s.DB.Transaction(func(tx *gorm.DB) error {
+			err := s.Notifications.NotifyTx(tx, owners, models.Notification{
+				Type:     "secret_" + state,
+				Title:    title,
+				Body:     body,
+				Entity:   "apikey",
+				EntityID: k.ID,
+			})
+			if err != nil {
+				return err
+			}
+			// logged too, so webhooks can subscribe to rotation_due and expiry_due
+			return enqueueActivity(tx, &models.Activity{
+				UserID:         k.OwnerID,
+				Type:           dueActivity(state),
+				Entity:         "apikey",
+				EntityID:       k.ID,
+				Message:        title,
+				OrganizationID: k.OrganizationID,
+			})
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", k.ID, err))
+			continue
+		}
+		reminded = append(reminded, k.ID)
+	}
+
+	if err := s.Keys.MarkReminded(s.DB, reminded, now); err != nil {
+		errs = append(errs, err)
+	}
+	return len(reminded), errors.Join(errs...)
+}
+
+// KeyDueState reports the most urgent expiry or rotation state of a key within
+// ReminderLeadTime of now, and the date it refers to.
+func KeyDueState(k *models.APIKey, now time.Time) (string, time.Time, bool) {
+	horizon := now.Add(ReminderLeadTime)
+
+	var rotationDue time.Time
+	hasRotation := k.RotateEveryDays > 0
+	if hasRotation {
+		last := k.CreatedAt
+		if k.LastRotatedAt != nil {
+			last = *k.LastRotatedAt
+		}
+		rotationDue = last.AddDate(0, 0, k.RotateEveryDays)
+	}
+
+	switch {
+	case k.ExpiresAt != nil && !k.ExpiresAt.After(now):
+		return DueExpired, *k.ExpiresAt, true
+	case hasRotation && !rotationDue.After(now):
+		return DueRotationOverdue, rotationDue, true
+	case k.ExpiresAt != nil && !k.ExpiresAt.After(horizon):
+		return DueExpiringSoon, *k.ExpiresAt, true
+	case hasRotation && !rotationDue.After(horizon):
+		return DueRotationSoon, rotationDue, true
+	}
+	return "", time.Time{}, false
+}
+
+// dueActivity is the activity type logged with a reminder.
+func dueActivity(state string) string {
+	if state == DueRotationOverdue || state == DueRotationSoon {
+		return "rotation_due"
+	}
+	return "expiry_due"
+}
+
+func reminderText(k *models.APIKey, state string, dueAt time.Time) (string, string) {
+	date := dueAt.Format("2006-01-02")
+	switch state {
+	case DueExpired:
+		return "Secret expired: " + k.Name, fmt.Sprintf("%s expired on %s. Replace it before something breaks.", k.Name, date)
+	case DueRotationOverdue:
+		return "Rotation overdue: " + k.Name, fmt.Sprintf("%s was due for rotation on %s.", k.Name, date)
+	case DueExpiringSoon:
+		return "Secret expiring soon: " + k.Name, fmt.Sprintf("%s expires on %s.", k.Name, date)
+	default:
+		return "Rotation due soon: " + k.Name, fmt.Sprintf("%s is due for rotation on %s.", k.Name, date)
+	}
+}
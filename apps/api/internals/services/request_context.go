@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"gorm.io/gorm"
+)
+
+// requestDB binds db to a request's context so the activities logged through
+// it record the request (see audit.Meta). Cancellation is dropped: work a
+// service has started is finished even if the client goes away.
+func requestDB(db *gorm.DB, ctx context.Context) *gorm.DB {
+	return db.WithContext(context.WithoutCancel(ctx))
+}
+
+// logDenied records an attempt refused by access control as an
+// "access_denied" activity. reason is a short code such as "no_access".
+func logDenied(db *gorm.DB, userID uint, entity string, entityID uint, reason, msg string) {
+	recordActivity(db, &models.Activity{
+		UserID:   userID,
+		Type:     "access_denied",
+		Entity:   entity,
+		EntityID: entityID,
+		Message:  truncate(msg, 255),
+		Outcome:  audit.OutcomeDenied,
+		Reason:   reason,
+	})
+}
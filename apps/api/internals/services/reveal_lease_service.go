@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRevealLeaseInvalid = errors.New("lease is no longer valid; fetch the secret again")
+	ErrLeaseNotRenewable  = errors.New("lease is not renewable")
+)
+
+// RevealLeaseService attaches a lease to every reveal so clients know how
+// long they may cache the value and when they must fetch it again.
+type RevealLeaseService struct {
+	Repo      repository.RevealLeaseRepository
+	Versions  repository.SecretVersionRepository
+	Keys      repository.APIKeyRepository
+	DB        *gorm.DB
+	MasterKey []byte
+	TTL       time.Duration // initial lease and default renewal
+	MaxTTL    time.Duration // how long a lease can be renewed for in total
+}
+
+func NewRevealLeaseService(repo repository.RevealLeaseRepository, versions repository.SecretVersionRepository, keys repository.APIKeyRepository, db *gorm.DB, masterKey []byte, ttl, maxTTL time.Duration) *RevealLeaseService {
+	return &RevealLeaseService{Repo: repo, Versions: versions, Keys: keys, DB: db, MasterKey: masterKey, TTL: ttl, MaxTTL: maxTTL}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *RevealLeaseService) WithContext(ctx context.Context) *RevealLeaseService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+// LeaseOptions narrows a lease for reveals made under a temporary grant.
+type LeaseOptions struct {
+	Renewable bool
+	NotAfter  *time.Time // e.g. the end of an access-request window
+}
+
+// RevealLeaseStatus is what a client polls to decide whether its cached
+// value is still good.
+type RevealLeaseStatus struct {
+	*models.RevealLease
+	Valid          bool `json:"valid"`
+	TTLSeconds     int  `json:"ttl_seconds"`
+	CurrentVersion int  `json:"current_version"`
+}
+
+// Issue records a lease for a value that was just revealed to the user.
+func (s *RevealLeaseService) Issue(orgID, userID uint, key *models.APIKey, plaintext string, opts LeaseOptions) (*models.RevealLease, error) {
+	v, err := currentSecretVersion(s.DB, s.Versions, s.MasterKey, key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	leaseID, err := utils.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	maxExp := now.Add(s.MaxTTL)
+	if !opts.Renewable {
+		maxExp = now.Add(s.TTL)
+	}
+	if opts.NotAfter != nil && opts.NotAfter.Before(maxExp) {
+		maxExp = *opts.NotAfter
+	}
+	exp := now.Add(s.TTL)
+	if exp.After(maxExp) {
+		exp = maxExp
+	}
+
+	lease := &models.RevealLease{
+		LeaseID:        leaseID,
+		OrganizationID: orgID,
+		APIKeyID:       key.ID,
+		UserID:         userID,
+		Version:        v.Version,
+		Fingerprint:    v.Fingerprint,
+		Renewable:      opts.Renewable && exp.Before(maxExp),
+		ExpiresAt:      exp,
+		MaxExpiresAt:   maxExp,
+	}
+	if err := s.Repo.Create(s.DB, lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Lookup reports whether a lease is still valid, for its holder or anyone
+// who manages the key.
+func (s *RevealLeaseService) Lookup(orgID, userID uint, leaseID string) (*RevealLeaseStatus, error) {
+	lease, err := s.visibleLease(orgID, userID, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	st := &RevealLeaseStatus{RevealLease: lease, CurrentVersion: lease.Version}
+	if err := s.check(lease); err != nil {
+		if !errors.Is(err, ErrRevealLeaseInvalid) {
+			return nil, err
+		}
+	} else {
+		st.Valid = true
+		st.TTLSeconds = int(time.Until(lease.ExpiresAt) / time.Second)
+	}
+	if v, err := s.Versions.FindByState(s.DB, lease.APIKeyID, models.VersionCurrent); err == nil {
+		st.CurrentVersion = v.Version
+	}
+	return st, nil
+}
+
+// Renew extends a lease by increment (the default TTL when 0), up to its
+// maximum. A lease whose value changed or whose holder lost access to the
+// key is revoked instead.
+func (s *RevealLeaseService) Renew(orgID, userID uint, leaseID string, increment time.Duration) (*models.RevealLease, error) {
+	lease, err := s.Repo.Get(s.DB, orgID, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	if lease.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := s.check(lease); err != nil {
+		return nil, err
+	}
+	if !lease.Renewable {
+		return nil, ErrLeaseNotRenewable
+	}
+	if _, err := s.Keys.FindAccessibleByID(s.DB, orgID, userID, lease.APIKeyID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.revoke(lease, "access_removed")
+			return nil, ErrRevealLeaseInvalid
+		}
+		return nil, err
+	}
+
+	if increment <= 0 {
+		increment = s.TTL
+	}
+	exp := time.Now().Add(increment)
+	if exp.After(lease.MaxExpiresAt) {
+		exp = lease.MaxExpiresAt
+	}
+	lease.ExpiresAt = exp
+	lease.Renewable = exp.Before(lease.MaxExpiresAt)
+	if err := s.Repo.Update(s.DB, lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Revoke ends a lease early. Holders can revoke their own leases and key
+// managers any lease on their keys.
+func (s *RevealLeaseService) Revoke(orgID, userID uint, leaseID string) error {
+	lease, err := s.visibleLease(orgID, userID, leaseID)
+	if err != nil {
+		return err
+	}
+	if lease.RevokedAt != nil {
+		return nil
+	}
+	s.revoke(lease, "revoked")
+	return nil
+}
+
+// RevokeKey revokes every outstanding lease on a key the user manages, e.g.
+// after a suspected leak.
+func (s *RevealLeaseService) RevokeKey(orgID, userID, keyID uint) (int64, error) {
+	ok, err := s.Keys.CanManage(s.DB, orgID, userID, keyID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	var n int64
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if n, err = s.Invalidate(tx, keyID, "revoked"); err != nil {
+			return err
+		}
+		return enqueueActivity(tx, &models.Activity{
+			UserID:   userID,
+			Type:     "apikey_leases_revoked",
+			Entity:   "apikey",
+			EntityID: keyID,
+			Message:  fmt.Sprintf("Revoked %d outstanding leases", n),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Invalidate revokes all outstanding leases on a key, e.g. after a rotation.
+func (s *RevealLeaseService) Invalidate(db *gorm.DB, keyID uint, reason string) (int64, error) {
+	return s.Repo.RevokeForKey(db, keyID, reason, time.Now())
+}
+
+// check returns ErrRevealLeaseInvalid for revoked or expired leases and
+// revokes leases whose value has since changed.
+func (s *RevealLeaseService) check(lease *models.RevealLease) error {
+	if lease.RevokedAt != nil || !lease.ExpiresAt.After(time.Now()) {
+		return ErrRevealLeaseInvalid
+	}
+	key, err := s.Keys.FindByID(s.DB, lease.OrganizationID, lease.APIKeyID)
+	if err != nil {
+		return err
+	}
+	fp := key.Fingerprint
+	if fp == "" {
+		plaintext, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+		if err != nil {
+			return err
+		}
+		fp = utils.Fingerprint(s.MasterKey, plaintext)
+	}
+	if fp != lease.Fingerprint {
+		s.revoke(lease, "value_changed")
+		return ErrRevealLeaseInvalid
+	}
+	return nil
+}
+
+func (s *RevealLeaseService) revoke(lease *models.RevealLease, reason string) {
+	now := time.Now()
+	lease.RevokedAt = &now
+	lease.RevokeReason = reason
+	lease.Renewable = false
+	if err := s.Repo.Update(s.DB, lease); err != nil {
+		fmt.Printf("failed to revoke lease %s: %v\n", lease.LeaseID, err)
+	}
+}
+
+func (s *RevealLeaseService) visibleLease(orgID, userID uint, leaseID string) (*models.RevealLease, error) {
+	lease, err := s.Repo.Get(s.DB, orgID, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	if lease.UserID == userID {
+		return lease, nil
+	}
+	ok, err := s.Keys.CanManage(s.DB, orgID, userID, lease.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return lease, nil
+}
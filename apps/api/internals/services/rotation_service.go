@@ -0,0 +1,689 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/rotation"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoRotationPolicy   = errors.New("no rotation policy for this key")
+	ErrNoPreviousVersion  = errors.New("no previous version to roll back to")
+	ErrRotationInProgress = errors.New("a rotation of this key is already running")
+	ErrRotationFailed     = errors.New("rotation failed")
+)
+
+const (
+	// rotationLease bounds how long a crashed worker can hold a policy.
+	rotationLease       = 10 * time.Minute
+	rotationBatch       = 20
+	maxRotationAttempts = 10
+)
+
+// RotationService rotates secrets through pluggable rotators, keeping every
+// value as a SecretVersion so a bad rotation can be rolled back.
+type RotationService struct {
+	Repo          repository.RotationRepository
+	Versions      repository.SecretVersionRepository
+	Keys          repository.APIKeyRepository
+	Orgs          repository.OrganizationRepository
+	Leases        *RevealLeaseService
+	Notifications *NotificationService
+	DB            *gorm.DB
+	MasterKey     []byte
+	Interval      time.Duration
+	NewRotator    func(kind string, config json.RawMessage) (rotation.Rotator, error)
+}
+
+// NewRotationService builds rotators that may reach private addresses only
+// when allowPrivate is set, for development against local targets.
+func NewRotationService(repo repository.RotationRepository, versions repository.SecretVersionRepository, keys repository.APIKeyRepository, orgs repository.OrganizationRepository, leases *RevealLeaseService, notifications *NotificationService, db *gorm.DB, masterKey []byte, allowPrivate bool, interval time.Duration) *RotationService {
+	opts := rotation.Options{AllowPrivate: allowPrivate}
+	return &RotationService{
+		Repo:          repo,
+		Versions:      versions,
+		Keys:          keys,
+		Orgs:          orgs,
+		Leases:        leases,
+		Notifications: notifications,
+		DB:            db,
+		MasterKey:     masterKey,
+		Interval:      interval,
+		NewRotator: func(kind string, config json.RawMessage) (rotation.Rotator, error) {
+			return rotation.New(kind, config, opts)
+		},
+	}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *RotationService) WithContext(ctx context.Context) *RotationService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	c.Leases = s.Leases.WithContext(ctx)
+	return &c
+}
+
+type RotationPolicyInput struct {
+	Type        string
+	Config      json.RawMessage
+	EveryDays   int
+	MaxAttempts int
+	Enabled     bool
+}
+
+// RotationHistory is a key's policy with its versions and recent runs.
+type RotationHistory struct {
+	Policy   *models.RotationPolicy `json:"policy"`
+	Versions []models.SecretVersion `json:"versions"`
+	Runs     []models.RotationRun   `json:"runs"`
+}
+
+// Configure creates or replaces the rotation policy of a key. The rotator is
+// built once up front so bad settings are rejected before they are stored.
+func (s *RotationService) Configure(orgID, userID, keyID uint, in RotationPolicyInput) (*models.RotationPolicy, error) {
+	key, err := s.manageable(orgID, userID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if in.EveryDays < 0 {
+		return nil, errors.New("rotate_every must not be negative")
+	}
+	if in.MaxAttempts <= 0 {
+		in.MaxAttempts = 3
+	}
+	if in.MaxAttempts > maxRotationAttempts {
+		return nil, fmt.Errorf("max_attempts must be at most %d", maxRotationAttempts)
+	}
+	if _, err := s.NewRotator(in.Type, in.Config); err != nil {
+		return nil, fmt.Errorf("invalid rotator config: %w", err)
+	}
+	ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, string(in.Config))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.Repo.GetPolicy(s.DB, key.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		p = &models.RotationPolicy{OrganizationID: orgID, APIKeyID: key.ID, CreatedBy: userID}
+	} else if err != nil {
+		return nil, err
+	}
+	p.Type = in.Type
+	p.ConfigCiphertext = ct
+	p.ConfigNonce = nonce
+	p.EveryDays = in.EveryDays
+	p.MaxAttempts = in.MaxAttempts
+	p.Enabled = in.Enabled
+	p.FailedAttempts = 0
+	p.LastError = ""
+	p.NextRunAt = nil
+	if in.EveryDays > 0 {
+		last := key.CreatedAt
+		if key.LastRotatedAt != nil {
+			last = *key.LastRotatedAt
+		}
+		next := last.AddDate(0, 0, in.EveryDays)
+		p.NextRunAt = &next
+	}
+
+	// The key's own schedule drives reminders and the dashboard score, so
+	// it follows the policy.
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.SavePolicy(tx, p); err != nil {
+			return err
+		}
+		if err := s.Keys.SetLifecycle(tx, key.ID, key.ExpiresAt, in.EveryDays); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, key, "apikey_rotation_configured", fmt.Sprintf("%s rotation configured for %s", in.Type, key.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *RotationService) GetPolicy(orgID, userID, keyID uint) (*models.RotationPolicy, error) {
+	if _, err := s.manageable(orgID, userID, keyID); err != nil {
+		return nil, err
+	}
+	p, err := s.Repo.GetPolicy(s.DB, keyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoRotationPolicy
+	}
+	return p, err
+}
+
+func (s *RotationService) History(orgID, userID, keyID uint) (*RotationHistory, error) {
+	if _, err := s.manageable(orgID, userID, keyID); err != nil {
+		return nil, err
+	}
+	h := &RotationHistory{}
+	p, err := s.Repo.GetPolicy(s.DB, keyID)
+	if err == nil {
+		h.Policy = p
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if h.Versions, err = s.Versions.List(s.DB, keyID); err != nil {
+		return nil, err
+	}
+	if h.Runs, err = s.Repo.ListRuns(s.DB, keyID, 50); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// RotateNow rotates a key immediately, outside its schedule. A failed manual
+// rotation is reported to the caller and does not schedule retries.
+func (s *RotationService) RotateNow(ctx context.Context, orgID, userID, keyID uint) (*models.RotationRun, error) {
+	key, err := s.manageable(orgID, userID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.claim(key.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.rotate(ctx, key, p, &userID)
+}
+
+// Rollback makes the previous version current again, both at the target
+// system and in the vault.
+func (s *RotationService) Rollback(ctx context.Context, orgID, userID, keyID uint) (*models.RotationRun, error) {
+	key, err := s.manageable(orgID, userID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.claim(key.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := s.Repo.ReleasePolicy(s.DB, p.ID); err != nil {
+			fmt.Printf("failed to release rotation policy %d: %v\n", p.ID, err)
+		}
+	}()
+
+	rotator, err := s.rotatorFor(p)
+	if err != nil {
+		return nil, err
+	}
+	current, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := currentSecretVersion(s.DB, s.Versions, s.MasterKey, key, current)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := s.Versions.FindByState(s.DB, key.ID, models.VersionPrevious)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoPreviousVersion
+	}
+	if err != nil {
+		return nil, err
+	}
+	restore, err := utils.DecryptAPIKey(s.MasterKey, prev.Ciphertext, prev.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.RotationRun{
+		APIKeyID:    key.ID,
+		Kind:        "rollback",
+		Status:      "running",
+		FromVersion: cur.Version,
+		ToVersion:   prev.Version,
+		TriggeredBy: &userID,
+		StartedAt:   time.Now(),
+	}
+	if err := s.Repo.CreateRun(s.DB, run); err != nil {
+		return nil, err
+	}
+
+	err = rotator.Rollback(ctx, restore, current)
+	if err == nil {
+		now := time.Now()
+		err = s.DB.Transaction(func(tx *gorm.DB) error {
+			cur.State = models.VersionRolledBack
+			if err := s.Versions.Update(tx, cur); err != nil {
+				return err
+			}
+			prev.State = models.VersionCurrent
+			prev.PromotedAt = &now
+			if err := s.Versions.Update(tx, prev); err != nil {
+				return err
+			}
+			key.Ciphertext = prev.Ciphertext
+			key.Nonce = prev.Nonce
+			key.Fingerprint = prev.Fingerprint
+			if err := s.Keys.Update(tx, key); err != nil {
+				return err
+			}
+			_, err := s.Leases.Invalidate(tx, key.ID, "rotated")
+			return err
+		})
+		if err != nil {
+			// the target already holds the old value; put the new one back
+			// so it matches what the vault still stores
+			if rerr := rotator.Rollback(ctx, current, restore); rerr != nil {
+				err = fmt.Errorf("%v; restoring the target also failed: %v", err, rerr)
+			}
+		}
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = "rolled_back"
+	if err != nil {
+		run.Status = "failed"
+		run.Error = truncate(err.Error(), 1024)
+	}
+	typ, msg := "apikey_rotation_rolled_back", fmt.Sprintf("%s rolled back to version %d", key.Name, prev.Version)
+	if err != nil {
+		typ, msg = "apikey_rotation_failed", fmt.Sprintf("Rollback of %s to version %d failed", key.Name, prev.Version)
+	}
+	uerr := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.UpdateRun(tx, run); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, key, typ, msg)
+	})
+	if uerr != nil {
+		fmt.Printf("failed to record rotation run %d: %v\n", run.ID, uerr)
+	}
+	if err != nil {
+		return run, fmt.Errorf("%w: %v", ErrRotationFailed, err)
+	}
+	return run, nil
+}
+
+// Start runs due rotations now and then every Interval until ctx is done.
+func (s *RotationService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.RunDue(ctx, time.Now()); err != nil {
+				fmt.Printf("scheduled rotations failed: %v\n", err)
+			} else if n > 0 {
+				fmt.Printf("rotated %d secrets\n", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue rotates every key whose policy is due and returns how many
+// rotations succeeded. Individual failures are recorded on the policy and
+// retried with backoff rather than returned.
+func (s *RotationService) RunDue(ctx context.Context, now time.Time) (int, error) {
+	policies, err := s.Repo.ListDuePolicies(s.DB, now, rotationBatch)
+	if err != nil {
+		return 0, err
+	}
+	rotated := 0
+	for i := range policies {
+		p := &policies[i]
+		ok, err := s.Repo.ClaimPolicy(s.DB, p.ID, now, now.Add(rotationLease))
+		if err != nil {
+			return rotated, err
+		}
+		if !ok {
+			continue
+		}
+		key, err := s.Keys.FindByID(s.DB, p.OrganizationID, p.APIKeyID)
+		if err != nil {
+			s.Repo.ReleasePolicy(s.DB, p.ID)
+			return rotated, err
+		}
+		if _, err := s.rotate(ctx, key, p, nil); err == nil {
+			rotated++
+		}
+	}
+	return rotated, nil
+}
+
+// rotate runs one claimed rotation and records its outcome on the run, the
+// policy and the activity log. actor is nil for scheduled rotations.
+func (s *RotationService) rotate(ctx context.Context, key *models.APIKey, p *models.RotationPolicy, actor *uint) (*models.RotationRun, error) {
+	run := &models.RotationRun{
+		APIKeyID:    key.ID,
+		Kind:        "rotate",
+		Status:      "running",
+		Attempt:     p.FailedAttempts + 1,
+		TriggeredBy: actor,
+		StartedAt:   time.Now(),
+	}
+	if err := s.Repo.CreateRun(s.DB, run); err != nil {
+		s.Repo.ReleasePolicy(s.DB, p.ID)
+		return nil, err
+	}
+
+	err := s.stageAndPromote(ctx, key, p, run, actor)
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if err == nil {
+		run.Status = "promoted"
+	} else {
+		if run.Status == "running" {
+			run.Status = "failed"
+		}
+		run.Error = truncate(err.Error(), 1024)
+	}
+	if uerr := s.Repo.UpdateRun(s.DB, run); uerr != nil {
+		fmt.Printf("failed to record rotation run %d: %v\n", run.ID, uerr)
+	}
+	s.recordOutcome(key, p, run, actor)
+
+	if err != nil {
+		return run, fmt.Errorf("%w: %v", ErrRotationFailed, err)
+	}
+	return run, nil
+}
+
+// stageAndPromote asks the rotator for a new value, stores it as a staged
+// version, verifies it and then promotes it in one transaction. If
+// verification or promotion fails the target is rolled back.
+func (s *RotationService) stageAndPromote(ctx context.Context, key *models.APIKey, p *models.RotationPolicy, run *models.RotationRun, actor *uint) error {
+	rotator, err := s.rotatorFor(p)
+	if err != nil {
+		return err
+	}
+	current, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+	if err != nil {
+		return err
+	}
+	cur, err := currentSecretVersion(s.DB, s.Versions, s.MasterKey, key, current)
+	if err != nil {
+		return err
+	}
+	run.FromVersion = cur.Version
+
+	next, err := rotator.Rotate(ctx, current)
+	if err != nil {
+		return fmt.Errorf("rotate: %w", err)
+	}
+
+	ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, next)
+	if err != nil {
+		return s.abort(ctx, rotator, nil, run, current, next, err)
+	}
+	latest, err := s.Versions.LatestNumber(s.DB, key.ID)
+	if err != nil {
+		return s.abort(ctx, rotator, nil, run, current, next, err)
+	}
+	staged := &models.SecretVersion{
+		APIKeyID:    key.ID,
+		Version:     latest + 1,
+		State:       models.VersionStaged,
+		Ciphertext:  ct,
+		Nonce:       nonce,
+		Fingerprint: utils.Fingerprint(s.MasterKey, next),
+		CreatedBy:   actor,
+	}
+	if err := s.Versions.Create(s.DB, staged); err != nil {
+		return s.abort(ctx, rotator, nil, run, current, next, err)
+	}
+	run.ToVersion = staged.Version
+
+	if err := rotator.Verify(ctx, next); err != nil {
+		return s.abort(ctx, rotator, staged, run, current, next, fmt.Errorf("verify: %w", err))
+	}
+
+	now := time.Now()
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Versions.RetireState(tx, key.ID, models.VersionPrevious); err != nil {
+			return err
+		}
+		cur.State = models.VersionPrevious
+		if err := s.Versions.Update(tx, cur); err != nil {
+			return err
+		}
+		staged.State = models.VersionCurrent
+		staged.PromotedAt = &now
+		if err := s.Versions.Update(tx, staged); err != nil {
+			return err
+		}
+		key.Ciphertext = ct
+		key.Nonce = nonce
+		key.Fingerprint = staged.Fingerprint
+		key.LastRotatedAt = &now
+		key.LastReminderAt = nil
+		if err := s.Keys.Update(tx, key); err != nil {
+			return err
+		}
+		// clients holding the old value must refetch
+		_, err := s.Leases.Invalidate(tx, key.ID, "rotated")
+		return err
+	})
+	if err != nil {
+		return s.abort(ctx, rotator, staged, run, current, next, fmt.Errorf("promote: %w", err))
+	}
+	return nil
+}
+
+// abort restores the current value at the target after a failed stage.
+func (s *RotationService) abort(ctx context.Context, rotator rotation.Rotator, staged *models.SecretVersion, run *models.RotationRun, current, next string, cause error) error {
+	state := models.VersionRolledBack
+	if err := rotator.Rollback(ctx, current, next); err != nil {
+		cause = fmt.Errorf("%v; rollback also failed: %v", cause, err)
+		state = models.VersionFailed
+	} else {
+		run.Status = "rolled_back"
+	}
+	if staged != nil {
+		staged.State = state
+		if err := s.Versions.Update(s.DB, staged); err != nil {
+			fmt.Printf("failed to update secret version %d: %v\n", staged.ID, err)
+		}
+	}
+	return cause
+}
+
+// recordOutcome updates the policy schedule, releases its lock and logs the
+// result. Scheduled failures retry with backoff until MaxAttempts, after
+// which the key owners are notified and the policy waits for its next period.
+func (s *RotationService) recordOutcome(key *models.APIKey, p *models.RotationPolicy, run *models.RotationRun, actor *uint) {
+	now := time.Now()
+	p.LastRunAt = &now
+	p.LastStatus = run.Status
+	p.LastError = run.Error
+	p.LockedUntil = nil
+
+	userID := key.OwnerID
+	if actor != nil {
+		userID = *actor
+	}
+
+	notify := false
+	typ, msg := "apikey_rotated", fmt.Sprintf("%s rotated to version %d", key.Name, run.ToVersion)
+	if run.Status == "promoted" {
+		p.FailedAttempts = 0
+		p.NextRunAt = nextRotation(now, p.EveryDays)
+	} else {
+		typ, msg = "apikey_rotation_failed", fmt.Sprintf("Rotation of %s failed: %s", key.Name, truncate(run.Error, 160))
+		if actor == nil {
+			p.FailedAttempts++
+			if p.FailedAttempts >= p.MaxAttempts {
+				p.FailedAttempts = 0
+				p.NextRunAt = nextRotation(now, p.EveryDays)
+				notify = true
+			} else {
+				retry := now.Add(rotationBackoff(p.FailedAttempts))
+				p.NextRunAt = &retry
+			}
+		}
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.SavePolicy(tx, p); err != nil {
+			return err
+		}
+		if err := s.logActivity(tx, userID, key, typ, msg); err != nil {
+			return err
+		}
+		if notify {
+			return s.notifyFailure(tx, key, run)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("failed to update rotation policy %d: %v\n", p.ID, err)
+	}
+}
+
+func (s *RotationService) notifyFailure(tx *gorm.DB, key *models.APIKey, run *models.RotationRun) error {
+	owners, err := s.Keys.ListOwnerIDs(tx, key.ID)
+	if err != nil {
+		return err
+	}
+	return s.Notifications.NotifyTx(tx, owners, models.Notification{
+		Type:     "rotation_failed",
+		Title:    "Rotation failed: " + key.Name,
+		Body:     fmt.Sprintf("Automatic rotation of %s failed %d times: %s", key.Name, run.Attempt, run.Error),
+		Entity:   "apikey",
+		EntityID: key.ID,
+	})
+}
+
+// currentSecretVersion returns the version matching the key's stored value. When
+// the value was set or changed outside a rotation it is recorded as a new
+// current version, and older versions are retired so a rollback never skips
+// over a manual change.
+func currentSecretVersion(db *gorm.DB, versions repository.SecretVersionRepository, masterKey []byte, key *models.APIKey, plaintext string) (*models.SecretVersion, error) {
+	fp := key.Fingerprint
+	if fp == "" {
+		fp = utils.Fingerprint(masterKey, plaintext)
+	}
+	cur, err := versions.FindByState(db, key.ID, models.VersionCurrent)
+	if err == nil && cur.Fingerprint == fp {
+		return cur, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	v := &models.SecretVersion{
+		APIKeyID:    key.ID,
+		State:       models.VersionCurrent,
+		Ciphertext:  key.Ciphertext,
+		Nonce:       key.Nonce,
+		Fingerprint: fp,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, state := range []string{models.VersionCurrent, models.VersionPrevious} {
+			if err := versions.RetireState(tx, key.ID, state); err != nil {
+				return err
+			}
+		}
+		latest, err := versions.LatestNumber(tx, key.ID)
+		if err != nil {
+			return err
+		}
+		v.Version = latest + 1
+		return versions.Create(tx, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *RotationService) rotatorFor(p *models.RotationPolicy) (rotation.Rotator, error) {
+	config, err := utils.DecryptAPIKey(s.MasterKey, p.ConfigCiphertext, p.ConfigNonce)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewRotator(p.Type, json.RawMessage(config))
+}
+
+// claim loads the key's policy and takes its lock for a manual operation.
+func (s *RotationService) claim(keyID uint) (*models.RotationPolicy, error) {
+	p, err := s.Repo.GetPolicy(s.DB, keyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoRotationPolicy
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ok, err := s.Repo.ClaimPolicy(s.DB, p.ID, now, now.Add(rotationLease))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRotationInProgress
+	}
+	return p, nil
+}
+
+// manageable loads a key whose rotation the user may configure and run: its
+// owner's, or any key for an org admin. Team admins the key is shared with
+// are not enough, since a rotator decides where new values come from.
+func (s *RotationService) manageable(orgID, userID, keyID uint) (*models.APIKey, error) {
+	key, err := s.Keys.FindByID(s.DB, orgID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.OwnerID == userID {
+		return key, nil
+	}
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *RotationService) logActivity(db *gorm.DB, userID uint, key *models.APIKey, typ, msg string) error {
+	return enqueueActivity(db, &models.Activity{
+		UserID:   userID,
+		Type:     typ,
+		Entity:   "apikey",
+		EntityID: key.ID,
+		Message:  truncate(msg, 255),
+	})
+}
+
+func nextRotation(now time.Time, everyDays int) *time.Time {
+	if everyDays <= 0 {
+		return nil
+	}
+	next := now.AddDate(0, 0, everyDays)
+	return &next
+}
+
+// rotationBackoff doubles from one minute per failed attempt, capped at an hour.
+func rotationBackoff(failures int) time.Duration {
+	d := time.Minute << (failures - 1)
+	if d <= 0 || d > time.Hour {
+		return time.Hour
+	}
+	return d
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
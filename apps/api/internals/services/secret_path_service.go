@@ -0,0 +1,430 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPathTaken     = errors.New("a secret already exists at this path")
+	ErrPathForbidden = errors.New("you do not have access to this path")
+)
+
+// SecretPathService addresses secrets by folder path ("payments/stripe/live")
+// and resolves access from team grants on the folders above them. Reveals and
+// value changes go through APIKeys so approval, leases and secret versions apply.
+type SecretPathService struct {
+	Keys        repository.APIKeyRepository
+	Permissions repository.FolderPermissionRepository
+	Orgs        repository.OrganizationRepository
+	Teams       repository.TeamRepository
+	APIKeys     *APIKeyService
+	DB          *gorm.DB
+	MasterKey   []byte
+}
+
+type PutPathSecretInput struct {
+	OrganizationID uint
+	UserID         uint
+	Path           string
+	Value          string
+	Description    string
+}
+
+// PathEntry describes a secret in a folder listing without its value.
+type PathEntry struct {
+	ID          uint      `json:"id"`
+	Path        string    `json:"path"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	OwnerID     uint      `json:"owner_id"`
+	Access      string    `json:"access"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type FolderListing struct {
+	Folder  string      `json:"folder"`
+	Folders []string    `json:"folders"`
+	Secrets []PathEntry `json:"secrets"`
+}
+
+// pathAccess holds what is needed to resolve a user's access on any path in an org.
+type pathAccess struct {
+	userID uint
+	grants []models.FolderPermission
+	shared map[uint]bool // keys shared with the user's teams
+}
+
+func NewSecretPathService(keys repository.APIKeyRepository, perms repository.FolderPermissionRepository, orgs repository.OrganizationRepository, teams repository.TeamRepository, apiKeys *APIKeyService, db *gorm.DB, masterKey []byte) *SecretPathService {
+	return &SecretPathService{Keys: keys, Permissions: perms, Orgs: orgs, Teams: teams, APIKeys: apiKeys, DB: db, MasterKey: masterKey}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *SecretPathService) WithContext(ctx context.Context) *SecretPathService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	c.APIKeys = s.APIKeys.WithContext(ctx)
+	return &c
+}
+
+// List returns the secrets the user can read under a folder. Non-recursive
+// listings return direct children only, plus the subfolders that contain
+// readable secrets.
+func (s *SecretPathService) List(orgID, userID uint, folder string, recursive bool) (*FolderListing, error) {
+	folder, err := utils.NormalizePath(folder)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := s.access(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.Keys.ListUnderPath(s.DB, orgID, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &FolderListing{Folder: folder, Folders: []string{}, Secrets: []PathEntry{}}
+	subfolders := map[string]bool{}
+	for i := range keys {
+		k := &keys[i]
+		level := acc.levelFor(k)
+		if level == AccessNone {
+			continue
+		}
+
+		rest := strings.TrimPrefix(strings.TrimPrefix(k.Path, folder), "/")
+		if !recursive {
+			if i := strings.Index(rest, "/"); i >= 0 {
+				sub := rest[:i]
+				if folder != "" {
+					sub = folder + "/" + sub
+				}
+				subfolders[sub] = true
+				continue
+			}
+		}
+		listing.Secrets = append(listing.Secrets, PathEntry{
+			ID:          k.ID,
+			Path:        k.Path,
+			Name:        k.Name,
+			Description: k.Description,
+			Fingerprint: k.Fingerprint,
+			OwnerID:     k.OwnerID,
+			Access:      level,
+			UpdatedAt:   k.UpdatedAt,
+		})
+	}
+	for sub := range subfolders {
+		listing.Folders = append(listing.Folders, sub)
+	}
+	sort.Strings(listing.Folders)
+	return listing, nil
+}
+
+// Put creates the secret at a path or replaces its value. New secrets can be
+// created in folders nobody has been granted on, or where the user has write access.
+func (s *SecretPathService) Put(in PutPathSecretInput) (*PathEntry, error) {
+	path, err := utils.NormalizePath(in.Path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" || in.Value == "" {
+		return nil, errors.New("path and value required")
+	}
+	acc, err := s.access(in.OrganizationID, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.Keys.FindByPath(s.DB, in.OrganizationID, path)
+	activityType := "apikey_updated"
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := s.requireCreate(acc, in.OrganizationID, path); err != nil {
+			return nil, err
+		}
+		ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, in.Value)
+		if err != nil {
+			return nil, err
+		}
+		activityType = "apikey_created"
+		key = &models.APIKey{
+			Name:           utils.PathBase(path),
+			Path:           path,
+			OrganizationID: in.OrganizationID,
+			OwnerID:        in.UserID,
+			Ciphertext:     ct,
+			Nonce:          nonce,
+			Fingerprint:    utils.Fingerprint(s.MasterKey, in.Value),
+			Description:    in.Description,
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if acc.levelFor(key) != AccessWrite {
+			return nil, ErrPathForbidden
+		}
+		if in.Description != "" {
+			key.Description = in.Description
+		}
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if key.ID != 0 {
+			if err := s.APIKeys.updateValue(tx, key, in.Value, in.UserID); err != nil {
+				return err
+			}
+		} else if err := s.Keys.Create(tx, key); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrPathTaken
+			}
+			return err
+		}
+		return s.logActivity(tx, in.UserID, key.ID, activityType, "Secret set: "+key.Path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PathEntry{
+		ID:          key.ID,
+		Path:        key.Path,
+		Name:        key.Name,
+		Description: key.Description,
+		Fingerprint: key.Fingerprint,
+		OwnerID:     key.OwnerID,
+		Access:      AccessWrite,
+		UpdatedAt:   key.UpdatedAt,
+	}, nil
+}
+
+// Reveal reveals the secret at a path the user can read. Keys that require
+// approval are held behind an access request exactly as when revealed by id.
+func (s *SecretPathService) Reveal(orgID, userID uint, path, reason string) (*RevealResult, error) {
+	path, err := utils.NormalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := s.access(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.Keys.FindByPath(s.DB, orgID, path)
+	if err != nil {
+		return nil, err
+	}
+	if acc.levelFor(key) == AccessNone {
+		logDenied(s.DB, userID, "apikey", key.ID, "no_access", "Reveal denied: "+key.Path)
+		return nil, ErrPathForbidden
+	}
+	return s.APIKeys.revealAccessible(orgID, userID, key, reason)
+}
+
+// Move renames a secret or a whole folder. The user needs write access on
+// everything being moved and must be allowed to create at the destination;
+// folder grants move along with the subtree.
+func (s *SecretPathService) Move(orgID, userID uint, from, to string) (int64, error) {
+	from, err := utils.NormalizePath(from)
+	if err != nil {
+		return 0, err
+	}
+	to, err = utils.NormalizePath(to)
+	if err != nil {
+		return 0, err
+	}
+	if from == "" || to == "" {
+		return 0, errors.New("from and to are required")
+	}
+	if from == to {
+		return 0, nil
+	}
+	if utils.PathWithin(to, from) {
+		return 0, errors.New("cannot move a folder into itself")
+	}
+
+	acc, err := s.access(orgID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.requireCreate(acc, orgID, to); err != nil {
+		return 0, err
+	}
+
+	var moved int64
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		keys, err := s.Keys.ListUnderPath(tx, orgID, from)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		existing, err := s.Keys.ListUnderPath(tx, orgID, to)
+		if err != nil {
+			return err
+		}
+		taken := make(map[string]bool, len(existing))
+		for _, k := range existing {
+			taken[k.Path] = true
+		}
+		for _, k := range keys {
+			if acc.levelFor(&k) != AccessWrite {
+				return ErrPathForbidden
+			}
+			if taken[to+strings.TrimPrefix(k.Path, from)] {
+				return ErrPathTaken
+			}
+		}
+
+		n, err := s.Keys.MovePath(tx, orgID, from, to)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrPathTaken
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.Permissions.MovePath(tx, orgID, from, to); err != nil {
+			return err
+		}
+		moved = n
+		return s.logActivity(tx, userID, 0, "apikey_moved", fmt.Sprintf("Moved %d secret(s) from %s to %s", moved, from, to))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// SetGrant gives a team read or write access on a folder and everything
+// beneath it; access "none" removes the grant. Org owners/admins only.
+func (s *SecretPathService) SetGrant(orgID, userID uint, folder string, teamID uint, access string) error {
+	folder, err := utils.NormalizePath(folder)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return err
+	}
+	team, err := s.Teams.GetByID(s.DB, orgID, teamID)
+	if err != nil {
+		return err
+	}
+
+	if access != "none" && access != AccessRead && access != AccessWrite {
+		return errors.New("access must be read, write or none")
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if access == "none" {
+			if err := s.Permissions.Delete(tx, orgID, folder, team.ID); err != nil {
+				return err
+			}
+		} else {
+			perm := &models.FolderPermission{OrganizationID: orgID, Path: folder, TeamID: team.ID, Access: access, GrantedBy: userID}
+			if err := s.Permissions.Upsert(tx, perm); err != nil {
+				return err
+			}
+		}
+		return s.logActivity(tx, userID, 0, "folder_permission_changed", fmt.Sprintf("Team %s access on /%s set to %s", team.Name, folder, access))
+	})
+}
+
+// ListGrants returns the grants that apply to a folder, including those
+// inherited from its parents.
+func (s *SecretPathService) ListGrants(orgID, userID uint, folder string) ([]repository.FolderPermissionRow, error) {
+	folder, err := utils.NormalizePath(folder)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	return s.Permissions.ListOnPaths(s.DB, orgID, utils.PathAncestors(folder))
+}
+
+// BackfillPaths gives keys created before folders existed their name as path.
+func (s *SecretPathService) BackfillPaths() error {
+	return s.Keys.BackfillPaths(s.DB)
+}
+
+func (s *SecretPathService) access(orgID, userID uint) (*pathAccess, error) {
+	if _, err := s.Orgs.GetMember(s.DB, orgID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrgMember
+		}
+		return nil, err
+	}
+	grants, err := s.Permissions.ListForUser(s.DB, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.Keys.ListSharedIDs(s.DB, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	shared := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		shared[id] = true
+	}
+	return &pathAccess{userID: userID, grants: grants, shared: shared}, nil
+}
+
+// requireCreate allows creating at path when the user can write there, or when
+// no folder above it has any grant yet.
+func (s *SecretPathService) requireCreate(acc *pathAccess, orgID uint, path string) error {
+	if acc.levelFor(&models.APIKey{Path: path}) == AccessWrite {
+		return nil
+	}
+	governing, err := s.Permissions.ListOnPaths(s.DB, orgID, utils.PathAncestors(path))
+	if err != nil {
+		return err
+	}
+	if len(governing) > 0 {
+		return ErrPathForbidden
+	}
+	return nil
+}
+
+func (s *SecretPathService) logActivity(db *gorm.DB, userID, keyID uint, activityType, message string) error {
+	return enqueueActivity(db, &models.Activity{
+		UserID:   userID,
+		Type:     activityType,
+		Entity:   "apikey",
+		EntityID: keyID,
+		Message:  message,
+	})
+}
+
+// levelFor returns the user's access on a key: write for its owner, otherwise
+// the highest grant on its path or any folder above it, and at least read when
+// the key is shared with one of the user's teams. Org admins get no access of
+// their own; they only manage the grants.
+func (a *pathAccess) levelFor(k *models.APIKey) string {
+	if k.OwnerID != 0 && k.OwnerID == a.userID {
+		return AccessWrite
+	}
+	level := AccessNone
+	if k.ID != 0 && a.shared[k.ID] {
+		level = AccessRead
+	}
+	for _, g := range a.grants {
+		if !utils.PathWithin(k.Path, g.Path) {
+			continue
+		}
+		if g.Access == AccessWrite {
+			return AccessWrite
+		}
+		level = AccessRead
+	}
+	return level
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrShareLinkGone covers unknown, expired, revoked and used-up links
+	// alike, so a redeemer learns nothing about which it was.
+	ErrShareLinkGone       = errors.New("this link has expired or was already used")
+	ErrSharePassphrase     = errors.New("wrong passphrase")
+	ErrSharePassphraseNeed = errors.New("this link needs a passphrase")
+	ErrShareNeedsApproval  = errors.New("sharing this key needs an approved access request")
+)
+
+const (
+	defaultShareTTL       = 24 * time.Hour
+	maxShareTTL           = 7 * 24 * time.Hour
+	maxShareViews         = 100
+	maxSharePassAttempts  = 5
+	shareLinkCipherScheme = "AES-256-GCM"
+)
+
+// ShareLinkService sends key values to people outside the vault. The value
+// is re-encrypted under a fresh per-link key that is returned once, inside
+// the URL fragment, and never stored; redeeming a link hands back only the
+// ciphertext for the recipient's browser to decrypt.
+type ShareLinkService struct {
+	Repo          repository.ShareLinkRepository
+	Keys          repository.APIKeyRepository
+	Access        repository.AccessRequestRepository
+	Notifications *NotificationService
+	DB            *gorm.DB
+	MasterKey     []byte
+	BaseURL       string // public page that decrypts, e.g. https://app.example.com/share
+	BCryptCost    int
+}
+
+func NewShareLinkService(repo repository.ShareLinkRepository, keys repository.APIKeyRepository, access repository.AccessRequestRepository, notifications *NotificationService, db *gorm.DB, masterKey []byte, baseURL string, bcryptCost int) *ShareLinkService {
+	return &ShareLinkService{
+		Repo:          repo,
+		Keys:          keys,
+		Access:        access,
+		Notifications: notifications,
+		DB:            db,
+		MasterKey:     masterKey,
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		BCryptCost:    bcryptCost,
+	}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *ShareLinkService) WithContext(ctx context.Context) *ShareLinkService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+type CreateShareLinkInput struct {
+	OrganizationID uint
+	UserID         uint
+	APIKeyID       uint
+	ExpiresIn      time.Duration
+	MaxViews       int
+	Passphrase     string
+	Note           string
+}
+
+// CreatedShareLink is returned once; URL is the only copy of the link key.
+type CreatedShareLink struct {
+	Link *models.ShareLink `json:"link"`
+	URL  string            `json:"url"`
+}
+
+// ShareLinkInfo is what an anonymous visitor may see before redeeming.
+type ShareLinkInfo struct {
+	NeedsPassphrase bool      `json:"needs_passphrase"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	ViewsLeft       int       `json:"views_left"`
+	Note            string    `json:"note,omitempty"`
+}
+
+// RedeemedShareLink carries the ciphertext; the recipient decrypts it with
+// the key from the URL fragment.
+type RedeemedShareLink struct {
+	Name       string `json:"name"`
+	Ciphertext string `json:"ciphertext"`
+	Nonce      string `json:"nonce"`
+	Algorithm  string `json:"algorithm"`
+	ViewsLeft  int    `json:"views_left"`
+	Note       string `json:"note,omitempty"`
+}
+
+// Create makes a share link for a key the user manages. A key that requires
+// approval can only be shared by someone who may reveal it; see shareable.
+func (s *ShareLinkService) Create(in CreateShareLinkInput) (*CreatedShareLink, error) {
+	key, grant, err := s.shareable(in.OrganizationID, in.UserID, in.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.ExpiresIn == 0 {
+		in.ExpiresIn = defaultShareTTL
+	}
+	if in.ExpiresIn < time.Minute || in.ExpiresIn > maxShareTTL {
+		return nil, fmt.Errorf("expiry must be between 1m and %s", maxShareTTL)
+	}
+	if in.MaxViews == 0 {
+		in.MaxViews = 1
+	}
+	if in.MaxViews < 1 || in.MaxViews > maxShareViews {
+		return nil, fmt.Errorf("max_views must be between 1 and %d", maxShareViews)
+	}
+	if len(in.Note) > 500 {
+		return nil, errors.New("note must be at most 500 characters")
+	}
+	expiresAt := time.Now().Add(in.ExpiresIn)
+	if grant != nil && grant.ExpiresAt != nil && expiresAt.After(*grant.ExpiresAt) {
+		return nil, fmt.Errorf("the link must expire by %s, when your access grant ends", grant.ExpiresAt.Format(time.RFC3339))
+	}
+
+	plaintext, err := utils.DecryptAPIKey(s.MasterKey, key.Ciphertext, key.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	linkKey := make([]byte, 32)
+	if _, err := rand.Read(linkKey); err != nil {
+		return nil, err
+	}
+	ct, nonce, err := utils.EncryptAPIKey(linkKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	link := &models.ShareLink{
+		TokenHash:      utils.HashToken(token),
+		OrganizationID: in.OrganizationID,
+		APIKeyID:       key.ID,
+		CreatedBy:      in.UserID,
+		Ciphertext:     ct,
+		Nonce:          nonce,
+		Note:           in.Note,
+		MaxViews:       in.MaxViews,
+		ExpiresAt:      expiresAt,
+	}
+	if in.Passphrase != "" {
+		if link.PassphraseHash, err = utils.HashPassword(in.Passphrase, s.BCryptCost); err != nil {
+			return nil, err
+		}
+		link.HasPassphrase = true
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Create(tx, link); err != nil {
+			return err
+		}
+		if grant != nil {
+			if grant.GrantType == "once" {
+				ok, err := s.Access.Consume(tx, grant.ID, time.Now())
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return ErrGrantUsed
+				}
+			}
+			err := s.logActivity(tx, in.OrganizationID, in.UserID, "access_request_used", "access_request", grant.ID,
+				fmt.Sprintf("Access request #%d used to share API key: %s", grant.ID, key.Name))
+			if err != nil {
+				return err
+			}
+		}
+		return s.logActivity(tx, in.OrganizationID, in.UserID, "share_link_created", "apikey", key.ID,
+			fmt.Sprintf("Share link #%d created for %s (%d views, expires %s)", link.ID, key.Name, link.MaxViews, link.ExpiresAt.Format(time.RFC3339)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedShareLink{
+		Link: link,
+		URL:  s.BaseURL + "/" + token + "#" + base64.RawURLEncoding.EncodeToString(linkKey),
+	}, nil
+}
+
+// shareable loads a key the user may make links for. A link hands the value
+// to someone outside the vault, so a key that requires approval needs the
+// same rights as revealing it: being its owner, or holding an approved
+// access request, which is returned so Create can use it up. Managing a team
+// the key is shared with is not enough.
+func (s *ShareLinkService) shareable(orgID, userID, keyID uint) (*models.APIKey, *models.AccessRequest, error) {
+	ok, err := s.Keys.CanManage(s.DB, orgID, userID, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	key, err := s.Keys.FindByID(s.DB, orgID, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !key.RequiresApproval || key.OwnerID == userID {
+		return key, nil, nil
+	}
+	grant, err := s.Access.FindActiveGrant(s.DB, key.ID, userID, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logDenied(s.DB, userID, "apikey", key.ID, "approval_required", fmt.Sprintf("Share link denied without an approved access request: %s", key.Name))
+		return nil, nil, ErrShareNeedsApproval
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, grant, nil
+}
+
+// ListForKey lists the links made for a key the user manages.
+func (s *ShareLinkService) ListForKey(orgID, userID, keyID uint) ([]models.ShareLink, error) {
+	ok, err := s.Keys.CanManage(s.DB, orgID, userID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := s.Repo.PurgeExpired(s.DB, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListForKey(s.DB, keyID)
+}
+
+// Revoke burns a link before it is used. Its creator and the key's managers may do so.
+func (s *ShareLinkService) Revoke(orgID, userID, linkID uint) error {
+	link, err := s.Repo.GetByID(s.DB, orgID, linkID)
+	if err != nil {
+		return err
+	}
+	if link.CreatedBy != userID {
+		ok, err := s.Keys.CanManage(s.DB, orgID, userID, link.APIKeyID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	if link.BurnedAt != nil {
+		return nil
+	}
+	burn(link, "revoked")
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, link); err != nil {
+			return err
+		}
+		return s.logActivity(tx, orgID, userID, "share_link_revoked", "apikey", link.APIKeyID, fmt.Sprintf("Share link #%d revoked", link.ID))
+	})
+}
+
+// Info describes a live link without using up a view.
+func (s *ShareLinkService) Info(token string) (*ShareLinkInfo, error) {
+	link, err := s.Repo.FindByTokenHash(s.DB, utils.HashToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShareLinkGone
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !shareLinkLive(link, time.Now()) {
+		return nil, ErrShareLinkGone
+	}
+	return &ShareLinkInfo{
+		NeedsPassphrase: link.HasPassphrase,
+		ExpiresAt:       link.ExpiresAt,
+		ViewsLeft:       link.MaxViews - link.Views,
+		Note:            link.Note,
+	}, nil
+}
+
+// Redeem counts one view of a link and returns its ciphertext, burning the
+// link on its last view. Too many wrong passphrases burn it as well. from is
+// the caller's address, for the activity log.
+func (s *ShareLinkService) Redeem(token, passphrase, from string) (*RedeemedShareLink, error) {
+	var (
+		out       *RedeemedShareLink
+		link      *models.ShareLink
+		redeemErr error
+	)
+	now := time.Now()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		link, err = s.Repo.LockByTokenHash(tx, utils.HashToken(token))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			redeemErr = ErrShareLinkGone
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !shareLinkLive(link, now) {
+			redeemErr = ErrShareLinkGone
+			return nil
+		}
+
+		// a failed attempt is committed, not rolled back, so the limit holds
+		if link.HasPassphrase {
+			if passphrase == "" {
+				redeemErr = ErrSharePassphraseNeed
+				return nil
+			}
+			if !utils.CheckPassword(link.PassphraseHash, passphrase) {
+				link.FailedAttempts++
+				if link.FailedAttempts >= maxSharePassAttempts {
+					burn(link, "too_many_attempts")
+				}
+				redeemErr = ErrSharePassphrase
+				if err := s.Repo.Update(tx, link); err != nil {
+					return err
+				}
+				return enqueueActivity(tx, &models.Activity{
+					OrganizationID: link.OrganizationID,
+					UserID:         link.CreatedBy,
+					Type:           "share_link_passphrase_failed",
+					Entity:         "apikey",
+					EntityID:       link.APIKeyID,
+					Message:        truncate(fmt.Sprintf("Wrong passphrase for share link #%d from %s (%d/%d)", link.ID, from, link.FailedAttempts, maxSharePassAttempts), 255),
+					Outcome:        audit.OutcomeFailure,
+					Reason:         "wrong_passphrase",
+				})
+			}
+		}
+
+		key, err := s.Keys.FindByID(tx, link.OrganizationID, link.APIKeyID)
+		if err != nil {
+			return err
+		}
+		out = &RedeemedShareLink{
+			Name:       key.Name,
+			Ciphertext: link.Ciphertext,
+			Nonce:      link.Nonce,
+			Algorithm:  shareLinkCipherScheme,
+			Note:       link.Note,
+		}
+		link.Views++
+		out.ViewsLeft = link.MaxViews - link.Views
+		if out.ViewsLeft == 0 {
+			burn(link, "used")
+		}
+		if err := s.Repo.Update(tx, link); err != nil {
+			return err
+		}
+		err = s.logActivity(tx, link.OrganizationID, link.CreatedBy, "share_link_redeemed", "apikey", link.APIKeyID,
+			fmt.Sprintf("Share link #%d for %s opened from %s (%d views left)", link.ID, out.Name, from, out.ViewsLeft))
+		if err != nil {
+			return err
+		}
+		return s.Notifications.NotifyTx(tx, []uint{link.CreatedBy}, models.Notification{
+			Type:     "share_link_redeemed",
+			Title:    "Share link opened: " + out.Name,
+			Body:     fmt.Sprintf("Your share link for %s was opened from %s. %d views left.", out.Name, from, out.ViewsLeft),
+			Entity:   "apikey",
+			EntityID: link.APIKeyID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if redeemErr != nil {
+		return nil, redeemErr
+	}
+	return out, nil
+}
+
+func shareLinkLive(l *models.ShareLink, now time.Time) bool {
+	return l.BurnedAt == nil && l.ExpiresAt.After(now) && l.Views < l.MaxViews
+}
+
+// burn closes a link and drops its ciphertext.
+func burn(l *models.ShareLink, reason string) {
+	now := time.Now()
+	l.BurnedAt = &now
+	l.BurnReason = reason
+	l.Ciphertext = ""
+	l.Nonce = ""
+}
+
+func (s *ShareLinkService) logActivity(db *gorm.DB, orgID, userID uint, typ, entity string, id uint, msg string) error {
+	return enqueueActivity(db, &models.Activity{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Type:           typ,
+		Entity:         entity,
+		EntityID:       id,
+		Message:        truncate(msg, 255),
+	})
+}
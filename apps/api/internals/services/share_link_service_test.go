@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"gorm.io/gorm"
+)
+
+func newShareLinkTest(t *testing.T) *ShareLinkService {
+	t.Helper()
+	db := testDB(t, &models.APIKey{}, &models.Team{}, &models.TeamMembership{}, &models.APIKeyTeam{}, &models.AccessRequest{}, &models.ShareLink{}, &models.Notification{})
+	notifications := NewNotificationService(repository.NewNotificationRepository(), db)
+	return NewShareLinkService(repository.NewShareLinkRepository(), repository.NewAPIKeyRepository(), repository.NewAccessRequestRepository(), notifications, db, testMasterKey, "https://vault.example.com/share", 4)
+}
+
+// linkToken returns the token part of a share URL, without the link key.
+func linkToken(url string) string {
+	token := url[strings.LastIndex(url, "/")+1:]
+	return token[:strings.Index(token, "#")]
+}
+
+func TestShareLinkRedeemsOnce(t *testing.T) {
+	svc := newShareLinkTest(t)
+	key := createKey(t, svc.DB, &models.APIKey{OrganizationID: 1, OwnerID: 10, Name: "stripe"}, "sk_live_1")
+
+	created, err := svc.Create(CreateShareLinkInput{OrganizationID: 1, UserID: 10, APIKeyID: key.ID})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	token := linkToken(created.URL)
+
+	if _, err := svc.Redeem(token, "", "203.0.113.7"); err != nil {
+		t.Fatalf("first Redeem: %v", err)
+	}
+	if _, err := svc.Redeem(token, "", "203.0.113.7"); !errors.Is(err, ErrShareLinkGone) {
+		t.Fatalf("second Redeem = %v, want ErrShareLinkGone", err)
+	}
+	if _, err := svc.Info(token); !errors.Is(err, ErrShareLinkGone) {
+		t.Errorf("Info after the last view = %v, want ErrShareLinkGone", err)
+	}
+}
+
+func TestShareLinkCreateRefusals(t *testing.T) {
+	svc := newShareLinkTest(t)
+	key := createKey(t, svc.DB, &models.APIKey{OrganizationID: 1, OwnerID: 10, Name: "stripe", RequiresApproval: true}, "sk_live_1")
+	shareWithTeam(t, svc.DB, key, 20, "admin")
+
+	// not shared with the user at all
+	if _, err := svc.Create(CreateShareLinkInput{OrganizationID: 1, UserID: 30, APIKeyID: key.ID}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Create by an outsider = %v, want ErrRecordNotFound", err)
+	}
+	// managing the key's team is not enough without an approved request
+	if _, err := svc.Create(CreateShareLinkInput{OrganizationID: 1, UserID: 20, APIKeyID: key.ID}); !errors.Is(err, ErrShareNeedsApproval) {
+		t.Errorf("Create by a team admin without approval = %v, want ErrShareNeedsApproval", err)
+	}
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+var ErrCannotManageKey = errors.New("only the key owner or a team owner/admin can change this key")
+
+type TagService struct {
+	Repo repository.TagRepository
+	Keys repository.APIKeyRepository
+	Orgs repository.OrganizationRepository
+	DB   *gorm.DB
+}
+
+type SetCustomFieldInput struct {
+	OrganizationID uint
+	UserID         uint
+	KeyID          uint
+	Name           string
+	Type           string
+	Value          string
+}
+
+func NewTagService(repo repository.TagRepository, keys repository.APIKeyRepository, orgs repository.OrganizationRepository, db *gorm.DB) *TagService {
+	return &TagService{Repo: repo, Keys: keys, Orgs: orgs, DB: db}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *TagService) WithContext(ctx context.Context) *TagService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+// ApplyTags replaces a secret's tags with the given "key" / "key:value" list,
+// creating org tags as needed. Run it inside the caller's transaction.
+func (s *TagService) ApplyTags(tx *gorm.DB, orgID, keyID uint, tags []string) ([]models.Tag, error) {
+	applied := make([]models.Tag, 0, len(tags))
+	ids := make([]uint, 0, len(tags))
+	for _, raw := range tags {
+		key, value, err := utils.ParseTag(raw)
+		if err != nil {
+			return nil, err
+		}
+		t, err := s.Repo.FindOrCreate(tx, orgID, key, value)
+		if err != nil {
+			return nil, err
+		}
+		applied = append(applied, *t)
+		ids = append(ids, t.ID)
+	}
+	if err := s.Repo.SetKeyTags(tx, keyID, ids); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.RefreshMirror(tx, []uint{keyID}); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (s *TagService) SetKeyTags(orgID, userID, keyID uint, tags []string) ([]models.Tag, error) {
+	if err := s.requireManage(orgID, userID, keyID); err != nil {
+		return nil, err
+	}
+
+	var applied []models.Tag
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if applied, err = s.ApplyTags(tx, orgID, keyID, tags); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "apikey", keyID, "apikey_tags_updated", "Tags set: "+strings.Join(tags, ", "))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (s *TagService) ListTags(orgID, userID uint) ([]repository.TagUsageRow, error) {
+	if _, err := s.Orgs.GetMember(s.DB, orgID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrgMember
+		}
+		return nil, err
+	}
+	return s.Repo.ListByOrg(s.DB, orgID)
+}
+
+// Rename changes a tag everywhere it is used. Renaming onto an existing tag
+// merges the two.
+func (s *TagService) Rename(orgID, userID, tagID uint, newTag string) (*models.Tag, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	key, value, err := utils.ParseTag(newTag)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := s.Repo.GetByID(s.DB, orgID, tagID)
+	if err != nil {
+		return nil, err
+	}
+	oldName := utils.FormatTag(tag.Key, tag.Value)
+
+	existing, err := s.Repo.Find(s.DB, orgID, key, value)
+	switch {
+	case err == nil && existing.ID != tag.ID:
+		return s.Merge(orgID, userID, tag.ID, existing.ID)
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		tag.Key, tag.Value = key, value
+		if err := s.Repo.Update(tx, tag); err != nil {
+			return err
+		}
+		keyIDs, err := s.Repo.KeyIDsWithTag(tx, tag.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.Repo.RefreshMirror(tx, keyIDs); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "tag", tag.ID, "tag_renamed", fmt.Sprintf("Tag %s renamed to %s", oldName, utils.FormatTag(key, value)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Merge moves every secret tagged fromID onto intoID and deletes fromID.
+func (s *TagService) Merge(orgID, userID, fromID, intoID uint) (*models.Tag, error) {
+	if err := requireOrgAdmin(s.Orgs, s.DB, orgID, userID); err != nil {
+		return nil, err
+	}
+	if fromID == intoID {
+		return nil, errors.New("cannot merge a tag into itself")
+	}
+	from, err := s.Repo.GetByID(s.DB, orgID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	into, err := s.Repo.GetByID(s.DB, orgID, intoID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		keyIDs, err := s.Repo.KeyIDsWithTag(tx, from.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.Repo.Merge(tx, from.ID, into.ID); err != nil {
+			return err
+		}
+		if err := s.Repo.RefreshMirror(tx, keyIDs); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "tag", into.ID, "tag_merged", fmt.Sprintf("Tag %s merged into %s",
+			utils.FormatTag(from.Key, from.Value), utils.FormatTag(into.Key, into.Value)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return into, nil
+}
+
+func (s *TagService) SetField(in SetCustomFieldInput) (*models.CustomField, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, errors.New("field name required")
+	}
+	value, err := utils.NormalizeFieldValue(in.Type, in.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireManage(in.OrganizationID, in.UserID, in.KeyID); err != nil {
+		return nil, err
+	}
+
+	f := &models.CustomField{APIKeyID: in.KeyID, Name: name, Type: in.Type, Value: value}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.UpsertField(tx, f); err != nil {
+			return err
+		}
+		return s.logActivity(tx, in.UserID, "apikey", in.KeyID, "apikey_field_set", "Custom field set: "+name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *TagService) DeleteField(orgID, userID, keyID uint, name string) error {
+	if err := s.requireManage(orgID, userID, keyID); err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.DeleteField(tx, keyID, name); err != nil {
+			return err
+		}
+		return s.logActivity(tx, userID, "apikey", keyID, "apikey_field_deleted", "Custom field deleted: "+name)
+	})
+}
+
+func (s *TagService) ListFields(orgID, userID, keyID uint) ([]models.CustomField, error) {
+	if _, err := s.Keys.FindAccessibleByID(s.DB, orgID, userID, keyID); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListFields(s.DB, keyID)
+}
+
+// BackfillLegacyTags copies comma-separated tags from before the tag table
+// existed into it, once per key. Entries that do not parse are left in the
+// legacy column only.
+func (s *TagService) BackfillLegacyTags() error {
+	keys, err := s.Repo.ListUnmigratedKeys(s.DB)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		tags, invalid := utils.SplitTagEntries(k.Tags)
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if len(tags) == 0 {
+				// nothing replaces the column, so it keeps the bad entries as is
+				return s.Repo.MarkTagsBackfilled(tx, k.ID, nil)
+			}
+			if _, err := s.ApplyTags(tx, k.OrganizationID, k.ID, tags); err != nil {
+				return err
+			}
+			return s.Repo.MarkTagsBackfilled(tx, k.ID, invalid)
+		})
+		if err != nil {
+			return err
+		}
+		if len(invalid) > 0 {
+			fmt.Printf("kept %d unparseable tags of key %d in its legacy column: %s\n", len(invalid), k.ID, strings.Join(invalid, ", "))
+		}
+	}
+	return nil
+}
+
+func (s *TagService) requireManage(orgID, userID, keyID uint) error {
+	ok, err := s.Keys.CanManage(s.DB, orgID, userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := s.Keys.FindByID(s.DB, orgID, keyID); err != nil {
+			return err
+		}
+		return ErrCannotManageKey
+	}
+	return nil
+}
+
+func (s *TagService) logActivity(db *gorm.DB, userID uint, entity string, entityID uint, activityType, message string) error {
+	return enqueueActivity(db, &models.Activity{
+		UserID:   userID,
+		Type:     activityType,
+		Entity:   entity,
+		EntityID: entityID,
+		Message:  message,
+	})
+}
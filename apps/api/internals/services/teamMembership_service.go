@@ -1,61 +1,118 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"strconv"
-	"fmt"
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
 	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
 	"gorm.io/gorm"
 )
 
+var ErrRemoveTeamOwner = errors.New("the team owner cannot be removed; transfer ownership first")
+
 type TeamMembershipService interface {
-	AddUserToTeam(teamID, userID uint, role string) error
-	GetTeamMemberships(teamID uint) ([]models.TeamMembership, error)
-	GetUserMemberships(userID uint) ([]models.TeamMembership, error)
-	RemoveUserFromTeam(teamID, userID uint) error
-	
+	AddUserToTeam(orgID, actorID, teamID, userID uint, role string) error
+	GetTeamMemberships(orgID, teamID uint) ([]models.TeamMembership, error)
+	GetUserMemberships(orgID, userID uint) ([]models.TeamMembership, error)
+	RemoveUserFromTeam(orgID, actorID, teamID, userID uint) error
+	// WithContext returns a copy whose activities record the request in ctx.
+	WithContext(ctx context.Context) TeamMembershipService
 }
 
 type teamMembershipService struct {
 	repo repository.TeamMembershipRepository
+	teamRepo repository.TeamRepository
+	orgRepo repository.OrganizationRepository
 	DB   *gorm.DB
 }
 
-func NewTeamMembershipService(repo repository.TeamMembershipRepository, DB   *gorm.DB) TeamMembershipService {
-	return &teamMembershipService{repo: repo, DB: DB}
+func NewTeamMembershipService(repo repository.TeamMembershipRepository, teamRepo repository.TeamRepository, orgRepo repository.OrganizationRepository, DB   *gorm.DB) TeamMembershipService {
+	return &teamMembershipService{repo: repo, teamRepo: teamRepo, orgRepo: orgRepo, DB: DB}
+}
+
+func (s *teamMembershipService) WithContext(ctx context.Context) TeamMembershipService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
 }
 
-func (s *teamMembershipService) AddUserToTeam(teamID, userID uint, role string) error {
+// AddUserToTeam lets a team owner or admin add a member of the organization
+// directly, as a member or admin. Everyone else joins through invitations.
+func (s *teamMembershipService) AddUserToTeam(orgID, actorID, teamID, userID uint, role string) error {
+	if role != "member" && role != "admin" {
+		return errors.New("role must be member or admin")
+	}
+	if _, err := s.teamRepo.GetByID(s.DB, orgID, teamID); err != nil {
+		return err
+	}
+	if err := s.requireManager(orgID, teamID, actorID); err != nil {
+		return err
+	}
+	if _, err := s.orgRepo.GetMember(s.DB, orgID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotOrgMember
+		}
+		return err
+	}
+
 	m := &models.TeamMembership{
 		TeamID: teamID,
 		UserID: userID,
 		Role:   role,
 	}
 
-	// Then log the activity
-	activity := &models.Activity{
-		UserID:   userID,
-		Type:     "member_added",
-		Entity:   "team",
-		EntityID: teamID,
-		Message:  "Member added: " + strconv.Itoa(int(userID)),
-	}
+	// the membership and its activity commit together, so a failed insert
+	// leaves no "member_added" behind
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithDB(tx).Create(m); err != nil {
+			return err
+		}
+		return logTeamActivity(tx, actorID, teamID, "member_added", "Member added: "+strconv.Itoa(int(userID)))
+	})
+}
 
-	if err := s.DB.Create(activity).Error; err != nil {
-		fmt.Printf("failed to log activity: %v\n", err)
+func (s *teamMembershipService) GetTeamMemberships(orgID, teamID uint) ([]models.TeamMembership, error) {
+	if _, err := s.teamRepo.GetByID(s.DB, orgID, teamID); err != nil {
+		return nil, err
 	}
-
-	return s.repo.Create(m)
+	return s.repo.ListByTeam(teamID)
 }
 
-func (s *teamMembershipService) GetTeamMemberships(teamID uint) ([]models.TeamMembership, error) {
-	return s.repo.ListByTeam(teamID)
+func (s *teamMembershipService) GetUserMemberships(orgID, userID uint) ([]models.TeamMembership, error) {
+	return s.repo.ListByUserInOrg(orgID, userID)
 }
 
-func (s *teamMembershipService) GetUserMemberships(userID uint) ([]models.TeamMembership, error) {
-	return s.repo.ListByUser(userID)
+// RemoveUserFromTeam lets a team owner or admin remove a member, and anyone
+// leave. The owner stays until ownership is transferred.
+func (s *teamMembershipService) RemoveUserFromTeam(orgID, actorID, teamID, userID uint) error {
+	team, err := s.teamRepo.GetByID(s.DB, orgID, teamID)
+	if err != nil {
+		return err
+	}
+	if actorID != userID {
+		if err := s.requireManager(orgID, teamID, actorID); err != nil {
+			return err
+		}
+	}
+	if team.OwnerID == userID {
+		return ErrRemoveTeamOwner
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithDB(tx).Delete(teamID, userID); err != nil {
+			return err
+		}
+		return logTeamActivity(tx, actorID, teamID, "member_removed", "Member removed: "+strconv.Itoa(int(userID)))
+	})
 }
 
-func (s *teamMembershipService) RemoveUserFromTeam(teamID, userID uint) error {
-	return s.repo.Delete(teamID, userID)
+func (s *teamMembershipService) requireManager(orgID, teamID, userID uint) error {
+	ok, err := isTeamManager(s.teamRepo, s.repo, s.DB, orgID, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotTeamManager
+	}
+	return nil
 }
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"gorm.io/gorm"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrNotTeamManager     = errors.New("only team owners and admins can manage invitations")
+	ErrInvitationInvalid  = errors.New("invitation is invalid or has expired")
+	ErrInvitationMismatch = errors.New("invitation was sent to a different email address")
+)
+
+type TeamInvitationService struct {
+	Repo           repository.TeamInvitationRepository
+	TeamRepo       repository.TeamRepository
+	MembershipRepo repository.TeamMembershipRepository
+	Orgs           *OrganizationService
+	UserRepo       repository.UserRepository
+	Notifications  *NotificationService
+	Mailer         Mailer // nil: invitations are not emailed
+	AcceptURL      string // the invitation link; the token is appended as ?token=
+	DB             *gorm.DB
+}
+
+type InviteInput struct {
+	OrganizationID uint
+	InviterID      uint
+	TeamID         uint
+	Email          string
+	Role           string
+}
+
+// InviteResult carries the plaintext token, which is only ever returned here.
+type InviteResult struct {
+	Invitation *models.TeamInvitation `json:"invitation"`
+	Token      string                 `json:"token"`
+}
+
+func NewTeamInvitationService(repo repository.TeamInvitationRepository, teamRepo repository.TeamRepository, membershipRepo repository.TeamMembershipRepository, orgs *OrganizationService, userRepo repository.UserRepository, notifications *NotificationService, mailer Mailer, acceptURL string, db *gorm.DB) *TeamInvitationService {
+	return &TeamInvitationService{Repo: repo, TeamRepo: teamRepo, MembershipRepo: membershipRepo, Orgs: orgs, UserRepo: userRepo, Notifications: notifications, Mailer: mailer, AcceptURL: acceptURL, DB: db}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *TeamInvitationService) WithContext(ctx context.Context) *TeamInvitationService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	c.Orgs = s.Orgs.WithContext(ctx)
+	return &c
+}
+
+// Invite creates an invitation and emails its link to the invitee. Existing
+// users are also notified in-app and can accept by the invitation's id.
+func (s *TeamInvitationService) Invite(in InviteInput) (*InviteResult, error) {
+	addr, err := mail.ParseAddress(in.Email)
+	if err != nil {
+		return nil, errors.New("invalid email")
+	}
+	email := strings.ToLower(addr.Address)
+
+	if in.Role == "" {
+		in.Role = "member"
+	}
+	if in.Role != "member" && in.Role != "admin" {
+		return nil, errors.New("role must be member or admin")
+	}
+
+	team, err := s.TeamRepo.GetByID(s.DB, in.OrganizationID, in.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireManager(in.TeamID, in.InviterID); err != nil {
+		return nil, err
+	}
+
+	invitee, err := s.UserRepo.FindByEmail(s.DB, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if invitee != nil {
+		if _, err := s.MembershipRepo.FindRole(in.TeamID, invitee.ID); err == nil {
+			return nil, errors.New("user is already a member of this team")
+		}
+	}
+	if _, err := s.Repo.FindPending(s.DB, in.TeamID, email); err == nil {
+		return nil, errors.New("a pending invitation already exists for this email")
+	}
+
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &models.TeamInvitation{
+		OrganizationID: in.OrganizationID,
+		TeamID:         in.TeamID,
+		Email:          email,
+		Role:           in.Role,
+		TokenHash:      utils.HashToken(token),
+		InvitedBy:      in.InviterID,
+		Status:         "pending",
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Create(tx, inv); err != nil {
+			return err
+		}
+		if err := logTeamActivity(tx, in.InviterID, in.TeamID, "member_invited", fmt.Sprintf("Invited %s to team as %s", email, in.Role)); err != nil {
+			return err
+		}
+		if invitee == nil {
+			return nil
+		}
+		return s.Notifications.NotifyTx(tx, []uint{invitee.ID}, models.Notification{
+			Type:     "team_invitation",
+			Title:    "You have been invited to a team",
+			Body:     fmt.Sprintf("You were invited to join team %s as %s. Accept it from your invitations.", team.Name, in.Role),
+			Entity:   "team_invitation",
+			EntityID: inv.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.email(inv, team, token)
+	return &InviteResult{Invitation: inv, Token: token}, nil
+}
+
+// email sends the invitation link. The invitation stands without it (the
+// inviter also gets the token), so a failure is only logged.
+func (s *TeamInvitationService) email(inv *models.TeamInvitation, team *models.Team, token string) {
+	if s.Mailer == nil {
+		return
+	}
+	body := fmt.Sprintf("You were invited to join the team %s as %s.\n\nAccept the invitation, or sign up with it, here:\n%s?token=%s\n\nThe link expires on %s.\n",
+		team.Name, inv.Role, s.AcceptURL, url.QueryEscape(token), inv.ExpiresAt.Format("January 2, 2006"))
+	if err := s.Mailer.Send(inv.Email, "You have been invited to "+team.Name, body); err != nil {
+		fmt.Printf("failed to email invitation #%d: %v\n", inv.ID, err)
+	}
+}
+
+// Accept joins the user to the invitation's team. The invitation is identified
+// by its token, or by its id for invitations addressed to the user's email.
+func (s *TeamInvitationService) Accept(userID uint, token string, id uint) (*models.TeamInvitation, error) {
+	user, err := s.UserRepo.FindByID(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	inv, err := s.pending(user.Email, token, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.join(tx, inv, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// AcceptTx accepts an invitation by token inside the caller's transaction, so
+// an account signed up with an invitation is only created if it joins the team.
+func (s *TeamInvitationService) AcceptTx(tx *gorm.DB, user *models.User, token string) (*models.TeamInvitation, error) {
+	inv, err := s.pendingByToken(token, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	return inv, s.join(tx, inv, user.ID)
+}
+
+func (s *TeamInvitationService) join(tx *gorm.DB, inv *models.TeamInvitation, userID uint) error {
+	now := time.Now()
+	inv.Status = "accepted"
+	inv.RespondedAt = &now
+	if err := s.Repo.Update(tx, inv); err != nil {
+		return err
+	}
+	if err := s.Orgs.EnsureMember(tx, inv.OrganizationID, userID); err != nil {
+		return err
+	}
+	if _, err := s.MembershipRepo.WithDB(tx).FindRole(inv.TeamID, userID); err != nil {
+		err := s.MembershipRepo.WithDB(tx).Create(&models.TeamMembership{
+			TeamID: inv.TeamID,
+			UserID: userID,
+			Role:   inv.Role,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return logTeamActivity(tx, userID, inv.TeamID, "member_added", fmt.Sprintf("Member added: %d (invitation #%d accepted)", userID, inv.ID))
+}
+
+// Decline rejects an invitation, identified either by its token or by its id
+// for invitations addressed to the user's email.
+func (s *TeamInvitationService) Decline(userID uint, token string, id uint) (*models.TeamInvitation, error) {
+	user, err := s.UserRepo.FindByID(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	inv, err := s.pending(user.Email, token, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return inv, s.respond(inv, "declined", userID, "member_invite_declined", fmt.Sprintf("Invitation #%d declined by %s", inv.ID, inv.Email))
+}
+
+// Revoke cancels a pending invitation; only team owners and admins may revoke.
+func (s *TeamInvitationService) Revoke(orgID, userID, id uint) (*models.TeamInvitation, error) {
+	inv, err := s.pendingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if inv.OrganizationID != orgID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := s.requireManager(inv.TeamID, userID); err != nil {
+		return nil, err
+	}
+
+	return inv, s.respond(inv, "revoked", userID, "member_invite_revoked", fmt.Sprintf("Invitation #%d for %s revoked", inv.ID, inv.Email))
+}
+
+// ListSent returns invitations for teams the user manages in the organization.
+func (s *TeamInvitationService) ListSent(orgID, userID uint) ([]models.TeamInvitation, error) {
+	return s.Repo.ListForManager(s.DB, orgID, userID)
+}
+
+// ListReceived returns invitations addressed to the user's email.
+func (s *TeamInvitationService) ListReceived(userID uint) ([]models.TeamInvitation, error) {
+	user, err := s.UserRepo.FindByID(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ListByEmail(s.DB, strings.ToLower(user.Email))
+}
+
+// CheckSignupToken validates an invitation token and the inviting organization's
+// password policy before an account is created for email.
+func (s *TeamInvitationService) CheckSignupToken(token, email, password string) error {
+	inv, err := s.pendingByToken(token, email)
+	if err != nil {
+		return err
+	}
+	policy, err := s.Orgs.PasswordPolicy(inv.OrganizationID)
+	if err != nil {
+		return err
+	}
+	return policy.Validate(password)
+}
+
+func (s *TeamInvitationService) respond(inv *models.TeamInvitation, status string, userID uint, activityType, message string) error {
+	now := time.Now()
+	inv.Status = status
+	inv.RespondedAt = &now
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, inv); err != nil {
+			return err
+		}
+		return logTeamActivity(tx, userID, inv.TeamID, activityType, message)
+	})
+}
+
+// pending finds an invitation addressed to email by its token or, without
+// one, by its id.
+func (s *TeamInvitationService) pending(email, token string, id uint) (*models.TeamInvitation, error) {
+	if token != "" {
+		return s.pendingByToken(token, email)
+	}
+	inv, err := s.pendingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(inv.ExpiresAt) {
+		return nil, ErrInvitationInvalid
+	}
+	if !strings.EqualFold(inv.Email, email) {
+		return nil, ErrInvitationMismatch
+	}
+	return inv, nil
+}
+
+func (s *TeamInvitationService) pendingByToken(token, email string) (*models.TeamInvitation, error) {
+	if token == "" {
+		return nil, ErrInvitationInvalid
+	}
+	inv, err := s.Repo.FindByTokenHash(s.DB, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, err
+	}
+	if inv.Status != "pending" || time.Now().After(inv.ExpiresAt) {
+		return nil, ErrInvitationInvalid
+	}
+	if !strings.EqualFold(inv.Email, email) {
+		return nil, ErrInvitationMismatch
+	}
+	return inv, nil
+}
+
+func (s *TeamInvitationService) pendingByID(id uint) (*models.TeamInvitation, error) {
+	inv, err := s.Repo.GetByID(s.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Status != "pending" {
+		return nil, fmt.Errorf("invitation is already %s", inv.Status)
+	}
+	return inv, nil
+}
+
+func (s *TeamInvitationService) requireManager(teamID, userID uint) error {
+	role, err := s.MembershipRepo.FindRole(teamID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotTeamManager
+		}
+		return err
+	}
+	if role != "owner" && role != "admin" {
+		return ErrNotTeamManager
+	}
+	return nil
+}
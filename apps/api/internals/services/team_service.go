@@ -1,22 +1,28 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
+
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
 	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TeamService struct {
-	Repo            repository.TeamRepository
-	MembershipRepo  repository.TeamMembershipRepository
-	DB              *gorm.DB
+	Repo           repository.TeamRepository
+	MembershipRepo repository.TeamMembershipRepository
+	DB             *gorm.DB
 }
 
 type CreateTeamInput struct {
-	Name        string
-	Description string
-	OwnerID     uint
+	OrganizationID uint
+	Name           string
+	Description    string
+	OwnerID        uint
 }
 
 type CreateTeamResult struct {
@@ -25,8 +31,53 @@ type CreateTeamResult struct {
 	Description string `json:"description"`
 }
 
-func NewTeamService(repo repository.TeamRepository,membershipRepo repository.TeamMembershipRepository, db *gorm.DB) *TeamService {
-	return &TeamService{Repo: repo,MembershipRepo: membershipRepo, DB: db}
+var (
+	ErrNotTeamMember = errors.New("you are not a member of this team")
+	ErrNotTeamOwner  = errors.New("only the team owner can do this")
+	ErrTeamArchived  = errors.New("team is archived")
+)
+
+type TeamSummary struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	OwnerID     uint       `json:"owner_id"`
+	Role        string     `json:"role,omitempty"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type TeamDetail struct {
+	TeamSummary
+	Members []repository.TeamMemberRow `json:"members"`
+	APIKeys []TeamKeySummary           `json:"api_keys"`
+}
+
+type TeamKeySummary struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	OwnerID     uint   `json:"owner_id"`
+}
+
+type UpdateTeamInput struct {
+	OrganizationID uint
+	UserID         uint
+	TeamID         uint
+	Name           *string
+	Description    *string
+}
+
+func NewTeamService(repo repository.TeamRepository, membershipRepo repository.TeamMembershipRepository, db *gorm.DB) *TeamService {
+	return &TeamService{Repo: repo, MembershipRepo: membershipRepo, DB: db}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *TeamService) WithContext(ctx context.Context) *TeamService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
 }
 
 func (s *TeamService) Create(in CreateTeamInput) (*CreateTeamResult, error) {
@@ -35,31 +86,276 @@ func (s *TeamService) Create(in CreateTeamInput) (*CreateTeamResult, error) {
 	}
 
 	t := &models.Team{
-		Name:        in.Name,
-		Description: in.Description,
-		OwnerID:     in.OwnerID,
+		OrganizationID: in.OrganizationID,
+		Name:           in.Name,
+		Description:    in.Description,
+		OwnerID:        in.OwnerID,
 	}
-	if err := s.Repo.Create(s.DB, t); err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Create(tx, t); err != nil {
+			return err
+		}
+		err := s.MembershipRepo.WithDB(tx).Create(&models.TeamMembership{
+			TeamID: t.ID,
+			UserID: in.OwnerID,
+			Role:   "owner",
+		})
+		if err != nil {
+			return err
+		}
+		return logTeamActivity(tx, in.OwnerID, t.ID, "team_created", "Team created: "+t.Name)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return &CreateTeamResult{ID: t.ID, Name: t.Name, Description: t.Description}, nil
+}
 
-	membership:= models.TeamMembership{
-		TeamID: t.ID,
-		UserID: in.OwnerID,
-		Role: "owner",
+// List returns the teams the user belongs to along with their role in each.
+func (s *TeamService) List(orgID, userID uint, includeArchived bool) ([]TeamSummary, error) {
+	teams, err := s.Repo.ListByMember(s.DB, orgID, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	memberships, err := s.MembershipRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[uint]string, len(memberships))
+	for _, m := range memberships {
+		roles[m.TeamID] = m.Role
 	}
 
-	if err := s.MembershipRepo.Create(&membership); err != nil {
+	out := make([]TeamSummary, 0, len(teams))
+	for _, t := range teams {
+		sum := summarizeTeam(&t)
+		sum.Role = roles[t.ID]
+		out = append(out, sum)
+	}
+	return out, nil
+}
+
+// Get returns the team with its members and attached keys; members only.
+func (s *TeamService) Get(orgID, userID, teamID uint) (*TeamDetail, error) {
+	t, err := s.Repo.GetByID(s.DB, orgID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.requireRole(teamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.Repo.ListMembers(s.DB, teamID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.Repo.ListAPIKeys(s.DB, teamID)
+	if err != nil {
 		return nil, err
 	}
 
-	activity:= &models.Activity{
-		UserID: in.OwnerID,
-		Type: "team_created",
-		EntityID: t.ID,
-		Message: "Team created: "+ t.Name,
+	detail := &TeamDetail{TeamSummary: summarizeTeam(t), Members: members, APIKeys: []TeamKeySummary{}}
+	detail.Role = role
+	for _, k := range keys {
+		detail.APIKeys = append(detail.APIKeys, TeamKeySummary{ID: k.ID, Name: k.Name, Description: k.Description, OwnerID: k.OwnerID})
 	}
+	return detail, nil
+}
 
-	s.DB.Create(activity)
-	return &CreateTeamResult{ID: t.ID, Name: t.Name, Description: t.Description}, nil
-}
\ No newline at end of file
+// Update renames a team or edits its description; owners and admins only.
+func (s *TeamService) Update(in UpdateTeamInput) (*TeamSummary, error) {
+	t, err := s.Repo.GetByID(s.DB, in.OrganizationID, in.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.requireRole(in.TeamID, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if role != "owner" && role != "admin" {
+		return nil, ErrNotTeamManager
+	}
+	if t.ArchivedAt != nil {
+		return nil, ErrTeamArchived
+	}
+	if in.Name != nil {
+		if *in.Name == "" {
+			return nil, errors.New("name required")
+		}
+		t.Name = *in.Name
+	}
+	if in.Description != nil {
+		t.Description = *in.Description
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, t); err != nil {
+			return err
+		}
+		return logTeamActivity(tx, in.UserID, t.ID, "team_updated", "Team updated: "+t.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := summarizeTeam(t)
+	return &sum, nil
+}
+
+// SetArchived archives or restores a team; owner only.
+func (s *TeamService) SetArchived(orgID, userID, teamID uint, archived bool) (*TeamSummary, error) {
+	t, err := s.ownedTeam(orgID, userID, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	activityType, message := "team_unarchived", "Team restored: "+t.Name
+	if archived {
+		now := time.Now()
+		t.ArchivedAt = &now
+		activityType, message = "team_archived", "Team archived: "+t.Name
+	} else {
+		t.ArchivedAt = nil
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, t); err != nil {
+			return err
+		}
+		return logTeamActivity(tx, userID, t.ID, activityType, message)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := summarizeTeam(t)
+	return &sum, nil
+}
+
+// Delete removes the team and detaches its keys and members; owner only.
+// The keys themselves stay with their owners.
+func (s *TeamService) Delete(orgID, userID, teamID uint) error {
+	t, err := s.ownedTeam(orgID, userID, teamID)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Delete(tx, t.ID); err != nil {
+			return err
+		}
+		return logTeamActivity(tx, userID, t.ID, "team_deleted", "Team deleted: "+t.Name)
+	})
+}
+
+// TransferOwnership hands the team to another member. Team.OwnerID and both
+// membership roles change in one transaction; the previous owner becomes an admin.
+func (s *TeamService) TransferOwnership(orgID, userID, teamID, newOwnerID uint) (*TeamSummary, error) {
+	t, err := s.ownedTeam(orgID, userID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if newOwnerID == userID {
+		return nil, errors.New("you already own this team")
+	}
+	if _, err := s.MembershipRepo.FindRole(teamID, newOwnerID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("new owner must already be a member of the team")
+		}
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// lock the team row so concurrent transfers serialize
+		var locked models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, t.ID).Error; err != nil {
+			return err
+		}
+		if locked.OwnerID != userID {
+			return ErrNotTeamOwner
+		}
+
+		locked.OwnerID = newOwnerID
+		if err := s.Repo.Update(tx, &locked); err != nil {
+			return err
+		}
+		members := s.MembershipRepo.WithDB(tx)
+		if err := members.UpdateRole(t.ID, userID, "admin"); err != nil {
+			return err
+		}
+		if err := members.UpdateRole(t.ID, newOwnerID, "owner"); err != nil {
+			return err
+		}
+		t = &locked
+		return logTeamActivity(tx, userID, t.ID, "team_ownership_transferred", fmt.Sprintf("Team %s ownership transferred to user %d", t.Name, newOwnerID))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := summarizeTeam(t)
+	return &sum, nil
+}
+
+func (s *TeamService) requireRole(teamID, userID uint) (string, error) {
+	role, err := s.MembershipRepo.FindRole(teamID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrNotTeamMember
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+func (s *TeamService) ownedTeam(orgID, userID, teamID uint) (*models.Team, error) {
+	t, err := s.Repo.GetByID(s.DB, orgID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if t.OwnerID != userID {
+		return nil, ErrNotTeamOwner
+	}
+	return t, nil
+}
+
+// logTeamActivity queues a team activity in tx, the transaction that made the change.
+func logTeamActivity(tx *gorm.DB, userID, teamID uint, activityType, message string) error {
+	return enqueueActivity(tx, &models.Activity{
+		UserID:   userID,
+		Type:     activityType,
+		Entity:   "team",
+		EntityID: teamID,
+		Message:  message,
+	})
+}
+
+// isTeamManager reports whether the team is in the organization and the
+// user owns or administers it.
+func isTeamManager(teams repository.TeamRepository, members repository.TeamMembershipRepository, db *gorm.DB, orgID, teamID, userID uint) (bool, error) {
+	t, err := teams.GetByID(db, orgID, teamID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if t.OwnerID == userID {
+		return true, nil
+	}
+	role, err := members.FindRole(teamID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return role == "owner" || role == "admin", nil
+}
+
+func summarizeTeam(t *models.Team) TeamSummary {
+	return TeamSummary{
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		OwnerID:     t.OwnerID,
+		ArchivedAt:  t.ArchivedAt,
+		CreatedAt:   t.CreatedAt,
+	}
+}
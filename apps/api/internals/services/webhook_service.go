@@ -0,0 +1,681 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/models"
+	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
+	"github.com/intojhanurag/One-Password/apps/api/internals/utils"
+	"github.com/intojhanurag/One-Password/apps/api/internals/webhook"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWebhookForbidden = errors.New("only team owners and admins can manage team webhooks")
+	ErrWebhookDisabled  = errors.New("webhook is disabled; enable it first")
+	ErrWebhookLimit     = fmt.Errorf("at most %d webhooks per user or team", maxWebhooks)
+)
+
+const (
+	maxWebhooks = 20
+
+	webhookTimeout       = 10 * time.Second
+	webhookBatch         = 50
+	webhookLease         = time.Minute
+	webhookMaxAttempts   = 8  // per delivery, about 32 minutes of retries
+	webhookDisableAfter  = 20 // failed attempts in a row, across deliveries
+	webhookRetention     = 30 * 24 * time.Hour
+	webhookPurgeEvery    = time.Hour
+	webhookResponseLimit = 1024
+
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 200
+)
+
+// WebhookService lets users and teams register HTTP endpoints for activity
+// events. It is an outbox subscriber: each matching activity becomes a
+// delivery row, which a dispatcher POSTs with an HMAC signature, retrying
+// failures with backoff. An endpoint that keeps failing is disabled and its
+// owner notified.
+type WebhookService struct {
+	Repo          repository.WebhookRepository
+	Teams         repository.TeamRepository
+	Members       repository.TeamMembershipRepository
+	Notifications *NotificationService
+	DB            *gorm.DB
+	MasterKey     []byte
+	AllowPrivate  bool // accept http and internal addresses, for development
+	Client        *http.Client
+	Interval      time.Duration
+}
+
+func NewWebhookService(repo repository.WebhookRepository, teams repository.TeamRepository, members repository.TeamMembershipRepository, notifications *NotificationService, db *gorm.DB, masterKey []byte, allowPrivate bool, interval time.Duration) *WebhookService {
+	return &WebhookService{
+		Repo:          repo,
+		Teams:         teams,
+		Members:       members,
+		Notifications: notifications,
+		DB:            db,
+		MasterKey:     masterKey,
+		AllowPrivate:  allowPrivate,
+		Client:        webhook.NewClient(webhookTimeout, allowPrivate),
+		Interval:      interval,
+	}
+}
+
+// WithContext returns a copy of the service whose activities record the
+// request in ctx.
+func (s *WebhookService) WithContext(ctx context.Context) *WebhookService {
+	c := *s
+	c.DB = requestDB(s.DB, ctx)
+	return &c
+}
+
+type CreateWebhookInput struct {
+	OrganizationID uint
+	UserID         uint
+	TeamID         *uint // nil for a personal webhook
+	URL            string
+	Description    string
+	Events         []string
+}
+
+// WebhookWithSecret is returned on creation and secret rotation; the secret
+// is not shown again.
+type WebhookWithSecret struct {
+	Webhook *models.Webhook `json:"webhook"`
+	Secret  string          `json:"secret"`
+}
+
+// Create registers an endpoint. Team webhooks need a team owner or admin.
+func (s *WebhookService) Create(in CreateWebhookInput) (*WebhookWithSecret, error) {
+	if in.TeamID != nil {
+		if err := s.requireTeamManager(in.OrganizationID, *in.TeamID, in.UserID); err != nil {
+			return nil, err
+		}
+	}
+	u, err := webhook.CheckURL(strings.TrimSpace(in.URL), s.AllowPrivate)
+	if err != nil {
+		return nil, err
+	}
+	events, err := webhook.ParseEvents(in.Events)
+	if err != nil {
+		return nil, err
+	}
+	if len(in.Description) > 255 {
+		return nil, errors.New("description must be at most 255 characters")
+	}
+
+	var existing []models.Webhook
+	if in.TeamID != nil {
+		existing, err = s.Repo.ListForTeam(s.DB, in.OrganizationID, *in.TeamID)
+	} else {
+		existing, err = s.Repo.ListPersonal(s.DB, in.OrganizationID, in.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhooks {
+		return nil, ErrWebhookLimit
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	ct, nonce, err := utils.EncryptAPIKey(s.MasterKey, secret)
+	if err != nil {
+		return nil, err
+	}
+	w := &models.Webhook{
+		OrganizationID:   in.OrganizationID,
+		OwnerID:          in.UserID,
+		TeamID:           in.TeamID,
+		URL:              u.String(),
+		Description:      in.Description,
+		Events:           strings.Join(events, ","),
+		SecretCiphertext: ct,
+		SecretNonce:      nonce,
+		Active:           true,
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Create(tx, w); err != nil {
+			return err
+		}
+		return logWebhookActivity(tx, w, in.UserID, "webhook_created", "Webhook added: "+w.URL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.EventList = events
+	return &WebhookWithSecret{Webhook: w, Secret: secret}, nil
+}
+
+// List returns the user's personal webhooks, or a team's.
+func (s *WebhookService) List(orgID, userID uint, teamID *uint) ([]models.Webhook, error) {
+	if teamID == nil {
+		return s.Repo.ListPersonal(s.DB, orgID, userID)
+	}
+	if err := s.requireTeamManager(orgID, *teamID, userID); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListForTeam(s.DB, orgID, *teamID)
+}
+
+// UpdateWebhookInput changes the fields that are set. Enabling a webhook
+// clears its failure count; deliveries queued while it was disabled are
+// then sent.
+type UpdateWebhookInput struct {
+	OrganizationID uint
+	UserID         uint
+	ID             uint
+	URL            *string
+	Description    *string
+	Events         []string // nil leaves them unchanged
+	Active         *bool
+}
+
+func (s *WebhookService) Update(in UpdateWebhookInput) (*models.Webhook, error) {
+	w, err := s.authorize(in.OrganizationID, in.UserID, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	if in.URL != nil {
+		u, err := webhook.CheckURL(strings.TrimSpace(*in.URL), s.AllowPrivate)
+		if err != nil {
+			return nil, err
+		}
+		if u.String() != w.URL {
+			w.URL = u.String()
+			changes = append(changes, "url")
+		}
+	}
+	if in.Description != nil {
+		if len(*in.Description) > 255 {
+			return nil, errors.New("description must be at most 255 characters")
+		}
+		w.Description = *in.Description
+		changes = append(changes, "description")
+	}
+	if in.Events != nil {
+		events, err := webhook.ParseEvents(in.Events)
+		if err != nil {
+			return nil, err
+		}
+		w.Events = strings.Join(events, ",")
+		changes = append(changes, "events")
+	}
+
+	activity, message := "webhook_updated", ""
+	if in.Active != nil && *in.Active != w.Active {
+		now := time.Now()
+		w.Active = *in.Active
+		if w.Active {
+			w.ConsecutiveFailures = 0
+			w.DisabledAt = nil
+			w.DisabledReason = ""
+			activity, message = "webhook_enabled", "Webhook enabled: "+w.URL
+		} else {
+			w.DisabledAt = &now
+			w.DisabledReason = "disabled by user"
+			activity, message = "webhook_disabled", "Webhook disabled: "+w.URL
+		}
+	} else if len(changes) == 0 {
+		return w, nil
+	} else {
+		message = fmt.Sprintf("Webhook updated (%s): %s", strings.Join(changes, ", "), w.URL)
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, w); err != nil {
+			return err
+		}
+		return logWebhookActivity(tx, w, in.UserID, activity, message)
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.EventList = strings.Split(w.Events, ",")
+	return w, nil
+}
+
+// RotateSecret replaces the signing secret. Deliveries already queued are
+// signed with the new one.
+func (s *WebhookService) RotateSecret(orgID, userID, id uint) (*WebhookWithSecret, error) {
+	w, err := s.authorize(orgID, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if w.SecretCiphertext, w.SecretNonce, err = utils.EncryptAPIKey(s.MasterKey, secret); err != nil {
+		return nil, err
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Update(tx, w); err != nil {
+			return err
+		}
+		return logWebhookActivity(tx, w, userID, "webhook_secret_rotated", "Webhook secret rotated: "+w.URL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookWithSecret{Webhook: w, Secret: secret}, nil
+}
+
+// Delete removes a webhook and its delivery log.
+func (s *WebhookService) Delete(orgID, userID, id uint) error {
+	w, err := s.authorize(orgID, userID, id)
+	if err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.Delete(tx, w.ID); err != nil {
+			return err
+		}
+		return logWebhookActivity(tx, w, userID, "webhook_deleted", "Webhook removed: "+w.URL)
+	})
+}
+
+type WebhookDeliveriesInput struct {
+	OrganizationID uint
+	UserID         uint
+	WebhookID      uint
+	Status         string // pending, succeeded or failed; empty for all
+	Before         uint   // delivery id to page back from
+	Limit          int
+}
+
+// Deliveries returns a webhook's delivery log, newest first.
+func (s *WebhookService) Deliveries(in WebhookDeliveriesInput) ([]models.WebhookDelivery, error) {
+	w, err := s.authorize(in.OrganizationID, in.UserID, in.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	switch in.Status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		return nil, fmt.Errorf("invalid status %q", in.Status)
+	}
+	if in.Limit <= 0 {
+		in.Limit = defaultDeliveryPageSize
+	}
+	if in.Limit > maxDeliveryPageSize {
+		in.Limit = maxDeliveryPageSize
+	}
+	return s.Repo.ListDeliveries(s.DB, w.ID, in.Status, in.Before, in.Limit)
+}
+
+// Redeliver queues a past delivery's payload again, as a new delivery with
+// its own attempts. The event id is kept so receivers can recognise it.
+func (s *WebhookService) Redeliver(orgID, userID, deliveryID uint) (*models.WebhookDelivery, error) {
+	orig, err := s.Repo.GetDelivery(s.DB, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.authorize(orgID, userID, orig.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	if !w.Active {
+		return nil, ErrWebhookDisabled
+	}
+	now := time.Now()
+	d := &models.WebhookDelivery{
+		WebhookID:     w.ID,
+		EventID:       orig.EventID,
+		EventType:     orig.EventType,
+		Payload:       orig.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+		RedeliveryOf:  &orig.ID,
+		Manual:        true,
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.Repo.CreateDelivery(tx, d); err != nil {
+			return err
+		}
+		return logWebhookActivity(tx, w, userID, "webhook_redelivered",
+			fmt.Sprintf("Webhook delivery #%d queued again: %s", orig.ID, w.URL))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// authorize loads a webhook the user may manage: their own personal one, or
+// a team one when they own or administer the team.
+func (s *WebhookService) authorize(orgID, userID, id uint) (*models.Webhook, error) {
+	w, err := s.Repo.GetByID(s.DB, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w.TeamID != nil {
+		if err := s.requireTeamManager(orgID, *w.TeamID, userID); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	if w.OwnerID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return w, nil
+}
+
+func (s *WebhookService) requireTeamManager(orgID, teamID, userID uint) error {
+	ok, err := isTeamManager(s.Teams, s.Members, s.DB, orgID, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWebhookForbidden
+	}
+	return nil
+}
+
+func logWebhookActivity(tx *gorm.DB, w *models.Webhook, userID uint, activityType, message string) error {
+	return enqueueActivity(tx, &models.Activity{
+		UserID:         userID,
+		Type:           activityType,
+		Entity:         "webhook",
+		EntityID:       w.ID,
+		Message:        message,
+		OrganizationID: w.OrganizationID,
+		TeamID:         w.TeamID,
+	})
+}
+
+// Name, Handles and Deliver make the service an outbox subscriber.
+
+func (s *WebhookService) Name() string { return "webhooks" }
+
+func (s *WebhookService) Handles(topic string) bool { return topic == TopicActivity }
+
+// Deliver queues the activity for every active webhook in its organization
+// that subscribes to its type and may see it. A redelivered event is not
+// queued twice.
+func (s *WebhookService) Deliver(ctx context.Context, e Event) error {
+	var a models.Activity
+	if err := json.Unmarshal(e.Payload, &a); err != nil {
+		return err
+	}
+	if a.OrganizationID == 0 {
+		return nil
+	}
+	db := s.DB.WithContext(ctx)
+	hooks, err := s.Repo.ListActive(db, a.OrganizationID)
+	if err != nil {
+		return err
+	}
+	var subscribed []*models.Webhook
+	for i := range hooks {
+		if webhook.Matches(hooks[i].EventList, a.Type) {
+			subscribed = append(subscribed, &hooks[i])
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	users := map[uint]bool{a.UserID: true}
+	teams := map[uint]bool{}
+	if a.TeamID != nil {
+		teams[*a.TeamID] = true
+	}
+	if a.Entity == "apikey" && a.EntityID != 0 {
+		owner, keyTeams, err := s.Repo.KeyAudience(db, a.EntityID)
+		if err != nil {
+			return err
+		}
+		users[owner] = true
+		for _, t := range keyTeams {
+			teams[t] = true
+		}
+	}
+
+	var teamID uint
+	if a.TeamID != nil {
+		teamID = *a.TeamID
+	}
+	body, err := json.Marshal(webhook.Payload{
+		ID:             e.ID,
+		Type:           a.Type,
+		CreatedAt:      a.CreatedAt,
+		OrganizationID: a.OrganizationID,
+		TeamID:         teamID,
+		ActorID:        a.UserID,
+		Principal:      a.Principal,
+		Entity:         a.Entity,
+		EntityID:       a.EntityID,
+		Message:        a.Message,
+		Outcome:        a.Outcome,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, w := range subscribed {
+			if w.TeamID != nil && !teams[*w.TeamID] || w.TeamID == nil && !users[w.OwnerID] {
+				continue
+			}
+			exists, err := s.Repo.HasDelivery(tx, w.ID, e.ID)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			err = s.Repo.CreateDelivery(tx, &models.WebhookDelivery{
+				WebhookID:     w.ID,
+				EventID:       e.ID,
+				EventType:     a.Type,
+				Payload:       string(body),
+				Status:        models.DeliveryPending,
+				NextAttemptAt: &now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Start sends due deliveries every Interval until ctx is done, and drops
+// finished ones after 30 days.
+func (s *WebhookService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		var lastPurge time.Time
+		for {
+			now := time.Now()
+			if _, err := s.RunOnce(ctx, now); err != nil {
+				fmt.Printf("webhook dispatch failed: %v\n", err)
+			}
+			if now.Sub(lastPurge) >= webhookPurgeEvery {
+				if err := s.Repo.PurgeDeliveries(s.DB, now.Add(-webhookRetention)); err != nil {
+					fmt.Printf("webhook delivery purge failed: %v\n", err)
+				}
+				lastPurge = now
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce attempts the deliveries that are due and returns how many
+// succeeded.
+func (s *WebhookService) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.Repo.ListDueDeliveries(s.DB, now, webhookBatch)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for i := range due {
+		d := &due[i]
+		ok, err := s.Repo.ClaimDelivery(s.DB, d.ID, now, now.Add(webhookLease))
+		if err != nil {
+			return sent, err
+		}
+		if !ok {
+			continue
+		}
+		if s.attempt(ctx, d) {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// attempt POSTs one claimed delivery and records the outcome.
+func (s *WebhookService) attempt(ctx context.Context, d *models.WebhookDelivery) bool {
+	w := d.Webhook
+	res := deliveryResult{}
+	secret, err := utils.DecryptAPIKey(s.MasterKey, w.SecretCiphertext, w.SecretNonce)
+	if err != nil {
+		res.err = fmt.Errorf("signing secret: %w", err)
+	} else {
+		res = s.post(ctx, w, d, secret)
+	}
+	if err := s.record(d.ID, w.ID, res); err != nil {
+		fmt.Printf("failed to record webhook delivery %d: %v\n", d.ID, err)
+	}
+	return res.ok()
+}
+
+type deliveryResult struct {
+	code     int
+	body     string
+	err      error
+	duration time.Duration
+	at       time.Time
+}
+
+func (r deliveryResult) ok() bool {
+	return r.err == nil && r.code >= 200 && r.code < 300
+}
+
+func (s *WebhookService) post(ctx context.Context, w *models.Webhook, d *models.WebhookDelivery, secret string) deliveryResult {
+	res := deliveryResult{at: time.Now()}
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		res.err = err
+		return res
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OnePassword-Webhooks/1.0")
+	req.Header.Set(webhook.EventHeader, d.EventType)
+	req.Header.Set(webhook.DeliveryHeader, strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(secret, res.at, body))
+
+	resp, err := s.Client.Do(req)
+	res.duration = time.Since(res.at)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	res.code, res.body = resp.StatusCode, string(b)
+	if !res.ok() {
+		res.err = fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return res
+}
+
+// record saves an attempt's outcome on the delivery and the webhook. A
+// failure schedules a retry, or gives up after webhookMaxAttempts; once the
+// webhook has failed webhookDisableAfter times in a row it is disabled and
+// its owner told.
+func (s *WebhookService) record(deliveryID, webhookID uint, res deliveryResult) error {
+	if res.at.IsZero() {
+		res.at = time.Now()
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		w, err := s.Repo.LockByID(tx, webhookID)
+		if err != nil {
+			return err
+		}
+		d, err := s.Repo.GetDelivery(tx, deliveryID)
+		if err != nil {
+			return err
+		}
+
+		d.Attempts++
+		d.LastAttemptAt = &res.at
+		d.LockedUntil = nil
+		d.ResponseCode = res.code
+		d.ResponseBody = truncate(res.body, webhookResponseLimit)
+		d.DurationMs = res.duration.Milliseconds()
+		d.Error = ""
+		if res.ok() {
+			d.Status = models.DeliverySucceeded
+			d.DeliveredAt = &res.at
+			d.NextAttemptAt = nil
+			w.ConsecutiveFailures = 0
+			w.LastSuccessAt = &res.at
+		} else {
+			d.Error = truncate(res.err.Error(), 1024)
+			if d.Attempts >= webhookMaxAttempts {
+				d.Status = models.DeliveryFailed
+				d.NextAttemptAt = nil
+			} else {
+				next := res.at.Add(webhook.Backoff(d.Attempts))
+				d.NextAttemptAt = &next
+			}
+			w.ConsecutiveFailures++
+			w.LastFailureAt = &res.at
+		}
+		if err := s.Repo.UpdateDelivery(tx, d); err != nil {
+			return err
+		}
+
+		if !res.ok() && w.Active && w.ConsecutiveFailures >= webhookDisableAfter {
+			if err := s.disable(tx, w, res.at); err != nil {
+				return err
+			}
+		}
+		return s.Repo.Update(tx, w)
+	})
+}
+
+// disable turns off a webhook that keeps failing. Its pending deliveries
+// are kept and go out if it is enabled again.
+func (s *WebhookService) disable(tx *gorm.DB, w *models.Webhook, at time.Time) error {
+	w.Active = false
+	w.DisabledAt = &at
+	w.DisabledReason = fmt.Sprintf("disabled after %d failed deliveries in a row", w.ConsecutiveFailures)
+
+	err := s.Notifications.NotifyTx(tx, []uint{w.OwnerID}, models.Notification{
+		Type:     "webhook_disabled",
+		Title:    "Webhook disabled",
+		Body:     fmt.Sprintf("%s was %s. Fix the endpoint, then enable it again to send the queued events.", w.URL, w.DisabledReason),
+		Entity:   "webhook",
+		EntityID: w.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return logWebhookActivity(tx, w, w.OwnerID, "webhook_disabled", "Webhook "+w.DisabledReason+": "+w.URL)
+}
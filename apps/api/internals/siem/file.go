@@ -0,0 +1,103 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+const (
+	defaultMaxBytes = 100 << 20
+	defaultMaxFiles = 5
+)
+
+// FileSink appends entries to a JSON Lines file. When the file grows past
+// MaxBytes it is renamed to path.1 (path.1 to path.2, and so on) and a new
+// one started; MaxFiles rotated files are kept.
+type FileSink struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	f    *os.File
+	size int64
+}
+
+func NewFileSink(c Config) *FileSink {
+	s := &FileSink{path: c.Path, maxBytes: c.MaxBytes, maxFiles: c.MaxFiles}
+	if s.maxBytes <= 0 {
+		s.maxBytes = defaultMaxBytes
+	}
+	if s.maxFiles <= 0 {
+		s.maxFiles = defaultMaxFiles
+	}
+	return s
+}
+
+func (s *FileSink) Send(ctx context.Context, batch []audit.Entry) error {
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size >= s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	w := bufio.NewWriter(s.f)
+	enc := json.NewEncoder(w)
+	for _, e := range batch {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	n := w.Buffered()
+	if err := w.Flush(); err != nil {
+		s.Close()
+		return err
+	}
+	s.size += int64(n)
+	return s.f.Sync()
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f, s.size = f, st.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxFiles))
+	for i := s.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
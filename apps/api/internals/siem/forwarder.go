@@ -0,0 +1,285 @@
+package siem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+const (
+	defaultBufferSize    = 1000
+	defaultBatchSize     = 100
+	defaultMaxSpillBytes = 1 << 30
+
+	flushInterval = time.Second
+	minBackoff    = time.Second
+	maxBackoff    = time.Minute
+)
+
+var (
+	ErrBufferFull = errors.New("sink buffer is full")
+	ErrStopped    = errors.New("sink forwarder has stopped")
+)
+
+// Health is a sink's state as reported by GET /audit/sinks.
+type Health struct {
+	Name                string     `json:"name"`
+	Type                string     `json:"type"`
+	Target              string     `json:"target"`
+	Healthy             bool       `json:"healthy"` // the last send succeeded
+	Buffered            int        `json:"buffered"`
+	SpilledBytes        int64      `json:"spilled_bytes"`
+	Sent                uint64     `json:"sent"`
+	Rejected            uint64     `json:"rejected"` // entries refused with buffer and spill full
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Forwarder feeds one sink. Entries wait in memory and go out in batches;
+// while the sink fails, the batch is retried with backoff, and entries that
+// no longer fit in memory are spilled to disk and sent once it recovers.
+// Entries stay in order, except that on shutdown those still in memory are
+// spilled after ones already on disk.
+type Forwarder struct {
+	cfg   Config
+	sink  Sink
+	spill *Spill // nil without a spill directory
+
+	mu      sync.Mutex
+	buf     []audit.Entry
+	wake    chan struct{}
+	health  Health
+	stopped bool // shut down; nothing taken in now would be sent or spilled
+
+	bufferSize int
+	batchSize  int
+	done       chan struct{}
+}
+
+// NewForwarder wraps sink. Spill files go in spillDir; without one, entries
+// that do not fit in memory are refused.
+func NewForwarder(c Config, sink Sink, spillDir string) (*Forwarder, error) {
+	f := &Forwarder{
+		cfg:        c,
+		sink:       sink,
+		wake:       make(chan struct{}, 1),
+		health:     Health{Name: c.Name, Type: c.Type, Target: c.Target(), Healthy: true},
+		bufferSize: c.BufferSize,
+		batchSize:  c.BatchSize,
+		done:       make(chan struct{}),
+	}
+	if f.bufferSize <= 0 {
+		f.bufferSize = defaultBufferSize
+	}
+	if f.batchSize <= 0 {
+		f.batchSize = defaultBatchSize
+	}
+	if spillDir != "" {
+		max := c.MaxSpillBytes
+		if max <= 0 {
+			max = defaultMaxSpillBytes
+		}
+		var err error
+		if f.spill, err = OpenSpill(spillDir, c.Name, max); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *Forwarder) Name() string { return f.cfg.Name }
+
+// Enqueue hands an entry to the forwarder. It fails only when the entry
+// cannot be kept: memory is full and so is the spill queue, or there is none,
+// or the forwarder has already shut down.
+func (f *Forwarder) Enqueue(e audit.Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return ErrStopped
+	}
+	// once entries are on disk, later ones queue behind them
+	if len(f.buf) < f.bufferSize && (f.spill == nil || f.spill.Pending() == 0) {
+		f.buf = append(f.buf, e)
+		f.signal()
+		return nil
+	}
+	if f.spill == nil {
+		f.health.Rejected++
+		return ErrBufferFull
+	}
+	if err := f.spill.Append(e); err != nil {
+		f.health.Rejected++
+		return err
+	}
+	f.signal()
+	return nil
+}
+
+func (f *Forwarder) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Health reports the sink's state.
+func (f *Forwarder) Health() Health {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := f.health
+	h.Buffered = len(f.buf)
+	if f.spill != nil {
+		h.SpilledBytes = f.spill.Pending()
+	}
+	return h
+}
+
+// Start sends entries until ctx is done, then spills what is left in memory
+// and closes the sink. Done is closed when it has finished.
+func (f *Forwarder) Start(ctx context.Context) {
+	go f.run(ctx)
+}
+
+// Done is closed once the forwarder has stopped.
+func (f *Forwarder) Done() <-chan struct{} { return f.done }
+
+func (f *Forwarder) run(ctx context.Context) {
+	defer close(f.done)
+	defer f.sink.Close()
+	timer := time.NewTimer(flushInterval)
+	defer timer.Stop()
+
+	for {
+		batch, spillNext, fromSpill := f.next()
+		if len(batch) == 0 && !fromSpill {
+			select {
+			case <-ctx.Done():
+				f.shutdown(nil)
+				return
+			case <-f.wake:
+			case <-timer.C:
+				timer.Reset(flushInterval)
+			}
+			continue
+		}
+		if len(batch) > 0 && !f.send(ctx, batch) {
+			if !fromSpill {
+				f.shutdown(batch)
+			} else {
+				f.shutdown(nil)
+			}
+			return
+		}
+		if fromSpill {
+			if err := f.spill.Commit(spillNext); err != nil {
+				f.fail(fmt.Errorf("spill commit: %w", err))
+			}
+		}
+	}
+}
+
+// next takes the next batch: from memory, or from the spill queue once
+// memory is empty.
+func (f *Forwarder) next() ([]audit.Entry, int64, bool) {
+	f.mu.Lock()
+	if len(f.buf) > 0 {
+		n := min(len(f.buf), f.batchSize)
+		batch := append([]audit.Entry(nil), f.buf[:n]...)
+		f.buf = f.buf[n:]
+		f.mu.Unlock()
+		return batch, 0, false
+	}
+	f.mu.Unlock()
+
+	if f.spill == nil || f.spill.Pending() == 0 {
+		return nil, 0, false
+	}
+	batch, next, err := f.spill.Peek(f.batchSize)
+	if err != nil {
+		f.fail(fmt.Errorf("spill read: %w", err))
+		return nil, 0, false
+	}
+	return batch, next, true
+}
+
+// send retries a batch with backoff until it goes through; it returns
+// false if ctx is done first.
+func (f *Forwarder) send(ctx context.Context, batch []audit.Entry) bool {
+	backoff := minBackoff
+	for {
+		sctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := f.sink.Send(sctx, batch)
+		cancel()
+		if err == nil {
+			now := time.Now()
+			f.mu.Lock()
+			f.health.Healthy = true
+			f.health.ConsecutiveFailures = 0
+			f.health.Sent += uint64(len(batch))
+			f.health.LastSuccessAt = &now
+			f.mu.Unlock()
+			return true
+		}
+		f.fail(err)
+		f.overflow()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// overflow moves what is in memory to the spill queue while the sink is
+// down, so the buffer has room for new entries.
+func (f *Forwarder) overflow() {
+	if f.spill == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.buf) < f.bufferSize {
+		return
+	}
+	if err := f.spill.Append(f.buf...); err != nil {
+		return
+	}
+	f.buf = nil
+}
+
+func (f *Forwarder) fail(err error) {
+	now := time.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.health.Healthy = false
+	f.health.ConsecutiveFailures++
+	f.health.LastError = err.Error()
+	f.health.LastErrorAt = &now
+}
+
+// shutdown keeps unsent entries on disk for the next start.
+func (f *Forwarder) shutdown(inflight []audit.Entry) {
+	f.mu.Lock()
+	pending := append(inflight, f.buf...)
+	f.buf = nil
+	f.stopped = true
+	f.mu.Unlock()
+	if f.spill == nil {
+		return
+	}
+	if len(pending) > 0 {
+		if err := f.spill.Append(pending...); err != nil {
+			fmt.Printf("audit sink %s: %d entries lost on shutdown: %v\n", f.cfg.Name, len(pending), err)
+		}
+	}
+	f.spill.Close()
+}
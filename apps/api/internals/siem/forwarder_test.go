@@ -0,0 +1,241 @@
+package siem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+func TestParseConfigs(t *testing.T) {
+	cs, err := ParseConfigs(`[{"type":"syslog","network":"tls","address":"siem:6514"},{"name":"archive","type":"file","path":"/tmp/a.jsonl"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs[0].Name != "syslog1" || cs[0].Target() != "tls://siem:6514" || cs[1].Name != "archive" {
+		t.Fatalf("configs = %+v", cs)
+	}
+	for _, bad := range []string{
+		`[{"type":"syslog","network":"quic","address":"x:1"}]`,
+		`[{"type":"http"}]`,
+		`[{"type":"kafka"}]`,
+		`[{"name":"a","type":"file","path":"x"},{"name":"a","type":"file","path":"y"}]`,
+		`[{"name":"../etc","type":"file","path":"x"}]`,
+	} {
+		if _, err := ParseConfigs(bad); err == nil {
+			t.Fatalf("ParseConfigs(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var got []audit.Entry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0k" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(Config{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer t0k"}})
+	if err := sink.Send(context.Background(), []audit.Entry{entry(1, "a"), entry(2, "b")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Seq != 2 {
+		t.Fatalf("collector got %+v", got)
+	}
+
+	sink = NewHTTPSink(Config{URL: srv.URL})
+	if err := sink.Send(context.Background(), []audit.Entry{entry(3, "c")}); err == nil {
+		t.Fatal("expected an error for a 401")
+	}
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink := NewFileSink(Config{Path: path, MaxBytes: 300, MaxFiles: 2})
+	defer sink.Close()
+	for i := int64(1); i <= 6; i++ {
+		if err := sink.Send(context.Background(), []audit.Entry{entry(i, "x")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(name), err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatal("kept more than MaxFiles rotated files")
+	}
+}
+
+func TestSpillSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpill(dir, "q", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append(entry(1, "a"), entry(2, "b"), entry(3, "c"))
+	batch, next, err := s.Peek(2)
+	if err != nil || len(batch) != 2 {
+		t.Fatalf("Peek = %v, %v", batch, err)
+	}
+	s.Commit(next)
+	s.Close()
+
+	// a crash mid-append leaves half a line behind
+	f, _ := os.OpenFile(filepath.Join(dir, "q.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"seq":4,"ty`)
+	f.Close()
+
+	s, err = OpenSpill(dir, "q", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Append(entry(5, "e"))
+	batch, next, _ = s.Peek(10)
+	if len(batch) != 2 || batch[0].Seq != 3 || batch[1].Seq != 5 {
+		t.Fatalf("after reopen got %+v", batch)
+	}
+	s.Commit(next)
+	if s.Pending() != 0 {
+		t.Fatalf("pending = %d after committing everything", s.Pending())
+	}
+
+	small, _ := OpenSpill(t.TempDir(), "q", 10)
+	defer small.Close()
+	if err := small.Append(entry(1, "a")); !errors.Is(err, ErrSpillFull) {
+		t.Fatalf("Append over the cap = %v", err)
+	}
+}
+
+// flakySink fails until it is told to recover.
+type flakySink struct {
+	mu   sync.Mutex
+	down bool
+	got  []int64
+}
+
+func (s *flakySink) Send(ctx context.Context, batch []audit.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	for _, e := range batch {
+		s.got = append(s.got, e.Seq)
+	}
+	return nil
+}
+
+func (s *flakySink) Close() error { return nil }
+
+func (s *flakySink) set(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func (s *flakySink) received() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.got...)
+}
+
+func TestForwarderSpillsWhileSinkIsDown(t *testing.T) {
+	sink := &flakySink{down: true}
+	f, err := NewForwarder(Config{Name: "flaky", Type: TypeHTTP, URL: "http://x", BufferSize: 3, BatchSize: 2}, sink, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.Start(ctx)
+
+	for i := int64(1); i <= 10; i++ {
+		if err := f.Enqueue(entry(i, "x")); err != nil {
+			t.Fatalf("Enqueue(%d): %v", i, err)
+		}
+	}
+	waitFor(t, func() bool { return !f.Health().Healthy })
+	if h := f.Health(); h.SpilledBytes == 0 || !strings.Contains(h.LastError, "refused") {
+		t.Fatalf("health while down = %+v", h)
+	}
+
+	sink.set(false)
+	waitFor(t, func() bool { return len(sink.received()) == 10 })
+	for i, seq := range sink.received() {
+		if seq != int64(i+1) {
+			t.Fatalf("received out of order: %v", sink.received())
+		}
+	}
+	if h := f.Health(); !h.Healthy || h.Sent != 10 || h.SpilledBytes != 0 || h.Buffered != 0 {
+		t.Fatalf("health after recovery = %+v", h)
+	}
+}
+
+func TestForwarderWithoutSpillRefusesOverflow(t *testing.T) {
+	f, _ := NewForwarder(Config{Name: "mem", Type: TypeHTTP, URL: "http://x", BufferSize: 2}, &flakySink{down: true}, "")
+	f.Enqueue(entry(1, "x"))
+	f.Enqueue(entry(2, "x"))
+	if err := f.Enqueue(entry(3, "x")); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("Enqueue = %v, want ErrBufferFull", err)
+	}
+	if h := f.Health(); h.Rejected != 1 || h.Buffered != 2 {
+		t.Fatalf("health = %+v", h)
+	}
+}
+
+func TestForwarderSpillsOnShutdown(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewForwarder(Config{Name: "down", Type: TypeHTTP, URL: "http://x"}, &flakySink{down: true}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f.Start(ctx)
+	for i := int64(1); i <= 3; i++ {
+		if err := f.Enqueue(entry(i, "x")); err != nil {
+			t.Fatalf("Enqueue(%d): %v", i, err)
+		}
+	}
+	cancel()
+	<-f.Done()
+	if err := f.Enqueue(entry(4, "x")); !errors.Is(err, ErrStopped) {
+		t.Fatalf("Enqueue after shutdown = %v, want ErrStopped", err)
+	}
+
+	sink := &flakySink{}
+	f, err = NewForwarder(Config{Name: "down", Type: TypeHTTP, URL: "http://x"}, sink, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	f.Start(ctx)
+	waitFor(t, func() bool { return len(sink.received()) == 3 })
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
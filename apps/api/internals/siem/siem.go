@@ -0,0 +1,165 @@
+// Package siem forwards audit entries to external collectors as they are
+// logged: RFC 5424 syslog over UDP, TCP or TLS, a JSON-over-HTTP endpoint,
+// or a local file with rotation. Each sink sits behind a Forwarder that
+// buffers, batches and retries, and spills to disk while the sink is down.
+//
+// Like package audit it only depends on the standard library, so the sinks
+// can be tested against local listeners.
+package siem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+// Sink types.
+const (
+	TypeSyslog = "syslog"
+	TypeHTTP   = "http"
+	TypeFile   = "file"
+)
+
+// Sink delivers batches of entries. Send is only called from one goroutine
+// at a time; an error means the whole batch is retried.
+type Sink interface {
+	Send(ctx context.Context, batch []audit.Entry) error
+	Close() error
+}
+
+// Config describes one sink; AUDIT_SINKS holds a JSON array of them.
+type Config struct {
+	Name string `json:"name"` // unique, used in health reports and spill file names
+	Type string `json:"type"` // TypeSyslog, TypeHTTP or TypeFile
+
+	// syslog
+	Network    string `json:"network,omitempty"`  // udp, tcp or tls
+	Address    string `json:"address,omitempty"`  // host:port
+	Facility   *int   `json:"facility,omitempty"` // default 13, log audit
+	AppName    string `json:"app_name,omitempty"`
+	CAFile     string `json:"ca_file,omitempty"`     // PEM roots for tls, instead of the system's
+	ServerName string `json:"server_name,omitempty"` // for tls, when it differs from Address
+
+	// http
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"` // e.g. Authorization
+
+	// file
+	Path     string `json:"path,omitempty"`
+	MaxBytes int64  `json:"max_bytes,omitempty"` // rotate past this size, default 100 MiB
+	MaxFiles int    `json:"max_files,omitempty"` // rotated files kept, default 5
+
+	// forwarding
+	BufferSize    int   `json:"buffer_size,omitempty"`     // entries held in memory, default 1000
+	BatchSize     int   `json:"batch_size,omitempty"`      // entries per Send, default 100
+	MaxSpillBytes int64 `json:"max_spill_bytes,omitempty"` // disk spill cap, default 1 GiB
+}
+
+// Target describes where a sink sends to, for health reports.
+func (c Config) Target() string {
+	switch c.Type {
+	case TypeSyslog:
+		return c.Network + "://" + c.Address
+	case TypeHTTP:
+		return c.URL
+	default:
+		return c.Path
+	}
+}
+
+// ParseConfigs reads a JSON array of sink configs; empty means none. Sinks
+// without a name are named after their type and position.
+func ParseConfigs(s string) ([]Config, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var cs []Config
+	if err := json.Unmarshal([]byte(s), &cs); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i := range cs {
+		c := &cs[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("%s%d", c.Type, i+1)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("sink %q is configured twice", c.Name)
+		}
+		seen[c.Name] = true
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("sink %q: %w", c.Name, err)
+		}
+	}
+	return cs, nil
+}
+
+func (c *Config) validate() error {
+	for _, r := range c.Name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return errors.New("name may only hold letters, digits, - and _")
+		}
+	}
+	switch c.Type {
+	case TypeSyslog:
+		if c.Network != "udp" && c.Network != "tcp" && c.Network != "tls" {
+			return errors.New("network must be udp, tcp or tls")
+		}
+		if c.Address == "" {
+			return errors.New("address is required")
+		}
+		if c.Facility != nil && (*c.Facility < 0 || *c.Facility > 23) {
+			return errors.New("facility must be 0 to 23")
+		}
+	case TypeHTTP:
+		if c.URL == "" {
+			return errors.New("url is required")
+		}
+	case TypeFile:
+		if c.Path == "" {
+			return errors.New("path is required")
+		}
+	default:
+		return errors.New("type must be syslog, http or file")
+	}
+	return nil
+}
+
+// NewSink builds the sink a config describes. Connections are made on the
+// first Send.
+func NewSink(c Config) (Sink, error) {
+	switch c.Type {
+	case TypeSyslog:
+		return NewSyslogSink(c)
+	case TypeHTTP:
+		return NewHTTPSink(c), nil
+	case TypeFile:
+		return NewFileSink(c), nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", c.Type)
+}
+
+// Open builds a forwarder for every config, spilling under spillDir (no
+// spilling when empty).
+func Open(configs []Config, spillDir string) ([]*Forwarder, error) {
+	var out []*Forwarder
+	for _, c := range configs {
+		sink, err := NewSink(c)
+		if err != nil {
+			return nil, err
+		}
+		f, err := NewForwarder(c, sink, spillDir)
+		if err != nil {
+			return nil, fmt.Errorf("sink %q: %w", c.Name, err)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// sendTimeout bounds one network Send.
+const sendTimeout = 10 * time.Second
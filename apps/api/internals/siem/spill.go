@@ -0,0 +1,168 @@
+package siem
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+var ErrSpillFull = errors.New("spill queue is full")
+
+// Spill is a disk-backed FIFO of entries: a JSON Lines file read from a
+// committed offset, which is kept in a file next to it. The file is emptied
+// once everything in it has been committed.
+type Spill struct {
+	mu      sync.Mutex
+	path    string
+	offPath string
+	max     int64
+
+	w    *os.File
+	size int64 // bytes in the file
+	off  int64 // bytes already delivered
+}
+
+// OpenSpill opens (or creates) the spill queue name in dir, holding at most
+// max bytes of pending entries.
+func OpenSpill(dir, name string, max int64) (*Spill, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &Spill{
+		path:    filepath.Join(dir, name+".jsonl"),
+		offPath: filepath.Join(dir, name+".offset"),
+		max:     max,
+	}
+	w, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	st, err := w.Stat()
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	s.w, s.size = w, st.Size()
+	if err := s.endLine(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if b, err := os.ReadFile(s.offPath); err == nil {
+		s.off, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	}
+	if s.off < 0 || s.off > s.size {
+		s.off = 0
+	}
+	return s, nil
+}
+
+// endLine terminates a line left unfinished by a crash, so that the next
+// entry starts a line of its own and only the broken one is skipped.
+func (s *Spill) endLine() error {
+	if s.size == 0 {
+		return nil
+	}
+	last := make([]byte, 1)
+	if _, err := s.w.ReadAt(last, s.size-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	n, err := s.w.Write([]byte{'\n'})
+	s.size += int64(n)
+	return err
+}
+
+// Pending is the number of bytes waiting to be delivered.
+func (s *Spill) Pending() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size - s.off
+}
+
+// Append adds entries to the end of the queue.
+func (s *Spill) Append(entries ...audit.Entry) error {
+	var buf []byte
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.off+int64(len(buf)) > s.max {
+		return ErrSpillFull
+	}
+	n, err := s.w.Write(buf)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.w.Sync()
+}
+
+// Peek reads up to n entries from the head of the queue and returns the
+// offset to Commit once they are delivered. Unreadable lines are skipped.
+func (s *Spill) Peek(n int) ([]audit.Entry, int64, error) {
+	s.mu.Lock()
+	off, size := s.off, s.size
+	s.mu.Unlock()
+	if off >= size {
+		return nil, off, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(s.w, off, size-off))
+	var out []audit.Entry
+	next := off
+	for len(out) < n {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, off, err
+		}
+		next += int64(len(line))
+		var e audit.Entry
+		if json.Unmarshal(line, &e) == nil {
+			out = append(out, e)
+		}
+	}
+	return out, next, nil
+}
+
+// Commit drops everything before offset next.
+func (s *Spill) Commit(next int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if next > s.size {
+		next = s.size
+	}
+	s.off = next
+	if s.off == s.size {
+		if err := s.w.Truncate(0); err != nil {
+			return err
+		}
+		s.off, s.size = 0, 0
+	}
+	tmp := s.offPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(s.off, 10)), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.offPath)
+}
+
+func (s *Spill) Close() error {
+	return s.w.Close()
+}
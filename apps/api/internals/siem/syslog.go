@@ -0,0 +1,211 @@
+package siem
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+const (
+	defaultFacility = 13 // log audit
+	defaultAppName  = "one-password"
+
+	// structured data id; 32473 is the private enterprise number RFC 5424
+	// reserves for examples and documentation
+	sdID = "audit@32473"
+
+	// RFC 5424 severities
+	sevWarning = 4
+	sevInfo    = 6
+
+	maxUDPMessage = 8192
+)
+
+// SyslogSink writes RFC 5424 messages over UDP (one per datagram) or over
+// TCP or TLS with octet-counting framing (RFC 6587).
+type SyslogSink struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	procID   string
+	tls      *tls.Config
+
+	conn net.Conn
+}
+
+func NewSyslogSink(c Config) (*SyslogSink, error) {
+	s := &SyslogSink{
+		network:  c.Network,
+		address:  c.Address,
+		facility: defaultFacility,
+		appName:  c.AppName,
+		hostname: "-",
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+	if c.Facility != nil {
+		s.facility = *c.Facility
+	}
+	if s.appName == "" {
+		s.appName = defaultAppName
+	}
+	if h, err := os.Hostname(); err == nil && h != "" {
+		s.hostname = h
+	}
+	if c.Network == "tls" {
+		s.tls = &tls.Config{ServerName: c.ServerName, MinVersion: tls.VersionTLS12}
+		if s.tls.ServerName == "" {
+			s.tls.ServerName, _, _ = net.SplitHostPort(c.Address)
+		}
+		if c.CAFile != "" {
+			pem, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			s.tls.RootCAs = x509.NewCertPool()
+			if !s.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, errors.New("ca_file holds no PEM certificates")
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) Send(ctx context.Context, batch []audit.Entry) error {
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return err
+		}
+	}
+	deadline := time.Now().Add(sendTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.conn.SetWriteDeadline(deadline)
+
+	var buf []byte
+	for _, e := range batch {
+		msg := FormatSyslog(e, s.facility, s.hostname, s.appName, s.procID)
+		if s.network == "udp" {
+			if len(msg) > maxUDPMessage {
+				msg = msg[:maxUDPMessage]
+			}
+			if _, err := s.conn.Write([]byte(msg)); err != nil {
+				s.Close()
+				return err
+			}
+			continue
+		}
+		buf = strconv.AppendInt(buf, int64(len(msg)), 10)
+		buf = append(buf, ' ')
+		buf = append(buf, msg...)
+	}
+	if len(buf) > 0 {
+		if _, err := s.conn.Write(buf); err != nil {
+			// the collector may have seen part of the batch; it is resent whole
+			s.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial(ctx context.Context) error {
+	d := &net.Dialer{Timeout: sendTimeout}
+	var err error
+	switch s.network {
+	case "tls":
+		s.conn, err = (&tls.Dialer{NetDialer: d, Config: s.tls}).DialContext(ctx, "tcp", s.address)
+	default:
+		s.conn, err = d.DialContext(ctx, s.network, s.address)
+	}
+	return err
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// FormatSyslog renders an entry as an RFC 5424 message: the activity type is
+// the MSGID, its fields are structured data and its message the MSG. Denied
+// and failed actions are warnings, the rest informational.
+func FormatSyslog(e audit.Entry, facility int, hostname, appName, procID string) string {
+	sev := sevInfo
+	if e.Outcome == audit.OutcomeDenied || e.Outcome == audit.OutcomeFailure {
+		sev = sevWarning
+	}
+	var b strings.Builder
+	b.WriteString("<" + strconv.Itoa(facility*8+sev) + ">1 ")
+	b.WriteString(e.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z07:00"))
+	b.WriteString(" " + header(hostname, 255) + " " + header(appName, 48) + " " + header(procID, 128) + " " + header(e.Type, 32) + " ")
+
+	b.WriteString("[" + sdID)
+	params := [][2]string{
+		{"event", e.EventID},
+		{"seq", intParam(e.Seq)},
+		{"user", intParam(int64(e.UserID))},
+		{"org", intParam(int64(e.OrganizationID))},
+		{"team", intParam(int64(e.TeamID))},
+		{"entity", e.Entity},
+		{"entityId", intParam(int64(e.EntityID))},
+		{"outcome", e.Outcome},
+		{"reason", e.Reason},
+		{"principal", e.Principal},
+		{"auth", e.AuthMethod},
+		{"ip", e.IP},
+		{"requestId", e.RequestID},
+		{"hash", e.Hash},
+	}
+	for _, p := range params {
+		if p[1] != "" {
+			b.WriteString(" " + p[0] + `="` + sdEscaper.Replace(p[1]) + `"`)
+		}
+	}
+	b.WriteString("]")
+
+	if e.Message != "" {
+		b.WriteString(" \xEF\xBB\xBF" + e.Message)
+	}
+	return b.String()
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// header makes a header field printable US-ASCII without spaces, at most
+// max long; "-" stands for empty.
+func header(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func intParam(v int64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
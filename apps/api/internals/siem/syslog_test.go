@@ -0,0 +1,123 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intojhanurag/One-Password/apps/api/internals/audit"
+)
+
+func entry(seq int64, typ string) audit.Entry {
+	return audit.Entry{
+		Seq:       seq,
+		UserID:    7,
+		Type:      typ,
+		Entity:    "apikey",
+		EntityID:  42,
+		Message:   "API key revealed: stripe",
+		CreatedAt: time.Date(2026, 5, 4, 10, 30, 0, 123456000, time.UTC),
+		Outcome:   audit.OutcomeSuccess,
+		EventID:   "ev" + strconv.FormatInt(seq, 10),
+	}
+}
+
+func TestFormatSyslog(t *testing.T) {
+	e := entry(1, "apikey_revealed")
+	e.Outcome = audit.OutcomeDenied
+	e.Reason = `no "grant" [x]`
+	got := FormatSyslog(e, 13, "vault-1", "one-password", "99")
+
+	want := `<108>1 2026-05-04T10:30:00.123456Z vault-1 one-password 99 apikey_revealed ` +
+		`[audit@32473 event="ev1" seq="1" user="7" entity="apikey" entityId="42" outcome="denied" reason="no \"grant\" [x\]"]` +
+		" \xEF\xBB\xBFAPI key revealed: stripe"
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	if got := header("has space\tandé", 32); got != "hasspaceand" {
+		t.Fatalf("header = %q", got)
+	}
+	if got := header("", 32); got != "-" {
+		t.Fatalf("empty header = %q", got)
+	}
+}
+
+func TestSyslogTCPFramesMessages(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for len(msgs) < 2 {
+			n, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(n))
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				break
+			}
+			msgs = append(msgs, string(buf))
+		}
+		got <- msgs
+	}()
+
+	sink, err := NewSyslogSink(Config{Network: "tcp", Address: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Send(context.Background(), []audit.Entry{entry(1, "a_b"), entry(2, "c_d")}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msgs := <-got:
+		if len(msgs) != 2 || !strings.Contains(msgs[0], " a_b [") || !strings.Contains(msgs[1], " c_d [") {
+			t.Fatalf("messages = %q", msgs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no messages received")
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	sink, err := NewSyslogSink(Config{Network: "udp", Address: pc.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Send(context.Background(), []audit.Entry{entry(3, "team_created")}); err != nil {
+		t.Fatal(err)
+	}
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxUDPMessage)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<110>1 ") || !strings.Contains(msg, ` seq="3"`) {
+		t.Fatalf("datagram = %q", msg)
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidTimezone = errors.New("timezone must be an IANA name such as Europe/Berlin")
+
+// LoadTimezone resolves an IANA zone name; empty means UTC. "Local" is
+// refused because it means nothing to a client or to Postgres.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// StartOfDay is local midnight of t's day in loc. On days where midnight
+// is skipped by a DST change it is the first instant of the day.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// StartOfMonth is local midnight on the first of t's month in loc.
+func StartOfMonth(t time.Time, loc *time.Location) time.Time {
+	y, m, _ := t.In(loc).Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+}
+
+// AddDays moves a local midnight by n calendar days, which is not always
+// n*24 hours.
+func AddDays(day time.Time, n int) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d+n, 0, 0, 0, 0, day.Location())
+}
+
+// ParseDay parses a YYYY-MM-DD date as local midnight in loc.
+func ParseDay(s string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadTimezone(t *testing.T) {
+	if loc, err := LoadTimezone(""); err != nil || loc != time.UTC {
+		t.Fatalf("LoadTimezone(\"\") = %v, %v; want UTC", loc, err)
+	}
+	for _, bad := range []string{"Local", "Mars/Olympus", "../etc/passwd"} {
+		if _, err := LoadTimezone(bad); err != ErrInvalidTimezone {
+			t.Fatalf("LoadTimezone(%q) error = %v, want ErrInvalidTimezone", bad, err)
+		}
+	}
+}
+
+func TestStartOfDayAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+
+	// 01:30 UTC on 31 Mar 2024 is 03:30 in Berlin, just after clocks went forward
+	day := StartOfDay(time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC), berlin)
+	if want := time.Date(2024, 3, 30, 23, 0, 0, 0, time.UTC); !day.Equal(want) {
+		t.Fatalf("StartOfDay = %v, want %v", day.UTC(), want)
+	}
+	// that day has 23 hours
+	next := AddDays(day, 1)
+	if got := next.Sub(day); got != 23*time.Hour {
+		t.Fatalf("day length = %v, want 23h", got)
+	}
+	if got := StartOfMonth(next, berlin); !got.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, berlin)) {
+		t.Fatalf("StartOfMonth = %v", got)
+	}
+}
+
+func TestParseDay(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	d, err := ParseDay("2024-05-01", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 4, 30, 15, 0, 0, 0, time.UTC); !d.Equal(want) {
+		t.Fatalf("ParseDay = %v, want %v", d.UTC(), want)
+	}
+	if _, err := ParseDay("01/05/2024", tokyo); err == nil {
+		t.Fatal("expected ParseDay to reject a non-ISO date")
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import "testing"
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.4")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies returned error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{"direct", "203.0.113.7:5123", "", "203.0.113.7"},
+		{"untrusted peer sends header", "203.0.113.7:5123", "1.2.3.4", "203.0.113.7"},
+		{"one trusted proxy", "10.1.2.3:80", "198.51.100.9", "198.51.100.9"},
+		{"spoofed left of real client", "10.1.2.3:80", "1.2.3.4, 198.51.100.9", "198.51.100.9"},
+		{"proxy chain", "192.168.1.4:80", "198.51.100.9, 10.9.9.9", "198.51.100.9"},
+		{"only proxies", "10.1.2.3:80", "10.2.2.2", "10.2.2.2"},
+		{"garbage hop", "10.1.2.3:80", "198.51.100.9, nonsense", "10.1.2.3"},
+		{"mapped ipv6", "[::ffff:10.1.2.3]:80", "198.51.100.9", "198.51.100.9"},
+		{"ipv6 client", "[2001:db8::1]:443", "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientIP(tt.remoteAddr, tt.xff, trusted); got != tt.want {
+				t.Fatalf("ClientIP(%q, %q) = %q, want %q", tt.remoteAddr, tt.xff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies_Invalid(t *testing.T) {
+	if _, err := ParseTrustedProxies("10.0.0.0/8, not-an-ip"); err == nil {
+		t.Fatalf("expected an error for an invalid entry")
+	}
+}
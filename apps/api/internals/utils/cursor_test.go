@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	in := testCursor{Sort: "name", Value: "payments/stripe", ID: 42}
+	token, err := EncodeCursor(in)
+	if err != nil {
+		t.Fatalf("EncodeCursor returned error: %v", err)
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Fatalf("cursor %q is not URL-safe", token)
+	}
+
+	var out testCursor
+	if err := DecodeCursor(token, &out); err != nil {
+		t.Fatalf("DecodeCursor returned error: %v", err)
+	}
+	if out != in {
+		t.Fatalf("round trip = %+v, want %+v", out, in)
+	}
+}
+
+func TestCursor_RejectsGarbage(t *testing.T) {
+	var out testCursor
+	for _, bad := range []string{"not base64!", "bm90IGpzb24"} {
+		if err := DecodeCursor(bad, &out); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("DecodeCursor(%q) = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
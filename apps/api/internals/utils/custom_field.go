@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CustomFieldTypes are the value types a custom field may declare.
+var CustomFieldTypes = []string{"string", "number", "bool", "url", "email", "date"}
+
+// NormalizeFieldValue checks that value is valid for the field type and returns
+// it in canonical form (e.g. "TRUE" becomes "true", dates become YYYY-MM-DD).
+func NormalizeFieldValue(fieldType, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch fieldType {
+	case "string":
+		return value, nil
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a number", value)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a boolean", value)
+		}
+		return strconv.FormatBool(b), nil
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%q is not an http(s) URL", value)
+		}
+		return u.String(), nil
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an email address", value)
+		}
+		return strings.ToLower(addr.Address), nil
+	case "date":
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a YYYY-MM-DD date", value)
+		}
+		return d.Format("2006-01-02"), nil
+	default:
+		return "", fmt.Errorf("unknown field type %q (want one of %s)", fieldType, strings.Join(CustomFieldTypes, ", "))
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+func TestNormalizeFieldValue(t *testing.T) {
+	ok := []struct{ typ, in, want string }{
+		{"string", " Platform team ", "Platform team"},
+		{"number", "1200.50", "1200.5"},
+		{"bool", "TRUE", "true"},
+		{"url", "https://dashboard.stripe.com", "https://dashboard.stripe.com"},
+		{"email", "Ops <OPS@example.com>", "ops@example.com"},
+		{"date", "2026-03-01", "2026-03-01"},
+	}
+	for _, c := range ok {
+		got, err := NormalizeFieldValue(c.typ, c.in)
+		if err != nil {
+			t.Fatalf("NormalizeFieldValue(%q, %q) returned error: %v", c.typ, c.in, err)
+		}
+		if got != c.want {
+			t.Fatalf("NormalizeFieldValue(%q, %q) = %q, want %q", c.typ, c.in, got, c.want)
+		}
+	}
+
+	bad := []struct{ typ, in string }{
+		{"number", "ten"},
+		{"bool", "maybe"},
+		{"url", "ftp://example.com"},
+		{"email", "not-an-email"},
+		{"date", "01/03/2026"},
+		{"color", "red"},
+	}
+	for _, c := range bad {
+		if _, err := NormalizeFieldValue(c.typ, c.in); err == nil {
+			t.Fatalf("expected NormalizeFieldValue(%q, %q) to fail", c.typ, c.in)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Fingerprint returns a keyed hash of a secret value. Two values are equal
+// exactly when their fingerprints are, but the fingerprint reveals nothing
+// about the value to anyone without the master key.
+func Fingerprint(masterKey []byte, plaintext string) string {
+	sub := hmac.New(sha256.New, masterKey)
+	sub.Write([]byte("secret-fingerprint"))
+
+	mac := hmac.New(sha256.New, sub.Sum(nil))
+	mac.Write([]byte(plaintext))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
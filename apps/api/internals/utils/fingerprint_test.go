@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFingerprint_EqualValuesMatch(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	if Fingerprint(key, "sk_live_123") != Fingerprint(key, "sk_live_123") {
+		t.Fatalf("expected identical fingerprints for identical values")
+	}
+	if Fingerprint(key, "sk_live_123") == Fingerprint(key, "sk_live_124") {
+		t.Fatalf("expected different fingerprints for different values")
+	}
+	if len(Fingerprint(key, "x")) != 32 {
+		t.Fatalf("expected 32-char hex fingerprint")
+	}
+}
+
+func TestFingerprint_DependsOnMasterKey(t *testing.T) {
+	a := Fingerprint(bytes.Repeat([]byte{1}, 32), "value")
+	b := Fingerprint(bytes.Repeat([]byte{2}, 32), "value")
+	if a == b {
+		t.Fatalf("expected fingerprints to differ across master keys")
+	}
+}
@@ -7,9 +7,11 @@ import (
 )
 
 // GenerateJWT generates a signed JWT token with user ID as subject.
+// The "amr" claim records the sign-in method for organization auth policies.
 func GenerateJWT(secret string, userID uint, expiresMinutes int) (string, error) {
 	claims := jwt.MapClaims{
 		"sub":  userID,
+		"amr":  "password",
 		"exp":  time.Now().Add(time.Duration(expiresMinutes) * time.Minute).Unix(),
 		"iat":  time.Now().Unix(),
 	}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// PasswordPolicy describes the password requirements an organization enforces.
+type PasswordPolicy struct {
+	MinLength        int
+	RequireMixedCase bool
+	RequireNumber    bool
+	RequireSymbol    bool
+}
+
+// Validate returns an error describing the first requirement the password misses.
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+
+	var upper, lower, number, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			number = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			symbol = true
+		}
+	}
+
+	if p.RequireMixedCase && !(upper && lower) {
+		return errors.New("password must contain upper and lower case letters")
+	}
+	if p.RequireNumber && !number {
+		return errors.New("password must contain a number")
+	}
+	if p.RequireSymbol && !symbol {
+		return errors.New("password must contain a symbol")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package utils
+
+import "testing"
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 10, RequireMixedCase: true, RequireNumber: true, RequireSymbol: true}
+
+	cases := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		wantErr  bool
+	}{
+		{"default accepts long enough", PasswordPolicy{MinLength: 8}, "password", false},
+		{"too short", PasswordPolicy{MinLength: 8}, "pass", true},
+		{"strict accepts compliant", strict, "Sup3r-Secret", false},
+		{"missing upper case", strict, "sup3r-secret", true},
+		{"missing number", strict, "Super-Secret", true},
+		{"missing symbol", strict, "Sup3rSecret1", true},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "ééé", true},
+	}
+
+	for _, tc := range cases {
+		err := tc.policy.Validate(tc.password)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: Validate(%q) error = %v, wantErr %v", tc.name, tc.password, err, tc.wantErr)
+		}
+	}
+}
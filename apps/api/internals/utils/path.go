@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var pathSegmentPattern = regexp.MustCompile(`^[^\x00-\x1f\x7f]+$`)
+
+// NormalizePath cleans a secret or folder path such as "/payments//stripe/live/"
+// into "payments/stripe/live". Segments may contain any printable character
+// but may not be "." or "..", so key names from before paths existed remain
+// valid single-segment paths. An empty path is the root and is returned as "".
+func NormalizePath(p string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(p), "/")
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if part == "." || part == ".." || !pathSegmentPattern.MatchString(part) {
+			return "", errors.New("invalid path segment: " + part)
+		}
+		segments = append(segments, part)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// PathAncestors returns every folder containing the path, root first, ending
+// with the path itself: "a/b/c" gives ["", "a", "a/b", "a/b/c"].
+func PathAncestors(p string) []string {
+	out := []string{""}
+	if p == "" {
+		return out
+	}
+	for i, c := range p {
+		if c == '/' {
+			out = append(out, p[:i])
+		}
+	}
+	return append(out, p)
+}
+
+// PathBase returns the last segment of a path.
+func PathBase(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
+
+// PathWithin reports whether p is the folder itself or lies beneath it.
+func PathWithin(p, folder string) bool {
+	return folder == "" || p == folder || strings.HasPrefix(p, folder+"/")
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePath(t *testing.T) {
+	cases := map[string]string{
+		"payments/stripe/live":    "payments/stripe/live",
+		"/payments//stripe/live/": "payments/stripe/live",
+		"":                        "",
+		"/":                       "",
+		"STRIPE_KEY":              "STRIPE_KEY",
+		"My Stripe Key":           "My Stripe Key",
+	}
+	for in, want := range cases {
+		got, err := NormalizePath(in)
+		if err != nil {
+			t.Fatalf("NormalizePath(%q) returned error: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("NormalizePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, bad := range []string{"a/../b", "a/./b", "a/\tb", "a/\x00"} {
+		if _, err := NormalizePath(bad); err == nil {
+			t.Fatalf("expected NormalizePath(%q) to fail", bad)
+		}
+	}
+}
+
+func TestPathAncestors(t *testing.T) {
+	got := PathAncestors("a/b/c")
+	want := []string{"", "a", "a/b", "a/b/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PathAncestors = %v, want %v", got, want)
+	}
+	if got := PathAncestors(""); !reflect.DeepEqual(got, []string{""}) {
+		t.Fatalf("PathAncestors(\"\") = %v", got)
+	}
+}
+
+func TestPathWithin(t *testing.T) {
+	if !PathWithin("payments/stripe/live", "payments") {
+		t.Fatalf("expected descendant to be within folder")
+	}
+	if PathWithin("payments-old/key", "payments") {
+		t.Fatalf("sibling with shared prefix must not be within folder")
+	}
+	if !PathWithin("anything", "") {
+		t.Fatalf("everything is within the root")
+	}
+	if PathBase("payments/stripe/live") != "live" {
+		t.Fatalf("unexpected PathBase")
+	}
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// DeriveSigningKey derives an ed25519 key for one purpose from the master
+// key, so deployments that set no dedicated key still sign consistently
+// across restarts.
+func DeriveSigningKey(masterKey []byte, purpose string) ed25519.PrivateKey {
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte("signing-key:" + purpose))
+	return ed25519.NewKeyFromSeed(mac.Sum(nil))
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeriveSigningKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	a := DeriveSigningKey(key, "audit")
+	if !a.Equal(DeriveSigningKey(key, "audit")) {
+		t.Fatalf("expected the same key for the same master key and purpose")
+	}
+	if a.Equal(DeriveSigningKey(key, "other")) {
+		t.Fatalf("expected keys to differ across purposes")
+	}
+	if a.Equal(DeriveSigningKey(bytes.Repeat([]byte{2}, 32), "audit")) {
+		t.Fatalf("expected keys to differ across master keys")
+	}
+}
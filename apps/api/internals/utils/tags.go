@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseTag splits "env:prod" into ("env", "prod") and "deprecated" into
+// ("deprecated", ""). Keys are lowercased; values keep their case.
+func ParseTag(s string) (key, value string, err error) {
+	s = strings.TrimSpace(s)
+	key, value, _ = strings.Cut(s, ":")
+	key = strings.ToLower(strings.TrimSpace(key))
+	value = strings.TrimSpace(value)
+	if key == "" {
+		return "", "", fmt.Errorf("invalid tag %q", s)
+	}
+	if strings.ContainsAny(key, " ()") || strings.ContainsAny(value, " ()") {
+		return "", "", fmt.Errorf("tag %q may not contain spaces or parentheses", s)
+	}
+	return key, value, nil
+}
+
+// FormatTag is the inverse of ParseTag.
+func FormatTag(key, value string) string {
+	if value == "" {
+		return key
+	}
+	return key + ":" + value
+}
+
+// SplitTagList parses the legacy comma-separated tag string into tags,
+// skipping empty entries and duplicates.
+func SplitTagList(csv string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, part := range strings.Split(csv, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, err := ParseTag(part)
+		if err != nil {
+			return nil, err
+		}
+		tag := FormatTag(key, value)
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	return out, nil
+}
+
+// SplitTagEntries is SplitTagList for data that may hold bad entries: it
+// returns the tags that parse and, separately, the trimmed entries that do not.
+func SplitTagEntries(csv string) (tags, invalid []string) {
+	seen := map[string]bool{}
+	for _, part := range strings.Split(csv, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, err := ParseTag(part)
+		if err != nil {
+			invalid = append(invalid, strings.TrimSpace(part))
+			continue
+		}
+		tag := FormatTag(key, value)
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, invalid
+}
+
+// TagExpr is a parsed tag filter such as "env:prod AND (vendor:stripe OR NOT legacy)".
+type TagExpr interface {
+	// Match reports whether a secret with the given tags ("key" or "key:value") satisfies the expression.
+	Match(tags []string) bool
+}
+
+// TagTerm matches a single tag. An empty Value matches any value for the key.
+type TagTerm struct{ Key, Value string }
+
+type TagAnd struct{ Left, Right TagExpr }
+
+type TagOr struct{ Left, Right TagExpr }
+
+type TagNot struct{ Inner TagExpr }
+
+func (t TagTerm) Match(tags []string) bool {
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, ":")
+		if key != t.Key {
+			continue
+		}
+		if t.Value == "" || value == t.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func (e TagAnd) Match(tags []string) bool { return e.Left.Match(tags) && e.Right.Match(tags) }
+
+func (e TagOr) Match(tags []string) bool { return e.Left.Match(tags) || e.Right.Match(tags) }
+
+func (e TagNot) Match(tags []string) bool { return !e.Inner.Match(tags) }
+
+// ParseTagExpr parses a tag filter. Terms are tags; AND, OR and NOT are
+// case-insensitive operators, AND binds tighter than OR, adjacent terms are
+// implicitly ANDed and parentheses group.
+func ParseTagExpr(s string) (TagExpr, error) {
+	p := &tagExprParser{tokens: tokenizeTagExpr(s)}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("empty tag expression")
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in tag expression", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+func tokenizeTagExpr(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type tagExprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *tagExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagExprParser) parseOr() (TagExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = TagOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagExprParser) parseAnd() (TagExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		next := p.peek()
+		if next == "" || next == ")" || strings.EqualFold(next, "OR") {
+			return left, nil
+		}
+		if strings.EqualFold(next, "AND") {
+			p.pos++
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = TagAnd{left, right}
+	}
+}
+
+func (p *tagExprParser) parseNot() (TagExpr, error) {
+	if strings.EqualFold(p.peek(), "NOT") {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return TagNot{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *tagExprParser) parsePrimary() (TagExpr, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return nil, errors.New("unexpected end of tag expression")
+	case tok == "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing closing parenthesis in tag expression")
+		}
+		p.pos++
+		return expr, nil
+	case tok == ")" || strings.EqualFold(tok, "AND") || strings.EqualFold(tok, "OR"):
+		return nil, fmt.Errorf("unexpected %q in tag expression", tok)
+	}
+	p.pos++
+	key, value, err := ParseTag(tok)
+	if err != nil {
+		return nil, err
+	}
+	if value == "*" {
+		value = ""
+	}
+	return TagTerm{Key: key, Value: value}, nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	key, value, err := ParseTag(" Env:prod ")
+	if err != nil || key != "env" || value != "prod" {
+		t.Fatalf("ParseTag = (%q, %q, %v)", key, value, err)
+	}
+	key, value, err = ParseTag("deprecated")
+	if err != nil || key != "deprecated" || value != "" {
+		t.Fatalf("ParseTag bare = (%q, %q, %v)", key, value, err)
+	}
+	if _, _, err := ParseTag(":prod"); err == nil {
+		t.Fatalf("expected error for empty key")
+	}
+}
+
+func TestSplitTagList(t *testing.T) {
+	got, err := SplitTagList("env:prod, payments,,env:prod")
+	if err != nil {
+		t.Fatalf("SplitTagList returned error: %v", err)
+	}
+	want := []string{"env:prod", "payments"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SplitTagList = %v, want %v", got, want)
+	}
+}
+
+func TestSplitTagEntries(t *testing.T) {
+	tags, invalid := SplitTagEntries("env:prod, bad tag,payments,(x),env:prod")
+	if want := []string{"env:prod", "payments"}; !reflect.DeepEqual(tags, want) {
+		t.Fatalf("tags = %v, want %v", tags, want)
+	}
+	if want := []string{"bad tag", "(x)"}; !reflect.DeepEqual(invalid, want) {
+		t.Fatalf("invalid = %v, want %v", invalid, want)
+	}
+}
+
+func TestParseTagExpr(t *testing.T) {
+	tags := []string{"env:prod", "vendor:stripe", "payments"}
+	cases := map[string]bool{
+		"env:prod AND vendor:stripe":           true,
+		"env:prod vendor:stripe":               true,
+		"env:staging OR vendor:stripe":         true,
+		"env:staging OR vendor:aws":            false,
+		"NOT env:staging":                      true,
+		"env:prod AND NOT payments":            false,
+		"env":                                  true,
+		"env:*":                                true,
+		"(env:staging OR env:prod) and vendor": true,
+		"env:staging OR env:prod AND legacy":   false,
+	}
+	for expr, want := range cases {
+		e, err := ParseTagExpr(expr)
+		if err != nil {
+			t.Fatalf("ParseTagExpr(%q) returned error: %v", expr, err)
+		}
+		if got := e.Match(tags); got != want {
+			t.Fatalf("%q matched %v, want %v", expr, got, want)
+		}
+	}
+
+	for _, bad := range []string{"", "env:prod AND", "(env:prod", "env:prod)", "OR env:prod"} {
+		if _, err := ParseTagExpr(bad); err == nil {
+			t.Fatalf("expected ParseTagExpr(%q) to fail", bad)
+		}
+	}
+}
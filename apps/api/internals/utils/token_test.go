@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+func TestGenerateToken_UniqueAndURLSafe(t *testing.T) {
+	a, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	b, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if a == b {
+		t.Fatalf("expected distinct tokens, got %q twice", a)
+	}
+	if len(a) != 43 {
+		t.Fatalf("expected 43-char token, got %d", len(a))
+	}
+	for _, c := range a {
+		if c == '+' || c == '/' || c == '=' {
+			t.Fatalf("token %q is not URL-safe", a)
+		}
+	}
+}
+
+func TestHashToken_Deterministic(t *testing.T) {
+	if HashToken("abc") != HashToken("abc") {
+		t.Fatalf("expected identical hashes for identical tokens")
+	}
+	if HashToken("abc") == HashToken("abd") {
+		t.Fatalf("expected different hashes for different tokens")
+	}
+	if len(HashToken("abc")) != 64 {
+		t.Fatalf("expected 64-char hex digest")
+	}
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var ErrPrivateAddress = errors.New("webhook endpoints may not be on private, loopback or link-local addresses")
+
+// cgnat is the shared address space (RFC 6598), which netip does not count
+// as private.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// CheckURL validates an endpoint URL when it is registered. Only https is
+// accepted unless allowPrivate is set, which is meant for development
+// against local receivers. Host names are checked again at connect time.
+func CheckURL(raw string, allowPrivate bool) (*url.URL, error) {
+	if len(raw) > 2048 {
+		return nil, errors.New("url is too long")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Hostname() == "" {
+		return nil, errors.New("url must be absolute, e.g. https://example.com/hooks")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && allowPrivate:
+	default:
+		return nil, errors.New("url must use https")
+	}
+	if u.User != nil {
+		return nil, errors.New("url may not contain credentials")
+	}
+	if u.Fragment != "" {
+		return nil, errors.New("url may not contain a fragment")
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !allowPrivate && Blocked(ip) {
+		return nil, ErrPrivateAddress
+	}
+	return u, nil
+}
+
+// Blocked reports whether ip is an address webhooks must not reach: one of
+// this host's, the internal network's, or a cloud metadata service's.
+func Blocked(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip)
+}
+
+// NewDialer returns a dialer that, unless allowPrivate is set, refuses to
+// connect to blocked addresses. The check runs on the resolved address, so
+// it also covers names and protocols other than HTTP.
+func NewDialer(allowPrivate bool) *net.Dialer {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if Blocked(ap.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, ap.Addr())
+			}
+			return nil
+		}
+	}
+	return dialer
+}
+
+// NewClient returns the HTTP client deliveries are sent with. Unless
+// allowPrivate is set it refuses to connect to blocked addresses, checked
+// after DNS resolution so a name cannot be pointed at one later. Redirects
+// are not followed: the response to the endpoint itself is the outcome.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := NewDialer(allowPrivate)
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
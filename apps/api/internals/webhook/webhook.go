@@ -0,0 +1,172 @@
+// Package webhook holds the wire format of outbound webhooks: how payloads
+// are signed, which event types an endpoint receives, and when failed
+// deliveries are retried.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers sent with every delivery.
+const (
+	EventHeader     = "X-OnePassword-Event"
+	DeliveryHeader  = "X-OnePassword-Delivery"
+	SignatureHeader = "X-OnePassword-Signature"
+)
+
+// SecretPrefix marks signing secrets so they are recognisable when leaked.
+const SecretPrefix = "whsec_"
+
+const (
+	// MaxEvents is how many event patterns one endpoint may subscribe to.
+	MaxEvents = 50
+
+	firstRetry = 30 * time.Second
+	lastRetry  = 6 * time.Hour
+)
+
+var (
+	ErrNoSignature  = errors.New("missing or malformed signature header")
+	ErrBadSignature = errors.New("signature does not match")
+	ErrStale        = errors.New("signature timestamp outside tolerance")
+)
+
+// Payload is the JSON body of a delivery. ID is the event id: it stays the
+// same when an event is retried or redelivered, so receivers use it to drop
+// repeats.
+type Payload struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	CreatedAt      time.Time `json:"created_at"`
+	OrganizationID uint      `json:"organization_id"`
+	TeamID         uint      `json:"team_id,omitempty"`
+	ActorID        uint      `json:"actor_id"`
+	Principal      string    `json:"principal,omitempty"`
+	Entity         string    `json:"entity"`
+	EntityID       uint      `json:"entity_id,omitempty"`
+	Message        string    `json:"message"`
+	Outcome        string    `json:"outcome,omitempty"`
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the signature header for body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". The timestamp is
+// covered so a captured request cannot be replayed later.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify checks a signature header the way a receiver should: the MAC must
+// match and the timestamp must be within tolerance of now.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrNoSignature
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return ErrStale
+	}
+	want := mac(secret, ts, body)
+	for _, s := range sigs {
+		if hmac.Equal([]byte(s), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+func mac(secret, ts string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts))
+	m.Write([]byte{'.'})
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+var eventPattern = regexp.MustCompile(`^([a-z][a-z0-9_]*\*?|\*)$`)
+
+// ParseEvents validates the event types an endpoint subscribes to. An entry
+// is an activity type such as "apikey_created", a prefix ending in "*" such
+// as "apikey_*", or "*" for everything. The result is sorted and deduplicated.
+func ParseEvents(events []string) ([]string, error) {
+	seen := make(map[string]bool, len(events))
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || seen[e] {
+			continue
+		}
+		if len(e) > 100 || !eventPattern.MatchString(e) {
+			return nil, fmt.Errorf("invalid event type %q", e)
+		}
+		seen[e] = true
+		out = append(out, e)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("subscribe to at least one event type")
+	}
+	if len(out) > MaxEvents {
+		return nil, fmt.Errorf("at most %d event types", MaxEvents)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// Matches reports whether an activity of type typ is covered by events.
+func Matches(events []string, typ string) bool {
+	for _, e := range events {
+		if e == typ || e == "*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(e, "*"); ok && strings.HasPrefix(typ, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff is the wait before retry number attempt (counting from 1):
+// 30 seconds, doubling up to 6 hours.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 10 {
+		return lastRetry
+	}
+	return min(firstRetry<<(attempt-1), lastRetry)
+}
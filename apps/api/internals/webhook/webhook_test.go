@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	body := []byte(`{"id":"ev1","type":"apikey_created"}`)
+	sig := Sign("whsec_test", now, body)
+	if !strings.HasPrefix(sig, "t=1767225600,v1=") {
+		t.Fatalf("signature = %q", sig)
+	}
+	if err := Verify("whsec_test", sig, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("Verify = %v", err)
+	}
+	// a receiver rotating secrets may see several v1 values
+	if err := Verify("whsec_test", "t=1767225600,v1=00,"+strings.Split(sig, ",")[1], body, now, time.Minute); err != nil {
+		t.Fatalf("Verify with two signatures = %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		secret string
+		header string
+		body   string
+		now    time.Time
+		want   error
+	}{
+		{"tampered body", "whsec_test", sig, `{"id":"ev2"}`, now, ErrBadSignature},
+		{"wrong secret", "whsec_other", sig, string(body), now, ErrBadSignature},
+		{"replayed later", "whsec_test", sig, string(body), now.Add(time.Hour), ErrStale},
+		{"no timestamp", "whsec_test", strings.Split(sig, ",")[1], string(body), now, ErrNoSignature},
+		{"empty", "whsec_test", "", string(body), now, ErrNoSignature},
+	}
+	for _, c := range cases {
+		if err := Verify(c.secret, c.header, []byte(c.body), c.now, 5*time.Minute); !errors.Is(err, c.want) {
+			t.Errorf("%s: Verify = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret()
+	if !strings.HasPrefix(a, SecretPrefix) || len(a) != len(SecretPrefix)+43 || a == b {
+		t.Fatalf("secrets %q, %q", a, b)
+	}
+}
+
+func TestParseEventsAndMatches(t *testing.T) {
+	events, err := ParseEvents([]string{" apikey_* ", "member_added", "APIKEY_*", "", "rotation_due"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(events, ",") != "apikey_*,member_added,rotation_due" {
+		t.Fatalf("events = %v", events)
+	}
+	for typ, want := range map[string]bool{
+		"apikey_created":  true,
+		"apikey_revealed": true,
+		"member_added":    true,
+		"member_removed":  false,
+		"rotation_due":    true,
+		"team_created":    false,
+	} {
+		if got := Matches(events, typ); got != want {
+			t.Errorf("Matches(%q) = %v", typ, got)
+		}
+	}
+	if !Matches([]string{"*"}, "anything") {
+		t.Error("* should match everything")
+	}
+
+	for _, bad := range [][]string{nil, {""}, {"apikey created"}, {"api*key"}, {"**"}, {"1abc"}} {
+		if _, err := ParseEvents(bad); err == nil {
+			t.Errorf("ParseEvents(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	if Backoff(11) != 6*time.Hour || Backoff(50) != 6*time.Hour {
+		t.Errorf("Backoff does not cap at 6h: %s, %s", Backoff(11), Backoff(50))
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, ok := range []string{"https://hooks.example.com/in", "https://203.0.113.9:8443/x?team=1"} {
+		if _, err := CheckURL(ok, false); err != nil {
+			t.Errorf("CheckURL(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{
+		"http://hooks.example.com/in",
+		"https://user:pw@hooks.example.com/",
+		"https://127.0.0.1/",
+		"https://[::1]/",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.1.2.3/",
+		"https://100.64.0.1/",
+		"/relative",
+		"ftp://example.com",
+	} {
+		if _, err := CheckURL(bad, false); err == nil {
+			t.Errorf("CheckURL(%q) succeeded", bad)
+		}
+	}
+	if _, err := CheckURL("http://127.0.0.1:9000/hook", true); err != nil {
+		t.Errorf("allowPrivate: %v", err)
+	}
+	if Blocked(netip.MustParseAddr("8.8.8.8")) || !Blocked(netip.MustParseAddr("::ffff:192.168.1.1")) {
+		t.Error("Blocked misclassifies addresses")
+	}
+}
+
+func TestClientRefusesPrivateAddressesAndRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(5*time.Second, false).Post(srv.URL, "application/json", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("guarded client reached loopback: %v", err)
+	}
+
+	client := NewClient(5*time.Second, true)
+	resp, err := client.Post(srv.URL+"/redirect", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("status = %d, redirect was followed", resp.StatusCode)
+	}
+}
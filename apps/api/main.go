@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/intojhanurag/One-Password/apps/api/internals/anomaly"
 	"github.com/intojhanurag/One-Password/apps/api/internals/config"
 	"github.com/intojhanurag/One-Password/apps/api/internals/database"
 	"github.com/intojhanurag/One-Password/apps/api/internals/handlers"
@@ -13,6 +20,7 @@ import (
 	"github.com/intojhanurag/One-Password/apps/api/internals/models"
 	"github.com/intojhanurag/One-Password/apps/api/internals/repository"
 	"github.com/intojhanurag/One-Password/apps/api/internals/services"
+	"github.com/intojhanurag/One-Password/apps/api/internals/siem"
 	"github.com/rs/cors"
 
 )
@@ -21,6 +29,11 @@ func main() {
 
 	cfg := config.Load()
 
+	// background workers stop on SIGINT/SIGTERM; the audit sinks then spill
+	// what they still hold so it is sent after the next start
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	db := database.Connect(cfg.DatabaseURL)
 
 
@@ -33,27 +46,177 @@ func main() {
 		&models.TeamMembership{},
 		&models.APIKeyTeam{},
 		&models.Activity{},
+		&models.AccessRequest{},
+		&models.Notification{},
+		&models.BreakGlassAccess{},
+		&models.TeamInvitation{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.Project{},
+		&models.Environment{},
+		&models.EnvironmentPermission{},
+		&models.FolderPermission{},
+		&models.Tag{},
+		&models.CustomField{},
+		&models.Item{},
+		&models.ItemField{},
+		&models.SecretVersion{},
+		&models.RotationPolicy{},
+		&models.RotationRun{},
+		&models.DynamicRole{},
+		&models.DynamicLease{},
+		&models.RevealLease{},
+		&models.ShareLink{},
+		&models.AuditCheckpoint{},
+		&models.OutboxEvent{},
+		&models.OutboxDelivery{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.SecurityAlert{},
+		&models.AccessCounter{},
 	); err != nil {
 		log.Fatalf("auto-migrate failed: %v", err)
 	}
+	if err := database.EnsureSearchIndexes(db); err != nil {
+		log.Fatalf("search index migration failed: %v", err)
+	}
+	if err := database.EnsureAuditLog(db); err != nil {
+		log.Fatalf("audit log migration failed: %v", err)
+	}
+	if err := database.EnsureActivityFeed(db); err != nil {
+		log.Fatalf("activity feed migration failed: %v", err)
+	}
 
 
 	
-	repo := repository.NewUserRepository()
-	service := services.NewAuthService(repo, db, cfg.BCryptCost)
-	h := handlers.NewAuthHandler(service, cfg)
 
-	akRepo := repository.NewAPIKeyRepository()
-	akSvc := services.NewAPIKeyService(akRepo, db, cfg.MasterKey)
-	akHandler := handlers.NewAPIKeyHandler(akSvc)
+	// Transactional outbox: activities and notifications are written in the
+	// transaction of the change they describe, then delivered from here to
+	// the audit log and any external channels
+	outboxSvc := services.NewOutboxService(repository.NewOutboxRepository(), db, time.Duration(cfg.OutboxPollIntervalMs)*time.Millisecond)
+	outboxSvc.Subscribe(services.NewAuditStore(db))
+
+	// and streamed to any configured SIEM, syslog or file sinks
+	auditSinks, err := siem.Open(cfg.AuditSinks, cfg.AuditSpillDir)
+	if err != nil {
+		log.Fatalf("audit sinks: %v", err)
+	}
+	for _, f := range auditSinks {
+		outboxSvc.Subscribe(services.NewSinkSubscriber(f, db))
+		f.Start(ctx)
+	}
+
+	// Notifications (in-app, plus an optional chat webhook)
+	if cfg.NotifyWebhookURL != "" {
+		outboxSvc.Subscribe(services.NewChannelSubscriber(services.NewWebhookChannel(cfg.NotifyWebhookURL)))
+	}
+	notificationSvc := services.NewNotificationService(repository.NewNotificationRepository(), db)
+	notificationHandler := handlers.NewNotificationHandler(notificationSvc)
+
+	// Organizations (tenant boundary above teams and keys)
+	userRepo := repository.NewUserRepository()
+	orgRepo := repository.NewOrganizationRepository()
+	orgSvc := services.NewOrganizationService(orgRepo, userRepo, db)
+	orgHandler := handlers.NewOrganizationHandler(orgSvc)
+	if err := orgSvc.BackfillPersonalOrgs(); err != nil {
+		log.Fatalf("organization backfill failed: %v", err)
+	}
 
 	// // TeamMembership
+	teamRepo := repository.NewTeamRepository()
 	teamMembershipRepo := repository.NewTeamMembershipRepository(db)
-	teamMembershipSvc := services.NewTeamMembershipService(teamMembershipRepo,db)
+	teamMembershipSvc := services.NewTeamMembershipService(teamMembershipRepo, teamRepo, orgRepo, db)
 	teamMembershipHandler := handlers.NewTeamMembershipHandler(teamMembershipSvc)
 
+	// Team invitations
+	var mailer services.Mailer
+	if cfg.SMTPAddr != "" {
+		mailer = services.NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	}
+	invitationSvc := services.NewTeamInvitationService(repository.NewTeamInvitationRepository(), teamRepo, teamMembershipRepo, orgSvc, userRepo, notificationSvc, mailer, cfg.InviteBaseURL, db)
+	invitationHandler := handlers.NewTeamInvitationHandler(invitationSvc)
+
+	service := services.NewAuthService(userRepo, db, cfg.BCryptCost, invitationSvc, orgSvc)
+	h := handlers.NewAuthHandler(service, cfg)
+
+	akRepo := repository.NewAPIKeyRepository()
+	accessReqRepo := repository.NewAccessRequestRepository()
+	// Structured tags and custom fields
+	tagSvc := services.NewTagService(repository.NewTagRepository(), akRepo, orgRepo, db)
+	tagHandler := handlers.NewTagHandler(tagSvc)
+	if err := tagSvc.BackfillLegacyTags(); err != nil {
+		log.Fatalf("tag backfill failed: %v", err)
+	}
+
+	// Every reveal carries a lease telling clients how long they may cache it
+	secretVersionRepo := repository.NewSecretVersionRepository()
+	leaseSvc := services.NewRevealLeaseService(repository.NewRevealLeaseRepository(), secretVersionRepo, akRepo, db, cfg.MasterKey, time.Duration(cfg.RevealLeaseTTLMin)*time.Minute, time.Duration(cfg.RevealLeaseMaxTTLMin)*time.Minute)
+	leaseHandler := handlers.NewRevealLeaseHandler(leaseSvc)
+
+	akSvc := services.NewAPIKeyService(akRepo, accessReqRepo, tagSvc, leaseSvc, secretVersionRepo, db, cfg.MasterKey)
+	akHandler := handlers.NewAPIKeyHandler(akSvc)
+
+	// Projects and environments (per-environment secret values)
+	projectSvc := services.NewProjectService(repository.NewProjectRepository(), akRepo, orgRepo, teamRepo, db, cfg.MasterKey)
+	projectHandler := handlers.NewProjectHandler(projectSvc)
+
+	// Typed vault items; existing API keys become api_key items
+	itemSvc := services.NewItemService(repository.NewItemRepository(), akSvc, db, cfg.MasterKey)
+	itemHandler := handlers.NewItemHandler(itemSvc)
+	if err := itemSvc.BackfillAPIKeyItems(); err != nil {
+		log.Fatalf("item backfill failed: %v", err)
+	}
+	if err := itemSvc.RepairPlainFields(); err != nil {
+		log.Fatalf("item field repair failed: %v", err)
+	}
+
+	// Path-addressed secrets with inherited folder grants
+	pathSvc := services.NewSecretPathService(akRepo, repository.NewFolderPermissionRepository(), orgRepo, teamRepo, akSvc, db, cfg.MasterKey)
+	pathHandler := handlers.NewSecretPathHandler(pathSvc)
+	if err := pathSvc.BackfillPaths(); err != nil {
+		log.Fatalf("secret path backfill failed: %v", err)
+	}
+
+	// Tamper-evident audit log: activities are hash-chained on insert and
+	// the chain head is signed periodically
+	auditSvc := services.NewAuditService(repository.NewAuditRepository(), orgRepo, db, cfg.AuditSigningKey, time.Duration(cfg.AuditCheckpointIntervalMin)*time.Minute)
+	if n, err := auditSvc.SealLegacy(); err != nil {
+		log.Fatalf("audit log sealing failed: %v", err)
+	} else if n > 0 {
+		fmt.Printf("sealed %d existing activities into the audit chain\n", n)
+	}
+	auditSvc.Sinks = auditSinks
+	auditHandler := handlers.NewAuditHandler(auditSvc)
+	auditSvc.Start(ctx)
+
+	// Expiry and rotation reminders go out through the notification system
+	reminderSvc := services.NewReminderService(akRepo, notificationSvc, db, time.Duration(cfg.ReminderIntervalMin)*time.Minute)
+	reminderSvc.Start(ctx)
+
+	// Automated rotation through pluggable rotators (webhook, postgres)
+	rotationSvc := services.NewRotationService(repository.NewRotationRepository(), secretVersionRepo, akRepo, orgRepo, leaseSvc, notificationSvc, db, cfg.MasterKey, cfg.WebhookAllowPrivate, time.Duration(cfg.RotationIntervalMin)*time.Minute)
+	rotationHandler := handlers.NewRotationHandler(rotationSvc)
+	rotationSvc.Start(ctx)
+
+	// Dynamic secrets engine: short-lived database users tracked by leases
+	dynamicSvc := services.NewDynamicCredentialService(repository.NewDynamicCredentialRepository(), orgRepo, db, cfg.MasterKey, cfg.WebhookAllowPrivate, time.Duration(cfg.LeaseSweepIntervalSec)*time.Second)
+	dynamicHandler := handlers.NewDynamicCredentialHandler(dynamicSvc)
+	dynamicSvc.Start(ctx)
+
+	// One-time share links for people outside the vault
+	shareSvc := services.NewShareLinkService(repository.NewShareLinkRepository(), akRepo, accessReqRepo, notificationSvc, db, cfg.MasterKey, cfg.ShareBaseURL, cfg.BCryptCost)
+	shareHandler := handlers.NewShareLinkHandler(shareSvc)
+
+	// Break-glass emergency access
+	breakGlassSvc := services.NewBreakGlassService(repository.NewBreakGlassRepository(), akRepo, akSvc, notificationSvc, db)
+	breakGlassHandler := handlers.NewBreakGlassHandler(breakGlassSvc)
+
+	// Access requests for keys that require approval
+	accessReqSvc := services.NewAccessRequestService(accessReqRepo, akRepo, db)
+	accessReqHandler := handlers.NewAccessRequestHandler(accessReqSvc)
+
+
 	
-	teamRepo := repository.NewTeamRepository()
 	teamSvc := services.NewTeamService(teamRepo,teamMembershipRepo,db)
 	teamHandler := handlers.NewTeamHandler(teamSvc)
 
@@ -64,7 +227,7 @@ func main() {
 
 	// //apikey-team relationship
 	aktmRepo := repository.NewAPIKeyTeamRepository(db)
-	aktmSvc := services.NewAPIKeyTeamService(aktmRepo)
+	aktmSvc := services.NewAPIKeyTeamService(aktmRepo, teamRepo, teamMembershipRepo, akRepo, db)
 	aktmHandler := handlers.NewAPIKeyTeamHandler(aktmSvc)
 
 	activityRepo := repository.NewActivityRepository(db)
@@ -74,13 +237,53 @@ func main() {
 	dashboardHandler := handlers.NewDashboardHandler(dashboardSvc)
 
 	
-	// activitySvc := services.NewActivityService(activityRepo)
-	activityHandler := handlers.NewActivityHandler(activityRepo)
+	activitySvc := services.NewActivityService(activityRepo, teamRepo, teamMembershipRepo, db)
+	activityHandler := handlers.NewActivityHandler(activitySvc)
+
+	// Live activity stream: Postgres notifies every instance of new
+	// activities, which fan out to the SSE clients connected to it
+	activityFeed := services.NewActivityFeed(activityRepo, cfg.DatabaseURL)
+	if err := activityFeed.Start(ctx); err != nil {
+		log.Fatalf("activity feed: %v", err)
+	}
+	activitySvc.Feed = activityFeed
+
+	// Outbound webhooks: matching activities are queued per endpoint by the
+	// outbox, then POSTed with an HMAC signature and retried with backoff
+	webhookSvc := services.NewWebhookService(repository.NewWebhookRepository(), teamRepo, teamMembershipRepo, notificationSvc, db, cfg.MasterKey, cfg.WebhookAllowPrivate, time.Duration(cfg.WebhookPollIntervalSec)*time.Second)
+	webhookHandler := handlers.NewWebhookHandler(webhookSvc)
+	outboxSvc.Subscribe(webhookSvc)
+	webhookSvc.Start(ctx)
+
+	// Anomaly detection: reveals and sign-ins are scored against each user's
+	// history; alerts go to org admins, and high scores suspend the sessions
+	var geo anomaly.Geo
+	if cfg.GeoIPCountryCSV != "" {
+		table, err := anomaly.OpenCountryTable(cfg.GeoIPCountryCSV)
+		if err != nil {
+			log.Fatalf("geoip country table: %v", err)
+		}
+		geo = table
+	}
+	anomalySvc := services.NewAnomalyService(repository.NewSecurityAlertRepository(), userRepo, orgRepo, notificationSvc, db, cfg.Anomaly, geo)
+	securityAlertHandler := handlers.NewSecurityAlertHandler(anomalySvc)
+	outboxSvc.Subscribe(anomalySvc)
+	anomalySvc.Start(ctx)
+
+	// every subscriber is in place; start delivering what the backfills and
+	// earlier runs left in the outbox
+	outboxSvc.Start(ctx)
 
 	
 
 	
-	authMW := middleware.AuthMW([]byte(cfg.JWTSecret))
+	// every authenticated route refuses tokens of suspended users and revoked
+	// sessions, and also resolves the organization it runs in
+	tokenMW := middleware.AuthMW([]byte(cfg.JWTSecret))
+	sessionMW := middleware.SessionMW(anomalySvc)
+	jwtMW := func(next http.HandlerFunc) http.HandlerFunc { return tokenMW(sessionMW(next)) }
+	orgMW := middleware.OrgMW(orgSvc)
+	authMW := func(next http.HandlerFunc) http.HandlerFunc { return jwtMW(orgMW(next)) }
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -93,9 +296,91 @@ func main() {
 	// APIKey
 	// when a user will add a new api_key
 	mux.HandleFunc("/apikeys", authMW(akHandler.Create))
-	mux.HandleFunc("/apikeys/list", authMW(akHandler.List))
+	mux.HandleFunc("/apikeys/list", authMW(middleware.CountAccess(anomalySvc, services.AccessListKeys)(akHandler.List)))
 	mux.HandleFunc("/apikeys/reveal", authMW(akHandler.RevealByName))
 	mux.HandleFunc("/apikeys/delete", authMW(akHandler.Delete))
+	mux.HandleFunc("/apikeys/approval", authMW(akHandler.SetApproval))
+	mux.HandleFunc("/apikeys/break-glass", authMW(akHandler.SetBreakGlass))
+	mux.HandleFunc("/apikeys/lifecycle", authMW(akHandler.SetLifecycle))
+	mux.HandleFunc("/apikeys/leases", authMW(leaseHandler.Lookup))
+	mux.HandleFunc("/apikeys/leases/renew", authMW(leaseHandler.Renew))
+	mux.HandleFunc("/apikeys/leases/revoke", authMW(leaseHandler.Revoke))
+	mux.HandleFunc("/apikeys/share", authMW(shareHandler.Links))
+	mux.HandleFunc("/apikeys/tags", authMW(tagHandler.SetKeyTags))
+	mux.HandleFunc("/apikeys/fields", authMW(tagHandler.Fields))
+
+	// Rotation
+	mux.HandleFunc("/apikeys/rotation", authMW(rotationHandler.Policy))
+	mux.HandleFunc("/apikeys/rotation/run", authMW(rotationHandler.RotateNow))
+	mux.HandleFunc("/apikeys/rotation/rollback", authMW(rotationHandler.Rollback))
+	mux.HandleFunc("/apikeys/rotation/history", authMW(rotationHandler.History))
+	mux.HandleFunc("/rotation/types", authMW(rotationHandler.Types))
+
+	// Dynamic database credentials
+	mux.HandleFunc("/dynamic/roles", authMW(dynamicHandler.Roles))
+	mux.HandleFunc("/dynamic/creds", authMW(dynamicHandler.Issue))
+	mux.HandleFunc("/dynamic/leases", authMW(dynamicHandler.Leases))
+	mux.HandleFunc("/dynamic/leases/renew", authMW(dynamicHandler.Renew))
+	mux.HandleFunc("/dynamic/leases/revoke", authMW(dynamicHandler.Revoke))
+
+	// Share link redemption is public: the link itself is the credential
+	mux.HandleFunc("/share/info", shareHandler.Info)
+	mux.HandleFunc("/share/redeem", shareHandler.Redeem)
+
+	// Tags
+	// key:value labels shared across the org; /apikeys/list?tags= filters by
+	// expressions like "env:prod AND vendor:stripe"
+	mux.HandleFunc("/tags", authMW(tagHandler.List))
+	mux.HandleFunc("/tags/rename", authMW(tagHandler.Rename))
+	mux.HandleFunc("/tags/merge", authMW(tagHandler.Merge))
+
+	// Items
+	// logins, database credentials, certificates, SSH keys and secure notes;
+	// concealed fields are revealed one at a time
+	mux.HandleFunc("/items", authMW(itemHandler.Items))
+	mux.HandleFunc("/items/types", authMW(itemHandler.Types))
+	mux.HandleFunc("/items/get", authMW(itemHandler.Get))
+	mux.HandleFunc("/items/update", authMW(itemHandler.Update))
+	mux.HandleFunc("/items/reveal", authMW(itemHandler.Reveal))
+	mux.HandleFunc("/items/delete", authMW(itemHandler.Delete))
+
+	// Secret paths & folders
+	// secrets live at paths like payments/stripe/live; team grants on a
+	// folder are inherited by everything beneath it
+	mux.HandleFunc("/secrets", authMW(pathHandler.Secrets))
+	mux.HandleFunc("/secrets/reveal", authMW(pathHandler.Reveal))
+	mux.HandleFunc("/secrets/move", authMW(pathHandler.Move))
+	mux.HandleFunc("/secrets/grants", authMW(pathHandler.Grants))
+
+	// Projects & environments
+	// each secret name can hold a different value per environment;
+	// teams get read or write access per environment
+	mux.HandleFunc("/projects", authMW(projectHandler.Projects))
+	mux.HandleFunc("/projects/get", authMW(projectHandler.Get))
+	mux.HandleFunc("/projects/environments", authMW(projectHandler.AddEnvironment))
+	mux.HandleFunc("/environments/permissions", authMW(projectHandler.Permissions))
+	mux.HandleFunc("/environments/secrets", authMW(projectHandler.Secrets))
+	mux.HandleFunc("/environments/secrets/reveal", authMW(projectHandler.Reveal))
+	mux.HandleFunc("/environments/compare", authMW(projectHandler.Compare))
+
+	// Access requests
+	// non-owner reveals of approval-gated keys create a pending request
+	// that the key owner or a team owner/admin approves or denies
+	mux.HandleFunc("/access-requests/list", authMW(accessReqHandler.List))
+	mux.HandleFunc("/access-requests/approve", authMW(accessReqHandler.Approve))
+	mux.HandleFunc("/access-requests/deny", authMW(accessReqHandler.Deny))
+
+	// Break-glass
+	// emergency time-boxed access with a written justification;
+	// owners are alerted and must acknowledge it after the incident
+	mux.HandleFunc("/break-glass", authMW(breakGlassHandler.Invoke))
+	mux.HandleFunc("/break-glass/reveal", authMW(breakGlassHandler.Reveal))
+	mux.HandleFunc("/break-glass/acknowledge", authMW(breakGlassHandler.Acknowledge))
+	mux.HandleFunc("/break-glass/list", authMW(breakGlassHandler.List))
+
+	// Notifications
+	mux.HandleFunc("/notifications", authMW(notificationHandler.List))
+	mux.HandleFunc("/notifications/read", authMW(notificationHandler.MarkRead))
 
 	// Dashboard
 	mux.HandleFunc("/dashboard", authMW(dashboardHandler.Get))
@@ -103,12 +388,52 @@ func main() {
 
 	mux.HandleFunc("/dashboard/activity", authMW(activityHandler.ListActivities))
 	mux.HandleFunc("/dashboard/activity/detail", authMW(activityHandler.GetActivityStats))
-
+	mux.HandleFunc("/dashboard/activity/stream", authMW(activityHandler.StreamActivities))
+
+	// Audit log integrity
+	mux.HandleFunc("/audit/verify", authMW(auditHandler.Verify))
+	mux.HandleFunc("/audit/checkpoints", authMW(auditHandler.Checkpoints))
+	mux.HandleFunc("/audit/public-key", authMW(auditHandler.PublicKey))
+	mux.HandleFunc("/audit/export", authMW(auditHandler.Export))
+	mux.HandleFunc("/audit/sinks", authMW(auditHandler.Sinks))
+
+	// Webhooks
+	// personal or team endpoints for activity events such as apikey_created,
+	// member_added or rotation_due; every attempt is kept in a delivery log
+	mux.HandleFunc("/webhooks", authMW(webhookHandler.Webhooks))
+	mux.HandleFunc("/webhooks/update", authMW(webhookHandler.Update))
+	mux.HandleFunc("/webhooks/rotate-secret", authMW(webhookHandler.RotateSecret))
+	mux.HandleFunc("/webhooks/deliveries", authMW(webhookHandler.Deliveries))
+	mux.HandleFunc("/webhooks/redeliver", authMW(webhookHandler.Redeliver))
+
+	// Security alerts
+	// raised by the anomaly detector (reveal volume, new address or country,
+	// unusual hours, key enumeration); org owners and admins triage them and
+	// lift suspensions
+	mux.HandleFunc("/security/alerts", authMW(securityAlertHandler.List))
+	mux.HandleFunc("/security/alerts/get", authMW(securityAlertHandler.Get))
+	mux.HandleFunc("/security/alerts/update", authMW(securityAlertHandler.Update))
+	mux.HandleFunc("/security/suspensions/lift", authMW(securityAlertHandler.LiftSuspension))
+
+
+	// Organizations
+	// pick one per request with the X-Org-ID header (defaults to the personal org)
+	mux.HandleFunc("/orgs", jwtMW(orgHandler.Orgs))
+	mux.HandleFunc("/orgs/members", authMW(orgHandler.Members))
+	mux.HandleFunc("/orgs/members/add", authMW(orgHandler.AddMember))
+	mux.HandleFunc("/orgs/members/role", authMW(orgHandler.UpdateMemberRole))
+	mux.HandleFunc("/orgs/members/remove", authMW(orgHandler.RemoveMember))
+	mux.HandleFunc("/orgs/settings", authMW(orgHandler.Settings))
 
 	//Teams
 	// when a user create a team
 	// then team_id, owner_id
-	mux.HandleFunc("/teams", authMW(teamHandler.Create))
+	mux.HandleFunc("/teams", authMW(teamHandler.Teams))
+	mux.HandleFunc("/teams/get", authMW(teamHandler.Get))
+	mux.HandleFunc("/teams/update", authMW(teamHandler.Update))
+	mux.HandleFunc("/teams/archive", authMW(teamHandler.Archive))
+	mux.HandleFunc("/teams/delete", authMW(teamHandler.Delete))
+	mux.HandleFunc("/teams/transfer", authMW(teamHandler.Transfer))
 
 	// Team Membership
 	//it will basically tell us which use bought our membership
@@ -123,6 +448,15 @@ func main() {
 	mux.HandleFunc("/team-memberships/list", authMW(teamMembershipHandler.List))
 	mux.HandleFunc("/team-memberships/delete", authMW(teamMembershipHandler.Delete))
 
+	// Team invitations
+	// invite by email with a single-use token; the invitee accepts or
+	// declines, and team owners/admins can revoke pending invitations
+	mux.HandleFunc("/team-invitations", authMW(invitationHandler.Create))
+	mux.HandleFunc("/team-invitations/list", authMW(invitationHandler.List))
+	mux.HandleFunc("/team-invitations/accept", authMW(invitationHandler.Accept))
+	mux.HandleFunc("/team-invitations/decline", authMW(invitationHandler.Decline))
+	mux.HandleFunc("/team-invitations/revoke", authMW(invitationHandler.Revoke))
+
 	// APIKey-Team relationship
 	// it is basically tell us to which team can access which api_key
 	// id , team_id, apikey_id
@@ -133,13 +467,30 @@ func main() {
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"https://one-password-web.vercel.app"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "Accept", "Origin", "X-Requested-With"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Accept", "Origin", "X-Requested-With", "Last-Event-ID", middleware.OrgHeader, middleware.RequestIDHeader},
+		ExposedHeaders:   []string{"X-Total-Count", "X-Next-Cursor", middleware.RequestIDHeader},
 		AllowCredentials: true,
 	})
 
 	addr := ":" + cfg.Port
 	fmt.Println("Starting server at", addr)
-	if err := http.ListenAndServe(addr, c.Handler(mux)); err != nil {
-		log.Fatal(err)
+	// every request records its source and id for the audit log
+	auditMW := middleware.AuditMW(cfg.TrustedProxies)
+	srv := &http.Server{Addr: addr, Handler: c.Handler(auditMW(mux))}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("server shutdown: %v\n", err)
+	}
+	for _, f := range auditSinks {
+		<-f.Done()
 	}
 }